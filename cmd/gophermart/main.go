@@ -8,13 +8,26 @@ import (
 
 	"github.com/rebus2015/gophermart/cmd/internal/api/handlers"
 	"github.com/rebus2015/gophermart/cmd/internal/api/middleware"
+	"github.com/rebus2015/gophermart/cmd/internal/api/openapi"
+	"github.com/rebus2015/gophermart/cmd/internal/blobstore"
 	"github.com/rebus2015/gophermart/cmd/internal/client"
 	"github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/expiration"
+	"github.com/rebus2015/gophermart/cmd/internal/holds"
+	"github.com/rebus2015/gophermart/cmd/internal/lockout"
 	"github.com/rebus2015/gophermart/cmd/internal/logger"
 	m "github.com/rebus2015/gophermart/cmd/internal/migrations"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/policy"
+	"github.com/rebus2015/gophermart/cmd/internal/reports"
 	"github.com/rebus2015/gophermart/cmd/internal/router"
+	"github.com/rebus2015/gophermart/cmd/internal/statements"
 	"github.com/rebus2015/gophermart/cmd/internal/storage/dbstorage"
 	"github.com/rebus2015/gophermart/cmd/internal/storage/memstorage"
+	"github.com/rebus2015/gophermart/cmd/internal/tiers"
+	"github.com/rebus2015/gophermart/cmd/internal/twofactor"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+	"github.com/rebus2015/gophermart/cmd/internal/webhook"
 )
 
 func main() {
@@ -24,6 +37,10 @@ func main() {
 		return
 	}
 	lg := logger.NewConsole(cfg)
+	if err = utils.SetHashPolicy(cfg.GetHashPolicy()); err != nil {
+		lg.Fatal().Err(err).Msg("Invalid password hash configuration")
+		return
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -38,6 +55,16 @@ func main() {
 		lg.Fatal().Err(err).Msgf("Error creating dbStorage, with conn: %s", cfg.ConnectionString)
 		return
 	}
+	if login := cfg.GetAdminLogin(); login != "" {
+		found, err := repo.UserRoleSet(login, model.RoleAdmin)
+		if err != nil {
+			lg.Fatal().Err(err).Msgf("Failed to grant admin role to [%s]", login)
+			return
+		}
+		if !found {
+			lg.Warn().Msgf("Admin login [%s] is not registered, restart after registration to grant the role", login)
+		}
+	}
 	orders := memstorage.NewStorage(ctx, repo, cfg, lg)
 	err = orders.Restore()
 	if err != nil {
@@ -45,17 +72,54 @@ func main() {
 		return
 	}
 
-	h := handlers.NewAPI(repo, lg, orders)
-	m := middleware.NewMiddlewares(repo, lg)
-	handle := router.NewRouter(m, h)
+	guard := lockout.NewGuard(repo, cfg, lg)
+	creds := utils.NewCredentials(cfg.GetCredCacheSize(), cfg.GetCredCacheTTL())
+	tf := twofactor.NewService(repo, cfg, lg)
+	files, err := blobstore.NewDisk(cfg.GetBlobDir())
+	if err != nil {
+		lg.Fatal().Err(err).Msg("Failed to open documents storage")
+		return
+	}
+	h := handlers.NewAPI(repo, lg, orders, cfg, guard, creds, tf, files)
+	pol, err := policy.NewPolicy(cfg)
+	if err != nil {
+		lg.Fatal().Err(err).Msg("Invalid login and password policy")
+		return
+	}
+	m := middleware.NewMiddlewares(repo, lg, guard, creds, pol, tf)
+	handle := router.NewRouter(m, h, lg)
+	if err = openapi.Verify(handle); err != nil {
+		lg.Fatal().Err(err).Msg("OpenAPI specification check failed")
+		return
+	}
+	var handler http.Handler = handle
+	if cfg.APIValidate {
+		handler = openapi.Validator(lg)(handle)
+	}
 	accrualClient := client.NewClient(ctx, orders, cfg, lg)
 	accrualClient.Run()
+	webhooks := webhook.NewDispatcher(ctx, repo, cfg, lg)
+	webhooks.Run()
+	sweeper := holds.NewSweeper(ctx, repo, cfg, lg)
+	sweeper.Run()
+	expiry := expiration.NewJob(ctx, repo, cfg, lg)
+	expiry.Run()
+	tierJob, err := tiers.NewJob(ctx, repo, cfg, lg)
+	if err != nil {
+		lg.Fatal().Err(err).Msg("Invalid tier recalculation configuration")
+		return
+	}
+	tierJob.Run()
+	monthly := statements.NewJob(ctx, repo, files, cfg, lg)
+	monthly.Run()
+	reportsJob := reports.NewJob(ctx, repo, cfg, lg)
+	reportsJob.Run()
 
 	srv := &http.Server{
 		Addr:         cfg.RunAddress,
 		ReadTimeout:  160 * time.Second,
 		WriteTimeout: 160 * time.Second,
-		Handler:      handle,
+		Handler:      handler,
 	}
 
 	lg.Info().Msgf("server started \n address:%v \n accrualService: '%v', \n database:%v,\n restore interval: %v ",
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+const (
+	searchLimit    = 50
+	searchMaxLimit = 500
+)
+
+// queryInt читает неотрицательный целый параметр запроса, def - если параметра нет
+func queryInt(r *http.Request, name string, def int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, true
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// adminUser находит пользователя из пути запроса, при ошибке сам отвечает клиенту
+func (a *api) adminUser(w http.ResponseWriter, r *http.Request, handler string) (*model.User, bool) {
+	login := chi.URLParam(r, "login")
+	account, err := a.repo.UserFind(login)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("%s failed to find user [%s], database error", handler, login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return nil, false
+	}
+	if account == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.UserNotFound)
+		return nil, false
+	}
+	return &model.User{ID: account.ID, Login: account.Login, Role: account.Role}, true
+}
+
+func (a *api) writeJSON(w http.ResponseWriter, handler string, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		a.log.Err(err).Msgf("Error: [%s] Result Json encode error :%v", handler, err)
+	}
+}
+
+func (a *api) AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, okLimit := queryInt(r, "limit", searchLimit)
+	offset, okOffset := queryInt(r, "offset", 0)
+	if !okLimit || !okOffset || limit == 0 || limit > searchMaxLimit {
+		problem.WriteDetail(w, r, http.StatusBadRequest, problem.QueryInvalid, "limit must be 1.."+strconv.Itoa(searchMaxLimit)+", offset must be non-negative")
+		return
+	}
+	users, err := a.repo.UsersSearch(r.URL.Query().Get("q"), limit, offset)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msg("AdminUsersHandler failed to search users, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*users) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "AdminUsersHandler", users)
+}
+
+func (a *api) AdminUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminUser(w, r, "AdminUserHandler")
+	if !ok {
+		return
+	}
+	a.writeJSON(w, "AdminUserHandler", &model.Account{ID: user.ID, Login: user.Login, Role: user.Role})
+}
+
+func (a *api) AdminUserOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminUser(w, r, "AdminUserOrdersHandler")
+	if !ok {
+		return
+	}
+	orders, err := a.repo.OrdersAll(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminUserOrdersHandler failed to get orders for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*orders) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "AdminUserOrdersHandler", orders)
+}
+
+func (a *api) AdminUserWithdrawalsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminUser(w, r, "AdminUserWithdrawalsHandler")
+	if !ok {
+		return
+	}
+	wdrls, err := a.repo.Withdrawals(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminUserWithdrawalsHandler failed to get withdrawals for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*wdrls) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "AdminUserWithdrawalsHandler", wdrls)
+}
+
+func (a *api) AdminUserBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminUser(w, r, "AdminUserBalanceHandler")
+	if !ok {
+		return
+	}
+	balance, err := a.balance(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminUserBalanceHandler failed to get balance for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	a.writeJSON(w, "AdminUserBalanceHandler", balance)
+}
+
+func (a *api) AdminRoleHandler(w http.ResponseWriter, r *http.Request) {
+	account, ok := r.Context().Value(keys.RoleContextKey{}).(*model.Account)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminRoleHandler] Role info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	login := chi.URLParam(r, "login")
+	found, err := a.repo.UserRoleSet(login, account.Role)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminRoleHandler failed to set role for user [%s], database error", login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !found {
+		problem.Write(w, r, http.StatusNotFound, problem.UserNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	a.log.Info().Msgf("Role '%s' granted to user [%s]", account.Role, login)
+}
+
+func (a *api) AdminRepollHandler(w http.ResponseWriter, r *http.Request) {
+	num, err := strconv.ParseInt(chi.URLParam(r, "number"), 10, 64)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.OrderNumberInvalid)
+		return
+	}
+	order, err := a.repo.OrderGet(num)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminRepollHandler failed to get order [%v], database error", num)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if order == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.NotFound)
+		return
+	}
+	// начисление обработанного заказа не пересчитывается: иначе оно изменилось бы задним числом.
+	// INVALID опрашивается заново: система начислений могла отклонить заказ по ошибке
+	if order.Status == "PROCESSED" {
+		problem.WriteDetail(w, r, http.StatusConflict, problem.OrderFinal, "order status is "+order.Status)
+		return
+	}
+	// клиент системы начислений опросит заказ заново и обновит статус
+	a.ms.Add(order)
+	w.WriteHeader(http.StatusAccepted)
+	a.log.Info().Msgf("Order number [%v] scheduled for accrual re-poll", num)
+}
+
+func (a *api) AdminAdjustmentHandler(w http.ResponseWriter, r *http.Request) {
+	adj, ok := r.Context().Value(keys.AdjustmentContextKey{}).(*model.Adjustment)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminAdjustmentHandler] Adjustment info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	user, ok := a.adminUser(w, r, "AdminAdjustmentHandler")
+	if !ok {
+		return
+	}
+	adj.UserID = user.ID
+	result, err := a.repo.AdjustmentAdd(adj)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminAdjustmentHandler failed to adjust balance for user [%s], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch result {
+	case model.AdjustmentDuplicate:
+		problem.Write(w, r, http.StatusConflict, problem.ReferenceTaken)
+		return
+	case model.AdjustmentInsufficient:
+		problem.Write(w, r, http.StatusPaymentRequired, problem.InsufficientBalance)
+		return
+	}
+	adj.Ins = time.Now()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(adj); err != nil {
+		a.log.Err(err).Msgf("Error: [AdminAdjustmentHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Balance of user [%s] adjusted by %v by user id [%s], reference [%s]", user.Login, *adj.Amount, adj.ActorID, adj.Reference)
+}
+
+func (a *api) AdminReversalHandler(w http.ResponseWriter, r *http.Request) {
+	rev, ok := r.Context().Value(keys.ReversalContextKey{}).(*model.Reversal)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminReversalHandler] Reversal info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	num, err := strconv.ParseInt(chi.URLParam(r, "order"), 10, 64)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.OrderNumberInvalid)
+		return
+	}
+	rev.Num = num
+	result, err := a.repo.WithdrawalReverse(rev)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminReversalHandler failed to reverse withdrawal [%v], database error", num)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch result {
+	case model.ReversalNotFound:
+		problem.Write(w, r, http.StatusNotFound, problem.WithdrawalNotFound)
+		return
+	case model.ReversalAmbiguous:
+		problem.Write(w, r, http.StatusConflict, problem.WithdrawalAmbiguous)
+		return
+	case model.ReversalExceeds:
+		problem.WriteExt(w, r, http.StatusConflict, problem.ReversalExceeds, map[string]any{"remaining": rev.Remaining})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(rev); err != nil {
+		a.log.Err(err).Msgf("Error: [AdminReversalHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Withdrawal [%v] of user id [%s] reversed by %v by user id [%s]", num, rev.UserID, *rev.Amount, rev.ActorID)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	conf "github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// repollRepo отдает заказ со статусом status
+type repollRepo struct {
+	repository
+	status string
+}
+
+func (r *repollRepo) OrderGet(num int64) (*model.Order, error) {
+	return &model.Order{Num: &num, Status: r.status}, nil
+}
+
+// repollQueue запоминает заказы, отправленные на повторный опрос
+type repollQueue struct {
+	orders []*model.Order
+}
+
+func (q *repollQueue) Add(order *model.Order) {
+	q.orders = append(q.orders, order)
+}
+
+// TestAdminRepoll заново опрашивается любой заказ, кроме обработанного, в том числе отклоненный INVALID
+func TestAdminRepoll(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	cfg := &conf.Config{}
+	for _, tc := range []struct {
+		status string
+		code   int
+	}{
+		{"NEW", http.StatusAccepted},
+		{"PROCESSING", http.StatusAccepted},
+		{"INVALID", http.StatusAccepted},
+		{"PROCESSED", http.StatusConflict},
+	} {
+		t.Run(tc.status, func(t *testing.T) {
+			queue := &repollQueue{}
+			a := NewAPI(&repollRepo{status: tc.status}, logger.New(cfg), queue, cfg, nil, nil, nil, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("number", "12345678903")
+			r := httptest.NewRequest(http.MethodPost, "/api/admin/orders/12345678903/repoll", nil)
+			w := httptest.NewRecorder()
+			a.AdminRepollHandler(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+
+			if w.Code != tc.code {
+				t.Fatalf("status %d, want %d", w.Code, tc.code)
+			}
+			if scheduled := len(queue.orders) == 1; scheduled != (tc.code == http.StatusAccepted) {
+				t.Errorf("scheduled %d orders", len(queue.orders))
+			}
+		})
+	}
+}
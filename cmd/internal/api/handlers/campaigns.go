@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+func (a *api) AdminCampaignsHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := a.repo.Campaigns()
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msg("AdminCampaignsHandler failed to get campaigns, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "AdminCampaignsHandler", list)
+}
+
+func (a *api) AdminCampaignAddHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := r.Context().Value(keys.CampaignContextKey{}).(*model.Campaign)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminCampaignAddHandler] Campaign info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if err := a.repo.CampaignAdd(c); err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminCampaignAddHandler failed to add campaign [%s], database error", c.Name)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		a.log.Err(err).Msgf("Error: [AdminCampaignAddHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Campaign [%s] '%s' created", c.ID, c.Name)
+}
+
+func (a *api) AdminCampaignUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := r.Context().Value(keys.CampaignContextKey{}).(*model.Campaign)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminCampaignUpdateHandler] Campaign info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	c.ID = chi.URLParam(r, "id")
+	if !utils.ValidUUID(c.ID) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	found, err := a.repo.CampaignUpdate(c)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminCampaignUpdateHandler failed to update campaign [%s], database error", c.ID)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !found {
+		problem.Write(w, r, http.StatusNotFound, problem.NotFound)
+		return
+	}
+	a.writeJSON(w, "AdminCampaignUpdateHandler", c)
+	a.log.Info().Msgf("Campaign [%s] updated", c.ID)
+}
+
+func (a *api) AdminCampaignDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !utils.ValidUUID(id) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	found, err := a.repo.CampaignDelete(id)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminCampaignDeleteHandler failed to delete campaign [%s], database error", id)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !found {
+		problem.Write(w, r, http.StatusNotFound, problem.NotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	a.log.Info().Msgf("Campaign [%s] deleted", id)
+}
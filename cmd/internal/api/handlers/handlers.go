@@ -2,37 +2,144 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/lockout"
 	"github.com/rebus2015/gophermart/cmd/internal/logger"
 	"github.com/rebus2015/gophermart/cmd/internal/model"
 	"github.com/rebus2015/gophermart/cmd/internal/utils"
 )
 
 type repository interface {
-	UserRegister(user *model.User) (string, error)
+	UserRegister(user *model.User, referrerBonus int64, refereeBonus int64) (string, string, error)
 	UserLogin(user *model.User) (*model.User, error)
 	OrdersAll(user *model.User) (*[]model.Order, error)
 	OrdersNew(order *model.Order) (string, error)
 	Balance(user *model.User) (*model.Balance, error)
-	Withdraw(request *model.Withdraw) (bool, error)
+	Withdraw(request *model.Withdraw, rules *model.WithdrawRules) (string, int64, error)
 	Withdrawals(user *model.User) (*[]model.Withdraw, error)
+	WebhookAdd(hook *model.Webhook) (string, error)
+	Webhooks(user *model.User) (*[]model.Webhook, error)
+	WebhookDelete(user *model.User, id string) (bool, error)
+	Deliveries(user *model.User, webhookID string) (*[]model.Delivery, error)
+	Redeliver(user *model.User, deliveryID int64) (bool, error)
+	SessionAdd(userID string, token string, ip string, ttl time.Duration) error
+	PasswordSet(userID string, hash string) error
+	PasswordRehash(userID string, old string, hash string) (bool, error)
+	PasswordResetAdd(reset *model.PasswordReset, ttl time.Duration) (bool, error)
+	PasswordResetUse(change *model.PasswordChange) (bool, error)
+	UserFind(login string) (*model.Account, error)
+	UsersSearch(query string, limit int, offset int) (*[]model.Account, error)
+	UserRoleSet(login string, role string) (bool, error)
+	OrderGet(num int64) (*model.Order, error)
+	AdjustmentAdd(adj *model.Adjustment) (string, error)
+	History(user *model.User) (*[]model.HistoryEntry, error)
+	Statement(user *model.User, from time.Time, to time.Time, row func(*model.StatementRow) error) error
+	MonthlyStatements(user *model.User) (*[]model.MonthlyStatement, error)
+	MonthlyStatementGet(user *model.User, id string) (*model.MonthlyStatement, error)
+	WithdrawalReverse(rev *model.Reversal) (string, error)
+	HoldAdd(hold *model.Hold, ttl time.Duration, rules *model.WithdrawRules) (string, int64, error)
+	HoldClose(hold *model.Hold, capture bool) (string, error)
+	Holds(user *model.User) (*[]model.Hold, error)
+	PointsExpiring(user *model.User, months int, within time.Duration) (int64, error)
+	TransferAdd(t *model.Transfer, dailyLimit int64, confirmAbove int64) (string, int64, error)
+	TransferClose(userID string, t *model.Transfer, action string) (string, error)
+	Transfers(user *model.User) (*[]model.Transfer, error)
+	WithdrawLimits(userID string) (*model.WithdrawRules, error)
+	WithdrawLimitsSet(userID string, rules *model.WithdrawRules) error
+	Profile(user *model.User, window time.Duration) (*model.Profile, error)
+	Stats(user *model.User, months int) (*model.Stats, error)
+	ReportLiability() (*model.ReportLiability, error)
+	ReportDaily(from time.Time, to time.Time) (*[]model.ReportDay, error)
+	ReportStuck() (*[]model.ReportStuck, error)
+	ReportTopUsers(from time.Time, to time.Time, by string, limit int) (*[]model.ReportTopUser, error)
+	Referral(user *model.User) (*model.Referral, error)
+	VoucherBatchAdd(batch *model.VoucherBatch) error
+	VoucherBatches() (*[]model.VoucherBatch, error)
+	VoucherRedeem(red *model.Redemption) (string, error)
+	RewardAdd(rw *model.Reward) error
+	RewardUpdate(rw *model.Reward) (bool, error)
+	Rewards(activeOnly bool) (*[]model.Reward, error)
+	RewardGet(id string) (*model.Reward, error)
+	RewardRedeem(red *model.RewardRedemption, rules *model.WithdrawRules) (string, int64, error)
+	RewardRedemptions(user *model.User) (*[]model.RewardRedemption, error)
+	CampaignAdd(c *model.Campaign) error
+	CampaignUpdate(c *model.Campaign) (bool, error)
+	Campaigns() (*[]model.Campaign, error)
+	CampaignDelete(id string) (bool, error)
+}
+
+type guard interface {
+	Check(login string, ip string) (lockout.Verdict, error)
+	Fail(login string, ip string) (lockout.Verdict, error)
+	Success(login string) error
+	Unlock(login string) (bool, error)
+	CheckRedeem(login string, ip string) (lockout.Verdict, error)
+	FailRedeem(login string, ip string) (lockout.Verdict, error)
+	SuccessRedeem(login string) error
+}
+
+type twoFactor interface {
+	Enroll(user *model.User) (*model.TwoFactor, bool, error)
+	Confirm(userID string, code string) (bool, error)
+	Disable(userID string, code string) (bool, error)
+	Enabled(userID string) (bool, error)
+	Verify(userID string, code string) (bool, error)
+}
+
+type credentials interface {
+	Put(login string, password string, version int64)
+	Forget(login string)
+}
+
+type config interface {
+	GetSessionTTL() time.Duration
+	GetResetTTL() time.Duration
+	GetWithdrawOTPThreshold() int64
+	GetHoldTTL() time.Duration
+	GetPointsExpireMonths() int
+	GetPointsExpiringWithin() time.Duration
+	GetTransferDailyLimit() int64
+	GetTransferConfirmAbove() int64
+	GetWithdrawRules() *model.WithdrawRules
+	GetTierWindow() time.Duration
+	GetReferrerBonus() int64
+	GetRefereeBonus() int64
+	GetStatsMonths() int
+	GetStatsCacheSize() int
+	GetStatsCacheTTL() time.Duration
+}
+
+type files interface {
+	Open(key string) (io.ReadCloser, error)
 }
 
 type memstorage interface {
 	Add(order *model.Order)
 }
 
-func NewAPI(_repo repository, _log *logger.Logger, _ms memstorage) *api {
-	return &api{repo: _repo, log: _log, ms: _ms}
+func NewAPI(_repo repository, _log *logger.Logger, _ms memstorage, _cfg config, _guard guard, _creds credentials, _tf twoFactor, _files files) *api {
+	return &api{
+		repo: _repo, log: _log, ms: _ms, cfg: _cfg, guard: _guard, creds: _creds, tf: _tf, files: _files,
+		stats: utils.NewCache[string, *model.Stats](_cfg.GetStatsCacheSize(), _cfg.GetStatsCacheTTL()),
+	}
 }
 
 type api struct {
-	repo repository
-	log  *logger.Logger
-	ms   memstorage
+	repo  repository
+	log   *logger.Logger
+	ms    memstorage
+	cfg   config
+	guard guard
+	creds credentials
+	tf    twoFactor
+	files files
+	stats *utils.Cache[string, *model.Stats] // статистика по id пользователя
 }
 
 func (a *api) UserRegisterHandler(w http.ResponseWriter, r *http.Request) {
@@ -41,21 +148,32 @@ func (a *api) UserRegisterHandler(w http.ResponseWriter, r *http.Request) {
 		a.log.Printf(
 			"Error: [UserRegisterHandler] User info not found in context status-'500'",
 		)
-		http.Error(w, "User info not found in context", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 
-	id, err := a.repo.UserRegister(user)
+	user.IP = utils.ClientIP(r)
+	id, referral, err := a.repo.UserRegister(user, a.cfg.GetReferrerBonus(), a.cfg.GetRefereeBonus())
 	if err != nil { //ошибка запроса 500
 		a.log.Err(err).Msg("UserRegisterHandler failed to register, database error")
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if referral == model.ReferralUnknown { //неизвестный код 400
+		problem.Write(w, r, http.StatusBadRequest, problem.ReferralCodeInvalid)
 		return
 	}
 	if id == "" { //такой уже есть 409
 		a.log.Err(err).Msgf("UserRegisterHandler failed, login [%s] is busy", user.Login)
-		w.WriteHeader(http.StatusConflict)
+		problem.Write(w, r, http.StatusConflict, problem.LoginTaken)
 		return
 	}
+	if !a.sessionStart(w, r, id) {
+		return
+	}
+	if referral == model.ReferralRejected {
+		a.log.Warn().Msgf("Referral of user [%s] by code [%s] from [%s] rejected", user.Login, user.Referral, user.IP)
+	}
 	// иначе 200
 	w.WriteHeader(http.StatusOK)
 	a.log.Info().Msgf("User successfully registered: [%s]", user.Login)
@@ -67,24 +185,65 @@ func (a *api) UserLoginHandler(w http.ResponseWriter, r *http.Request) {
 		a.log.Printf(
 			"Error: [UserLoginHandler] User info not found in context status-'500'",
 		)
-		http.Error(w, "User info not found in context", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+
+	ip := utils.ClientIP(r)
+	verdict, err := a.guard.Check(user.Login, ip)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msg("UserLoginHandler: failed to check login attempts")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !verdict.Allowed() {
+		a.log.Warn().Msgf("UserLoginHandler: login [%s] from [%s] is throttled", user.Login, ip)
+		problem.WriteThrottled(w, r, verdict.Locked, verdict.Wait)
 		return
 	}
 
 	userAcc, err := a.repo.UserLogin(user)
 	if err != nil { //ошибка запроса 500
 		a.log.Err(err).Msg("UserLoginHandler: failed to log in")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
-	if userAcc == nil { //такого нет 401
-		a.log.Err(err).Msgf("UserLoginHandler: failed, login/pass [%s] failed", user.Login)
-		w.WriteHeader(http.StatusUnauthorized)
+	if userAcc == nil || !utils.CheckPasswordHash(user.Password, string(userAcc.Hash)) { //такого нет или пароль не тот 401
+		a.log.Warn().Msgf("UserLoginHandler: failed, login/pass [%s] failed", user.Login)
+		a.loginFailed(w, r, user.Login, ip, problem.InvalidCredentials)
+		return
+	}
+	twoFactor, err := a.tf.Enabled(userAcc.ID)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msg("UserLoginHandler: failed to check 2FA")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
-	pass := utils.CheckPasswordHash(user.Password, string(userAcc.Hash))
-	if !pass {
-		a.log.Err(err).Msgf("UserLoginHandler: failed, login/pass [%s] hash mismatch", user.Login)
-		w.WriteHeader(http.StatusUnauthorized)
+	if twoFactor { // сессия выдается только после второго фактора
+		if user.OTP == "" {
+			problem.Write(w, r, http.StatusUnauthorized, problem.OTPRequired)
+			return
+		}
+		valid, err := a.tf.Verify(userAcc.ID, user.OTP)
+		if err != nil { //ошибка запроса 500
+			a.log.Err(err).Msg("UserLoginHandler: failed to verify one-time code")
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		if !valid {
+			a.log.Warn().Msgf("UserLoginHandler: failed, one-time code for [%s] is invalid", user.Login)
+			a.loginFailed(w, r, user.Login, ip, problem.OTPInvalid)
+			return
+		}
+	}
+	if err = a.guard.Success(user.Login); err != nil {
+		a.log.Err(err).Msg("UserLoginHandler: failed to reset login attempts")
+	}
+	if !twoFactor { // с 2FA вход по паролю без сессии запрещен, кэшировать нечего
+		a.creds.Put(user.Login, user.Password, userAcc.Version)
+	}
+	a.rehash(userAcc, user.Password)
+	if !a.sessionStart(w, r, userAcc.ID) {
 		return
 	}
 	// иначе 200
@@ -92,13 +251,25 @@ func (a *api) UserLoginHandler(w http.ResponseWriter, r *http.Request) {
 	a.log.Info().Msgf("User successfully logged in: [%s]", user.Login)
 }
 
+// loginFailed учитывает неудачную попытку входа и отвечает 401
+func (a *api) loginFailed(w http.ResponseWriter, r *http.Request, login string, ip string, code problem.Code) {
+	verdict, err := a.guard.Fail(login, ip)
+	if err != nil {
+		a.log.Err(err).Msg("UserLoginHandler: failed to count failed login")
+	}
+	if verdict.Wait > 0 {
+		problem.RetryAfter(w, verdict.Wait)
+	}
+	problem.Write(w, r, http.StatusUnauthorized, code)
+}
+
 func (a *api) UserOrderNewHandler(w http.ResponseWriter, r *http.Request) {
 	orderNew, ok := r.Context().Value(keys.OrderContextKey{}).(*model.Order)
 	if !ok {
 		a.log.Printf(
 			"Error: [UserOrderNewHandler] Order info not found in context status-'500'",
 		)
-		http.Error(w, "Order info not found in context", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 
@@ -111,7 +282,7 @@ func (a *api) UserOrderNewHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.repo.OrdersNew(&order)
 	if err != nil { //ошибка запроса 500
 		a.log.Err(err).Msg("UserRegisterHandler failed to register, database error")
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 	switch id {
@@ -129,7 +300,7 @@ func (a *api) UserOrderNewHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	default:
 		{
-			w.WriteHeader(http.StatusConflict)
+			problem.Write(w, r, http.StatusConflict, problem.OrderTaken)
 			a.log.Warn().Msgf("Order number [%v] is already added by another user", *order.Num)
 		}
 	}
@@ -141,13 +312,13 @@ func (a *api) OrdersAllHandler(w http.ResponseWriter, r *http.Request) {
 		a.log.Error().Msgf(
 			"Error: [OrdersAllHandler] User info not found in context status-'500'",
 		)
-		http.Error(w, "User info not found in context", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 	ordersList, err := a.repo.OrdersAll(user)
 	if err != nil { //ошибка запроса 500
 		a.log.Err(err).Msgf("OrdersAllHandler failed to get orders for user [%v], database error", user.Login)
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 	if len(*ordersList) == 0 {
@@ -161,24 +332,41 @@ func (a *api) OrdersAllHandler(w http.ResponseWriter, r *http.Request) {
 	err = encoder.Encode(ordersList)
 	if err != nil {
 		a.log.Err(err).Msgf("Error: [OrdersAllHandler] Result Json encode error :%v", err)
-		http.Error(w, "[OrdersAllHandler] Result Json encode error", http.StatusInternalServerError)
 	}
 	a.log.Debug().Msgf("Возвращаем OrdersJSON result :%v", ordersList)
 }
 
+// balance баланс пользователя; при включенном сгорании баллов - с суммой, которая скоро сгорит
+func (a *api) balance(user *model.User) (*model.Balance, error) {
+	balance, err := a.repo.Balance(user)
+	if err != nil {
+		return nil, err
+	}
+	months := a.cfg.GetPointsExpireMonths()
+	if months <= 0 {
+		return balance, nil
+	}
+	expiring, err := a.repo.PointsExpiring(user, months, a.cfg.GetPointsExpiringWithin())
+	if err != nil {
+		return nil, err
+	}
+	balance.Expiring = &expiring
+	return balance, nil
+}
+
 func (a *api) BalanceHandler(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
 	if !ok {
 		a.log.Error().Msgf(
 			"Error: [BalanceHandler] User info not found in context status-'500'",
 		)
-		http.Error(w, "User info not found in context", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
-	balance, err := a.repo.Balance(user)
+	balance, err := a.balance(user)
 	if err != nil { //ошибка запроса 500
 		a.log.Err(err).Msgf("BalanceHandler failed to get balance for user [%v], database error", user.Login)
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -187,7 +375,6 @@ func (a *api) BalanceHandler(w http.ResponseWriter, r *http.Request) {
 	err = encoder.Encode(balance)
 	if err != nil {
 		a.log.Err(err).Msgf("Error: [BalanceHandler] Result Json encode error :%v", err)
-		http.Error(w, "[BalanceHandler] Result Json encode error", http.StatusInternalServerError)
 	}
 	a.log.Debug().Msgf("Возвращаем UpdateJSON result :%v", balance)
 }
@@ -198,7 +385,11 @@ func (a *api) WithdrawHandler(w http.ResponseWriter, r *http.Request) {
 		a.log.Printf(
 			"Error: [WithdrawHandler] Withdraw info not found in context status-'500'",
 		)
-		http.Error(w, "Withdraw info not found in context", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+
+	if !a.withdrawTwoFactor(w, r, withdrawNew) {
 		return
 	}
 
@@ -208,16 +399,15 @@ func (a *api) WithdrawHandler(w http.ResponseWriter, r *http.Request) {
 		Expence: withdrawNew.Expence,
 		Ins:     time.Now(),
 	}
-	success, err := a.repo.Withdraw(&withdraw)
+	result, remaining, err := a.repo.Withdraw(&withdraw, a.cfg.GetWithdrawRules())
 	if err != nil { //ошибка запроса 500
 		a.log.Err(err).Msg("WithdrawHandler failed to register, database error")
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 
-	if !success {
-		w.WriteHeader(http.StatusPaymentRequired)
-		a.log.Error().Msgf("Withdraw FAIL, order number [%v]. Reason: balance is low.", withdraw.Num)
+	if a.withdrawRefused(w, r, result, remaining) {
+		a.log.Error().Msgf("Withdraw FAIL, order number [%v]. Reason: %s.", *withdraw.Num, result)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -230,13 +420,13 @@ func (a *api) WithdrawalsAllHandler(w http.ResponseWriter, r *http.Request) {
 		a.log.Error().Msgf(
 			"Error: [WithdrawalsAllHandler] User info not found in context status-'500'",
 		)
-		http.Error(w, "User info not found in context", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 	wdrls, err := a.repo.Withdrawals(user)
 	if err != nil { //ошибка запроса 500
 		a.log.Err(err).Msgf("WithdrawalsAllHandler failed to get Withdrawals for user [%v], database error", user.Login)
-		w.WriteHeader(http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
 		return
 	}
 	if len(*wdrls) == 0 {
@@ -250,7 +440,90 @@ func (a *api) WithdrawalsAllHandler(w http.ResponseWriter, r *http.Request) {
 	err = encoder.Encode(wdrls)
 	if err != nil {
 		a.log.Err(err).Msgf("Error: [Withdrawals] Result Json encode error :%v", err)
-		http.Error(w, "[Withdrawals] Result Json encode error", http.StatusInternalServerError)
 	}
 	a.log.Debug().Msgf("Возвращаем Withdrawals result :%v", wdrls)
 }
+
+func (a *api) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [HistoryHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	history, err := a.repo.History(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("HistoryHandler failed to get history for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*history) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		a.log.Info().Msgf("No history was found for user [%v]", user.Login)
+		return
+	}
+	a.writeJSON(w, "HistoryHandler", history)
+}
+
+func (a *api) ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [ProfileHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	profile, err := a.repo.Profile(user, a.cfg.GetTierWindow())
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("ProfileHandler failed to get profile for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	a.writeJSON(w, "ProfileHandler", profile)
+}
+
+// StatsHandler статистика пользователя; результат кэшируется, изменения видны после истечения записи кэша
+func (a *api) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [StatsHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	stats, ok := a.stats.Get(user.ID)
+	if !ok {
+		var err error
+		stats, err = a.repo.Stats(user, a.cfg.GetStatsMonths())
+		if err != nil { //ошибка запроса 500
+			a.log.Err(err).Msgf("StatsHandler failed to get stats for user [%v], database error", user.Login)
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		a.stats.Set(user.ID, stats)
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(a.cfg.GetStatsCacheTTL().Seconds())))
+	a.writeJSON(w, "StatsHandler", stats)
+}
+
+func (a *api) ReferralHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [ReferralHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	ref, err := a.repo.Referral(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("ReferralHandler failed to get referral info for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	a.writeJSON(w, "ReferralHandler", ref)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+func (a *api) HoldAddHandler(w http.ResponseWriter, r *http.Request) {
+	request, ok := r.Context().Value(keys.WithdrwContextKey{}).(*model.Withdraw)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [HoldAddHandler] Withdraw info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	// резерв под порогом 2FA проверяется так же, как списание: capture код уже не спрашивает
+	if !a.withdrawTwoFactor(w, r, request) {
+		return
+	}
+	hold := &model.Hold{
+		UserID: request.UserID,
+		Num:    *request.Num,
+		Amount: *request.Expence,
+	}
+	result, remaining, err := a.repo.HoldAdd(hold, a.cfg.GetHoldTTL(), a.cfg.GetWithdrawRules())
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msg("HoldAddHandler failed to add hold, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch result {
+	case model.HoldDuplicate:
+		problem.Write(w, r, http.StatusConflict, problem.HoldDuplicate)
+		return
+	}
+	if a.withdrawRefused(w, r, result, remaining) {
+		a.log.Warn().Msgf("Hold FAIL, order number [%v]. Reason: %s.", hold.Num, result)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(hold); err != nil {
+		a.log.Err(err).Msgf("Error: [HoldAddHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Hold [%s] of %v points for order number [%v] added", hold.ID, hold.Amount, hold.Num)
+}
+
+func (a *api) HoldsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [HoldsHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	list, err := a.repo.Holds(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("HoldsHandler failed to get holds for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "HoldsHandler", list)
+}
+
+func (a *api) HoldCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	a.holdClose(w, r, true)
+}
+
+func (a *api) HoldVoidHandler(w http.ResponseWriter, r *http.Request) {
+	a.holdClose(w, r, false)
+}
+
+// holdClose общая часть capture и void: capture отвечает закрытым резервом, void - 204
+func (a *api) holdClose(w http.ResponseWriter, r *http.Request, capture bool) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [HoldCloseHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if !utils.ValidUUID(id) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	hold := &model.Hold{ID: id, UserID: user.ID}
+	result, err := a.repo.HoldClose(hold, capture)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("HoldCloseHandler failed to close hold [%s], database error", id)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch result {
+	case model.HoldNotFound:
+		problem.Write(w, r, http.StatusNotFound, problem.HoldNotFound)
+		return
+	case model.HoldNotActive:
+		problem.WriteExt(w, r, http.StatusConflict, problem.HoldNotActive, map[string]any{"status": hold.Status})
+		return
+	case model.HoldDuplicate:
+		problem.Write(w, r, http.StatusConflict, problem.HoldDuplicate)
+		return
+	}
+	a.log.Info().Msgf("Hold [%s] of user [%s] closed with status %s", id, user.Login, hold.Status)
+	if !capture {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "HoldCaptureHandler", hold)
+}
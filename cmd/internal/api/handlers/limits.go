@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// withdrawRefused отвечает на отказ в списании или резерве; false - отказа нет
+func (a *api) withdrawRefused(w http.ResponseWriter, r *http.Request, result string, remaining int64) bool {
+	var code problem.Code
+	switch result {
+	case model.WithdrawOK:
+		return false
+	case model.WithdrawInsufficient:
+		problem.Write(w, r, http.StatusPaymentRequired, problem.InsufficientBalance)
+		return true
+	case model.WithdrawCooldown:
+		problem.WriteRetry(w, r, http.StatusForbidden, problem.WithdrawCooldown, time.Duration(remaining)*time.Second)
+		return true
+	case model.WithdrawDuplicate:
+		problem.Write(w, r, http.StatusConflict, problem.WithdrawDuplicate)
+		return true
+	case model.WithdrawBelowMin:
+		problem.WriteExt(w, r, http.StatusForbidden, problem.WithdrawBelowMin, map[string]any{"min_amount": remaining})
+		return true
+	case model.WithdrawAboveMax:
+		code = problem.WithdrawAboveMax
+	case model.WithdrawDailyLimit:
+		code = problem.WithdrawDailyLimit
+	case model.WithdrawMonthlyLimit:
+		code = problem.WithdrawMonthlyLimit
+	default:
+		a.log.Error().Msgf("Error: unknown withdraw result [%s] status-'500'", result)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return true
+	}
+	problem.WriteExt(w, r, http.StatusForbidden, code, map[string]any{"remaining": remaining})
+	return true
+}
+
+func (a *api) AdminWithdrawLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.adminUser(w, r, "AdminWithdrawLimitsHandler")
+	if !ok {
+		return
+	}
+	rules, err := a.repo.WithdrawLimits(user.ID)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminWithdrawLimitsHandler failed to get limits for user [%s], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	a.writeJSON(w, "AdminWithdrawLimitsHandler", rules)
+}
+
+func (a *api) AdminWithdrawLimitsSetHandler(w http.ResponseWriter, r *http.Request) {
+	rules, ok := r.Context().Value(keys.WithdrawRulesContextKey{}).(*model.WithdrawRules)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminWithdrawLimitsSetHandler] Rules info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	user, ok := a.adminUser(w, r, "AdminWithdrawLimitsSetHandler")
+	if !ok {
+		return
+	}
+	if err := a.repo.WithdrawLimitsSet(user.ID, rules); err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminWithdrawLimitsSetHandler failed to set limits for user [%s], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	a.writeJSON(w, "AdminWithdrawLimitsSetHandler", rules)
+	a.log.Info().Msgf("Withdraw limits of user [%s] changed", user.Login)
+}
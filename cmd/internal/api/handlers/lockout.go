@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+)
+
+func (a *api) AdminUnlockHandler(w http.ResponseWriter, r *http.Request) {
+	login := chi.URLParam(r, "login")
+	found, err := a.guard.Unlock(login)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminUnlockHandler failed to unlock [%s], database error", login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !found {
+		problem.Write(w, r, http.StatusNotFound, problem.NotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	a.log.Info().Msgf("Login [%s] unlocked by admin", login)
+}
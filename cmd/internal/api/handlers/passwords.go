@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+// sessionStart открывает сессию и отдает токен в заголовке Authorization
+func (a *api) sessionStart(w http.ResponseWriter, r *http.Request, userID string) bool {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		a.log.Err(err).Msg("failed to generate session token")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return false
+	}
+	if err = a.repo.SessionAdd(userID, token, utils.ClientIP(r), a.cfg.GetSessionTTL()); err != nil {
+		a.log.Err(err).Msgf("failed to start session for user id [%s]", userID)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return false
+	}
+	w.Header().Set("Authorization", "Bearer "+token)
+	return true
+}
+
+// rehash пересчитывает хэш пароля по текущей политике; ошибки не мешают входу
+func (a *api) rehash(user *model.User, password string) {
+	if !utils.NeedsRehash(user.Hash) {
+		return
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		a.log.Err(err).Msgf("failed to rehash password for user id [%s]", user.ID)
+		return
+	}
+	done, err := a.repo.PasswordRehash(user.ID, user.Hash, hash)
+	if err != nil {
+		a.log.Err(err).Msgf("failed to store rehashed password for user id [%s]", user.ID)
+		return
+	}
+	if done {
+		a.log.Info().Msgf("Password hash upgraded for user id [%s]", user.ID)
+	}
+}
+
+func (a *api) PasswordChangeHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [PasswordChangeHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	change, ok := r.Context().Value(keys.PasswordContextKey{}).(*model.PasswordChange)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [PasswordChangeHandler] Password info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	userAcc, err := a.repo.UserLogin(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("PasswordChangeHandler failed to get user [%s], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if userAcc == nil || !utils.CheckPasswordHash(change.OldPassword, userAcc.Hash) {
+		a.log.Warn().Msgf("PasswordChangeHandler: wrong current password for user [%s]", user.Login)
+		problem.Write(w, r, http.StatusForbidden, problem.PasswordOldInvalid)
+		return
+	}
+	if err = a.repo.PasswordSet(user.ID, change.Hash); err != nil {
+		a.log.Err(err).Msgf("PasswordChangeHandler failed to set password for user [%s], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	a.creds.Forget(user.Login)
+	// все сессии отозваны, выдаем новую взамен текущей
+	if !a.sessionStart(w, r, user.ID) {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	a.log.Info().Msgf("Password changed for user [%s]", user.Login)
+}
+
+func (a *api) PasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	change, ok := r.Context().Value(keys.PasswordContextKey{}).(*model.PasswordChange)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [PasswordResetHandler] Password info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if change.Login == "" || change.Token == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.ResetTokenInvalid)
+		return
+	}
+	done, err := a.repo.PasswordResetUse(change)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("PasswordResetHandler failed for user [%s], database error", change.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !done {
+		a.log.Warn().Msgf("PasswordResetHandler: invalid reset token for user [%s]", change.Login)
+		problem.Write(w, r, http.StatusBadRequest, problem.ResetTokenInvalid)
+		return
+	}
+	a.creds.Forget(change.Login)
+	w.WriteHeader(http.StatusOK)
+	a.log.Info().Msgf("Password reset for user [%s]", change.Login)
+}
+
+func (a *api) AdminPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := utils.RandomToken(16)
+	if err != nil {
+		a.log.Err(err).Msg("AdminPasswordResetHandler failed to generate token")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	reset := &model.PasswordReset{
+		Login:   chi.URLParam(r, "login"),
+		Token:   token,
+		Expires: time.Now().Add(a.cfg.GetResetTTL()),
+	}
+	found, err := a.repo.PasswordResetAdd(reset, a.cfg.GetResetTTL())
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminPasswordResetHandler failed for user [%s], database error", reset.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !found {
+		problem.Write(w, r, http.StatusNotFound, problem.UserNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(reset)
+	if err != nil {
+		a.log.Err(err).Msgf("Error: [AdminPasswordResetHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Password reset token issued for user [%s]", reset.Login)
+}
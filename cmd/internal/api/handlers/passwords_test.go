@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	conf "github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordRepo учетная запись с паролем, сессии и токен сброса в памяти;
+// смена пароля, как в базе, отзывает все сессии пользователя
+type passwordRepo struct {
+	repository
+	user     *model.User
+	token    string   //действующий токен сброса
+	sessions []string //открытые сессии пользователя
+	hash     string   //последний установленный хэш
+}
+
+func (r *passwordRepo) UserLogin(user *model.User) (*model.User, error) {
+	if user.Login != r.user.Login {
+		return nil, nil
+	}
+	return r.user, nil
+}
+
+func (r *passwordRepo) SessionAdd(userID string, token string, ip string, ttl time.Duration) error {
+	r.sessions = append(r.sessions, token)
+	return nil
+}
+
+func (r *passwordRepo) PasswordSet(userID string, hash string) error {
+	r.hash, r.sessions, r.token = hash, nil, ""
+	return nil
+}
+
+func (r *passwordRepo) PasswordResetAdd(reset *model.PasswordReset, ttl time.Duration) (bool, error) {
+	if reset.Login != r.user.Login {
+		return false, nil
+	}
+	r.token = reset.Token
+	return true, nil
+}
+
+func (r *passwordRepo) PasswordResetUse(change *model.PasswordChange) (bool, error) {
+	if change.Login != r.user.Login || r.token == "" || change.Token != r.token {
+		return false, nil
+	}
+	return true, r.PasswordSet(r.user.ID, change.Hash)
+}
+
+// forgetCreds запоминает логины, убранные из кэша проверенных паролей
+type forgetCreds struct {
+	forgotten []string
+}
+
+func (c *forgetCreds) Put(login string, password string, version int64) {}
+
+func (c *forgetCreds) Forget(login string) {
+	c.forgotten = append(c.forgotten, login)
+}
+
+func testPasswordAPI(t *testing.T) (*api, *passwordRepo, *forgetCreds) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("old-secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &conf.Config{SessionTTL: time.Hour, ResetTTL: time.Hour}
+	repo := &passwordRepo{user: &model.User{ID: "42", Login: "user", Hash: string(hash)}, sessions: []string{"old-session"}}
+	creds := &forgetCreds{}
+	lg := logger.New(cfg)
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	return NewAPI(repo, lg, nil, cfg, nil, creds, nil, nil), repo, creds
+}
+
+func withPassword(r *http.Request, change *model.PasswordChange) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), keys.PasswordContextKey{}, change))
+}
+
+// TestPasswordChange смена пароля требует текущий пароль, отзывает прежние сессии и выдает новую
+func TestPasswordChange(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		old  string
+		code int
+	}{
+		{"changed", "old-secret", http.StatusOK},
+		{"wrong password", "wrong", http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a, repo, creds := testPasswordAPI(t)
+			r := httptest.NewRequest(http.MethodPost, "/api/user/password", nil)
+			r = r.WithContext(context.WithValue(r.Context(), keys.UserContextKey{}, &model.User{ID: "42", Login: "user"}))
+			w := httptest.NewRecorder()
+			a.PasswordChangeHandler(w, withPassword(r, &model.PasswordChange{OldPassword: tc.old, NewPassword: "new", Hash: "new-hash"}))
+
+			if w.Code != tc.code {
+				t.Fatalf("status %d, want %d", w.Code, tc.code)
+			}
+			if tc.code != http.StatusOK {
+				if repo.hash != "" || len(repo.sessions) != 1 || len(creds.forgotten) != 0 {
+					t.Errorf("rejected change applied: hash %q, sessions %v, forgotten %v", repo.hash, repo.sessions, creds.forgotten)
+				}
+				return
+			}
+			if repo.hash != "new-hash" {
+				t.Errorf("hash %q, want new-hash", repo.hash)
+			}
+			if len(creds.forgotten) != 1 || creds.forgotten[0] != "user" {
+				t.Errorf("forgotten %v, want [user]", creds.forgotten)
+			}
+			token := strings.TrimPrefix(w.Header().Get("Authorization"), "Bearer ")
+			if len(repo.sessions) != 1 || repo.sessions[0] != token || token == "old-session" {
+				t.Errorf("sessions %v, token %q; want only the new session", repo.sessions, token)
+			}
+		})
+	}
+}
+
+// TestPasswordReset токен сброса выдается администратором, действует для своего логина один раз
+// и отзывает сессии пользователя
+func TestPasswordReset(t *testing.T) {
+	a, repo, creds := testPasswordAPI(t)
+	issue := func(login string) *httptest.ResponseRecorder {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("login", login)
+		r := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+login+"/password-reset", nil)
+		w := httptest.NewRecorder()
+		a.AdminPasswordResetHandler(w, r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx)))
+		return w
+	}
+	if w := issue("nobody"); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown login: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	w := issue("user")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("issue: status %d, want %d", w.Code, http.StatusCreated)
+	}
+	reset := &model.PasswordReset{}
+	if err := json.NewDecoder(w.Body).Decode(reset); err != nil {
+		t.Fatal(err)
+	}
+	if reset.Login != "user" || reset.Token == "" || reset.Token != repo.token || !reset.Expires.After(time.Now()) {
+		t.Fatalf("reset %+v, stored token %q", *reset, repo.token)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		change model.PasswordChange
+		code   int
+		hash   string //хэш пароля после попытки
+	}{
+		{"no token", model.PasswordChange{Login: "user"}, http.StatusBadRequest, ""},
+		{"no login", model.PasswordChange{Token: reset.Token}, http.StatusBadRequest, ""},
+		{"other login", model.PasswordChange{Login: "other", Token: reset.Token}, http.StatusBadRequest, ""},
+		{"wrong token", model.PasswordChange{Login: "user", Token: "wrong"}, http.StatusBadRequest, ""},
+		{"reset", model.PasswordChange{Login: "user", Token: reset.Token}, http.StatusOK, "new-hash"},
+		{"used token", model.PasswordChange{Login: "user", Token: reset.Token, Hash: "other-hash"}, http.StatusBadRequest, "new-hash"},
+	} {
+		change := tc.change
+		change.NewPassword = "new"
+		if change.Hash == "" {
+			change.Hash = "new-hash"
+		}
+		r := httptest.NewRequest(http.MethodPost, "/api/user/password/reset", nil)
+		w := httptest.NewRecorder()
+		a.PasswordResetHandler(w, withPassword(r, &change))
+		if w.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.code)
+		}
+		if repo.hash != tc.hash {
+			t.Errorf("%s: hash %q, want %q", tc.name, repo.hash, tc.hash)
+		}
+	}
+	if len(repo.sessions) != 0 {
+		t.Errorf("sessions %v kept after reset", repo.sessions)
+	}
+	if len(creds.forgotten) != 1 || creds.forgotten[0] != "user" {
+		t.Errorf("forgotten %v, want [user]", creds.forgotten)
+	}
+}
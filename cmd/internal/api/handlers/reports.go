@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+const (
+	reportJSON     = "json"
+	reportCSV      = "csv"
+	reportDays     = 30 //период отчета по умолчанию
+	reportTopLimit = 20
+	reportMaxLimit = 1000
+)
+
+// reportFormat формат отчета из параметра format: json (по умолчанию) или csv
+func reportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = reportJSON
+	}
+	if format != reportJSON && format != reportCSV {
+		problem.WriteDetail(w, r, http.StatusBadRequest, problem.QueryInvalid, "format must be json or csv")
+		return "", false
+	}
+	return format, true
+}
+
+// reportPeriod период отчета из параметров from и to, по умолчанию - последние reportDays дней
+func reportPeriod(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	from, okFrom := queryTime(r, "from", false)
+	to, okTo := queryTime(r, "to", true)
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -reportDays)
+	}
+	if !okFrom || !okTo || !to.After(from) {
+		problem.WriteDetail(w, r, http.StatusBadRequest, problem.QueryInvalid, "from and to must be RFC 3339 timestamps or YYYY-MM-DD dates, from before to")
+		return from, to, false
+	}
+	return from, to, true
+}
+
+// writeReport отдает отчет v в JSON или те же данные таблицей CSV с заголовком header.
+// Пустой отчет в JSON - 204, в CSV - только заголовок
+func (a *api) writeReport(w http.ResponseWriter, handler string, format string, name string, v any, header []string, rows [][]string) {
+	if format == reportJSON {
+		if len(rows) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		a.writeJSON(w, handler, v)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, name, time.Now().Format("2006-01-02")))
+	w.WriteHeader(http.StatusOK)
+	out := csv.NewWriter(w)
+	err := out.Write(header)
+	if err == nil {
+		err = out.WriteAll(rows) // WriteAll отправляет и заголовок
+	}
+	if err != nil {
+		a.log.Err(err).Msgf("Error: [%s] CSV write error :%v", handler, err)
+	}
+}
+
+func itoa(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func (a *api) AdminReportLiabilityHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := reportFormat(w, r)
+	if !ok {
+		return
+	}
+	rep, err := a.repo.ReportLiability()
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminReportLiabilityHandler failed to get report, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	a.writeReport(w, "AdminReportLiabilityHandler", format, "liability", rep,
+		[]string{"outstanding", "held", "available", "users"},
+		[][]string{{itoa(rep.Outstanding), itoa(rep.Held), itoa(rep.Available), itoa(rep.Users)}})
+}
+
+func (a *api) AdminReportDailyHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := reportFormat(w, r)
+	if !ok {
+		return
+	}
+	from, to, ok := reportPeriod(w, r)
+	if !ok {
+		return
+	}
+	list, err := a.repo.ReportDaily(from, to)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminReportDailyHandler failed to get report, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	rows := make([][]string, 0, len(*list))
+	for _, d := range *list {
+		rows = append(rows, []string{d.Day, itoa(d.Orders), itoa(d.Processed), itoa(d.Invalid), itoa(d.Accrued),
+			itoa(d.Withdrawals), itoa(d.Withdrawn), itoa(d.Credited), itoa(d.Debited)})
+	}
+	a.writeReport(w, "AdminReportDailyHandler", format, "daily", list,
+		[]string{"day", "orders", "processed", "invalid", "accrued", "withdrawals", "withdrawn", "credited", "debited"}, rows)
+}
+
+func (a *api) AdminReportStuckHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := reportFormat(w, r)
+	if !ok {
+		return
+	}
+	list, err := a.repo.ReportStuck()
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminReportStuckHandler failed to get report, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	rows := make([][]string, 0, len(*list))
+	for _, s := range *list {
+		rows = append(rows, []string{s.Status, s.Age, itoa(s.Orders), s.Oldest.Format(time.RFC3339)})
+	}
+	a.writeReport(w, "AdminReportStuckHandler", format, "stuck-orders", list,
+		[]string{"status", "age", "orders", "oldest"}, rows)
+}
+
+func (a *api) AdminReportTopUsersHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := reportFormat(w, r)
+	if !ok {
+		return
+	}
+	from, to, ok := reportPeriod(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(r, "limit", reportTopLimit)
+	if !ok || limit == 0 || limit > reportMaxLimit {
+		problem.WriteDetail(w, r, http.StatusBadRequest, problem.QueryInvalid, "limit must be 1.."+strconv.Itoa(reportMaxLimit))
+		return
+	}
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = model.ReportByAccrued
+	}
+	if by != model.ReportByAccrued && by != model.ReportByWithdrawn {
+		problem.WriteDetail(w, r, http.StatusBadRequest, problem.QueryInvalid, "by must be accrued or withdrawn")
+		return
+	}
+	list, err := a.repo.ReportTopUsers(from, to, by, limit)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminReportTopUsersHandler failed to get report, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	rows := make([][]string, 0, len(*list))
+	for _, u := range *list {
+		rows = append(rows, []string{u.Login, itoa(u.Orders), itoa(u.Accrued), itoa(u.Withdrawals), itoa(u.Withdrawn)})
+	}
+	a.writeReport(w, "AdminReportTopUsersHandler", format, "top-users", list,
+		[]string{"login", "orders", "accrued", "withdrawals", "withdrawn"}, rows)
+}
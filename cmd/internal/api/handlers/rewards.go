@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+func (a *api) RewardsHandler(w http.ResponseWriter, r *http.Request) {
+	a.rewards(w, r, "RewardsHandler", true)
+}
+
+func (a *api) AdminRewardsHandler(w http.ResponseWriter, r *http.Request) {
+	a.rewards(w, r, "AdminRewardsHandler", false)
+}
+
+// rewards каталог наград; пользователям - только активные
+func (a *api) rewards(w http.ResponseWriter, r *http.Request, handler string, activeOnly bool) {
+	list, err := a.repo.Rewards(activeOnly)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("%s failed to get rewards, database error", handler)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, handler, list)
+}
+
+func (a *api) AdminRewardAddHandler(w http.ResponseWriter, r *http.Request) {
+	rw, ok := r.Context().Value(keys.RewardContextKey{}).(*model.Reward)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminRewardAddHandler] Reward info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if err := a.repo.RewardAdd(rw); err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminRewardAddHandler failed to add reward [%s], database error", rw.Name)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(rw); err != nil {
+		a.log.Err(err).Msgf("Error: [AdminRewardAddHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Reward [%s] '%s' added to the catalog", rw.ID, rw.Name)
+}
+
+func (a *api) AdminRewardUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	rw, ok := r.Context().Value(keys.RewardContextKey{}).(*model.Reward)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminRewardUpdateHandler] Reward info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	rw.ID = chi.URLParam(r, "id")
+	if !utils.ValidUUID(rw.ID) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	found, err := a.repo.RewardUpdate(rw)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminRewardUpdateHandler failed to update reward [%s], database error", rw.ID)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !found {
+		problem.Write(w, r, http.StatusNotFound, problem.RewardNotFound)
+		return
+	}
+	a.writeJSON(w, "AdminRewardUpdateHandler", rw)
+	a.log.Info().Msgf("Reward [%s] updated", rw.ID)
+}
+
+func (a *api) RewardRedeemHandler(w http.ResponseWriter, r *http.Request) {
+	red, ok := r.Context().Value(keys.RewardRedemptionContextKey{}).(*model.RewardRedemption)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [RewardRedeemHandler] Redemption info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if !utils.ValidUUID(id) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	// цена нужна до списания, чтобы запросить второй фактор
+	rw, err := a.repo.RewardGet(id)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("RewardRedeemHandler failed to get reward [%s], database error", id)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if rw == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.RewardNotFound)
+		return
+	}
+	if !a.amountTwoFactor(w, r, "RewardRedeemHandler", rw.Price, red.OTP) {
+		return
+	}
+	red.OTP = ""
+	red.RewardID = &rw.ID
+	if red.Num == nil {
+		num, err := utils.OrderNumber()
+		if err != nil {
+			a.log.Err(err).Msg("RewardRedeemHandler failed to generate order number")
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		red.Num = &num
+	}
+	result, remaining, err := a.repo.RewardRedeem(red, a.cfg.GetWithdrawRules())
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("RewardRedeemHandler failed to redeem reward [%s], database error", id)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch result {
+	case model.RewardNotFound:
+		problem.Write(w, r, http.StatusNotFound, problem.RewardNotFound)
+		return
+	case model.RewardOutOfStock:
+		problem.Write(w, r, http.StatusConflict, problem.RewardOutOfStock)
+		return
+	case model.RewardDuplicate:
+		problem.Write(w, r, http.StatusConflict, problem.RewardOrderTaken)
+		return
+	}
+	if a.withdrawRefused(w, r, result, remaining) {
+		a.log.Info().Msgf("Reward [%s] redemption refused: %s", id, result)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(red); err != nil {
+		a.log.Err(err).Msgf("Error: [RewardRedeemHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Reward [%s] redeemed for %v points by user id [%s], order number [%v]", id, red.Price, red.UserID, *red.Num)
+}
+
+func (a *api) RewardRedemptionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [RewardRedemptionsHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	list, err := a.repo.RewardRedemptions(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("RewardRedemptionsHandler failed to get redemptions for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "RewardRedemptionsHandler", list)
+}
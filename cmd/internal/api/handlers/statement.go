@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+const (
+	statementCSV   = "csv"
+	statementJSONL = "jsonl"
+	statementFlush = 100 //строк между отправками клиенту
+
+	statementChunkTimeout = 30 * time.Second //время на отправку одной порции строк
+)
+
+var statementHeader = []string{"date", "kind", "reference", "status", "amount", "balance", "comment"}
+
+// queryTime разбирает параметр в формате RFC 3339 или ГГГГ-ММ-ДД; дата в конце периода (end) включает весь день
+func queryTime(r *http.Request, name string, end bool) (time.Time, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+// statementWriter пишет строки выписки в выбранном формате, заголовки ответа отправляются с первой строкой.
+// Срок записи продлевается на каждую порцию, иначе WriteTimeout сервера оборвал бы длинную выписку
+type statementWriter struct {
+	w      http.ResponseWriter
+	format string
+	name   string
+	csv    *csv.Writer
+	json   *json.Encoder
+	rc     *http.ResponseController
+	rows   int
+}
+
+func (s *statementWriter) start() error {
+	if s.csv != nil || s.json != nil {
+		return nil
+	}
+	s.rc = http.NewResponseController(s.w)
+	if err := s.extend(); err != nil {
+		return err
+	}
+	if s.format == statementJSONL {
+		s.w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		s.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+	s.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, s.name, s.format))
+	s.w.WriteHeader(http.StatusOK)
+	if s.format == statementJSONL {
+		s.json = json.NewEncoder(s.w)
+		return nil
+	}
+	s.csv = csv.NewWriter(s.w)
+	return s.csv.Write(statementHeader)
+}
+
+func (s *statementWriter) row(row *model.StatementRow) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	var err error
+	if s.json != nil {
+		err = s.json.Encode(row)
+	} else {
+		err = s.csv.Write([]string{
+			row.Date.Format(time.RFC3339),
+			row.Kind,
+			row.Reference,
+			row.Status,
+			strconv.FormatInt(row.Amount, 10),
+			strconv.FormatInt(row.Balance, 10),
+			row.Comment,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	s.rows++
+	if s.rows%statementFlush == 0 {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *statementWriter) flush() error {
+	if s.csv != nil {
+		s.csv.Flush()
+		if err := s.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return s.extend()
+}
+
+// extend продлевает срок записи ответа на следующую порцию строк
+func (s *statementWriter) extend() error {
+	err := s.rc.SetWriteDeadline(time.Now().Add(statementChunkTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+func (a *api) StatementHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [StatementHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	from, okFrom := queryTime(r, "from", false)
+	to, okTo := queryTime(r, "to", true)
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if !okFrom || !okTo || (!from.IsZero() && !to.After(from)) {
+		problem.WriteDetail(w, r, http.StatusBadRequest, problem.QueryInvalid, "from and to must be RFC 3339 timestamps or YYYY-MM-DD dates, from before to")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = statementCSV
+	}
+	if format != statementCSV && format != statementJSONL {
+		problem.WriteDetail(w, r, http.StatusBadRequest, problem.QueryInvalid, "format must be csv or jsonl")
+		return
+	}
+
+	out := &statementWriter{w: w, format: format, name: "statement-" + to.Format("2006-01-02")}
+	err := a.repo.Statement(user, from, to, out.row)
+	if err == nil {
+		err = out.start() // пустая выписка - только заголовок
+	}
+	if err == nil {
+		err = out.flush()
+	}
+	if err == nil {
+		a.log.Info().Msgf("Statement of %v rows sent to user [%s]", out.rows, user.Login)
+		return
+	}
+	if out.csv == nil && out.json == nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("StatementHandler failed to get statement for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	// ответ уже начат: обрываем соединение, чтобы клиент не принял неполную выписку за целую
+	a.log.Err(err).Msgf("StatementHandler aborted statement for user [%v] after %v rows", user.Login, out.rows)
+	panic(http.ErrAbortHandler)
+}
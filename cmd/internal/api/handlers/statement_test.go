@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	conf "github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// statementRepo отдает rows строк выписки с паузой pause перед каждой порцией и завершается ошибкой err
+type statementRepo struct {
+	repository
+	rows  int
+	pause time.Duration
+	err   error
+}
+
+func (s *statementRepo) Statement(user *model.User, from time.Time, to time.Time, row func(*model.StatementRow) error) error {
+	for i := 0; i < s.rows; i++ {
+		if i%statementFlush == 0 {
+			time.Sleep(s.pause)
+		}
+		if err := row(&model.StatementRow{Date: to, Kind: "accrual", Amount: 1, Balance: int64(i + 1)}); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func statementServer(repo *statementRepo, writeTimeout time.Duration) *httptest.Server {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	cfg := &conf.Config{}
+	a := NewAPI(repo, logger.New(cfg), nil, cfg, nil, nil, nil, nil)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := &model.User{ID: "42", Login: "user"}
+		a.StatementHandler(w, r.WithContext(context.WithValue(r.Context(), keys.UserContextKey{}, user)))
+	}))
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Start()
+	return srv
+}
+
+// TestStatementAbort ошибка после начала ответа обрывает соединение, а не завершает выписку как целую
+func TestStatementAbort(t *testing.T) {
+	srv := statementServer(&statementRepo{rows: 3 * statementFlush, err: errors.New("connection lost")}, 0)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/user/statement")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if _, err = io.ReadAll(resp.Body); err == nil {
+		t.Error("broken statement read as complete")
+	}
+}
+
+// TestStatementWriteDeadline выписка дольше WriteTimeout сервера отдается целиком: срок продлевается на каждую порцию
+func TestStatementWriteDeadline(t *testing.T) {
+	rows := 4 * statementFlush
+	srv := statementServer(&statementRepo{rows: rows, pause: 60 * time.Millisecond}, 100*time.Millisecond)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/user/statement")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("statement cut after %d bytes: %v", len(body), err)
+	}
+	if lines := bytes.Count(body, []byte("\n")); lines != rows+1 {
+		t.Errorf("statement has %d lines, want %d", lines, rows+1)
+	}
+}
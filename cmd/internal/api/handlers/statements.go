@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+// MonthlyStatementsHandler ежемесячные выписки пользователя, новые первыми
+func (a *api) MonthlyStatementsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [MonthlyStatementsHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	list, err := a.repo.MonthlyStatements(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("MonthlyStatementsHandler failed to get statements for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "MonthlyStatementsHandler", list)
+}
+
+// MonthlyStatementHandler PDF-документ выписки
+func (a *api) MonthlyStatementHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [MonthlyStatementHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if !utils.ValidUUID(id) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	ms, err := a.repo.MonthlyStatementGet(user, id)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("MonthlyStatementHandler failed to get statement [%s] for user [%v], database error", id, user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if ms == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.StatementNotFound)
+		return
+	}
+	doc, err := a.files.Open(ms.File)
+	if errors.Is(err, fs.ErrNotExist) {
+		a.log.Error().Msgf("MonthlyStatementHandler: document [%s] of statement [%s] is missing in the store", ms.File, ms.ID)
+		problem.Write(w, r, http.StatusNotFound, problem.StatementNotFound)
+		return
+	}
+	if err != nil { //ошибка хранилища 500
+		a.log.Err(err).Msgf("MonthlyStatementHandler failed to open document [%s]", ms.File)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	defer doc.Close()
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s.pdf"`, ms.Period))
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, doc); err != nil {
+		a.log.Err(err).Msgf("Error: [MonthlyStatementHandler] failed to send document [%s]", ms.File)
+	}
+}
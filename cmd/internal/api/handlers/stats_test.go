@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	conf "github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// statsRepo считает запросы статистики по пользователям
+type statsRepo struct {
+	repository
+	calls map[string]int
+}
+
+func (s *statsRepo) Stats(user *model.User, months int) (*model.Stats, error) {
+	s.calls[user.ID]++
+	return &model.Stats{Orders: int64(s.calls[user.ID]), Months: []model.StatsMonth{}}, nil
+}
+
+// TestStatsCache статистика берется из базы один раз на пользователя до истечения записи кэша;
+// кэш нулевого размера не используется
+func TestStatsCache(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	get := func(a *api, id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/user/stats", nil)
+		w := httptest.NewRecorder()
+		a.StatsHandler(w, r.WithContext(context.WithValue(r.Context(), keys.UserContextKey{}, &model.User{ID: id, Login: id})))
+		return w
+	}
+	for _, tc := range []struct {
+		name     string
+		size     int
+		calls    int //запросов в базу на три обращения пользователя
+		afterTTL int //то же после еще одного обращения по истечении ttl
+	}{
+		{"cached", 10, 1, 2},
+		{"disabled", 0, 3, 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &conf.Config{StatsMonths: 12, StatsCacheSize: tc.size, StatsCacheTTL: 50 * time.Millisecond}
+			repo := &statsRepo{calls: map[string]int{}}
+			a := NewAPI(repo, logger.New(cfg), nil, cfg, nil, nil, nil, nil)
+			for i := 0; i < 3; i++ {
+				if w := get(a, "first"); w.Code != http.StatusOK {
+					t.Fatalf("status %d, want %d", w.Code, http.StatusOK)
+				}
+			}
+			get(a, "second")
+			if repo.calls["first"] != tc.calls || repo.calls["second"] != 1 {
+				t.Errorf("database calls %v; want %d for the first user, 1 for the second", repo.calls, tc.calls)
+			}
+
+			time.Sleep(60 * time.Millisecond)
+			get(a, "first")
+			if repo.calls["first"] != tc.afterTTL {
+				t.Errorf("database calls after ttl %d, want %d", repo.calls["first"], tc.afterTTL)
+			}
+		})
+	}
+}
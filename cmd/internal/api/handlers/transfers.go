@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+func (a *api) TransferHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := r.Context().Value(keys.TransferContextKey{}).(*model.Transfer)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [TransferHandler] Transfer info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !a.amountTwoFactor(w, r, "TransferHandler", *t.Amount, t.OTP) {
+		return
+	}
+	t.OTP = ""
+	result, remaining, err := a.repo.TransferAdd(t, a.cfg.GetTransferDailyLimit(), a.cfg.GetTransferConfirmAbove())
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("TransferHandler failed to transfer from user [%s], database error", t.From)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch result {
+	case model.TransferNotFound:
+		problem.Write(w, r, http.StatusNotFound, problem.UserNotFound)
+		return
+	case model.TransferSelf:
+		problem.Write(w, r, http.StatusBadRequest, problem.TransferSelf)
+		return
+	case model.TransferLimit:
+		problem.WriteExt(w, r, http.StatusConflict, problem.TransferLimit, map[string]any{"remaining": remaining})
+		return
+	case model.TransferInsufficient:
+		problem.Write(w, r, http.StatusPaymentRequired, problem.InsufficientBalance)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(t); err != nil {
+		a.log.Err(err).Msgf("Error: [TransferHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Transfer [%s] of %v points from [%s] to [%s]: %s", t.ID, *t.Amount, t.From, t.To, t.Status)
+}
+
+func (a *api) TransfersHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [TransfersHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	list, err := a.repo.Transfers(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("TransfersHandler failed to get transfers for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "TransfersHandler", list)
+}
+
+func (a *api) TransferAcceptHandler(w http.ResponseWriter, r *http.Request) {
+	a.transferClose(w, r, model.TransferAccept)
+}
+
+func (a *api) TransferDeclineHandler(w http.ResponseWriter, r *http.Request) {
+	a.transferClose(w, r, model.TransferDecline)
+}
+
+func (a *api) TransferCancelHandler(w http.ResponseWriter, r *http.Request) {
+	a.transferClose(w, r, model.TransferCancel)
+}
+
+// transferClose решение по ожидающему переводу: принять и отклонить может получатель, отменить - отправитель
+func (a *api) transferClose(w http.ResponseWriter, r *http.Request, action string) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [TransferCloseHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if !utils.ValidUUID(id) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	t := &model.Transfer{ID: id}
+	result, err := a.repo.TransferClose(user.ID, t, action)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("TransferCloseHandler failed to %s transfer [%s], database error", action, id)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch result {
+	case model.TransferNotFound:
+		problem.Write(w, r, http.StatusNotFound, problem.TransferNotFound)
+		return
+	case model.TransferNotPending:
+		problem.WriteExt(w, r, http.StatusConflict, problem.TransferNotPending, map[string]any{"status": t.Status})
+		return
+	}
+	a.log.Info().Msgf("Transfer [%s] %s by user [%s]: %s", id, action, user.Login, t.Status)
+	a.writeJSON(w, "TransferCloseHandler", t)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+func (a *api) TwoFactorEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [TwoFactorEnrollHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	enrollment, done, err := a.tf.Enroll(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("TwoFactorEnrollHandler failed for user [%s], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !done {
+		problem.Write(w, r, http.StatusConflict, problem.TwoFactorEnabled)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(enrollment)
+	if err != nil {
+		a.log.Err(err).Msgf("Error: [TwoFactorEnrollHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("TOTP enrollment started for user [%s]", user.Login)
+}
+
+func (a *api) TwoFactorConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	user, otp, ok := a.otpRequest(w, r, "TwoFactorConfirmHandler")
+	if !ok {
+		return
+	}
+	if !a.otpVerify(w, r, "TwoFactorConfirmHandler", user, otp.Code, http.StatusBadRequest, a.tf.Confirm) {
+		return
+	}
+	// вход по одному паролю больше не действует
+	a.creds.Forget(user.Login)
+	w.WriteHeader(http.StatusOK)
+	a.log.Info().Msgf("2FA enabled for user [%s]", user.Login)
+}
+
+func (a *api) TwoFactorDisableHandler(w http.ResponseWriter, r *http.Request) {
+	user, otp, ok := a.otpRequest(w, r, "TwoFactorDisableHandler")
+	if !ok {
+		return
+	}
+	if !a.otpVerify(w, r, "TwoFactorDisableHandler", user, otp.Code, http.StatusBadRequest, a.tf.Disable) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	a.log.Info().Msgf("2FA disabled for user [%s]", user.Login)
+}
+
+// otpRequest достает пользователя и одноразовый код из контекста
+func (a *api) otpRequest(w http.ResponseWriter, r *http.Request, handler string) (*model.User, *model.OTP, bool) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf("Error: [%s] User info not found in context status-'500'", handler)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return nil, nil, false
+	}
+	otp, ok := r.Context().Value(keys.OTPContextKey{}).(*model.OTP)
+	if !ok {
+		a.log.Error().Msgf("Error: [%s] OTP info not found in context status-'500'", handler)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return nil, nil, false
+	}
+	return user, otp, true
+}
+
+// otpVerify проверяет одноразовый код функцией verify с тем же учетом попыток, что и вход:
+// при блокировке отвечает 423/429, неверный код засчитывается как неудачная попытка и отвечает status
+func (a *api) otpVerify(w http.ResponseWriter, r *http.Request, handler string, user *model.User, code string, status int,
+	verify func(userID string, code string) (bool, error)) bool {
+	ip := utils.ClientIP(r)
+	verdict, err := a.guard.Check(user.Login, ip)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("%s failed to check login attempts", handler)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return false
+	}
+	if !verdict.Allowed() {
+		a.log.Warn().Msgf("%s: login [%s] from [%s] is throttled", handler, user.Login, ip)
+		problem.WriteThrottled(w, r, verdict.Locked, verdict.Wait)
+		return false
+	}
+	valid, err := verify(user.ID, code)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("%s failed to verify one-time code for user [%s], database error", handler, user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return false
+	}
+	if !valid {
+		a.log.Warn().Msgf("%s FAIL for user [%s]. Reason: invalid one-time code.", handler, user.Login)
+		if verdict, err = a.guard.Fail(user.Login, ip); err != nil {
+			a.log.Err(err).Msgf("%s failed to count failed one-time code", handler)
+		}
+		if verdict.Wait > 0 {
+			problem.RetryAfter(w, verdict.Wait)
+		}
+		problem.Write(w, r, status, problem.OTPInvalid)
+		return false
+	}
+	if err = a.guard.Success(user.Login); err != nil {
+		a.log.Err(err).Msgf("%s failed to reset login attempts", handler)
+	}
+	return true
+}
+
+// withdrawTwoFactor требует второй фактор для списаний больше порога, при отказе сам отвечает клиенту
+func (a *api) withdrawTwoFactor(w http.ResponseWriter, r *http.Request, withdraw *model.Withdraw) bool {
+	return a.amountTwoFactor(w, r, "WithdrawHandler", *withdraw.Expence, withdraw.OTP)
+}
+
+// amountTwoFactor требует второй фактор для операций пользователя из контекста с суммой больше порога списания
+func (a *api) amountTwoFactor(w http.ResponseWriter, r *http.Request, handler string, sum int64, code string) bool {
+	threshold := a.cfg.GetWithdrawOTPThreshold()
+	if threshold <= 0 || sum <= threshold {
+		return true
+	}
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf("Error: [%s] User info not found in context status-'500'", handler)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return false
+	}
+	enabled, err := a.tf.Enabled(user.ID)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("%s failed to check 2FA, database error", handler)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return false
+	}
+	if !enabled {
+		problem.Write(w, r, http.StatusForbidden, problem.TwoFactorRequired)
+		return false
+	}
+	if code == "" {
+		problem.Write(w, r, http.StatusForbidden, problem.OTPRequired)
+		return false
+	}
+	return a.otpVerify(w, r, handler, user, code, http.StatusForbidden, a.tf.Verify)
+}
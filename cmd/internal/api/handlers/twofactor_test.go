@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	conf "github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/lockout"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+type testGuard struct {
+	verdict   lockout.Verdict
+	fails     int
+	successes int
+	// счетчики погашения ваучеров
+	redeem          lockout.Verdict
+	redeemFails     int
+	redeemSuccesses int
+}
+
+func (g *testGuard) Check(login string, ip string) (lockout.Verdict, error) {
+	return g.verdict, nil
+}
+
+func (g *testGuard) Fail(login string, ip string) (lockout.Verdict, error) {
+	g.fails++
+	return lockout.Verdict{Wait: time.Second}, nil
+}
+
+func (g *testGuard) Success(login string) error {
+	g.successes++
+	return nil
+}
+
+func (g *testGuard) Unlock(login string) (bool, error) {
+	return true, nil
+}
+
+func (g *testGuard) CheckRedeem(login string, ip string) (lockout.Verdict, error) {
+	return g.redeem, nil
+}
+
+func (g *testGuard) FailRedeem(login string, ip string) (lockout.Verdict, error) {
+	g.redeemFails++
+	return lockout.Verdict{Wait: time.Second}, nil
+}
+
+func (g *testGuard) SuccessRedeem(login string) error {
+	g.redeemSuccesses++
+	return nil
+}
+
+// testTwoFactor принимает только код "123456"
+type testTwoFactor struct {
+	calls int
+}
+
+func (tf *testTwoFactor) check(userID string, code string) (bool, error) {
+	tf.calls++
+	return code == "123456", nil
+}
+
+func (tf *testTwoFactor) Enroll(user *model.User) (*model.TwoFactor, bool, error) {
+	return &model.TwoFactor{}, true, nil
+}
+
+func (tf *testTwoFactor) Confirm(userID string, code string) (bool, error) {
+	return tf.check(userID, code)
+}
+
+func (tf *testTwoFactor) Disable(userID string, code string) (bool, error) {
+	return tf.check(userID, code)
+}
+
+func (tf *testTwoFactor) Enabled(userID string) (bool, error) {
+	return true, nil
+}
+
+func (tf *testTwoFactor) Verify(userID string, code string) (bool, error) {
+	return tf.check(userID, code)
+}
+
+type testCredentials struct{}
+
+func (testCredentials) Put(login string, password string, version int64) {}
+
+func (testCredentials) Forget(login string) {}
+
+// TestOTPAttemptsCounted неверные одноразовые коды в списаниях и управлении 2FA учитываются как неудачные входы
+func TestOTPAttemptsCounted(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	cfg := &conf.Config{WithdrawOTPAbove: 100}
+	user := &model.User{ID: "42", Login: "user"}
+	withdraw := func(a *api, code string) *httptest.ResponseRecorder {
+		num, sum := int64(12345678903), int64(500)
+		r := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", nil)
+		r = r.WithContext(context.WithValue(r.Context(), keys.UserContextKey{}, user))
+		w := httptest.NewRecorder()
+		if a.withdrawTwoFactor(w, r, &model.Withdraw{UserID: user.ID, Num: &num, Expence: &sum, OTP: code}) {
+			w.WriteHeader(http.StatusOK)
+		}
+		return w
+	}
+	confirm := func(a *api, code string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/user/2fa/confirm", nil)
+		ctx := context.WithValue(r.Context(), keys.UserContextKey{}, user)
+		ctx = context.WithValue(ctx, keys.OTPContextKey{}, &model.OTP{Code: code})
+		w := httptest.NewRecorder()
+		a.TwoFactorConfirmHandler(w, r.WithContext(ctx))
+		return w
+	}
+	disable := func(a *api, code string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/user/2fa/disable", nil)
+		ctx := context.WithValue(r.Context(), keys.UserContextKey{}, user)
+		ctx = context.WithValue(ctx, keys.OTPContextKey{}, &model.OTP{Code: code})
+		w := httptest.NewRecorder()
+		a.TwoFactorDisableHandler(w, r.WithContext(ctx))
+		return w
+	}
+	for _, tc := range []struct {
+		name    string
+		call    func(a *api, code string) *httptest.ResponseRecorder
+		ok      int
+		invalid int
+	}{
+		{"withdraw", withdraw, http.StatusOK, http.StatusForbidden},
+		{"confirm", confirm, http.StatusOK, http.StatusBadRequest},
+		{"disable", disable, http.StatusNoContent, http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g, tf := &testGuard{}, &testTwoFactor{}
+			a := NewAPI(nil, logger.New(cfg), nil, cfg, g, testCredentials{}, tf, nil)
+
+			w := tc.call(a, "000000")
+			if w.Code != tc.invalid || g.fails != 1 {
+				t.Errorf("wrong code: status %d, failures %d; want %d, 1", w.Code, g.fails, tc.invalid)
+			}
+			if w.Header().Get("Retry-After") == "" {
+				t.Error("wrong code: Retry-After is not set")
+			}
+			if w = tc.call(a, "123456"); w.Code != tc.ok || g.successes != 1 {
+				t.Errorf("valid code: status %d, successes %d; want %d, 1", w.Code, g.successes, tc.ok)
+			}
+
+			g.verdict = lockout.Verdict{Locked: true, Wait: time.Minute}
+			calls := tf.calls
+			if w = tc.call(a, "123456"); w.Code != http.StatusLocked {
+				t.Errorf("locked account: status %d, want %d", w.Code, http.StatusLocked)
+			}
+			if tf.calls != calls {
+				t.Error("locked account: one-time code was checked")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+func (a *api) AdminVoucherBatchHandler(w http.ResponseWriter, r *http.Request) {
+	batch, ok := r.Context().Value(keys.VoucherBatchContextKey{}).(*model.VoucherBatch)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [AdminVoucherBatchHandler] Voucher batch info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	batch.Codes = make([]string, batch.Count)
+	for i := range batch.Codes {
+		code, err := utils.VoucherCode()
+		if err != nil {
+			a.log.Err(err).Msg("AdminVoucherBatchHandler failed to generate voucher code")
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		batch.Codes[i] = code
+	}
+	if err := a.repo.VoucherBatchAdd(batch); err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("AdminVoucherBatchHandler failed to add voucher batch [%s], database error", batch.Name)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(batch); err != nil {
+		a.log.Err(err).Msgf("Error: [AdminVoucherBatchHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Voucher batch [%s] of %v codes issued by user id [%s]", batch.ID, batch.Count, batch.ActorID)
+}
+
+func (a *api) AdminVoucherBatchesHandler(w http.ResponseWriter, r *http.Request) {
+	list, err := a.repo.VoucherBatches()
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msg("AdminVoucherBatchesHandler failed to get voucher batches, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	a.writeJSON(w, "AdminVoucherBatchesHandler", list)
+}
+
+func (a *api) RedeemHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [RedeemHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	red, ok := r.Context().Value(keys.RedemptionContextKey{}).(*model.Redemption)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [RedeemHandler] Redemption info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+
+	ip := utils.ClientIP(r)
+	verdict, err := a.guard.CheckRedeem(user.Login, ip)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msg("RedeemHandler: failed to check redeem attempts")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !verdict.Allowed() {
+		a.log.Warn().Msgf("RedeemHandler: user [%s] from [%s] is throttled", user.Login, ip)
+		problem.WriteRetry(w, r, http.StatusTooManyRequests, problem.TooManyAttempts, verdict.Wait)
+		return
+	}
+
+	result, err := a.repo.VoucherRedeem(red)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("RedeemHandler failed to redeem voucher for user [%s], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	switch result {
+	case model.VoucherNotFound: // перебором считаются только несуществующие коды
+		verdict, err = a.guard.FailRedeem(user.Login, ip)
+		if err != nil {
+			a.log.Err(err).Msg("RedeemHandler: failed to count failed redeem")
+		}
+		if verdict.Wait > 0 {
+			problem.RetryAfter(w, verdict.Wait)
+		}
+		problem.Write(w, r, http.StatusNotFound, problem.VoucherNotFound)
+		return
+	case model.VoucherExpired:
+		problem.Write(w, r, http.StatusGone, problem.VoucherExpired)
+		return
+	case model.VoucherExhausted:
+		problem.Write(w, r, http.StatusConflict, problem.VoucherExhausted)
+		return
+	case model.VoucherRedeemed:
+		problem.Write(w, r, http.StatusConflict, problem.VoucherRedeemed)
+		return
+	}
+	if err = a.guard.SuccessRedeem(user.Login); err != nil {
+		a.log.Err(err).Msg("RedeemHandler: failed to reset redeem attempts")
+	}
+	a.writeJSON(w, "RedeemHandler", red)
+	a.log.Info().Msgf("User [%s] redeemed a voucher for %v points", user.Login, red.Amount)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	conf "github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/lockout"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// voucherRepo отвечает на погашение результатом result
+type voucherRepo struct {
+	repository
+	result string
+	calls  int
+}
+
+func (v *voucherRepo) VoucherRedeem(red *model.Redemption) (string, error) {
+	v.calls++
+	if v.result == model.VoucherOK {
+		red.Amount = 100
+	}
+	return v.result, nil
+}
+
+// TestRedeem ответы на погашение ваучера; неудачной попыткой считается только неизвестный код,
+// при ограничении частоты код не проверяется
+func TestRedeem(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	cfg := &conf.Config{}
+	user := &model.User{ID: "42", Login: "user"}
+	for _, tc := range []struct {
+		name      string
+		result    string
+		throttled lockout.Verdict
+		code      int
+		fails     int
+		successes int
+		calls     int
+	}{
+		{name: "ok", result: model.VoucherOK, code: http.StatusOK, successes: 1, calls: 1},
+		{name: "not found", result: model.VoucherNotFound, code: http.StatusNotFound, fails: 1, calls: 1},
+		{name: "expired", result: model.VoucherExpired, code: http.StatusGone, calls: 1},
+		{name: "exhausted", result: model.VoucherExhausted, code: http.StatusConflict, calls: 1},
+		{name: "redeemed", result: model.VoucherRedeemed, code: http.StatusConflict, calls: 1},
+		{name: "throttled", result: model.VoucherOK, throttled: lockout.Verdict{Wait: time.Minute}, code: http.StatusTooManyRequests},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, g := &voucherRepo{result: tc.result}, &testGuard{redeem: tc.throttled}
+			a := NewAPI(repo, logger.New(cfg), nil, cfg, g, nil, nil, nil)
+			r := httptest.NewRequest(http.MethodPost, "/api/user/balance/redeem", nil)
+			ctx := context.WithValue(r.Context(), keys.UserContextKey{}, user)
+			ctx = context.WithValue(ctx, keys.RedemptionContextKey{}, &model.Redemption{Code: "ABCD-EFGH"})
+			w := httptest.NewRecorder()
+			a.RedeemHandler(w, r.WithContext(ctx))
+
+			if w.Code != tc.code {
+				t.Errorf("status %d, want %d", w.Code, tc.code)
+			}
+			if g.redeemFails != tc.fails || g.redeemSuccesses != tc.successes || repo.calls != tc.calls {
+				t.Errorf("failures %d, successes %d, redeems %d; want %d, %d, %d",
+					g.redeemFails, g.redeemSuccesses, repo.calls, tc.fails, tc.successes, tc.calls)
+			}
+			if retry := w.Header().Get("Retry-After") != ""; retry != (tc.fails > 0 || !tc.throttled.Allowed()) {
+				t.Errorf("Retry-After is set: %v", retry)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+func (a *api) WebhookAddHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := r.Context().Value(keys.WebhookContextKey{}).(*model.Webhook)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [WebhookAddHandler] Webhook info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		a.log.Err(err).Msg("WebhookAddHandler failed to generate secret")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	hook.Secret = secret
+	id, err := a.repo.WebhookAdd(hook)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msg("WebhookAddHandler failed to add webhook, database error")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	hook.ID = id
+	hook.Active = true
+	hook.Ins = time.Now()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(hook)
+	if err != nil {
+		a.log.Err(err).Msgf("Error: [WebhookAddHandler] Result Json encode error :%v", err)
+	}
+	a.log.Info().Msgf("Webhook [%s] registered for user id [%s]", id, hook.UserID)
+}
+
+func (a *api) WebhooksAllHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [WebhooksAllHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	hooks, err := a.repo.Webhooks(user)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("WebhooksAllHandler failed to get webhooks for user [%v], database error", user.Login)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*hooks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(hooks)
+	if err != nil {
+		a.log.Err(err).Msgf("Error: [WebhooksAllHandler] Result Json encode error :%v", err)
+	}
+}
+
+func (a *api) WebhookDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [WebhookDeleteHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if !utils.ValidUUID(id) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	found, err := a.repo.WebhookDelete(user, id)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("WebhookDeleteHandler failed to delete webhook [%v], database error", id)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !found {
+		problem.Write(w, r, http.StatusNotFound, problem.NotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+	a.log.Info().Msgf("Webhook [%s] deleted by user [%s]", id, user.Login)
+}
+
+func (a *api) DeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [DeliveriesHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	id := chi.URLParam(r, "id")
+	if !utils.ValidUUID(id) {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	list, err := a.repo.Deliveries(user, id)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("DeliveriesHandler failed to get deliveries for webhook [%v], database error", id)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if len(*list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		a.log.Err(err).Msgf("Error: [DeliveriesHandler] Result Json encode error :%v", err)
+	}
+}
+
+func (a *api) RedeliverHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		a.log.Error().Msgf(
+			"Error: [RedeliverHandler] User info not found in context status-'500'",
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidID)
+		return
+	}
+	found, err := a.repo.Redeliver(user, id)
+	if err != nil { //ошибка запроса 500
+		a.log.Err(err).Msgf("RedeliverHandler failed to schedule delivery [%v], database error", id)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if !found {
+		problem.Write(w, r, http.StatusNotFound, problem.NotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	a.log.Info().Msgf("Delivery [%v] scheduled for redelivery by user [%s]", id, user.Login)
+}
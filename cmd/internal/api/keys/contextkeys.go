@@ -2,4 +2,17 @@ package keys
 
 type UserContextKey struct{}
 type OrderContextKey struct{}
-type WithdrwContextKey struct{}
\ No newline at end of file
+type WithdrwContextKey struct{}
+type WebhookContextKey struct{}
+type PasswordContextKey struct{}
+type OTPContextKey struct{}
+type RoleContextKey struct{}
+type AdjustmentContextKey struct{}
+type ReversalContextKey struct{}
+type TransferContextKey struct{}
+type WithdrawRulesContextKey struct{}
+type CampaignContextKey struct{}
+type VoucherBatchContextKey struct{}
+type RedemptionContextKey struct{}
+type RewardContextKey struct{}
+type RewardRedemptionContextKey struct{}
@@ -4,129 +4,558 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/lockout"
 	"github.com/rebus2015/gophermart/cmd/internal/logger"
 	"github.com/rebus2015/gophermart/cmd/internal/model"
+	pol "github.com/rebus2015/gophermart/cmd/internal/policy"
 	"github.com/rebus2015/gophermart/cmd/internal/utils"
 )
 
 type middlewares struct {
-	r repository
-	l *logger.Logger
+	r     repository
+	l     *logger.Logger
+	guard guard
+	creds credentials
+	pol   policy
+	tf    twoFactor
 }
 
 type repository interface {
 	UserLogin(user *model.User) (*model.User, error)
+	SessionCheck(token string) (*model.User, error)
 }
 
-const compressed string = `gzip`
+type guard interface {
+	Check(login string, ip string) (lockout.Verdict, error)
+	Fail(login string, ip string) (lockout.Verdict, error)
+	Success(login string) error
+}
+
+type twoFactor interface {
+	Enabled(userID string) (bool, error)
+}
+
+type policy interface {
+	CheckLogin(login string) error
+	CheckPassword(login string, password string) error
+}
+
+type credentials interface {
+	Match(login string, password string, version int64) bool
+	Put(login string, password string, version int64)
+}
+
+const (
+	compressed string = `gzip`
+	bearer     string = `Bearer `
+
+	voucherBatchMax = 10000 //кодов в одной партии
+)
+
+func NewMiddlewares(_r repository, _l *logger.Logger, _guard guard, _creds credentials, _pol policy, _tf twoFactor) *middlewares {
+	return &middlewares{r: _r, l: _l, guard: _guard, creds: _creds, pol: _pol, tf: _tf}
+}
+
+// body возвращает тело запроса с учетом Content-Encoding
+func body(r *http.Request) (io.ReadCloser, error) {
+	if r.Header.Get(`Content-Encoding`) == compressed {
+		return gzip.NewReader(r.Body)
+	}
+	return r.Body, nil
+}
 
-func NewMiddlewares(_r repository, _l *logger.Logger) *middlewares {
-	return &middlewares{r: _r, l: _l}
+// decodeJSON разбирает JSON тело запроса в v, при ошибке сам отвечает клиенту
+func (m *middlewares) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	reader, err := body(r)
+	if err != nil {
+		m.l.Printf("Failed to create gzip reader: %v", err.Error())
+		problem.Write(w, r, http.StatusBadRequest, problem.InvalidEncoding)
+		return false
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(v); err != nil {
+		m.l.Debug().Msgf("Failed to decode request body from %v: %v", r.RequestURI, err)
+		problem.WriteDetail(w, r, http.StatusBadRequest, problem.InvalidJSON, err.Error())
+		return false
+	}
+	return true
+}
+
+// contextUser достает пользователя, сохраненного BasicAuthMiddleware
+func (m *middlewares) contextUser(w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+	if !ok {
+		m.l.Error().Msgf(
+			"Error: [%s] User info not found in context status-'500'", r.RequestURI,
+		)
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+	}
+	return user, ok
 }
 
 func (m *middlewares) BasicAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, bearer) {
+			m.sessionAuth(w, r, next, strings.TrimPrefix(auth, bearer))
+			return
+		}
 		username, password, ok := r.BasicAuth()
 		if !ok {
 			w.Header().Add("WWW-Authenticate", `Basic realm="Give username and password"`)
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(`{"message": "No basic auth present"}`))
-			if err != nil {
-				m.l.Err(err).Msgf("[BasicAuthMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusBadRequest, problem.AuthRequired)
 			return
 		}
 		usr := &model.User{
 			Login:    username,
 			Password: password,
 		}
+		ip := utils.ClientIP(r)
+		verdict, err := m.guard.Check(username, ip)
+		if err != nil {
+			m.l.Error().Err(err).Msgf("failed to check login attempts for user:%s", username)
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		if !verdict.Allowed() {
+			m.l.Warn().Msgf("user '%s' from [%s] is throttled", username, ip)
+			problem.WriteThrottled(w, r, verdict.Locked, verdict.Wait)
+			return
+		}
 		expectedUser, err := m.r.UserLogin(usr)
 		if err != nil {
 			m.l.Error().Err(err).Msgf("failed to get auth params for user:%s", username)
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err := w.Write([]byte(`{"message": "failed to get auth params for user due to database error"}`))
-			if err != nil {
-				m.l.Err(err).Msgf("[BasicAuthMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			return
+		}
+		// пара уже проверялась для текущей версии учетных данных: хэш пароля и 2FA не проверяем
+		if expectedUser != nil && m.creds.Match(username, password, expectedUser.Version) {
+			m.l.Debug().Msgf("user '%s' is authorized from cache", username)
+			usr.ID, usr.Role = expectedUser.ID, expectedUser.Role
+			ctx := context.WithValue(r.Context(), keys.UserContextKey{}, usr)
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 
-		if utils.CheckPasswordHash(password, string(expectedUser.Hash)) {
+		if expectedUser != nil && utils.CheckPasswordHash(password, string(expectedUser.Hash)) {
 			m.l.Info().Msgf("user '%s' is successfully authorized", username)
-			usr.ID = expectedUser.ID
+			if err = m.guard.Success(username); err != nil {
+				m.l.Error().Err(err).Msgf("failed to reset login attempts for user:%s", username)
+			}
+			twoFactor, err := m.tf.Enabled(expectedUser.ID)
+			if err != nil {
+				m.l.Error().Err(err).Msgf("failed to check 2FA for user:%s", username)
+				problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+				return
+			}
+			if twoFactor { // пароля недостаточно, нужна сессия из /api/user/login с одноразовым кодом
+				problem.WriteDetail(w, r, http.StatusUnauthorized, problem.OTPRequired, "log in with a one-time code and use the session token")
+				return
+			}
+			usr.ID, usr.Role = expectedUser.ID, expectedUser.Role
+			m.creds.Put(username, password, expectedUser.Version)
 			ctx := context.WithValue(r.Context(), keys.UserContextKey{}, usr)
 			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
 
 		m.l.Info().Msgf("user '%s' is NOT authorized", username)
+		if verdict, err = m.guard.Fail(username, ip); err != nil {
+			m.l.Error().Err(err).Msgf("failed to count failed login for user:%s", username)
+		}
+		if verdict.Wait > 0 {
+			problem.RetryAfter(w, verdict.Wait)
+		}
 		w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		problem.Write(w, r, http.StatusUnauthorized, problem.InvalidCredentials)
 	})
 }
 
-func (m *middlewares) UserJSONMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var reader io.Reader
-		if r.Header.Get(`Content-Encoding`) == compressed {
-			gz, err := gzip.NewReader(r.Body)
-			if err != nil {
-				m.l.Printf("Failed to create gzip reader: %v", err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				_, err := w.Write([]byte(fmt.Sprintf("Failed to create gzip reader: %v", err.Error())))
-				if err != nil {
-					m.l.Err(err).Msgf("[UserJSONMiddleware] Responce.Write returned error: %v", err)
-				}
+func (m *middlewares) sessionAuth(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	usr, err := m.r.SessionCheck(token)
+	if err != nil {
+		m.l.Error().Err(err).Msg("failed to check session")
+		problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+		return
+	}
+	if usr == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="restricted", error="invalid_token"`)
+		problem.Write(w, r, http.StatusUnauthorized, problem.SessionInvalid)
+		return
+	}
+	m.l.Debug().Msgf("user '%s' is authorized by session", usr.Login)
+	ctx := context.WithValue(r.Context(), keys.UserContextKey{}, usr)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RoleMiddleware пропускает пользователей с одной из ролей roles; ставится после BasicAuthMiddleware
+func (m *middlewares) RoleMiddleware(roles ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := m.contextUser(w, r)
+			if !ok {
 				return
 			}
-			reader = gz
-			defer gz.Close()
-		} else {
-			reader = r.Body
+			for _, role := range roles {
+				if user.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			m.l.Warn().Msgf("user '%s' with role '%s' is denied %v", user.Login, user.Role, r.RequestURI)
+			problem.Write(w, r, http.StatusForbidden, problem.Forbidden)
+		})
+	}
+}
+
+// RoleJSONMiddleware разбирает назначаемую роль
+func (m *middlewares) RoleJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account := &model.Account{}
+		if !m.decodeJSON(w, r, account) {
+			return
+		}
+		if !knownRole(account.Role) {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.RoleInvalid, account.Role)
+			return
 		}
+		ctx := context.WithValue(r.Context(), keys.RoleContextKey{}, account)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		user := &model.User{}
-		decoder := json.NewDecoder(reader)
+// AdjustmentJSONMiddleware разбирает корректировку баланса; автор - пользователь из BasicAuthMiddleware
+func (m *middlewares) AdjustmentJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := m.contextUser(w, r)
+		if !ok {
+			return
+		}
+		adj := &model.Adjustment{}
+		if !m.decodeJSON(w, r, adj) {
+			return
+		}
+		if adj.Amount == nil || *adj.Amount == 0 {
+			problem.Write(w, r, http.StatusBadRequest, problem.AmountInvalid)
+			return
+		}
+		adj.Reason = strings.TrimSpace(adj.Reason)
+		if adj.Reason == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.ReasonEmpty)
+			return
+		}
+		adj.Reference = strings.TrimSpace(adj.Reference)
+		if adj.Reference == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.ReferenceEmpty)
+			return
+		}
+		adj.ActorID = actor.ID
+		m.l.Printf("Incoming request Method: %v, Adjustment: %v by %v", r.RequestURI, *adj.Amount, actor.Login)
+		ctx := context.WithValue(r.Context(), keys.AdjustmentContextKey{}, adj)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		if err := decoder.Decode(user); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(fmt.Sprintf("[UserJSONMiddleware] Failed to Decode gzip user: %v", err.Error())))
-			if err != nil {
-				m.l.Err(err).Msgf("[UserJSONMiddleware] Responce.Write returned error: %v", err)
-			}
+// ReversalJSONMiddleware разбирает возврат списания; сумма необязательна, причина обязательна
+func (m *middlewares) ReversalJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := m.contextUser(w, r)
+		if !ok {
+			return
+		}
+		rev := &model.Reversal{}
+		if !m.decodeJSON(w, r, rev) {
+			return
+		}
+		if rev.Amount != nil && *rev.Amount <= 0 {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.AmountInvalid, "amount must be positive")
+			return
+		}
+		rev.Reason = strings.TrimSpace(rev.Reason)
+		if rev.Reason == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.ReasonEmpty)
 			return
 		}
+		rev.ActorID = actor.ID
+		m.l.Printf("Incoming request Method: %v, Reversal by %v", r.RequestURI, actor.Login)
+		ctx := context.WithValue(r.Context(), keys.ReversalContextKey{}, rev)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		if user.Login == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(`"user.Login is empty"`))
-			if err != nil {
-				m.l.Err(err).Msgf("[UserJSONMiddleware] Responce.Write returned error: %v", err)
-			}
+// TransferJSONMiddleware разбирает перевод баллов; отправитель - текущий пользователь
+func (m *middlewares) TransferJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := m.contextUser(w, r)
+		if !ok {
 			return
 		}
-		if user.Password == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(`"user.Password is empty"`))
-			if err != nil {
-				m.l.Err(err).Msgf("[UserJSONMiddleware] Responce.Write returned error: %v", err)
+		t := &model.Transfer{}
+		if !m.decodeJSON(w, r, t) {
+			return
+		}
+		t.To = strings.TrimSpace(t.To)
+		if t.To == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.LoginEmpty)
+			return
+		}
+		if t.Amount == nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.WithdrawSumEmpty)
+			return
+		}
+		if *t.Amount <= 0 {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.AmountInvalid, "sum must be positive")
+			return
+		}
+		t.Comment = strings.TrimSpace(t.Comment)
+		t.FromID = user.ID
+		t.From = user.Login
+		m.l.Printf("Incoming request Method: %v, Transfer from %v to %v", r.RequestURI, user.Login, t.To)
+		ctx := context.WithValue(r.Context(), keys.TransferContextKey{}, t)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// WithdrawRulesJSONMiddleware разбирает индивидуальные правила списаний, null - общее правило
+func (m *middlewares) WithdrawRulesJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rules := &model.WithdrawRules{}
+		if !m.decodeJSON(w, r, rules) {
+			return
+		}
+		for _, v := range []*int64{rules.Min, rules.MaxTx, rules.Daily, rules.Monthly, rules.Cooldown} {
+			if v != nil && *v < 0 {
+				problem.WriteDetail(w, r, http.StatusBadRequest, problem.AmountInvalid, "limits must not be negative")
+				return
 			}
+		}
+		ctx := context.WithValue(r.Context(), keys.WithdrawRulesContextKey{}, rules)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// CampaignJSONMiddleware разбирает условия маркетинговой акции
+func (m *middlewares) CampaignJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := &model.Campaign{}
+		if !m.decodeJSON(w, r, c) {
+			return
+		}
+		if c.Condition == "" {
+			c.Condition = model.CampaignAny
+		}
+		if c.Active == nil {
+			c.Active = new(bool)
+			*c.Active = true
+		}
+		detail := ""
+		switch {
+		case c.Name == "":
+			detail = "name is not specified"
+		case c.Kind != model.CampaignMultiplier && c.Kind != model.CampaignFixed:
+			detail = "kind must be 'multiplier' or 'fixed'"
+		case c.Value <= 0 || (c.Kind == model.CampaignMultiplier && c.Value <= 1):
+			detail = "value must be positive, multiplier must be above 1"
+		case c.Condition != model.CampaignAny && c.Condition != model.CampaignFirstOrder:
+			detail = "condition must be 'any' or 'first_order'"
+		case c.MinAccrual < 0:
+			detail = "min_accrual must not be negative"
+		case c.Starts.IsZero() || !c.Ends.After(c.Starts):
+			detail = "ends_at must be after starts_at"
+		}
+		if detail != "" {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.CampaignInvalid, detail)
+			return
+		}
+		ctx := context.WithValue(r.Context(), keys.CampaignContextKey{}, c)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// VoucherBatchJSONMiddleware разбирает условия выпуска партии ваучеров
+func (m *middlewares) VoucherBatchJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, ok := m.contextUser(w, r)
+		if !ok {
+			return
+		}
+		batch := &model.VoucherBatch{}
+		if !m.decodeJSON(w, r, batch) {
+			return
+		}
+		if batch.Amount <= 0 {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.AmountInvalid, "amount must be positive")
+			return
+		}
+		if batch.MaxUses == 0 {
+			batch.MaxUses = 1
+		}
+		batch.Name = strings.TrimSpace(batch.Name)
+		detail := ""
+		switch {
+		case batch.Name == "":
+			detail = "name is not specified"
+		case batch.Count < 1 || batch.Count > voucherBatchMax:
+			detail = "count must be 1.." + strconv.Itoa(voucherBatchMax)
+		case batch.MaxUses < 0:
+			detail = "max_uses must be positive"
+		case batch.Expires != nil && !batch.Expires.After(time.Now()):
+			detail = "expires_at must be in the future"
+		}
+		if detail != "" {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.VoucherBatchInvalid, detail)
+			return
+		}
+		batch.ActorID = actor.ID
+		m.l.Printf("Incoming request Method: %v, Voucher batch: %v by %v", r.RequestURI, batch.Name, actor.Login)
+		ctx := context.WithValue(r.Context(), keys.VoucherBatchContextKey{}, batch)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RedeemJSONMiddleware разбирает код ваучера для погашения
+func (m *middlewares) RedeemJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := m.contextUser(w, r)
+		if !ok {
+			return
+		}
+		red := &model.Redemption{}
+		if !m.decodeJSON(w, r, red) {
+			return
+		}
+		red.Code = utils.VoucherNormalize(red.Code)
+		if red.Code == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.VoucherCodeEmpty)
+			return
+		}
+		red.UserID = user.ID
+		ctx := context.WithValue(r.Context(), keys.RedemptionContextKey{}, red)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RewardJSONMiddleware разбирает награду каталога
+func (m *middlewares) RewardJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &model.Reward{}
+		if !m.decodeJSON(w, r, rw) {
+			return
+		}
+		if rw.Active == nil {
+			rw.Active = new(bool)
+			*rw.Active = true
+		}
+		rw.Name = strings.TrimSpace(rw.Name)
+		rw.Description = strings.TrimSpace(rw.Description)
+		if rw.Name == "" {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.RewardInvalid, "name is not specified")
+			return
+		}
+		if rw.Price <= 0 {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.AmountInvalid, "price must be positive")
+			return
+		}
+		if rw.Stock != nil && *rw.Stock < 0 {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.RewardInvalid, "stock must not be negative")
+			return
+		}
+		ctx := context.WithValue(r.Context(), keys.RewardContextKey{}, rw)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RewardRedeemJSONMiddleware разбирает обмен баллов на награду; номер заказа необязателен
+func (m *middlewares) RewardRedeemJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := m.contextUser(w, r)
+		if !ok {
+			return
+		}
+		red := &model.RewardRedemption{}
+		if !m.decodeJSON(w, r, red) {
+			return
+		}
+		if red.Num != nil && !utils.Valid(*red.Num) {
+			problem.Write(w, r, http.StatusUnprocessableEntity, problem.OrderLuhnInvalid)
+			return
+		}
+		red.UserID = user.ID
+		ctx := context.WithValue(r.Context(), keys.RewardRedemptionContextKey{}, red)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func knownRole(role string) bool {
+	for _, r := range model.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeUser разбирает логин и пароль из тела запроса
+func (m *middlewares) decodeUser(w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	user := &model.User{}
+	if !m.decodeJSON(w, r, user) {
+		return nil, false
+	}
+	if user.Login == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.LoginEmpty)
+		return nil, false
+	}
+	if user.Password == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.PasswordEmpty)
+		return nil, false
+	}
+	return user, true
+}
+
+// UserJSONMiddleware разбирает логин и пароль для входа, политика к ним не применяется
+func (m *middlewares) UserJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := m.decodeUser(w, r)
+		if !ok {
+			return
+		}
+		m.l.Printf("Incoming request Method: %v, Body: %v", r.RequestURI, user.Login)
+		ctx := context.WithValue(r.Context(), keys.UserContextKey{}, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RegisterJSONMiddleware разбирает данные новой учетной записи, проверяет их политикой и считает hash пароля
+func (m *middlewares) RegisterJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := m.decodeUser(w, r)
+		if !ok {
+			return
+		}
+		if err := m.pol.CheckLogin(user.Login); err != nil {
+			m.l.Debug().Msgf("login '%s' rejected by policy: %v", user.Login, err)
+			policyViolation(w, r, err)
+			return
+		}
+		if err := m.pol.CheckPassword(user.Login, user.Password); err != nil {
+			m.l.Debug().Msgf("password for '%s' rejected by policy: %v", user.Login, err)
+			policyViolation(w, r, err)
 			return
 		}
 
 		hash, err := utils.HashPassword(user.Password)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(`"user.Password is not valid, could not create Hash"`))
-			if err != nil {
-				m.l.Err(err).Msgf("[UserJSONMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusBadRequest, problem.PasswordHashFailed)
 			return
 		}
 		user.Hash = hash
@@ -136,74 +565,56 @@ func (m *middlewares) UserJSONMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (m *middlewares) WithdrawJSONMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var reader io.Reader
+var policyCodes = []struct {
+	err  error
+	code problem.Code
+}{
+	{pol.ErrLoginLength, problem.LoginLength},
+	{pol.ErrLoginCharset, problem.LoginCharset},
+	{pol.ErrPasswordShort, problem.PasswordTooShort},
+	{pol.ErrPasswordWeak, problem.PasswordWeak},
+	{pol.ErrPasswordIsLogin, problem.PasswordIsLogin},
+	{pol.ErrPasswordBreached, problem.PasswordBreached},
+}
 
-		user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
-		if !ok {
-			m.l.Error().Msgf(
-				"Error: [UserRegisterHandler] User info not found in context status-'500'",
-			)
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err := w.Write([]byte("Error: [UserRegisterHandler] User info not found in context status-'500'"))
-			if err != nil {
-				m.l.Err(err).Msgf("[WithdrawJSONMiddleware] Responce.Write returned error: %v", err)
-			}
+// policyViolation отвечает 400 с кодом нарушенного требования
+func policyViolation(w http.ResponseWriter, r *http.Request, err error) {
+	for _, c := range policyCodes {
+		if errors.Is(err, c.err) {
+			problem.WriteDetail(w, r, http.StatusBadRequest, c.code, err.Error())
 			return
 		}
+	}
+	problem.WriteDetail(w, r, http.StatusBadRequest, problem.InvalidBody, err.Error())
+}
 
-		if r.Header.Get(`Content-Encoding`) == compressed {
-			gz, err := gzip.NewReader(r.Body)
-			if err != nil {
-				m.l.Printf("Failed to create gzip reader: %v", err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				_, err := w.Write([]byte(fmt.Sprintf("Failed to create gzip reader: %v", err.Error())))
-				if err != nil {
-					m.l.Err(err).Msgf("[WithdrawJSONMiddleware] Responce.Write returned error: %v", err)
-				}
-				return
-			}
-			reader = gz
-			defer gz.Close()
-		} else {
-			reader = r.Body
+func (m *middlewares) WithdrawJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := m.contextUser(w, r)
+		if !ok {
+			return
 		}
 
 		wdr := &model.Withdraw{}
-		decoder := json.NewDecoder(reader)
-
-		if err := decoder.Decode(wdr); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(`"WithdrawJSONMiddleware failed to Decode Withdraw"`))
-			if err != nil {
-				m.l.Err(err).Msgf("[WithdrawJSONMiddleware] Responce.Write returned error: %v", err)
-			}
+		if !m.decodeJSON(w, r, wdr) {
 			return
 		}
 		if wdr.Num == nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(`"Withdraw Number is empty"`))
-			if err != nil {
-				m.l.Err(err).Msgf("[WithdrawJSONMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusBadRequest, problem.WithdrawOrderEmpty)
 			return
 		}
 		if wdr.Expence == nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(`"Withdraw Expence is empty"`))
-			if err != nil {
-				m.l.Err(err).Msgf("[WithdrawJSONMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusBadRequest, problem.WithdrawSumEmpty)
+			return
+		}
+		// отрицательное списание или резерв увеличили бы баланс
+		if *wdr.Expence <= 0 {
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.AmountInvalid, "sum must be positive")
 			return
 		}
 		if !utils.Valid(*wdr.Num) {
 			m.l.Debug().Msgf("Error withraw order num format mismatch on Luhn check: %v", wdr.Num)
-			w.WriteHeader(http.StatusUnprocessableEntity)
-			_, err := w.Write([]byte(fmt.Sprintf("Error withraw order num format mismatch on Luhn check: %v", wdr.Num)))
-			if err != nil {
-				m.l.Err(err).Msgf("[WithdrawJSONMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusUnprocessableEntity, problem.OrderLuhnInvalid)
 			return
 		}
 		wdr.UserID = user.ID
@@ -215,70 +626,36 @@ func (m *middlewares) WithdrawJSONMiddleware(next http.Handler) http.Handler {
 
 func (m *middlewares) OrderTexMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var reader io.Reader
-		user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User)
+		user, ok := m.contextUser(w, r)
 		if !ok {
-			m.l.Error().Msgf(
-				"Error: [UserRegisterHandler] User info not found in context status-'500'",
-			)
-			w.WriteHeader(http.StatusInternalServerError)
-			_, err := w.Write([]byte(`"User info not found in context"`))
-			if err != nil {
-				m.l.Err(err).Msgf("[OrderTexMiddleware] Responce.Write returned error: %v", err)
-			}
 			return
 		}
 
 		if r.Header.Get(`Content-Type`) != "text/plain" {
 			m.l.Error().Msg("LuhnCheckMiddleware: Error reading request.Body, supposed 'text/plain' content type")
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(`LuhnCheckMiddleware: Error reading request.Body, supposed 'text/plain' content type"`))
-			if err != nil {
-				m.l.Err(err).Msgf("[OrderTexMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.WriteDetail(w, r, http.StatusBadRequest, problem.InvalidContentType, "text/plain expected")
 			return
 		}
-		if r.Header.Get(`Content-Encoding`) == compressed {
-			gz, err := gzip.NewReader(r.Body)
-			if err != nil {
-				m.l.Printf("Failed to create gzip reader: %v", err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				_, err := w.Write([]byte(fmt.Sprintf("Failed to create gzip reader: %v", err.Error())))
-				if err != nil {
-					m.l.Err(err).Msgf("[OrderTexMiddleware] Responce.Write returned error: %v", err)
-				}
-				return
-			}
-			reader = gz
-			defer gz.Close()
-		} else {
-			reader = r.Body
+		reader, err := body(r)
+		if err != nil {
+			m.l.Printf("Failed to create gzip reader: %v", err.Error())
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidEncoding)
+			return
 		}
+		defer reader.Close()
 		number, err := io.ReadAll(reader)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(fmt.Sprintf("Failed to retrieve request body from: %v", r.RequestURI)))
-			if err != nil {
-				m.l.Err(err).Msgf("[OrderTexMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusBadRequest, problem.InvalidBody)
 			return
 		}
 		m.l.Debug().Msgf("Retrieved request body: %v", number)
 		orderNum, err := strconv.ParseInt(string(number), 10, 64)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			_, err := w.Write([]byte(fmt.Sprintf("Failed to convert request body to Int64: %s", string(number))))
-			if err != nil {
-				m.l.Err(err).Msgf("[OrderTexMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusBadRequest, problem.OrderNumberInvalid)
 			return
 		}
 		if !utils.Valid(orderNum) {
-			w.WriteHeader(http.StatusConflict)
-			_, err := w.Write([]byte(fmt.Sprintf("Error order format mismatch on Luhn check: %v", string(number))))
-			if err != nil {
-				m.l.Err(err).Msgf("[OrderTexMiddleware] Responce.Write returned error: %v", err)
-			}
+			problem.Write(w, r, http.StatusConflict, problem.OrderLuhnInvalid)
 			return
 		}
 		order := &model.Order{
@@ -291,3 +668,95 @@ func (m *middlewares) OrderTexMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func (m *middlewares) WebhookJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := m.contextUser(w, r)
+		if !ok {
+			return
+		}
+
+		hook := &model.Webhook{}
+		if !m.decodeJSON(w, r, hook) {
+			return
+		}
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.WebhookURLInvalid)
+			return
+		}
+		// имена проверяются при каждом соединении диспетчера, здесь отсекаем очевидное заранее
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if ip := net.ParseIP(host); (ip != nil && !utils.PublicIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			problem.Write(w, r, http.StatusBadRequest, problem.WebhookURLInvalid)
+			return
+		}
+		if len(hook.Events) == 0 {
+			hook.Events = model.Events
+		}
+		for _, e := range hook.Events {
+			if !knownEvent(e) {
+				problem.WriteDetail(w, r, http.StatusBadRequest, problem.WebhookEventUnknown, e)
+				return
+			}
+		}
+		hook.UserID = user.ID
+		m.l.Printf("Incoming request Method: %v, Webhook: %v", r.RequestURI, hook.URL)
+		ctx := context.WithValue(r.Context(), keys.WebhookContextKey{}, hook)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func knownEvent(event string) bool {
+	for _, e := range model.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *middlewares) OTPJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otp := &model.OTP{}
+		if !m.decodeJSON(w, r, otp) {
+			return
+		}
+		if otp.Code == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.OTPRequired)
+			return
+		}
+		ctx := context.WithValue(r.Context(), keys.OTPContextKey{}, otp)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (m *middlewares) PasswordJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		change := &model.PasswordChange{}
+		if !m.decodeJSON(w, r, change) {
+			return
+		}
+		if change.NewPassword == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.PasswordEmpty)
+			return
+		}
+		login := change.Login
+		if user, ok := r.Context().Value(keys.UserContextKey{}).(*model.User); ok {
+			login = user.Login
+		}
+		if err := m.pol.CheckPassword(login, change.NewPassword); err != nil {
+			m.l.Debug().Msgf("new password for '%s' rejected by policy: %v", login, err)
+			policyViolation(w, r, err)
+			return
+		}
+		hash, err := utils.HashPassword(change.NewPassword)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.PasswordHashFailed)
+			return
+		}
+		change.Hash = hash
+		ctx := context.WithValue(r.Context(), keys.PasswordContextKey{}, change)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/lockout"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+	"github.com/rs/zerolog"
+)
+
+type benchRepo struct {
+	user *model.User
+}
+
+func (r *benchRepo) UserLogin(user *model.User) (*model.User, error) {
+	return r.user, nil
+}
+
+func (r *benchRepo) SessionCheck(token string) (*model.User, error) {
+	return nil, nil
+}
+
+type benchConfig struct{}
+
+func (benchConfig) IsDebug() bool {
+	return false
+}
+
+type benchTwoFactor struct{}
+
+func (benchTwoFactor) Enabled(userID string) (bool, error) {
+	return false, nil
+}
+
+type benchGuard struct{}
+
+func (benchGuard) Check(login string, ip string) (lockout.Verdict, error) {
+	return lockout.Verdict{}, nil
+}
+
+func (benchGuard) Fail(login string, ip string) (lockout.Verdict, error) {
+	return lockout.Verdict{}, nil
+}
+
+func (benchGuard) Success(login string) error {
+	return nil
+}
+
+// BenchmarkBasicAuthMiddleware пропускная способность авторизации с кэшем проверенных паролей и без него
+func BenchmarkBasicAuthMiddleware(b *testing.B) {
+	lg := logger.New(benchConfig{})
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	hash, err := utils.HashPassword("secret")
+	if err != nil {
+		b.Fatal(err)
+	}
+	repo := &benchRepo{user: &model.User{ID: "42", Login: "user", Hash: hash}}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, bc := range []struct {
+		name string
+		size int
+	}{
+		{"bcrypt", 0},
+		{"cached", 10000},
+	} {
+		creds := utils.NewCredentials(bc.size, time.Minute)
+		handler := NewMiddlewares(repo, lg, benchGuard{}, creds, nil, benchTwoFactor{}).BasicAuthMiddleware(next)
+		b.Run(bc.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					r := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+					r.SetBasicAuth("user", "secret")
+					w := httptest.NewRecorder()
+					handler.ServeHTTP(w, r)
+					if w.Code != http.StatusOK {
+						b.Fatalf("unexpected status %d", w.Code)
+					}
+				}
+			})
+		})
+	}
+}
+
+type testGuard struct {
+	verdict lockout.Verdict
+}
+
+func (g *testGuard) Check(login string, ip string) (lockout.Verdict, error) {
+	return g.verdict, nil
+}
+
+func (g *testGuard) Fail(login string, ip string) (lockout.Verdict, error) {
+	return lockout.Verdict{}, nil
+}
+
+func (g *testGuard) Success(login string) error {
+	return nil
+}
+
+// TestBasicAuthMiddlewareCache кэш проверенных паролей не обходит блокировку, не сбрасывается
+// чужими неудачными попытками и устаревает вместе с версией учетных данных
+func TestBasicAuthMiddlewareCache(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	hash, err := utils.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &benchRepo{user: &model.User{ID: "42", Login: "user", Hash: hash, Version: 1}}
+	g := &testGuard{}
+	handler := NewMiddlewares(repo, logger.New(benchConfig{}), g, utils.NewCredentials(10, time.Minute), nil, benchTwoFactor{}).
+		BasicAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	auth := func(password string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+		r.SetBasicAuth("user", password)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := auth("secret"); code != http.StatusOK {
+		t.Fatalf("valid password: status %d", code)
+	}
+	g.verdict = lockout.Verdict{Locked: true, Wait: time.Minute}
+	if code := auth("secret"); code != http.StatusLocked {
+		t.Errorf("locked account with cached password: status %d, want %d", code, http.StatusLocked)
+	}
+	g.verdict = lockout.Verdict{}
+	if code := auth("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d, want %d", code, http.StatusUnauthorized)
+	}
+	// хэш больше не подходит: успех возможен только из кэша
+	repo.user.Hash = "broken"
+	if code := auth("secret"); code != http.StatusOK {
+		t.Errorf("cached password after a failed attempt: status %d, want %d", code, http.StatusOK)
+	}
+	repo.user.Version++
+	if code := auth("secret"); code != http.StatusUnauthorized {
+		t.Errorf("cached password after credentials version change: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	pol "github.com/rebus2015/gophermart/cmd/internal/policy"
+	"github.com/rs/zerolog"
+)
+
+type policyConfig struct {
+	breached string
+}
+
+func (policyConfig) GetLoginMinLength() int         { return 3 }
+func (policyConfig) GetLoginMaxLength() int         { return 16 }
+func (policyConfig) GetLoginPattern() string        { return `^[A-Za-z0-9._@-]+$` }
+func (policyConfig) GetPasswordMinLength() int      { return 8 }
+func (policyConfig) GetPasswordMinEntropy() float64 { return 36 }
+func (c policyConfig) GetBreachedPasswords() string { return c.breached }
+
+// TestRegisterJSONMiddlewarePolicy каждое нарушение политики отвечает 400 со своим кодом
+func TestRegisterJSONMiddlewarePolicy(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("Tr0ub4dor&3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := pol.NewPolicy(policyConfig{breached: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMiddlewares(nil, logger.New(benchConfig{}), nil, nil, policy, nil)
+	handler := m.RegisterJSONMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request passed the policy")
+	}))
+	for _, tc := range []struct {
+		name     string
+		login    string
+		password string
+		code     problem.Code
+	}{
+		{"login too short", "ab", "correct-Horse-9", problem.LoginLength},
+		{"login too long", strings.Repeat("a", 17), "correct-Horse-9", problem.LoginLength},
+		{"login charset", "bad login", "correct-Horse-9", problem.LoginCharset},
+		{"password too short", "gopher", "Ab1!xyz", problem.PasswordTooShort},
+		{"password low entropy", "gopher", "12345678", problem.PasswordWeak},
+		{"password equals login", "Alexander1", "alexander1", problem.PasswordIsLogin},
+		{"password breached", "gopher", "Tr0ub4dor&3", problem.PasswordBreached},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, _ := json.Marshal(map[string]string{"login": tc.login, "password": tc.password})
+			r := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(string(payload)))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			var p problem.Problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tc.code {
+				t.Errorf("problem code %s, want %s", p.Code, tc.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/keys"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+func TestWebhookJSONMiddlewareURL(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	m := NewMiddlewares(nil, logger.New(benchConfig{}), nil, nil, nil, nil)
+	handler := m.WebhookJSONMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	for _, tc := range []struct {
+		url  string
+		code int
+	}{
+		{"https://partner.example.com/hook", http.StatusCreated},
+		{"http://93.184.216.34:8080/hook", http.StatusCreated},
+		{"ftp://partner.example.com/hook", http.StatusBadRequest},
+		{"/relative", http.StatusBadRequest},
+		{"http://localhost:8080/hook", http.StatusBadRequest},
+		{"http://api.localhost/hook", http.StatusBadRequest},
+		{"http://127.0.0.1/hook", http.StatusBadRequest},
+		{"http://[::1]/hook", http.StatusBadRequest},
+		{"http://10.0.0.5/hook", http.StatusBadRequest},
+		{"http://172.20.1.1/hook", http.StatusBadRequest},
+		{"http://192.168.0.10/hook", http.StatusBadRequest},
+		{"http://169.254.169.254/latest/meta-data", http.StatusBadRequest},
+		{"http://[fe80::1]/hook", http.StatusBadRequest},
+		{"http://0.0.0.0/hook", http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/user/webhooks", strings.NewReader(`{"url":"`+tc.url+`"}`))
+		r = r.WithContext(context.WithValue(r.Context(), keys.UserContextKey{}, &model.User{ID: "42", Login: "user"}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.url, w.Code, tc.code)
+		}
+	}
+}
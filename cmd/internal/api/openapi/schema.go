@@ -0,0 +1,178 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema подмножество JSON Schema, используемое в спецификации
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	// Extra разрешает в объекте поля, не описанные в Properties
+	Extra bool `json:"additionalProperties"`
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func obj(required []string, props map[string]*Schema) *Schema {
+	return &Schema{Type: "object", Properties: props, Required: required}
+}
+
+func arr(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+func str() *Schema {
+	return &Schema{Type: "string"}
+}
+
+func strf(format string) *Schema {
+	return &Schema{Type: "string", Format: format}
+}
+
+func enum(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
+
+func integer() *Schema {
+	return &Schema{Type: "integer", Format: "int64"}
+}
+
+func number() *Schema {
+	return &Schema{Type: "number"}
+}
+
+func boolean() *Schema {
+	return &Schema{Type: "boolean"}
+}
+
+// nullable разрешает значению s быть null
+func nullable(s *Schema) *Schema {
+	s.Nullable = true
+	return s
+}
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if s.Ref != "" {
+		return json.Marshal(map[string]string{"$ref": s.Ref})
+	}
+	if s.Type != "object" {
+		// additionalProperties имеет смысл только для объектов
+		return json.Marshal(&struct {
+			*plain
+			Extra bool `json:"additionalProperties,omitempty"`
+		}{plain: (*plain)(s)})
+	}
+	return json.Marshal((*plain)(s))
+}
+
+// validate проверяет значение v, полученное json.Decoder с UseNumber, на соответствие схеме
+func (s *Schema) validate(v any, path string, violations *[]string) {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		target, ok := schemas[name]
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: unknown schema %s", path, s.Ref))
+			return
+		}
+		target.validate(v, path, violations)
+		return
+	}
+	if v == nil {
+		if !s.Nullable {
+			*violations = append(*violations, fmt.Sprintf("%s: null is not allowed", path))
+		}
+		return
+	}
+	switch s.Type {
+	case "object":
+		m, ok := v.(map[string]any)
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: object expected", path))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := m[name]; !ok {
+				*violations = append(*violations, fmt.Sprintf("%s.%s: required property is missing", path, name))
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := s.Properties[k]
+			if !ok {
+				if !s.Extra {
+					*violations = append(*violations, fmt.Sprintf("%s.%s: property is not described", path, k))
+				}
+				continue
+			}
+			prop.validate(m[k], path+"."+k, violations)
+		}
+	case "array":
+		a, ok := v.([]any)
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: array expected", path))
+			return
+		}
+		for i, item := range a {
+			s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), violations)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: string expected", path))
+			return
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			*violations = append(*violations, fmt.Sprintf("%s: value %q is not one of %v", path, str, s.Enum))
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				*violations = append(*violations, fmt.Sprintf("%s: RFC3339 date-time expected", path))
+			}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: integer expected", path))
+			return
+		}
+		if _, err := n.Int64(); err != nil {
+			*violations = append(*violations, fmt.Sprintf("%s: integer expected", path))
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: number expected", path))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: boolean expected", path))
+		}
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
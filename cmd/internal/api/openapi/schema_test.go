@@ -0,0 +1,120 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// samples заполненные модели в том виде, в каком их отдают обработчики
+func samples() map[string]any {
+	num := int64(12345678903)
+	sum := int64(500)
+	stock := int64(7)
+	active := true
+	code := 200
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	id := "0b6f5c2e-9d8a-4f3e-8a51-3c1d2e4f5a6b"
+	req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", nil)
+	return map[string]any{
+		"Account":  model.Account{ID: id, Login: "user", Role: model.RoleUser},
+		"Order":    model.Order{Num: &num, Status: "PROCESSED", Accrural: &sum, Ins: now},
+		"Balance":  model.Balance{Current: &sum, Expence: &sum, Held: &sum, Expiring: &sum},
+		"Referral": model.Referral{Code: "ABCD1234", Invited: 2, Rewarded: 1, Earned: 100},
+		"Transfer": model.Transfer{ID: id, From: "user", To: "friend", Amount: &sum, Comment: "gift",
+			Status: model.TransferPending, Ins: now},
+		"WithdrawRules": model.WithdrawRules{Min: &sum, MaxTx: &sum, Daily: &sum, Monthly: &sum, Cooldown: &sum},
+		"Profile": model.Profile{Login: "user", Tier: "silver", Multiplier: 1.5, Accrued: 1000, NextTier: "gold",
+			ToNextTier: &sum, Registered: &now},
+		"Stats": model.Stats{Orders: 3, Processed: 2, Invalid: 1, ProcessedRatio: 0.67, InvalidRatio: 0.33,
+			Accrued: 700, AvgAccrual: 350, Withdrawals: 1, Withdrawn: 500,
+			Months: []model.StatsMonth{{Month: "2024-03", Orders: 3, Processed: 2, Invalid: 1, Accrued: 700,
+				Withdrawals: 1, Withdrawn: 500}}},
+		"Campaign": model.Campaign{ID: id, Name: "spring", Kind: model.CampaignMultiplier, Value: 2,
+			Condition: model.CampaignFirstOrder, MinAccrual: 100, Starts: now, Ends: now.Add(time.Hour),
+			Active: &active, Ins: now},
+		"VoucherBatch": model.VoucherBatch{ID: id, Name: "promo", Amount: 100, Count: 2, MaxUses: 1, Expires: &now,
+			Redeemed: 1, Codes: []string{"AAAA", "BBBB"}, Ins: now},
+		"Redemption": model.Redemption{Code: "AAAA", Amount: 100},
+		"Reward": model.Reward{ID: id, Name: "mug", Description: "a mug", Price: 300, Stock: &stock,
+			Active: &active, Ins: now},
+		"RewardRedemption": model.RewardRedemption{ID: id, RewardID: &id, Name: "mug", Price: 300, Num: &num, Ins: now},
+		"MonthlyStatement": model.MonthlyStatement{ID: id, Period: "2024-02", Opening: 100, Accruals: 700,
+			Withdrawals: 500, Other: 50, Closing: 350, Ins: now},
+		"Hold":       model.Hold{ID: id, Num: num, Amount: sum, Status: model.HoldActive, Expires: now, Ins: now},
+		"Withdrawal": model.Withdraw{Num: &num, Expence: &sum, Ins: now, Status: model.WithdrawReversal},
+		"Webhook": model.Webhook{ID: id, URL: "https://example.com/hook", Events: []string{model.Events[0]},
+			Secret: "secret", Active: true, Ins: now},
+		"Delivery": model.Delivery{ID: 1, WebhookID: id, Event: model.Events[0], Status: "FAILED", Attempts: 3,
+			ResponseCode: &code, LastError: "timeout", NextTry: now, Upd: now},
+		"PasswordReset": model.PasswordReset{Login: "user", Token: "token", Expires: now},
+		"TwoFactor": model.TwoFactor{Secret: "JBSWY3DPEHPK3PXP", URI: "otpauth://totp/gophermart:user?secret=JBSWY3DPEHPK3PXP",
+			RecoveryCodes: []string{"code"}},
+		"Adjustment": model.Adjustment{ID: 1, ActorID: id, Amount: &sum, Reason: "support", Reference: "T-1", Ins: now},
+		"Reversal": model.Reversal{Num: num, Login: "user", ActorID: id, Amount: &sum, Remaining: 0, Reason: "refund",
+			Ins: now},
+		"HistoryEntry":    model.HistoryEntry{Kind: "order", Reference: "12345678903", Amount: 500, Comment: "bonus", Ins: now},
+		"ReportLiability": model.ReportLiability{Outstanding: 1000, Held: 100, Available: 900, Users: 2},
+		"ReportDay": model.ReportDay{Day: "2024-03-01", Orders: 3, Processed: 2, Invalid: 1, Accrued: 700,
+			Withdrawals: 1, Withdrawn: 500, Credited: 50, Debited: 10},
+		"ReportStuck":   model.ReportStuck{Status: "NEW", Age: "under_1h", Orders: 2, Oldest: now},
+		"ReportTopUser": model.ReportTopUser{Login: "user", Orders: 3, Accrued: 700, Withdrawals: 1, Withdrawn: 500},
+		"Problem": &problem.Problem{Type: "urn:gophermart:problem:withdraw_above_max", Title: "limit", Status: 403,
+			Detail: "detail", Instance: req.URL.Path, Code: problem.WithdrawAboveMax, RequestID: "id",
+			Ext: map[string]any{"remaining": 100}},
+	}
+}
+
+// schemaName имя схемы из components, на которую ссылается ответ
+func schemaName(s *Schema) string {
+	if s.Items != nil {
+		s = s.Items
+	}
+	return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+}
+
+func TestResponseSchemasMatchModels(t *testing.T) {
+	used := map[string]struct{}{}
+	for _, op := range operations {
+		for status, r := range op.responses() {
+			if r.Schema == nil || r.ContentType != jsonType && r.ContentType != problemType {
+				continue
+			}
+			name := schemaName(r.Schema)
+			if name == "" {
+				t.Errorf("%s %s %d: response schema is not a component", op.Method, op.Path, status)
+				continue
+			}
+			used[name] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	all := samples()
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			sample, ok := all[name]
+			if !ok {
+				t.Fatalf("no sample model for response schema %s", name)
+			}
+			payload, err := json.Marshal(sample)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			for _, v := range validateJSON(schemas[name], payload, name) {
+				t.Error(v)
+			}
+		})
+	}
+}
@@ -0,0 +1,1037 @@
+// Package openapi описывает HTTP API в формате OpenAPI 3 и проверяет его соответствие роутеру
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+const (
+	jsonType    = "application/json"
+	textType    = "text/plain"
+	problemType = "application/problem+json"
+)
+
+// Response описание ответа операции; Schema == nil - ответ без тела
+type Response struct {
+	Description string
+	Schema      *Schema
+	ContentType string
+	Alternates  []string // другие типы содержимого того же ответа, например форматы выгрузки
+}
+
+// accepts тип содержимого ответа описан в спецификации
+func (r *Response) accepts(mediaType string) bool {
+	if mediaType == r.ContentType {
+		return true
+	}
+	for _, t := range r.Alternates {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// Operation описание одного маршрута роутера
+type Operation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Auth        bool
+	Roles       []string // роли, которым доступна операция; подразумевает Auth
+	RequestType string
+	Request     *Schema
+	Responses   map[int]Response
+}
+
+var (
+	staff     = []string{"support", "admin"}
+	adminOnly = []string{"admin"}
+)
+
+var schemas = map[string]*Schema{
+	"Credentials": obj([]string{"login", "password"}, map[string]*Schema{
+		"login":    str(),
+		"password": str(),
+		"otp":      str(),
+	}),
+	"RegisterRequest": obj([]string{"login", "password"}, map[string]*Schema{
+		"login":         str(),
+		"password":      str(),
+		"referral_code": str(),
+	}),
+	"Referral": obj([]string{"code", "invited", "rewarded", "earned"}, map[string]*Schema{
+		"code":     str(),
+		"invited":  integer(),
+		"rewarded": integer(),
+		"earned":   integer(),
+	}),
+	"Order": obj([]string{"number", "status", "uploaded_at"}, map[string]*Schema{
+		"number":      integer(),
+		"status":      enum("NEW", "PROCESSING", "INVALID", "PROCESSED"),
+		"accrual":     integer(),
+		"uploaded_at": strf("date-time"),
+	}),
+	"Balance": obj([]string{"current", "withdrawn"}, map[string]*Schema{
+		"current":       integer(),
+		"withdrawn":     integer(),
+		"held":          integer(),
+		"expiring_soon": integer(),
+	}),
+	"TransferRequest": obj([]string{"to", "sum"}, map[string]*Schema{
+		"to":      str(),
+		"sum":     integer(),
+		"comment": str(),
+		"otp":     str(),
+	}),
+	"Transfer": obj([]string{"id", "from", "to", "sum", "status", "created_at"}, map[string]*Schema{
+		"id":         strf("uuid"),
+		"from":       str(),
+		"to":         str(),
+		"sum":        integer(),
+		"comment":    str(),
+		"status":     enum("PENDING", "COMPLETED", "DECLINED", "CANCELED"),
+		"created_at": strf("date-time"),
+	}),
+	"WithdrawRules": obj(nil, map[string]*Schema{
+		"min_amount":          nullable(integer()),
+		"max_per_transaction": nullable(integer()),
+		"daily":               nullable(integer()),
+		"monthly":             nullable(integer()),
+		"cooldown_seconds":    nullable(integer()),
+	}),
+	"Profile": obj([]string{"login", "tier", "multiplier", "rolling_accrued"}, map[string]*Schema{
+		"login":           str(),
+		"tier":            str(),
+		"multiplier":      number(),
+		"rolling_accrued": integer(),
+		"next_tier":       str(),
+		"points_to_next":  integer(),
+		"registered_at":   strf("date-time"),
+	}),
+	"Stats": obj([]string{"orders", "processed", "invalid", "processed_ratio", "invalid_ratio", "accrued", "avg_accrual", "withdrawals", "withdrawn", "months"}, map[string]*Schema{
+		"orders":          integer(),
+		"processed":       integer(),
+		"invalid":         integer(),
+		"processed_ratio": number(),
+		"invalid_ratio":   number(),
+		"accrued":         integer(),
+		"avg_accrual":     number(),
+		"withdrawals":     integer(),
+		"withdrawn":       integer(),
+		"months":          arr(ref("StatsMonth")),
+	}),
+	"StatsMonth": obj([]string{"month", "orders", "processed", "invalid", "accrued", "withdrawals", "withdrawn"}, map[string]*Schema{
+		"month":       str(),
+		"orders":      integer(),
+		"processed":   integer(),
+		"invalid":     integer(),
+		"accrued":     integer(),
+		"withdrawals": integer(),
+		"withdrawn":   integer(),
+	}),
+	"Campaign": obj([]string{"name", "kind", "value", "starts_at", "ends_at"}, map[string]*Schema{
+		"id":          strf("uuid"),
+		"name":        str(),
+		"kind":        enum("multiplier", "fixed"),
+		"value":       number(),
+		"condition":   enum("any", "first_order"),
+		"min_accrual": integer(),
+		"starts_at":   strf("date-time"),
+		"ends_at":     strf("date-time"),
+		"active":      boolean(),
+		"created_at":  strf("date-time"),
+	}),
+	"VoucherBatchRequest": obj([]string{"name", "amount", "count"}, map[string]*Schema{
+		"name":       str(),
+		"amount":     integer(),
+		"count":      integer(),
+		"max_uses":   integer(),
+		"expires_at": strf("date-time"),
+	}),
+	"VoucherBatch": obj([]string{"id", "name", "amount", "count", "max_uses", "redeemed", "created_at"}, map[string]*Schema{
+		"id":         strf("uuid"),
+		"name":       str(),
+		"amount":     integer(),
+		"count":      integer(),
+		"max_uses":   integer(),
+		"expires_at": strf("date-time"),
+		"redeemed":   integer(),
+		"codes":      arr(str()),
+		"created_at": strf("date-time"),
+	}),
+	"RedeemRequest": obj([]string{"code"}, map[string]*Schema{
+		"code": str(),
+	}),
+	"Redemption": obj([]string{"code", "amount"}, map[string]*Schema{
+		"code":   str(),
+		"amount": integer(),
+	}),
+	"RewardRequest": obj([]string{"name", "price"}, map[string]*Schema{
+		"name":        str(),
+		"description": str(),
+		"price":       integer(),
+		"stock":       nullable(integer()),
+		"active":      boolean(),
+	}),
+	"ReportLiability": obj([]string{"outstanding", "held", "available", "users"}, map[string]*Schema{
+		"outstanding": integer(),
+		"held":        integer(),
+		"available":   integer(),
+		"users":       integer(),
+	}),
+	"ReportDay": obj([]string{"day", "orders", "processed", "invalid", "accrued", "withdrawals", "withdrawn", "credited", "debited"}, map[string]*Schema{
+		"day":         strf("date"),
+		"orders":      integer(),
+		"processed":   integer(),
+		"invalid":     integer(),
+		"accrued":     integer(),
+		"withdrawals": integer(),
+		"withdrawn":   integer(),
+		"credited":    integer(),
+		"debited":     integer(),
+	}),
+	"ReportStuck": obj([]string{"status", "age", "orders", "oldest"}, map[string]*Schema{
+		"status": enum("NEW", "PROCESSING"),
+		"age":    enum("under_1h", "1h_1d", "1d_7d", "over_7d"),
+		"orders": integer(),
+		"oldest": strf("date-time"),
+	}),
+	"ReportTopUser": obj([]string{"login", "orders", "accrued", "withdrawals", "withdrawn"}, map[string]*Schema{
+		"login":       str(),
+		"orders":      integer(),
+		"accrued":     integer(),
+		"withdrawals": integer(),
+		"withdrawn":   integer(),
+	}),
+	"Reward": obj([]string{"id", "name", "price", "stock", "active", "created_at"}, map[string]*Schema{
+		"id":          strf("uuid"),
+		"name":        str(),
+		"description": str(),
+		"price":       integer(),
+		"stock":       nullable(integer()),
+		"active":      boolean(),
+		"created_at":  strf("date-time"),
+	}),
+	"RewardRedeemRequest": obj(nil, map[string]*Schema{
+		"order": integer(),
+		"otp":   str(),
+	}),
+	"RewardRedemption": obj([]string{"id", "reward_id", "name", "price", "order", "created_at"}, map[string]*Schema{
+		"id":         strf("uuid"),
+		"reward_id":  nullable(strf("uuid")),
+		"name":       str(),
+		"price":      integer(),
+		"order":      integer(),
+		"created_at": strf("date-time"),
+	}),
+	"MonthlyStatement": obj([]string{"id", "period", "opening_balance", "accruals", "withdrawals", "other", "closing_balance", "created_at"}, map[string]*Schema{
+		"id":              strf("uuid"),
+		"period":          str(),
+		"opening_balance": integer(),
+		"accruals":        integer(),
+		"withdrawals":     integer(),
+		"other":           integer(),
+		"closing_balance": integer(),
+		"created_at":      strf("date-time"),
+	}),
+	"Hold": obj([]string{"id", "order", "sum", "status", "expires_at", "created_at"}, map[string]*Schema{
+		"id":         strf("uuid"),
+		"order":      integer(),
+		"sum":        integer(),
+		"status":     enum("ACTIVE", "CAPTURED", "VOIDED", "EXPIRED"),
+		"expires_at": strf("date-time"),
+		"created_at": strf("date-time"),
+	}),
+	"WithdrawRequest": obj([]string{"order", "sum"}, map[string]*Schema{
+		"order": integer(),
+		"sum":   integer(),
+		"otp":   str(),
+	}),
+	"Withdrawal": obj([]string{"order", "sum", "processed_at"}, map[string]*Schema{
+		"order":        integer(),
+		"sum":          integer(),
+		"processed_at": strf("date-time"),
+		"status":       enum("PROCESSED", "PARTIALLY_REVERSED", "REVERSED", "REVERSAL"),
+	}),
+	"WebhookRequest": obj([]string{"url"}, map[string]*Schema{
+		"url":    strf("uri"),
+		"events": arr(enum(model.Events...)),
+	}),
+	"Webhook": obj([]string{"id", "url", "events", "active", "created_at"}, map[string]*Schema{
+		"id":         strf("uuid"),
+		"url":        strf("uri"),
+		"events":     arr(str()),
+		"secret":     str(),
+		"active":     boolean(),
+		"created_at": strf("date-time"),
+	}),
+	"Delivery": obj([]string{"id", "webhook_id", "event", "status", "attempts", "next_try", "updated_at"}, map[string]*Schema{
+		"id":            integer(),
+		"webhook_id":    strf("uuid"),
+		"event":         str(),
+		"status":        enum("PENDING", "DELIVERED", "FAILED"),
+		"attempts":      integer(),
+		"response_code": integer(),
+		"last_error":    str(),
+		"next_try":      strf("date-time"),
+		"updated_at":    strf("date-time"),
+	}),
+	"PasswordChange": obj([]string{"old_password", "new_password"}, map[string]*Schema{
+		"old_password": str(),
+		"new_password": str(),
+	}),
+	"PasswordResetRequest": obj([]string{"login", "token", "new_password"}, map[string]*Schema{
+		"login":        str(),
+		"token":        str(),
+		"new_password": str(),
+	}),
+	"PasswordReset": obj([]string{"login", "token", "expires_at"}, map[string]*Schema{
+		"login":      str(),
+		"token":      str(),
+		"expires_at": strf("date-time"),
+	}),
+	"TwoFactor": obj([]string{"secret", "otpauth_uri", "recovery_codes"}, map[string]*Schema{
+		"secret":         str(),
+		"otpauth_uri":    strf("uri"),
+		"recovery_codes": arr(str()),
+	}),
+	"OTP": obj([]string{"otp"}, map[string]*Schema{
+		"otp": str(),
+	}),
+	"Account": obj([]string{"id", "login", "role"}, map[string]*Schema{
+		"id":    strf("uuid"),
+		"login": str(),
+		"role":  enum("user", "support", "admin"),
+	}),
+	"RoleRequest": obj([]string{"role"}, map[string]*Schema{
+		"role": enum("user", "support", "admin"),
+	}),
+	"AdjustmentRequest": obj([]string{"amount", "reason", "reference"}, map[string]*Schema{
+		"amount":    integer(),
+		"reason":    str(),
+		"reference": str(),
+	}),
+	"Adjustment": obj([]string{"id", "actor_id", "amount", "reason", "reference", "created_at"}, map[string]*Schema{
+		"id":         integer(),
+		"actor_id":   strf("uuid"),
+		"amount":     integer(),
+		"reason":     str(),
+		"reference":  str(),
+		"created_at": strf("date-time"),
+	}),
+	"ReversalRequest": obj([]string{"reason"}, map[string]*Schema{
+		"amount": integer(),
+		"reason": str(),
+		"login":  str(),
+	}),
+	"Reversal": obj([]string{"order", "actor_id", "amount", "remaining", "reason", "created_at"}, map[string]*Schema{
+		"order":      integer(),
+		"login":      str(),
+		"actor_id":   strf("uuid"),
+		"amount":     integer(),
+		"remaining":  integer(),
+		"reason":     str(),
+		"created_at": strf("date-time"),
+	}),
+	"HistoryEntry": obj([]string{"kind", "reference", "amount", "processed_at"}, map[string]*Schema{
+		"kind":         str(),
+		"reference":    str(),
+		"amount":       integer(),
+		"comment":      str(),
+		"processed_at": strf("date-time"),
+	}),
+	"Problem": {
+		Type:     "object",
+		Required: []string{"type", "title", "status", "code"},
+		Extra:    true,
+		Properties: map[string]*Schema{
+			"type":       str(),
+			"title":      str(),
+			"status":     integer(),
+			"detail":     str(),
+			"instance":   str(),
+			"code":       str(),
+			"request_id": str(),
+		},
+	},
+}
+
+func ok(description string, s *Schema) Response {
+	return Response{Description: description, Schema: s, ContentType: jsonType}
+}
+
+func empty(description string) Response {
+	return Response{Description: description}
+}
+
+// transferClosed ответы на решение по ожидающему переводу
+func transferClosed() map[int]Response {
+	return map[int]Response{
+		200: ok("transfer with the new status", ref("Transfer")),
+		400: fail("malformed id"),
+		404: fail("transfer not found or you are not the party that may do this"),
+		409: fail("transfer is not pending"),
+	}
+}
+
+// file выгрузка без схемы в одном из форматов types
+func file(description string, types ...string) Response {
+	return Response{Description: description, ContentType: types[0], Alternates: types[1:]}
+}
+
+// report JSON по схеме или та же таблица в CSV
+func report(description string, s *Schema) Response {
+	return Response{Description: description, Schema: s, ContentType: jsonType, Alternates: []string{"text/csv"}}
+}
+
+func fail(description string) Response {
+	return Response{Description: description, Schema: ref("Problem"), ContentType: problemType}
+}
+
+var operations = []Operation{
+	{
+		Method: http.MethodGet, Path: "/api/openapi.json", Summary: "OpenAPI specification",
+		Responses: map[int]Response{200: {Description: "specification", ContentType: jsonType}},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/register", Summary: "Register a user",
+		RequestType: jsonType, Request: ref("RegisterRequest"),
+		Responses: map[int]Response{
+			200: empty("registered, session token in Authorization header; referral bonuses are credited after the first processed order"),
+			400: fail("malformed request, login or password violates the policy, unknown referral code"),
+			409: fail("login is taken"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/login", Summary: "Authenticate a user",
+		RequestType: jsonType, Request: ref("Credentials"),
+		Responses: map[int]Response{
+			200: empty("authenticated, session token in Authorization header"),
+			400: fail("malformed request"),
+			401: fail("invalid login or password, one-time code is required or invalid"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/password/reset", Summary: "Set a new password with a reset token",
+		RequestType: jsonType, Request: ref("PasswordResetRequest"),
+		Responses: map[int]Response{
+			200: empty("password changed, all sessions revoked"),
+			400: fail("malformed request, invalid token or new password violates the policy"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/password", Summary: "Change password", Auth: true,
+		RequestType: jsonType, Request: ref("PasswordChange"),
+		Responses: map[int]Response{
+			200: empty("password changed, other sessions revoked, new session token in Authorization header"),
+			400: fail("malformed request or new password violates the policy"),
+			403: fail("current password is wrong"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/2fa", Summary: "Start TOTP enrollment", Auth: true,
+		Responses: map[int]Response{
+			201: ok("secret, otpauth URI and recovery codes, returned only once", ref("TwoFactor")),
+			409: fail("2FA is already enabled"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/2fa/confirm", Summary: "Enable 2FA with the first one-time code", Auth: true,
+		RequestType: jsonType, Request: ref("OTP"),
+		Responses: map[int]Response{
+			200: empty("2FA enabled, password-only Basic auth is refused from now on"),
+			400: fail("malformed request or invalid code"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/2fa/disable", Summary: "Disable 2FA", Auth: true,
+		RequestType: jsonType, Request: ref("OTP"),
+		Responses: map[int]Response{
+			204: empty("2FA disabled"),
+			400: fail("malformed request or invalid code"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/orders", Summary: "Upload an order number", Auth: true,
+		RequestType: textType, Request: str(),
+		Responses: map[int]Response{
+			200: empty("already uploaded by this user"),
+			202: empty("accepted for processing"),
+			400: fail("malformed request"),
+			401: fail("not authenticated"),
+			409: fail("uploaded by another user or fails the Luhn check"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/orders", Summary: "List uploaded orders", Auth: true,
+		Responses: map[int]Response{
+			200: ok("orders, oldest first", arr(ref("Order"))),
+			204: empty("no orders"),
+			401: fail("not authenticated"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/balance", Summary: "Current balance", Auth: true,
+		Responses: map[int]Response{
+			200: ok("balance", ref("Balance")),
+			401: fail("not authenticated"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/withdraw", Summary: "Withdraw points", Auth: true,
+		RequestType: jsonType, Request: ref("WithdrawRequest"),
+		Responses: map[int]Response{
+			200: empty("withdrawn"),
+			400: fail("malformed request"),
+			401: fail("not authenticated"),
+			402: fail("not enough points"),
+			403: fail("2FA is required for the sum, or a withdrawal rule is violated (remaining allowance in the problem)"),
+			409: fail("points are already withdrawn for this order number"),
+			422: fail("order number fails the Luhn check"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/balance/withdrawals", Summary: "List withdrawals", Auth: true,
+		Responses: map[int]Response{
+			200: ok("withdrawals and their reversals, oldest first", arr(ref("Withdrawal"))),
+			204: empty("no withdrawals"),
+			401: fail("not authenticated"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/profile", Summary: "Loyalty profile and tier", Auth: true,
+		Responses: map[int]Response{
+			200: ok("profile; the tier multiplier applies to new accruals", ref("Profile")),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/stats", Summary: "Order and withdrawal statistics", Auth: true,
+		Responses: map[int]Response{
+			200: ok("all-time totals and ratios, months (YYYY-MM) with activity newest first; cached for a few minutes", ref("Stats")),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/rewards", Summary: "Rewards catalog", Auth: true,
+		Responses: map[int]Response{
+			200: ok("available rewards by price, stock null means unlimited", arr(ref("Reward"))),
+			204: empty("catalog is empty"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/rewards/{id}/redeem", Summary: "Exchange points for a reward", Auth: true,
+		RequestType: jsonType, Request: ref("RewardRedeemRequest"),
+		Responses: map[int]Response{
+			201: ok("redemption; the price is withdrawn under the given or a generated order number", ref("RewardRedemption")),
+			400: fail("malformed request or id"),
+			402: fail("not enough points"),
+			403: fail("price is above the 2FA threshold and one-time code is missing or invalid, or a withdrawal rule refused"),
+			404: fail("reward not found or not available"),
+			409: fail("out of stock or points are already withdrawn for the order number"),
+			422: fail("order number fails the Luhn check"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/rewards/redemptions", Summary: "Reward redemption history", Auth: true,
+		Responses: map[int]Response{
+			200: ok("redemptions, newest first", arr(ref("RewardRedemption"))),
+			204: empty("no redemptions"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/statement", Summary: "Download account statement", Auth: true,
+		Responses: map[int]Response{
+			200: file("orders, accruals, withdrawals and other movements with running balance, oldest first, streamed; "+
+				"query parameters from, to (RFC 3339 or YYYY-MM-DD, to defaults to now), format csv (default) or jsonl",
+				"text/csv", "application/x-ndjson"),
+			400: fail("invalid period or format"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/statements", Summary: "List monthly statements", Auth: true,
+		Responses: map[int]Response{
+			200: ok("statements generated after each month ends, newest first; period is YYYY-MM", arr(ref("MonthlyStatement"))),
+			204: empty("no statements yet"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/statements/{id}", Summary: "Download monthly statement", Auth: true,
+		Responses: map[int]Response{
+			200: file("statement document", "application/pdf"),
+			400: fail("malformed id"),
+			404: fail("statement not found"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/referral", Summary: "Referral code and invitations", Auth: true,
+		Responses: map[int]Response{
+			200: ok("referral code to share at registration and invitation totals", ref("Referral")),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/balance/history", Summary: "Points movements history", Auth: true,
+		Responses: map[int]Response{
+			200: ok("accruals, campaign and referral bonuses, vouchers, withdrawals, reversals, adjustments, transfers and expirations, oldest first", arr(ref("HistoryEntry"))),
+			204: empty("no movements"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/balance/holds", Summary: "List active holds", Auth: true,
+		Responses: map[int]Response{
+			200: ok("active holds, oldest first", arr(ref("Hold"))),
+			204: empty("no active holds"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/holds", Summary: "Hold points for an order until payment completes", Auth: true,
+		RequestType: jsonType, Request: ref("WithdrawRequest"),
+		Responses: map[int]Response{
+			201: ok("hold; held points are excluded from the current balance until capture, void or expiry", ref("Hold")),
+			400: fail("malformed request or non-positive sum"),
+			402: fail("not enough points"),
+			403: fail("2FA is required for the sum, or a withdrawal rule is violated (remaining allowance in the problem)"),
+			409: fail("points for the order are already held or withdrawn"),
+			422: fail("order number fails the Luhn check"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/holds/{id}/capture", Summary: "Turn a hold into a withdrawal", Auth: true,
+		Responses: map[int]Response{
+			200: ok("captured hold", ref("Hold")),
+			400: fail("malformed id"),
+			404: fail("hold not found"),
+			409: fail("hold is not active, or the order is already withdrawn"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/holds/{id}/void", Summary: "Release a hold", Auth: true,
+		Responses: map[int]Response{
+			204: empty("released"),
+			400: fail("malformed id"),
+			404: fail("hold not found"),
+			409: fail("hold is not active"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/transfer", Summary: "Transfer points to another user", Auth: true,
+		RequestType: jsonType, Request: ref("TransferRequest"),
+		Responses: map[int]Response{
+			201: ok("transfer; large sums stay PENDING until the recipient accepts", ref("Transfer")),
+			400: fail("malformed request, empty recipient, non-positive sum or transfer to yourself"),
+			402: fail("not enough points"),
+			403: fail("sum is above the 2FA threshold and one-time code is missing or invalid"),
+			404: fail("recipient not found"),
+			409: fail("daily transfer limit exceeded"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/redeem", Summary: "Redeem a voucher code", Auth: true,
+		RequestType: jsonType, Request: ref("RedeemRequest"),
+		Responses: map[int]Response{
+			200: ok("points credited", ref("Redemption")),
+			400: fail("malformed request or empty code"),
+			404: fail("code not found; repeated failures are throttled"),
+			409: fail("code is used up or already redeemed by you"),
+			410: fail("code has expired"),
+			429: fail("too many invalid codes, retry later"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/balance/transfers", Summary: "List incoming and outgoing transfers", Auth: true,
+		Responses: map[int]Response{
+			200: ok("transfers, newest first", arr(ref("Transfer"))),
+			204: empty("no transfers"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/transfers/{id}/accept", Summary: "Accept a pending incoming transfer", Auth: true,
+		Responses: transferClosed(),
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/transfers/{id}/decline", Summary: "Decline a pending incoming transfer", Auth: true,
+		Responses: transferClosed(),
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/balance/transfers/{id}/cancel", Summary: "Cancel a pending outgoing transfer", Auth: true,
+		Responses: transferClosed(),
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/webhooks", Summary: "Register a webhook", Auth: true,
+		RequestType: jsonType, Request: ref("WebhookRequest"),
+		Responses: map[int]Response{
+			201: ok("registered, secret is returned only once", ref("Webhook")),
+			400: fail("malformed request, unknown event or URL that is not a public http(s) host"),
+			401: fail("not authenticated"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/webhooks", Summary: "List webhooks", Auth: true,
+		Responses: map[int]Response{
+			200: ok("webhooks", arr(ref("Webhook"))),
+			204: empty("no webhooks"),
+			401: fail("not authenticated"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/user/webhooks/{id}", Summary: "Delete a webhook", Auth: true,
+		Responses: map[int]Response{
+			204: empty("deleted"),
+			400: fail("malformed id"),
+			401: fail("not authenticated"),
+			404: fail("not found"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/user/webhooks/{id}/deliveries", Summary: "Webhook delivery log", Auth: true,
+		Responses: map[int]Response{
+			200: ok("deliveries, newest first", arr(ref("Delivery"))),
+			204: empty("no deliveries"),
+			400: fail("malformed id"),
+			401: fail("not authenticated"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/user/webhooks/deliveries/{id}/redeliver", Summary: "Redeliver a webhook event", Auth: true,
+		Responses: map[int]Response{
+			202: empty("scheduled"),
+			400: fail("malformed id"),
+			401: fail("not authenticated"),
+			404: fail("not found"),
+			500: fail("internal error"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/admin/users/{login}/password-reset", Summary: "Issue a password reset token", Roles: adminOnly,
+		Responses: map[int]Response{
+			201: ok("one-time reset token", ref("PasswordReset")),
+			404: fail("user not found"),
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/admin/users/{login}/lockout", Summary: "Unlock a login after failed attempts", Roles: adminOnly,
+		Responses: map[int]Response{
+			204: empty("unlocked"),
+			404: fail("no failed attempts for the login"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/users", Summary: "Search users by part of the login", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("users ordered by login; query parameters q, limit (1..500, default 50), offset", arr(ref("Account"))),
+			204: empty("nothing found"),
+			400: fail("invalid limit or offset"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/users/{login}", Summary: "User account", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("account", ref("Account")),
+			404: fail("user not found"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/users/{login}/orders", Summary: "User orders", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("orders, oldest first", arr(ref("Order"))),
+			204: empty("no orders"),
+			404: fail("user not found"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/users/{login}/withdrawals", Summary: "User withdrawals", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("withdrawals, oldest first", arr(ref("Withdrawal"))),
+			204: empty("no withdrawals"),
+			404: fail("user not found"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/users/{login}/balance", Summary: "User balance", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("balance", ref("Balance")),
+			404: fail("user not found"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/users/{login}/withdraw-limits", Summary: "User withdrawal rules", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("per-user rules, null means the global rule applies", ref("WithdrawRules")),
+			404: fail("user not found"),
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/admin/users/{login}/withdraw-limits", Summary: "Replace user withdrawal rules", Roles: adminOnly,
+		RequestType: jsonType, Request: ref("WithdrawRules"),
+		Responses: map[int]Response{
+			200: ok("per-user rules, null or missing means the global rule applies, 0 disables the rule", ref("WithdrawRules")),
+			400: fail("malformed request or negative value"),
+			404: fail("user not found"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/campaigns", Summary: "Promotional campaigns", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("campaigns, latest start first", arr(ref("Campaign"))),
+			204: empty("no campaigns"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/admin/campaigns", Summary: "Create a promotional campaign", Roles: adminOnly,
+		RequestType: jsonType, Request: ref("Campaign"),
+		Responses: map[int]Response{
+			201: ok("campaign; bonuses apply to orders processed within the period", ref("Campaign")),
+			400: fail("malformed request or invalid terms"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/vouchers", Summary: "Voucher batches", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("batches with redemption counts, newest first", arr(ref("VoucherBatch"))),
+			204: empty("no batches"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/admin/vouchers", Summary: "Issue a batch of voucher codes", Roles: adminOnly,
+		RequestType: jsonType, Request: ref("VoucherBatchRequest"),
+		Responses: map[int]Response{
+			201: ok("batch; codes are returned only once, max_uses 1 makes single-use codes", ref("VoucherBatch")),
+			400: fail("malformed request or invalid terms"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/rewards", Summary: "Rewards catalog including inactive", Roles: staff,
+		Responses: map[int]Response{
+			200: ok("rewards by price", arr(ref("Reward"))),
+			204: empty("catalog is empty"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/admin/rewards", Summary: "Add a reward to the catalog", Roles: adminOnly,
+		RequestType: jsonType, Request: ref("RewardRequest"),
+		Responses: map[int]Response{
+			201: ok("reward", ref("Reward")),
+			400: fail("malformed request, empty name, non-positive price or negative stock"),
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/admin/rewards/{id}", Summary: "Replace a reward, including its stock", Roles: adminOnly,
+		RequestType: jsonType, Request: ref("RewardRequest"),
+		Responses: map[int]Response{
+			200: ok("reward", ref("Reward")),
+			400: fail("malformed request, id, empty name, non-positive price or negative stock"),
+			404: fail("not found"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/reports/liability", Summary: "Outstanding points liability", Roles: adminOnly,
+		Responses: map[int]Response{
+			200: report("unspent points of all users including holds; query parameter format json (default) or csv", ref("ReportLiability")),
+			400: fail("invalid format"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/reports/daily", Summary: "Accruals and withdrawals per day", Roles: adminOnly,
+		Responses: map[int]Response{
+			200: report("daily totals refreshed in the background every few minutes, oldest first; credited and debited are other ledger movements. "+
+				"Query parameters from, to (RFC 3339 or YYYY-MM-DD, default the last 30 days), format json (default) or csv", arr(ref("ReportDay"))),
+			204: empty("no activity in the period"),
+			400: fail("invalid period or format"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/reports/stuck-orders", Summary: "Orders waiting for accrual by age", Roles: adminOnly,
+		Responses: map[int]Response{
+			200: report("NEW and PROCESSING orders grouped by status and age; query parameter format json (default) or csv", arr(ref("ReportStuck"))),
+			204: empty("no pending orders"),
+			400: fail("invalid format"),
+		},
+	},
+	{
+		Method: http.MethodGet, Path: "/api/admin/reports/top-users", Summary: "Users with the most points accrued or withdrawn", Roles: adminOnly,
+		Responses: map[int]Response{
+			200: report("users ordered by points accrued or withdrawn in the period. Query parameters from, to (RFC 3339 or YYYY-MM-DD, "+
+				"default the last 30 days), by accrued (default) or withdrawn, limit (default 20, max 1000), format json (default) or csv", arr(ref("ReportTopUser"))),
+			204: empty("no activity in the period"),
+			400: fail("invalid period, ordering, limit or format"),
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/admin/campaigns/{id}", Summary: "Replace campaign terms", Roles: adminOnly,
+		RequestType: jsonType, Request: ref("Campaign"),
+		Responses: map[int]Response{
+			200: ok("campaign", ref("Campaign")),
+			400: fail("malformed request, id or invalid terms"),
+			404: fail("not found"),
+		},
+	},
+	{
+		Method: http.MethodDelete, Path: "/api/admin/campaigns/{id}", Summary: "Delete a campaign, granted bonuses stay", Roles: adminOnly,
+		Responses: map[int]Response{
+			204: empty("deleted"),
+			400: fail("malformed id"),
+			404: fail("not found"),
+		},
+	},
+	{
+		Method: http.MethodPut, Path: "/api/admin/users/{login}/role", Summary: "Grant a role", Roles: adminOnly,
+		RequestType: jsonType, Request: ref("RoleRequest"),
+		Responses: map[int]Response{
+			204: empty("role granted"),
+			400: fail("malformed request or unknown role"),
+			404: fail("user not found"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/admin/users/{login}/adjustments", Summary: "Credit or debit user points", Roles: staff,
+		RequestType: jsonType, Request: ref("AdjustmentRequest"),
+		Responses: map[int]Response{
+			201: ok("adjustment, recorded in the audit log with the acting user", ref("Adjustment")),
+			400: fail("malformed request, zero amount, empty reason or reference"),
+			402: fail("debit exceeds the balance"),
+			404: fail("user not found"),
+			409: fail("reference is already used for this user"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/admin/withdrawals/{order}/reverse", Summary: "Return withdrawn points, fully or partially", Roles: staff,
+		RequestType: jsonType, Request: ref("ReversalRequest"),
+		Responses: map[int]Response{
+			201: ok("reversal; without amount the whole remaining sum is returned", ref("Reversal")),
+			400: fail("malformed request or order number, non-positive amount, empty reason"),
+			404: fail("withdrawal not found"),
+			409: fail("order number is ambiguous without login, or amount exceeds the remaining sum"),
+		},
+	},
+	{
+		Method: http.MethodPost, Path: "/api/admin/orders/{number}/repoll", Summary: "Poll the accrual system for an order again", Roles: staff,
+		Responses: map[int]Response{
+			202: empty("scheduled"),
+			400: fail("malformed order number"),
+			404: fail("order not found"),
+			409: fail("order is already PROCESSED, its accrual is not recalculated"),
+		},
+	},
+}
+
+// responses дополняет ответы операции общими для всех маршрутов
+func (op *Operation) responses() map[int]Response {
+	list := map[int]Response{500: fail("internal error")}
+	if op.Auth || len(op.Roles) > 0 {
+		list[400] = fail("credentials are missing")
+		list[401] = fail("not authenticated")
+		list[423] = fail("account is locked after repeated failures")
+		list[429] = fail("too many failed attempts")
+	}
+	if len(op.Roles) > 0 {
+		list[403] = fail("role is not allowed")
+	}
+	for code, r := range op.Responses {
+		list[code] = r
+	}
+	return list
+}
+
+// Document собирает OpenAPI документ из описания операций
+func Document() map[string]any {
+	paths := map[string]map[string]any{}
+	for _, op := range operations {
+		item, ok := paths[op.Path]
+		if !ok {
+			item = map[string]any{}
+			paths[op.Path] = item
+		}
+		o := map[string]any{
+			"summary":   op.Summary,
+			"responses": responses(op.responses()),
+		}
+		if params := pathParams(op.Path); len(params) > 0 {
+			o["parameters"] = params
+		}
+		if op.Auth || len(op.Roles) > 0 {
+			o["security"] = []map[string][]string{{"basicAuth": {}}, {"bearerAuth": {}}}
+		}
+		if len(op.Roles) > 0 {
+			o["x-roles"] = op.Roles
+		}
+		if op.Request != nil {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{op.RequestType: map[string]any{"schema": op.Request}},
+			}
+		}
+		item[strings.ToLower(op.Method)] = o
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Gophermart loyalty system",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"basicAuth":  map[string]string{"type": "http", "scheme": "basic"},
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+func responses(list map[int]Response) map[string]any {
+	res := map[string]any{}
+	for code, r := range list {
+		item := map[string]any{"description": r.Description}
+		if r.ContentType != "" {
+			content := map[string]any{}
+			if r.Schema != nil {
+				content["schema"] = r.Schema
+			}
+			types := map[string]any{r.ContentType: content}
+			for _, t := range r.Alternates {
+				types[t] = map[string]any{}
+			}
+			item["content"] = types
+		}
+		res[strconv.Itoa(code)] = item
+	}
+	return res
+}
+
+func pathParams(path string) []map[string]any {
+	var params []map[string]any
+	for _, seg := range segments(path) {
+		if name, ok := param(seg); ok {
+			params = append(params, map[string]any{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   str(),
+			})
+		}
+	}
+	return params
+}
+
+// Handler отдает спецификацию
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", jsonType)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(Document())
+}
@@ -0,0 +1,151 @@
+package openapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+)
+
+// ViolationHeader помечает ответ, запрос или ответ которого не соответствует спецификации
+const ViolationHeader = "X-Contract-Violation"
+
+// Validator middleware для режима разработки: сверяет запросы и ответы со спецификацией
+// и сообщает о нарушениях в лог и заголовке ответа, не меняя поведение API
+func Validator(lg *logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := find(r.Method, r.URL.Path)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			var violations []string
+			if op.Request != nil {
+				violations = validateRequest(op, r)
+			}
+			rec := &recorder{ResponseWriter: w, op: op, request: violations}
+			next.ServeHTTP(rec, r)
+			rec.finish()
+			for _, v := range append(rec.request, rec.response...) {
+				lg.Warn().Msgf("contract violation %s %s: %s", r.Method, op.Path, v)
+			}
+		})
+	}
+}
+
+func validateRequest(op *Operation, r *http.Request) []string {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return []string{"request body could not be read: " + err.Error()}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != op.RequestType {
+		return []string{"request content type " + mediaType + " expected " + op.RequestType}
+	}
+	if op.RequestType != jsonType {
+		return nil
+	}
+	payload := raw
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return []string{"request body is not valid gzip"}
+		}
+		defer gz.Close()
+		if payload, err = io.ReadAll(gz); err != nil {
+			return []string{"request body is not valid gzip"}
+		}
+	}
+	return validateJSON(op.Request, payload, "request")
+}
+
+func validateJSON(s *Schema, payload []byte, path string) []string {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return []string{path + ": body is not valid JSON"}
+	}
+	var violations []string
+	s.validate(v, path, &violations)
+	return violations
+}
+
+type recorder struct {
+	http.ResponseWriter
+	op       *Operation
+	request  []string
+	response []string
+	expected *Response
+	status   int
+	body     bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status != 0 {
+		return
+	}
+	rec.status = status
+	r, ok := rec.op.responses()[status]
+	if !ok {
+		rec.response = append(rec.response, "undocumented response status "+http.StatusText(status))
+	} else {
+		rec.expected = &r
+		mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		switch {
+		case r.ContentType != "" && !r.accepts(mediaType):
+			rec.response = append(rec.response, "response content type "+mediaType+" expected "+r.ContentType)
+		case r.ContentType != "" && mediaType != r.ContentType:
+			// схема описывает только основной тип, альтернативные форматы не проверяются
+			rec.expected = &Response{Description: r.Description, ContentType: mediaType}
+		}
+	}
+	if len(rec.request)+len(rec.response) > 0 {
+		rec.Header().Set(ViolationHeader, strings.Join(append(rec.request, rec.response...), "; "))
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush отдает клиенту накопленную часть потокового ответа
+func (rec *recorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap открывает исходный ResponseWriter для http.ResponseController
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// finish проверяет тело ответа, когда обработчик уже отработал
+func (rec *recorder) finish() {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if rec.expected == nil {
+		return
+	}
+	switch {
+	case rec.expected.Schema != nil:
+		rec.response = append(rec.response, validateJSON(rec.expected.Schema, rec.body.Bytes(), "response")...)
+	case rec.expected.ContentType == "" && rec.body.Len() > 0:
+		rec.response = append(rec.response, "response body is not expected")
+	}
+}
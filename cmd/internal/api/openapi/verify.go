@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Verify сверяет маршруты роутера с описанием операций, чтобы спецификация не расходилась с кодом
+func Verify(routes chi.Routes) error {
+	registered := map[string]bool{}
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered[method+" "+normalize(route)] = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk routes: %w", err)
+	}
+	documented := map[string]bool{}
+	for _, op := range operations {
+		documented[op.Method+" "+op.Path] = true
+	}
+	var missing, stale []string
+	for route := range registered {
+		if !documented[route] {
+			missing = append(missing, route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			stale = append(stale, route)
+		}
+	}
+	if len(missing) == 0 && len(stale) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	return fmt.Errorf("openapi specification drifted from router: undocumented routes %v, documented but not routed %v", missing, stale)
+}
+
+// normalize приводит шаблон chi к виду пути OpenAPI
+func normalize(route string) string {
+	route = strings.ReplaceAll(route, "/*/", "/")
+	if len(route) > 1 {
+		route = strings.TrimSuffix(route, "/")
+	}
+	return route
+}
+
+func segments(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func param(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}"), true
+	}
+	return "", false
+}
+
+// find ищет операцию по методу и фактическому пути запроса
+func find(method, path string) *Operation {
+	actual := segments(normalize(path))
+	for i := range operations {
+		op := &operations[i]
+		if op.Method != method {
+			continue
+		}
+		pattern := segments(op.Path)
+		if len(pattern) != len(actual) {
+			continue
+		}
+		matched := true
+		for j := range pattern {
+			if _, ok := param(pattern[j]); ok {
+				continue
+			}
+			if pattern[j] != actual[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return op
+		}
+	}
+	return nil
+}
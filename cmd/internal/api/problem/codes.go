@@ -0,0 +1,402 @@
+package problem
+
+// Code стабильный машиночитаемый код ошибки
+type Code string
+
+const (
+	Internal             Code = "internal_error"
+	InvalidEncoding      Code = "invalid_encoding"
+	InvalidContentType   Code = "invalid_content_type"
+	InvalidJSON          Code = "invalid_json"
+	InvalidBody          Code = "invalid_body"
+	InvalidID            Code = "invalid_id"
+	NotFound             Code = "not_found"
+	MethodNotAllowed     Code = "method_not_allowed"
+	AuthRequired         Code = "auth_required"
+	InvalidCredentials   Code = "invalid_credentials"
+	LoginEmpty           Code = "login_empty"
+	PasswordEmpty        Code = "password_empty"
+	PasswordHashFailed   Code = "password_hash_failed"
+	LoginTaken           Code = "login_taken"
+	OrderNumberInvalid   Code = "order_number_invalid"
+	OrderLuhnInvalid     Code = "order_luhn_invalid"
+	OrderTaken           Code = "order_taken"
+	OrderFinal           Code = "order_final"
+	WithdrawOrderEmpty   Code = "withdraw_order_empty"
+	WithdrawSumEmpty     Code = "withdraw_sum_empty"
+	InsufficientBalance  Code = "insufficient_balance"
+	WebhookURLInvalid    Code = "webhook_url_invalid"
+	WebhookEventUnknown  Code = "webhook_event_unknown"
+	SessionInvalid       Code = "session_invalid"
+	Forbidden            Code = "forbidden"
+	PasswordOldInvalid   Code = "password_old_invalid"
+	ResetTokenInvalid    Code = "reset_token_invalid"
+	UserNotFound         Code = "user_not_found"
+	AccountLocked        Code = "account_locked"
+	TooManyAttempts      Code = "too_many_attempts"
+	LoginLength          Code = "login_length"
+	LoginCharset         Code = "login_charset"
+	PasswordTooShort     Code = "password_too_short"
+	PasswordWeak         Code = "password_weak"
+	PasswordIsLogin      Code = "password_is_login"
+	PasswordBreached     Code = "password_breached"
+	OTPRequired          Code = "otp_required"
+	OTPInvalid           Code = "otp_invalid"
+	TwoFactorEnabled     Code = "two_factor_enabled"
+	TwoFactorRequired    Code = "two_factor_required"
+	RoleInvalid          Code = "role_invalid"
+	QueryInvalid         Code = "query_invalid"
+	AmountInvalid        Code = "amount_invalid"
+	ReasonEmpty          Code = "reason_empty"
+	ReferenceEmpty       Code = "reference_empty"
+	ReferenceTaken       Code = "reference_taken"
+	WithdrawalNotFound   Code = "withdrawal_not_found"
+	WithdrawalAmbiguous  Code = "withdrawal_ambiguous"
+	ReversalExceeds      Code = "reversal_exceeds"
+	HoldDuplicate        Code = "hold_duplicate"
+	HoldNotFound         Code = "hold_not_found"
+	HoldNotActive        Code = "hold_not_active"
+	TransferSelf         Code = "transfer_self"
+	TransferLimit        Code = "transfer_limit"
+	TransferNotFound     Code = "transfer_not_found"
+	TransferNotPending   Code = "transfer_not_pending"
+	WithdrawBelowMin     Code = "withdraw_below_min"
+	WithdrawAboveMax     Code = "withdraw_above_max"
+	WithdrawDailyLimit   Code = "withdraw_daily_limit"
+	WithdrawMonthlyLimit Code = "withdraw_monthly_limit"
+	WithdrawCooldown     Code = "withdraw_cooldown"
+	WithdrawDuplicate    Code = "withdraw_duplicate"
+	CampaignInvalid      Code = "campaign_invalid"
+	ReferralCodeInvalid  Code = "referral_code_invalid"
+	VoucherBatchInvalid  Code = "voucher_batch_invalid"
+	VoucherCodeEmpty     Code = "voucher_code_empty"
+	VoucherNotFound      Code = "voucher_not_found"
+	VoucherExpired       Code = "voucher_expired"
+	VoucherExhausted     Code = "voucher_exhausted"
+	VoucherRedeemed      Code = "voucher_redeemed"
+	RewardInvalid        Code = "reward_invalid"
+	RewardNotFound       Code = "reward_not_found"
+	RewardOutOfStock     Code = "reward_out_of_stock"
+	RewardOrderTaken     Code = "reward_order_taken"
+	StatementNotFound    Code = "statement_not_found"
+)
+
+var languages = map[string]struct{}{
+	"en": {},
+	"ru": {},
+}
+
+var messages = map[Code]map[string]string{
+	Internal: {
+		"en": "Internal server error",
+		"ru": "Внутренняя ошибка сервера",
+	},
+	InvalidEncoding: {
+		"en": "Request body encoding is not supported or corrupted",
+		"ru": "Кодировка тела запроса не поддерживается или повреждена",
+	},
+	InvalidContentType: {
+		"en": "Unsupported request content type",
+		"ru": "Неподдерживаемый тип содержимого запроса",
+	},
+	InvalidJSON: {
+		"en": "Request body is not valid JSON",
+		"ru": "Тело запроса не является корректным JSON",
+	},
+	InvalidBody: {
+		"en": "Request body could not be read",
+		"ru": "Не удалось прочитать тело запроса",
+	},
+	InvalidID: {
+		"en": "Identifier in the request path is malformed",
+		"ru": "Некорректный идентификатор в пути запроса",
+	},
+	NotFound: {
+		"en": "Resource not found",
+		"ru": "Ресурс не найден",
+	},
+	MethodNotAllowed: {
+		"en": "Method is not allowed for this resource",
+		"ru": "Метод не поддерживается для этого ресурса",
+	},
+	AuthRequired: {
+		"en": "Authentication required",
+		"ru": "Требуется аутентификация",
+	},
+	InvalidCredentials: {
+		"en": "Invalid login or password",
+		"ru": "Неверная пара логин/пароль",
+	},
+	LoginEmpty: {
+		"en": "Login is empty",
+		"ru": "Не указан логин",
+	},
+	PasswordEmpty: {
+		"en": "Password is empty",
+		"ru": "Не указан пароль",
+	},
+	PasswordHashFailed: {
+		"en": "Password could not be hashed",
+		"ru": "Не удалось вычислить хеш пароля",
+	},
+	LoginTaken: {
+		"en": "Login is already taken",
+		"ru": "Логин уже занят",
+	},
+	OrderNumberInvalid: {
+		"en": "Order number must be a sequence of digits",
+		"ru": "Номер заказа должен состоять из цифр",
+	},
+	OrderLuhnInvalid: {
+		"en": "Order number fails the Luhn check",
+		"ru": "Номер заказа не прошел проверку алгоритмом Луна",
+	},
+	OrderTaken: {
+		"en": "Order number was already uploaded by another user",
+		"ru": "Номер заказа уже был загружен другим пользователем",
+	},
+	OrderFinal: {
+		"en": "Order is already processed by the accrual system",
+		"ru": "Заказ уже обработан системой начислений",
+	},
+	WithdrawOrderEmpty: {
+		"en": "Withdrawal order number is empty",
+		"ru": "Не указан номер заказа для списания",
+	},
+	WithdrawSumEmpty: {
+		"en": "Withdrawal sum is empty",
+		"ru": "Не указана сумма списания",
+	},
+	InsufficientBalance: {
+		"en": "Not enough points on the balance",
+		"ru": "На счету недостаточно баллов",
+	},
+	WebhookURLInvalid: {
+		"en": "Webhook URL must be an absolute http(s) URL of a public host",
+		"ru": "Адрес webhook должен быть абсолютным http(s) URL публичного узла",
+	},
+	WebhookEventUnknown: {
+		"en": "Unknown webhook event",
+		"ru": "Неизвестное событие webhook",
+	},
+	SessionInvalid: {
+		"en": "Session is invalid or expired",
+		"ru": "Сессия недействительна или истекла",
+	},
+	Forbidden: {
+		"en": "Access denied",
+		"ru": "Доступ запрещен",
+	},
+	PasswordOldInvalid: {
+		"en": "Current password is wrong",
+		"ru": "Неверный текущий пароль",
+	},
+	ResetTokenInvalid: {
+		"en": "Password reset token is invalid, used or expired",
+		"ru": "Токен сброса пароля недействителен, использован или истек",
+	},
+	UserNotFound: {
+		"en": "User not found",
+		"ru": "Пользователь не найден",
+	},
+	AccountLocked: {
+		"en": "Account is temporarily locked after too many failed logins",
+		"ru": "Учетная запись временно заблокирована из-за неудачных попыток входа",
+	},
+	TooManyAttempts: {
+		"en": "Too many failed logins, retry later",
+		"ru": "Слишком много неудачных попыток входа, повторите позже",
+	},
+	LoginLength: {
+		"en": "Login length is out of the allowed range",
+		"ru": "Длина логина вне допустимого диапазона",
+	},
+	LoginCharset: {
+		"en": "Login contains forbidden characters",
+		"ru": "Логин содержит недопустимые символы",
+	},
+	PasswordTooShort: {
+		"en": "Password is too short",
+		"ru": "Пароль слишком короткий",
+	},
+	PasswordWeak: {
+		"en": "Password is too simple, use more characters of different kinds",
+		"ru": "Пароль слишком простой, используйте больше символов разных типов",
+	},
+	PasswordIsLogin: {
+		"en": "Password must not be equal to the login",
+		"ru": "Пароль не должен совпадать с логином",
+	},
+	PasswordBreached: {
+		"en": "Password is known from data breaches, choose another one",
+		"ru": "Пароль встречается в утечках данных, выберите другой",
+	},
+	OTPRequired: {
+		"en": "One-time code from the authenticator app is required",
+		"ru": "Требуется одноразовый код из приложения-аутентификатора",
+	},
+	OTPInvalid: {
+		"en": "One-time code is invalid or already used",
+		"ru": "Одноразовый код неверен или уже использован",
+	},
+	TwoFactorEnabled: {
+		"en": "Two-factor authentication is already enabled",
+		"ru": "Двухфакторная аутентификация уже включена",
+	},
+	TwoFactorRequired: {
+		"en": "Enable two-factor authentication to perform this operation",
+		"ru": "Для этой операции включите двухфакторную аутентификацию",
+	},
+	RoleInvalid: {
+		"en": "Unknown role, expected user, support or admin",
+		"ru": "Неизвестная роль, ожидается user, support или admin",
+	},
+	QueryInvalid: {
+		"en": "Invalid query parameter",
+		"ru": "Некорректный параметр запроса",
+	},
+	AmountInvalid: {
+		"en": "Amount must be a non-zero number of points",
+		"ru": "Сумма должна быть ненулевым числом баллов",
+	},
+	ReasonEmpty: {
+		"en": "Reason is not specified",
+		"ru": "Не указана причина",
+	},
+	ReferenceEmpty: {
+		"en": "Reference is not specified",
+		"ru": "Не указана ссылка на основание операции",
+	},
+	ReferenceTaken: {
+		"en": "Operation with this reference already exists",
+		"ru": "Операция с такой ссылкой уже существует",
+	},
+	WithdrawalNotFound: {
+		"en": "Withdrawal for this order number not found",
+		"ru": "Списание по этому номеру заказа не найдено",
+	},
+	WithdrawalAmbiguous: {
+		"en": "Several users withdrew points for this order number, specify the login",
+		"ru": "По этому номеру заказа списывали несколько пользователей, укажите логин",
+	},
+	ReversalExceeds: {
+		"en": "Amount exceeds the part of the withdrawal not yet reversed",
+		"ru": "Сумма больше невозвращенной части списания",
+	},
+	HoldDuplicate: {
+		"en": "Points for this order number are already held or withdrawn",
+		"ru": "Баллы по этому номеру заказа уже зарезервированы или списаны",
+	},
+	HoldNotFound: {
+		"en": "Hold not found",
+		"ru": "Резерв не найден",
+	},
+	HoldNotActive: {
+		"en": "Hold is already captured, voided or expired",
+		"ru": "Резерв уже списан, отменен или истек",
+	},
+	TransferSelf: {
+		"en": "Points cannot be transferred to yourself",
+		"ru": "Нельзя перевести баллы самому себе",
+	},
+	TransferLimit: {
+		"en": "Daily transfer limit exceeded",
+		"ru": "Превышен дневной лимит переводов",
+	},
+	TransferNotFound: {
+		"en": "Transfer not found",
+		"ru": "Перевод не найден",
+	},
+	TransferNotPending: {
+		"en": "Transfer is already completed, declined or canceled",
+		"ru": "Перевод уже выполнен, отклонен или отменен",
+	},
+	WithdrawBelowMin: {
+		"en": "Sum is below the minimum withdrawal",
+		"ru": "Сумма меньше минимальной суммы списания",
+	},
+	WithdrawAboveMax: {
+		"en": "Sum is above the maximum single withdrawal",
+		"ru": "Сумма больше максимальной суммы одного списания",
+	},
+	WithdrawDailyLimit: {
+		"en": "Daily withdrawal limit exceeded",
+		"ru": "Превышен дневной лимит списаний",
+	},
+	WithdrawMonthlyLimit: {
+		"en": "Monthly withdrawal limit exceeded",
+		"ru": "Превышен месячный лимит списаний",
+	},
+	WithdrawCooldown: {
+		"en": "Withdrawals are not yet available for a newly registered account",
+		"ru": "Списания для недавно зарегистрированного пользователя пока недоступны",
+	},
+	WithdrawDuplicate: {
+		"en": "Points are already withdrawn for this order number",
+		"ru": "Баллы по этому номеру заказа уже списаны",
+	},
+	CampaignInvalid: {
+		"en": "Invalid campaign terms",
+		"ru": "Некорректные условия акции",
+	},
+	ReferralCodeInvalid: {
+		"en": "Referral code not found",
+		"ru": "Реферальный код не найден",
+	},
+	VoucherBatchInvalid: {
+		"en": "Invalid voucher batch terms",
+		"ru": "Некорректные условия партии ваучеров",
+	},
+	VoucherCodeEmpty: {
+		"en": "Voucher code is not specified",
+		"ru": "Не указан код ваучера",
+	},
+	VoucherNotFound: {
+		"en": "Voucher code not found",
+		"ru": "Код ваучера не найден",
+	},
+	VoucherExpired: {
+		"en": "Voucher has expired",
+		"ru": "Срок действия ваучера истек",
+	},
+	VoucherExhausted: {
+		"en": "Voucher has already been used the maximum number of times",
+		"ru": "Ваучер уже погашен максимальное число раз",
+	},
+	VoucherRedeemed: {
+		"en": "You have already redeemed this voucher",
+		"ru": "Вы уже погасили этот ваучер",
+	},
+	RewardInvalid: {
+		"en": "Invalid reward",
+		"ru": "Некорректная награда",
+	},
+	RewardNotFound: {
+		"en": "Reward not found or not available",
+		"ru": "Награда не найдена или недоступна",
+	},
+	RewardOutOfStock: {
+		"en": "Reward is out of stock",
+		"ru": "Награда закончилась",
+	},
+	RewardOrderTaken: {
+		"en": "Points are already withdrawn for this order number",
+		"ru": "Баллы по этому номеру заказа уже списаны",
+	},
+	StatementNotFound: {
+		"en": "Statement not found",
+		"ru": "Выписка не найдена",
+	},
+}
+
+// Message возвращает текст ошибки на языке lang
+func (c Code) Message(lang string) string {
+	m, ok := messages[c]
+	if !ok {
+		return string(c)
+	}
+	if msg, ok := m[lang]; ok {
+		return msg
+	}
+	return m[defaultLang]
+}
@@ -0,0 +1,122 @@
+// Package problem формирует ответы об ошибках в формате RFC 7807 (application/problem+json)
+package problem
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+const (
+	ContentType = "application/problem+json"
+	typePrefix  = "urn:gophermart:problem:"
+	defaultLang = "en"
+)
+
+// Problem тело ответа об ошибке
+type Problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail,omitempty"`
+	Instance  string         `json:"instance,omitempty"`
+	Code      Code           `json:"code"`
+	RequestID string         `json:"request_id,omitempty"`
+	Ext       map[string]any `json:"-"` // дополнительные поля, выводятся на верхнем уровне
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	body, err := json.Marshal((*plain)(p))
+	if err != nil || len(p.Ext) == 0 {
+		return body, err
+	}
+	fields := map[string]any{}
+	for k, v := range p.Ext {
+		fields[k] = v
+	}
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// New собирает Problem для запроса r с сообщением на языке из Accept-Language
+func New(r *http.Request, status int, code Code) *Problem {
+	return &Problem{
+		Type:      typePrefix + string(code),
+		Title:     code.Message(Lang(r)),
+		Status:    status,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// Send пишет p в w
+func (p *Problem) Send(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Write отвечает ошибкой status с кодом code
+func Write(w http.ResponseWriter, r *http.Request, status int, code Code) {
+	New(r, status, code).Send(w)
+}
+
+// WriteDetail отвечает ошибкой с пояснением detail
+func WriteDetail(w http.ResponseWriter, r *http.Request, status int, code Code, detail string) {
+	p := New(r, status, code)
+	p.Detail = detail
+	p.Send(w)
+}
+
+// WriteExt отвечает ошибкой с дополнительными полями ext
+func WriteExt(w http.ResponseWriter, r *http.Request, status int, code Code, ext map[string]any) {
+	p := New(r, status, code)
+	p.Ext = ext
+	p.Send(w)
+}
+
+// RetryAfter выставляет заголовок Retry-After в целых секундах и возвращает их
+func RetryAfter(w http.ResponseWriter, after time.Duration) int64 {
+	seconds := int64(math.Ceil(after.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	return seconds
+}
+
+// WriteRetry отвечает ошибкой с заголовком Retry-After
+func WriteRetry(w http.ResponseWriter, r *http.Request, status int, code Code, after time.Duration) {
+	seconds := RetryAfter(w, after)
+	WriteExt(w, r, status, code, map[string]any{"retry_after": seconds})
+}
+
+// WriteThrottled отвечает 423 для заблокированной учетной записи и 429 при ограничении частоты попыток
+func WriteThrottled(w http.ResponseWriter, r *http.Request, locked bool, after time.Duration) {
+	if locked {
+		WriteRetry(w, r, http.StatusLocked, AccountLocked, after)
+		return
+	}
+	WriteRetry(w, r, http.StatusTooManyRequests, TooManyAttempts, after)
+}
+
+// Lang выбирает поддерживаемый язык из заголовка Accept-Language
+func Lang(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		tag = strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+		if _, ok := languages[tag]; ok {
+			return tag
+		}
+	}
+	return defaultLang
+}
@@ -0,0 +1,73 @@
+// Package blobstore хранит сформированные документы по ключу; Disk - реализация на локальном диске,
+// другое хранилище (S3 и т.п.) подключается реализацией Store
+package blobstore
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store хранилище документов. Open для отсутствующего ключа возвращает ошибку, для которой
+// errors.Is(err, fs.ErrNotExist)
+type Store interface {
+	Put(key string, data []byte) error
+	Open(key string) (io.ReadCloser, error)
+}
+
+// Disk документы в каталоге dir, ключ - относительный путь с разделителем /
+type Disk struct {
+	dir string
+}
+
+func NewDisk(dir string) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create blob store directory [%s]: %w", dir, err)
+	}
+	return &Disk{dir: dir}, nil
+}
+
+func (d *Disk) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key [%s]", key)
+	}
+	return filepath.Join(d.dir, clean), nil
+}
+
+// Put записывает документ во временный файл и переименовывает его, читатели не видят частично записанный файл
+func (d *Disk) Put(key string, data []byte) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return fmt.Errorf("failed to create directory for blob [%s]: %w", key, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob [%s]: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob [%s]: %w", key, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob [%s]: %w", key, err)
+	}
+	if err = os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("failed to store blob [%s]: %w", key, err)
+	}
+	return nil
+}
+
+func (d *Disk) Open(key string) (io.ReadCloser, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
@@ -2,9 +2,13 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"math"
 	"time"
 
 	"github.com/caarlos0/env"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
 )
 
 type Config struct {
@@ -14,7 +18,58 @@ type Config struct {
 	ConnectionString string        `env:"DATABASE_URI"`           // строка подключения к БД
 	Debug            bool          `env:"DBUG_MODE"`              // уровень логирования
 	RateLimit        int           `env:"RATE_LIMIT"`             // частота запросов
-
+	WebhookInterval  time.Duration `env:"WEBHOOK_INTERVAL"`       // период опроса очереди доставки webhook
+	WebhookAttempts  int           `env:"WEBHOOK_MAX_ATTEMPTS"`   // число попыток доставки webhook
+	WebhookBackoff   time.Duration `env:"WEBHOOK_BACKOFF"`        // начальная задержка повтора доставки
+	WebhookTimeout   time.Duration `env:"WEBHOOK_TIMEOUT"`        // таймаут запроса к получателю webhook
+	APIValidate      bool          `env:"API_VALIDATE"`           // сверка запросов и ответов с OpenAPI (режим разработки)
+	SessionTTL       time.Duration `env:"SESSION_TTL"`            // время жизни сессии
+	ResetTTL         time.Duration `env:"PASSWORD_RESET_TTL"`     // время жизни токена сброса пароля
+	AdminLogin       string        `env:"ADMIN_LOGIN"`            // логин, которому при старте назначается роль admin
+	LoginFree        int           `env:"LOGIN_FREE_ATTEMPTS"`    // неудачных входов без задержки
+	LoginDelay       time.Duration `env:"LOGIN_DELAY"`            // начальная задержка после неудачного входа
+	LoginMaxDelay    time.Duration `env:"LOGIN_MAX_DELAY"`        // предельная задержка между попытками входа
+	LoginLockAfter   int           `env:"LOGIN_LOCK_AFTER"`       // неудач по логину до блокировки
+	IPLockAfter      int           `env:"IP_LOCK_AFTER"`          // неудач с одного адреса до блокировки
+	LoginLock        time.Duration `env:"LOGIN_LOCK_DURATION"`    // длительность блокировки
+	LoginWindow      time.Duration `env:"LOGIN_WINDOW"`           // окно, в котором неудачи считаются подряд
+	CredCacheSize    int           `env:"CRED_CACHE_SIZE"`        // число проверенных паролей в кэше, 0 - кэш отключен
+	CredCacheTTL     time.Duration `env:"CRED_CACHE_TTL"`         // время жизни проверенного пароля в кэше
+	LoginMinLength   int           `env:"LOGIN_MIN_LENGTH"`       // минимальная длина логина
+	LoginMaxLength   int           `env:"LOGIN_MAX_LENGTH"`       // максимальная длина логина
+	LoginPattern     string        `env:"LOGIN_PATTERN"`          // допустимые символы логина, регулярное выражение
+	PasswordMin      int           `env:"PASSWORD_MIN_LENGTH"`    // минимальная длина пароля
+	PasswordEntropy  float64       `env:"PASSWORD_MIN_ENTROPY"`   // минимальная оценка энтропии пароля, бит
+	BreachedList     string        `env:"BREACHED_PASSWORDS"`     // файл со списком утекших паролей, пустой - проверка отключена
+	TOTPIssuer       string        `env:"TOTP_ISSUER"`            // издатель в ссылке otpauth для приложений-аутентификаторов
+	WithdrawOTPAbove int64         `env:"WITHDRAW_OTP_THRESHOLD"` // списания больше этой суммы требуют 2FA, 0 - не требуют
+	PasswordHash     string        `env:"PASSWORD_HASH"`          // алгоритм хэширования паролей: bcrypt, argon2id
+	BcryptCost       int           `env:"BCRYPT_COST"`            // стоимость bcrypt
+	ArgonMemory      uint          `env:"ARGON2_MEMORY"`          // память argon2id, KiB
+	ArgonTime        uint          `env:"ARGON2_TIME"`            // число проходов argon2id
+	ArgonThreads     uint          `env:"ARGON2_THREADS"`         // параллелизм argon2id
+	HoldTTL          time.Duration `env:"HOLD_TTL"`               // время жизни резерва баллов
+	HoldSweep        time.Duration `env:"HOLD_SWEEP_INTERVAL"`    // период закрытия истекших резервов
+	PointsTTL        int           `env:"POINTS_EXPIRE_MONTHS"`   // через сколько месяцев сгорают начисленные баллы, 0 - не сгорают
+	PointsExpiring   time.Duration `env:"POINTS_EXPIRING_WITHIN"` // горизонт поля expiring_soon в балансе
+	PointsExpireRun  time.Duration `env:"POINTS_EXPIRE_INTERVAL"` // период списания сгоревших баллов
+	TransferDaily    int64         `env:"TRANSFER_DAILY_LIMIT"`   // сумма переводов одного пользователя за сутки, 0 - без ограничения
+	TransferConfirm  int64         `env:"TRANSFER_CONFIRM_ABOVE"` // переводы больше этой суммы ждут подтверждения получателем, 0 - не ждут
+	WithdrawMin      int64         `env:"WITHDRAW_MIN"`           // минимальная сумма списания, 0 - без ограничения
+	WithdrawMaxTx    int64         `env:"WITHDRAW_MAX"`           // максимальная сумма одного списания, 0 - без ограничения
+	WithdrawDaily    int64         `env:"WITHDRAW_DAILY_LIMIT"`   // сумма списаний за день, 0 - без ограничения
+	WithdrawMonthly  int64         `env:"WITHDRAW_MONTHLY_LIMIT"` // сумма списаний за месяц, 0 - без ограничения
+	WithdrawCooldown time.Duration `env:"WITHDRAW_COOLDOWN"`      // пауза между регистрацией и первым списанием
+	TierWindow       time.Duration `env:"TIER_WINDOW"`            // скользящее окно начислений для уровня лояльности
+	TierRecalcAt     string        `env:"TIER_RECALC_AT"`         // время ночного пересчета уровней, ЧЧ:ММ
+	ReferrerBonus    int64         `env:"REFERRER_BONUS"`         // бонус пригласившему за первый обработанный заказ приглашенного
+	RefereeBonus     int64         `env:"REFEREE_BONUS"`          // бонус приглашенному за первый обработанный заказ
+	BlobDir          string        `env:"BLOB_DIR"`               // каталог хранилища сформированных документов
+	StatementsRun    time.Duration `env:"STATEMENTS_INTERVAL"`    // период проверки готовности ежемесячных выписок
+	StatsMonths      int           `env:"STATS_MONTHS"`           // число месяцев в помесячной статистике пользователя
+	StatsCacheSize   int           `env:"STATS_CACHE_SIZE"`       // число пользователей в кэше статистики, 0 - кэш отключен
+	StatsCacheTTL    time.Duration `env:"STATS_CACHE_TTL"`        // время жизни статистики в кэше
+	ReportsRun       time.Duration `env:"REPORTS_INTERVAL"`       // период пересчета дневных итогов отчетов
 }
 
 func GetConfig() (*Config, error) {
@@ -28,13 +83,79 @@ func GetConfig() (*Config, error) {
 	flag.BoolVar(&conf.Debug, "l", true,
 		"logger mode")
 	flag.IntVar(&conf.RateLimit, "m", 3, "Rate limit for accrual client")
+	flag.DurationVar(&conf.WebhookInterval, "webhook-interval", time.Second*5, "Webhook delivery queue poll interval")
+	flag.IntVar(&conf.WebhookAttempts, "webhook-attempts", 8, "Webhook delivery max attempts")
+	flag.DurationVar(&conf.WebhookBackoff, "webhook-backoff", time.Second*10, "Webhook delivery initial retry delay")
+	flag.DurationVar(&conf.WebhookTimeout, "webhook-timeout", time.Second*10, "Webhook delivery request timeout")
+	flag.BoolVar(&conf.APIValidate, "api-validate", false, "Validate requests and responses against OpenAPI spec (dev mode)")
+	flag.DurationVar(&conf.SessionTTL, "session-ttl", time.Hour*24, "Session lifetime")
+	flag.DurationVar(&conf.ResetTTL, "reset-ttl", time.Hour, "Password reset token lifetime")
+	flag.StringVar(&conf.AdminLogin, "admin-login", "", "Login granted the admin role on startup")
+	flag.IntVar(&conf.LoginFree, "login-free", 3, "Failed logins allowed without delay")
+	flag.DurationVar(&conf.LoginDelay, "login-delay", time.Second, "Initial delay after a failed login")
+	flag.DurationVar(&conf.LoginMaxDelay, "login-max-delay", time.Minute, "Max delay between login attempts")
+	flag.IntVar(&conf.LoginLockAfter, "login-lock-after", 10, "Failed logins per account before lockout")
+	flag.IntVar(&conf.IPLockAfter, "ip-lock-after", 100, "Failed logins per client address before lockout")
+	flag.DurationVar(&conf.LoginLock, "login-lock", time.Minute*15, "Lockout duration")
+	flag.DurationVar(&conf.LoginWindow, "login-window", time.Hour, "Window failed logins are counted in")
+	flag.IntVar(&conf.CredCacheSize, "cred-cache-size", 10000, "Verified credentials cache size, 0 disables the cache")
+	flag.DurationVar(&conf.CredCacheTTL, "cred-cache-ttl", time.Minute*5, "Verified credentials cache entry lifetime")
+	flag.IntVar(&conf.LoginMinLength, "login-min", 3, "Login min length")
+	flag.IntVar(&conf.LoginMaxLength, "login-max", 64, "Login max length")
+	flag.StringVar(&conf.LoginPattern, "login-pattern", `^[A-Za-z0-9._@-]+$`, "Login allowed characters, regular expression")
+	flag.IntVar(&conf.PasswordMin, "password-min", 8, "Password min length")
+	flag.Float64Var(&conf.PasswordEntropy, "password-entropy", 36, "Password min entropy estimate, bits")
+	flag.StringVar(&conf.BreachedList, "breached-passwords", "", "Breached passwords list file, one password per line")
+	flag.StringVar(&conf.TOTPIssuer, "totp-issuer", "Gophermart", "TOTP issuer shown in authenticator apps")
+	flag.Int64Var(&conf.WithdrawOTPAbove, "withdraw-otp-threshold", 0, "Withdrawals above this sum require 2FA, 0 disables the check")
+	flag.StringVar(&conf.PasswordHash, "password-hash", "bcrypt", "Password hash algorithm: bcrypt or argon2id")
+	flag.IntVar(&conf.BcryptCost, "bcrypt-cost", 14, "bcrypt cost")
+	flag.UintVar(&conf.ArgonMemory, "argon2-memory", 64*1024, "argon2id memory, KiB")
+	flag.UintVar(&conf.ArgonTime, "argon2-time", 3, "argon2id iterations")
+	flag.UintVar(&conf.ArgonThreads, "argon2-threads", 2, "argon2id parallelism")
+	flag.DurationVar(&conf.HoldTTL, "hold-ttl", time.Minute*15, "Points hold lifetime")
+	flag.DurationVar(&conf.HoldSweep, "hold-sweep-interval", time.Minute, "Expired holds sweep interval")
+	flag.IntVar(&conf.PointsTTL, "points-expire-months", 0, "Months after accrual points expire in, 0 disables expiration")
+	flag.DurationVar(&conf.PointsExpiring, "points-expiring-within", time.Hour*24*30, "Horizon of the expiring soon balance field")
+	flag.DurationVar(&conf.PointsExpireRun, "points-expire-interval", time.Hour, "Expired points write-off interval")
+	flag.Int64Var(&conf.TransferDaily, "transfer-daily-limit", 10000, "Points a user may transfer per day, 0 disables the limit")
+	flag.Int64Var(&conf.TransferConfirm, "transfer-confirm-above", 1000, "Transfers above this sum wait for the recipient, 0 disables confirmation")
+	flag.Int64Var(&conf.WithdrawMin, "withdraw-min", 0, "Min withdrawal sum, 0 disables the rule")
+	flag.Int64Var(&conf.WithdrawMaxTx, "withdraw-max", 0, "Max sum of a single withdrawal, 0 disables the rule")
+	flag.Int64Var(&conf.WithdrawDaily, "withdraw-daily-limit", 0, "Withdrawals per calendar day, 0 disables the rule")
+	flag.Int64Var(&conf.WithdrawMonthly, "withdraw-monthly-limit", 0, "Withdrawals per calendar month, 0 disables the rule")
+	flag.DurationVar(&conf.WithdrawCooldown, "withdraw-cooldown", 0, "Delay between registration and the first withdrawal")
+	flag.DurationVar(&conf.TierWindow, "tier-window", time.Hour*24*365, "Rolling window of accruals counted for the loyalty tier")
+	flag.StringVar(&conf.TierRecalcAt, "tier-recalc-at", "03:00", "Nightly tier recalculation time, HH:MM")
+	flag.Int64Var(&conf.ReferrerBonus, "referrer-bonus", 100, "Points for the referrer when the referee's first order is processed")
+	flag.Int64Var(&conf.RefereeBonus, "referee-bonus", 50, "Points for the referee when their first order is processed")
+	flag.StringVar(&conf.BlobDir, "blob-dir", "data", "Generated documents storage directory")
+	flag.DurationVar(&conf.StatementsRun, "statements-interval", time.Hour, "Monthly statements generation check interval")
+	flag.IntVar(&conf.StatsMonths, "stats-months", 12, "Months in the user monthly stats")
+	flag.IntVar(&conf.StatsCacheSize, "stats-cache-size", 10000, "User stats cache size, 0 disables the cache")
+	flag.DurationVar(&conf.StatsCacheTTL, "stats-cache-ttl", time.Minute*5, "User stats cache entry lifetime")
+	flag.DurationVar(&conf.ReportsRun, "reports-interval", time.Minute*15, "Daily report totals refresh interval")
 	flag.Parse()
 
 	err := env.Parse(&conf)
+	if err == nil {
+		err = conf.checkArgon()
+	}
 
 	return &conf, err
 }
 
+// checkArgon параметры argon2id помещаются в типы argon2, иначе при приведении они молча обрезаются
+func (conf *Config) checkArgon() error {
+	if conf.ArgonMemory > math.MaxUint32 || conf.ArgonTime > math.MaxUint32 {
+		return fmt.Errorf("argon2id memory %d and time %d must not exceed %d", conf.ArgonMemory, conf.ArgonTime, uint32(math.MaxUint32))
+	}
+	if conf.ArgonThreads > math.MaxUint8 {
+		return fmt.Errorf("argon2id threads %d must not exceed %d", conf.ArgonThreads, math.MaxUint8)
+	}
+	return nil
+}
+
 func (conf *Config) IsDebug() bool {
 	return conf.Debug
 }
@@ -54,3 +175,190 @@ func (conf *Config) GetSyncInterval() time.Duration {
 func (conf *Config) GetRateLimit() int {
 	return conf.RateLimit
 }
+
+func (conf *Config) GetWebhookInterval() time.Duration {
+	return conf.WebhookInterval
+}
+
+func (conf *Config) GetWebhookAttempts() int {
+	return conf.WebhookAttempts
+}
+
+func (conf *Config) GetWebhookBackoff() time.Duration {
+	return conf.WebhookBackoff
+}
+
+func (conf *Config) GetWebhookTimeout() time.Duration {
+	return conf.WebhookTimeout
+}
+
+func (conf *Config) GetSessionTTL() time.Duration {
+	return conf.SessionTTL
+}
+
+func (conf *Config) GetResetTTL() time.Duration {
+	return conf.ResetTTL
+}
+
+func (conf *Config) GetAdminLogin() string {
+	return conf.AdminLogin
+}
+
+func (conf *Config) GetLoginFreeAttempts() int {
+	return conf.LoginFree
+}
+
+func (conf *Config) GetLoginDelay() time.Duration {
+	return conf.LoginDelay
+}
+
+func (conf *Config) GetLoginMaxDelay() time.Duration {
+	return conf.LoginMaxDelay
+}
+
+func (conf *Config) GetLoginLockAfter() int {
+	return conf.LoginLockAfter
+}
+
+func (conf *Config) GetIPLockAfter() int {
+	return conf.IPLockAfter
+}
+
+func (conf *Config) GetLoginLockDuration() time.Duration {
+	return conf.LoginLock
+}
+
+func (conf *Config) GetLoginWindow() time.Duration {
+	return conf.LoginWindow
+}
+
+func (conf *Config) GetCredCacheSize() int {
+	return conf.CredCacheSize
+}
+
+func (conf *Config) GetCredCacheTTL() time.Duration {
+	return conf.CredCacheTTL
+}
+
+func (conf *Config) GetLoginMinLength() int {
+	return conf.LoginMinLength
+}
+
+func (conf *Config) GetLoginMaxLength() int {
+	return conf.LoginMaxLength
+}
+
+func (conf *Config) GetLoginPattern() string {
+	return conf.LoginPattern
+}
+
+func (conf *Config) GetPasswordMinLength() int {
+	return conf.PasswordMin
+}
+
+func (conf *Config) GetPasswordMinEntropy() float64 {
+	return conf.PasswordEntropy
+}
+
+func (conf *Config) GetBreachedPasswords() string {
+	return conf.BreachedList
+}
+
+func (conf *Config) GetTOTPIssuer() string {
+	return conf.TOTPIssuer
+}
+
+func (conf *Config) GetWithdrawOTPThreshold() int64 {
+	return conf.WithdrawOTPAbove
+}
+
+func (conf *Config) GetHoldTTL() time.Duration {
+	return conf.HoldTTL
+}
+
+func (conf *Config) GetHoldSweepInterval() time.Duration {
+	return conf.HoldSweep
+}
+
+func (conf *Config) GetPointsExpireMonths() int {
+	return conf.PointsTTL
+}
+
+func (conf *Config) GetPointsExpiringWithin() time.Duration {
+	return conf.PointsExpiring
+}
+
+func (conf *Config) GetPointsExpireInterval() time.Duration {
+	return conf.PointsExpireRun
+}
+
+func (conf *Config) GetTransferDailyLimit() int64 {
+	return conf.TransferDaily
+}
+
+func (conf *Config) GetTransferConfirmAbove() int64 {
+	return conf.TransferConfirm
+}
+
+func (conf *Config) GetTierWindow() time.Duration {
+	return conf.TierWindow
+}
+
+func (conf *Config) GetTierRecalcAt() string {
+	return conf.TierRecalcAt
+}
+
+func (conf *Config) GetReferrerBonus() int64 {
+	return conf.ReferrerBonus
+}
+
+func (conf *Config) GetRefereeBonus() int64 {
+	return conf.RefereeBonus
+}
+
+func (conf *Config) GetBlobDir() string {
+	return conf.BlobDir
+}
+
+func (conf *Config) GetStatementsInterval() time.Duration {
+	return conf.StatementsRun
+}
+
+func (conf *Config) GetStatsMonths() int {
+	return conf.StatsMonths
+}
+
+func (conf *Config) GetStatsCacheSize() int {
+	return conf.StatsCacheSize
+}
+
+func (conf *Config) GetStatsCacheTTL() time.Duration {
+	return conf.StatsCacheTTL
+}
+
+func (conf *Config) GetReportsInterval() time.Duration {
+	return conf.ReportsRun
+}
+
+// GetWithdrawRules общие правила списаний
+func (conf *Config) GetWithdrawRules() *model.WithdrawRules {
+	cooldown := int64(conf.WithdrawCooldown.Seconds())
+	return &model.WithdrawRules{
+		Min:      &conf.WithdrawMin,
+		MaxTx:    &conf.WithdrawMaxTx,
+		Daily:    &conf.WithdrawDaily,
+		Monthly:  &conf.WithdrawMonthly,
+		Cooldown: &cooldown,
+	}
+}
+
+// GetHashPolicy политика хэширования новых паролей
+func (conf *Config) GetHashPolicy() utils.HashPolicy {
+	return utils.HashPolicy{
+		Algorithm:    conf.PasswordHash,
+		BcryptCost:   conf.BcryptCost,
+		ArgonMemory:  uint32(conf.ArgonMemory),
+		ArgonTime:    uint32(conf.ArgonTime),
+		ArgonThreads: uint8(conf.ArgonThreads),
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckArgon(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		conf Config
+		ok   bool
+	}{
+		{"defaults", Config{ArgonMemory: 64 * 1024, ArgonTime: 3, ArgonThreads: 2}, true},
+		{"max threads", Config{ArgonMemory: 64 * 1024, ArgonTime: 3, ArgonThreads: math.MaxUint8}, true},
+		{"threads overflow uint8", Config{ArgonMemory: 64 * 1024, ArgonTime: 3, ArgonThreads: 256}, false},
+		{"memory overflows uint32", Config{ArgonMemory: math.MaxUint32 + 1, ArgonTime: 3, ArgonThreads: 2}, false},
+		{"time overflows uint32", Config{ArgonMemory: 64 * 1024, ArgonTime: math.MaxUint32 + 1, ArgonThreads: 2}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.conf.checkArgon(); (err == nil) != tc.ok {
+				t.Errorf("checkArgon() = %v, want ok %v", err, tc.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package expiration периодически списывает сгоревшие баллы
+package expiration
+
+import (
+	"context"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+)
+
+type Job struct {
+	repo repository
+	cfg  config
+	lg   *logger.Logger
+	ctx  context.Context
+}
+
+type config interface {
+	GetPointsExpireMonths() int
+	GetPointsExpireInterval() time.Duration
+}
+
+type repository interface {
+	PointsExpire(months int) (int64, error)
+}
+
+func NewJob(c context.Context, r repository, conf config, lg *logger.Logger) *Job {
+	return &Job{
+		repo: r,
+		cfg:  conf,
+		lg:   lg,
+		ctx:  c,
+	}
+}
+
+// Run запускает задачу, если сгорание баллов включено
+func (j *Job) Run() {
+	if j.cfg.GetPointsExpireMonths() <= 0 {
+		return
+	}
+	go j.worker()
+}
+
+func (j *Job) worker() {
+	ticker := time.NewTicker(j.cfg.GetPointsExpireInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n, err := j.repo.PointsExpire(j.cfg.GetPointsExpireMonths())
+			if err != nil {
+				j.lg.Err(err).Msg("points expiration job failed")
+				continue
+			}
+			if n > 0 {
+				j.lg.Info().Msgf("points expired for %v users", n)
+			}
+		case <-j.ctx.Done():
+			j.lg.Info().Msgf("points expiration job stopped")
+			return
+		}
+	}
+}
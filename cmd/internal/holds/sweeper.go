@@ -0,0 +1,58 @@
+// Package holds закрывает истекшие резервы баллов
+package holds
+
+import (
+	"context"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+)
+
+type Sweeper struct {
+	repo repository
+	cfg  config
+	lg   *logger.Logger
+	ctx  context.Context
+}
+
+type config interface {
+	GetHoldSweepInterval() time.Duration
+}
+
+type repository interface {
+	HoldsExpire() (int64, error)
+}
+
+func NewSweeper(c context.Context, r repository, conf config, lg *logger.Logger) *Sweeper {
+	return &Sweeper{
+		repo: r,
+		cfg:  conf,
+		lg:   lg,
+		ctx:  c,
+	}
+}
+
+func (s *Sweeper) Run() {
+	go s.worker()
+}
+
+func (s *Sweeper) worker() {
+	ticker := time.NewTicker(s.cfg.GetHoldSweepInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n, err := s.repo.HoldsExpire()
+			if err != nil {
+				s.lg.Err(err).Msg("hold sweeper failed to expire holds")
+				continue
+			}
+			if n > 0 {
+				s.lg.Info().Msgf("hold sweeper expired %v holds", n)
+			}
+		case <-s.ctx.Done():
+			s.lg.Info().Msgf("hold sweeper stopped")
+			return
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package lockout защищает вход по паролю и погашение ваучеров от перебора: прогрессивная задержка и временная блокировка
+package lockout
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+type repository interface {
+	LoginAttempts(userKind string, login string, ipKind string, ip string) (*[]model.LoginAttempt, error)
+	LoginAttemptFail(kind string, key string, lockAfter int, lock time.Duration, window time.Duration) (*model.LoginAttempt, error)
+	LoginAttemptReset(kind string, key string) (bool, error)
+}
+
+type config interface {
+	GetLoginFreeAttempts() int
+	GetLoginDelay() time.Duration
+	GetLoginMaxDelay() time.Duration
+	GetLoginLockAfter() int
+	GetIPLockAfter() int
+	GetLoginLockDuration() time.Duration
+	GetLoginWindow() time.Duration
+}
+
+// Verdict решение по попытке входа
+type Verdict struct {
+	Locked bool          // учетная запись заблокирована
+	Wait   time.Duration // через сколько можно повторить попытку
+}
+
+// Allowed попытку можно проверять
+func (v Verdict) Allowed() bool {
+	return !v.Locked && v.Wait <= 0
+}
+
+// scope виды пары счетчиков: по учетной записи и по адресу клиента
+type scope struct {
+	user string
+	ip   string
+	name string
+}
+
+var (
+	loginScope  = scope{user: model.AttemptLogin, ip: model.AttemptIP, name: "login"}
+	redeemScope = scope{user: model.AttemptRedeem, ip: model.AttemptRedeemIP, name: "voucher redeem"}
+)
+
+type Guard struct {
+	repo repository
+	cfg  config
+	lg   *logger.Logger
+}
+
+func NewGuard(r repository, c config, lg *logger.Logger) *Guard {
+	return &Guard{repo: r, cfg: c, lg: lg}
+}
+
+// Check проверяет, можно ли сейчас принимать пароль для login с адреса ip
+func (g *Guard) Check(login string, ip string) (Verdict, error) {
+	return g.check(loginScope, login, ip)
+}
+
+// Fail учитывает неудачную попытку по логину и адресу
+func (g *Guard) Fail(login string, ip string) (Verdict, error) {
+	return g.fail(loginScope, login, ip)
+}
+
+// Success сбрасывает счетчик логина; счетчик адреса сбрасывается только по времени
+func (g *Guard) Success(login string) error {
+	return g.success(loginScope, login)
+}
+
+// CheckRedeem проверяет, можно ли сейчас принимать код ваучера от login с адреса ip
+func (g *Guard) CheckRedeem(login string, ip string) (Verdict, error) {
+	return g.check(redeemScope, login, ip)
+}
+
+// FailRedeem учитывает неверный код ваучера
+func (g *Guard) FailRedeem(login string, ip string) (Verdict, error) {
+	return g.fail(redeemScope, login, ip)
+}
+
+// SuccessRedeem сбрасывает счетчик неверных кодов пользователя
+func (g *Guard) SuccessRedeem(login string) error {
+	return g.success(redeemScope, login)
+}
+
+func (g *Guard) check(s scope, login string, ip string) (Verdict, error) {
+	attempts, err := g.repo.LoginAttempts(s.user, login, s.ip, ip)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to check %s attempts for [%s]: %w", s.name, login, err)
+	}
+	v := Verdict{}
+	for _, a := range *attempts {
+		v = worst(v, g.verdict(s, &a))
+	}
+	return v, nil
+}
+
+func (g *Guard) fail(s scope, login string, ip string) (Verdict, error) {
+	cfg := g.cfg
+	byLogin, err := g.repo.LoginAttemptFail(s.user, login, cfg.GetLoginLockAfter(), cfg.GetLoginLockDuration(), cfg.GetLoginWindow())
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to count failed %s for [%s]: %w", s.name, login, err)
+	}
+	byIP, err := g.repo.LoginAttemptFail(s.ip, ip, cfg.GetIPLockAfter(), cfg.GetLoginLockDuration(), cfg.GetLoginWindow())
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to count failed %s from [%s]: %w", s.name, ip, err)
+	}
+	v := worst(g.verdict(s, byLogin), g.verdict(s, byIP))
+	if !v.Allowed() {
+		g.lg.Warn().Msgf("%s throttled for [%s] from [%s]: locked=%v, wait %v", s.name, login, ip, v.Locked, v.Wait)
+	}
+	return v, nil
+}
+
+func (g *Guard) success(s scope, login string) error {
+	if _, err := g.repo.LoginAttemptReset(s.user, login); err != nil {
+		return fmt.Errorf("failed to reset %s attempts for [%s]: %w", s.name, login, err)
+	}
+	return nil
+}
+
+// Unlock снимает блокировку логина, false - блокировки не было
+func (g *Guard) Unlock(login string) (bool, error) {
+	found, err := g.repo.LoginAttemptReset(model.AttemptLogin, login)
+	if err != nil {
+		return false, fmt.Errorf("failed to unlock login [%s]: %w", login, err)
+	}
+	return found, nil
+}
+
+func (g *Guard) verdict(s scope, a *model.LoginAttempt) Verdict {
+	if a.LockedFor > 0 {
+		// блокировка адреса не блокирует учетную запись, это ограничение частоты
+		return Verdict{Locked: a.Kind == s.user, Wait: a.LockedFor}
+	}
+	if a.SinceFailure > g.cfg.GetLoginWindow() {
+		return Verdict{}
+	}
+	return Verdict{Wait: g.delay(a.Failures) - a.SinceFailure}
+}
+
+// delay задержка после failures неудач: первые попытки бесплатны, дальше задержка удваивается
+func (g *Guard) delay(failures int) time.Duration {
+	over := failures - g.cfg.GetLoginFreeAttempts()
+	if over <= 0 {
+		return 0
+	}
+	d := g.cfg.GetLoginDelay()
+	for i := 1; i < over; i++ {
+		d *= 2
+		if d >= g.cfg.GetLoginMaxDelay() {
+			return g.cfg.GetLoginMaxDelay()
+		}
+	}
+	return d
+}
+
+func worst(a, b Verdict) Verdict {
+	res := Verdict{Locked: a.Locked || b.Locked, Wait: a.Wait}
+	if b.Wait > res.Wait {
+		res.Wait = b.Wait
+	}
+	return res
+}
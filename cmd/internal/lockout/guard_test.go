@@ -0,0 +1,240 @@
+package lockout
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	conf "github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// attemptsRepo счетчики неудач в памяти, устроенные как в базе: блокировка после lockAfter неудач подряд
+type attemptsRepo struct {
+	attempts map[string]*model.LoginAttempt
+	err      error
+}
+
+func newAttemptsRepo(attempts ...model.LoginAttempt) *attemptsRepo {
+	r := &attemptsRepo{attempts: map[string]*model.LoginAttempt{}}
+	for i := range attempts {
+		a := attempts[i]
+		r.attempts[a.Kind+":"+a.Key] = &a
+	}
+	return r
+}
+
+func (r *attemptsRepo) LoginAttempts(userKind string, login string, ipKind string, ip string) (*[]model.LoginAttempt, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	list := []model.LoginAttempt{}
+	for _, key := range []string{userKind + ":" + login, ipKind + ":" + ip} {
+		if a, ok := r.attempts[key]; ok {
+			list = append(list, *a)
+		}
+	}
+	return &list, nil
+}
+
+func (r *attemptsRepo) LoginAttemptFail(kind string, key string, lockAfter int, lock time.Duration, window time.Duration) (*model.LoginAttempt, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	a, ok := r.attempts[kind+":"+key]
+	if !ok {
+		a = &model.LoginAttempt{Kind: kind, Key: key}
+		r.attempts[kind+":"+key] = a
+	}
+	a.Failures++
+	a.SinceFailure = 0
+	if a.Failures >= lockAfter {
+		a.LockedFor = lock
+	}
+	res := *a
+	return &res, nil
+}
+
+func (r *attemptsRepo) LoginAttemptReset(kind string, key string) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	_, ok := r.attempts[kind+":"+key]
+	delete(r.attempts, kind+":"+key)
+	return ok, nil
+}
+
+func testConfig() *conf.Config {
+	return &conf.Config{LoginFree: 3, LoginDelay: time.Second, LoginMaxDelay: 10 * time.Second,
+		LoginLockAfter: 5, IPLockAfter: 8, LoginLock: 15 * time.Minute, LoginWindow: time.Hour}
+}
+
+func testGuard(r *attemptsRepo) *Guard {
+	cfg := testConfig()
+	lg := logger.New(cfg)
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	return NewGuard(r, cfg, lg)
+}
+
+// TestGuardCheck задержка удваивается после бесплатных попыток до предела, прошедшее время засчитывается;
+// блокировка логина запрещает вход, блокировка адреса только задерживает
+func TestGuardCheck(t *testing.T) {
+	login := func(failures int, since time.Duration) model.LoginAttempt {
+		return model.LoginAttempt{Kind: model.AttemptLogin, Key: "user", Failures: failures, SinceFailure: since}
+	}
+	ip := func(failures int, since time.Duration) model.LoginAttempt {
+		return model.LoginAttempt{Kind: model.AttemptIP, Key: "10.0.0.1", Failures: failures, SinceFailure: since}
+	}
+	for _, tc := range []struct {
+		name     string
+		attempts []model.LoginAttempt
+		want     Verdict
+	}{
+		{"no failures", nil, Verdict{}},
+		{"free attempts", []model.LoginAttempt{login(3, 0)}, Verdict{}},
+		{"first delay", []model.LoginAttempt{login(4, 0)}, Verdict{Wait: time.Second}},
+		{"doubled", []model.LoginAttempt{login(6, 0)}, Verdict{Wait: 4 * time.Second}},
+		{"max delay", []model.LoginAttempt{login(9, 0)}, Verdict{Wait: 10 * time.Second}},
+		{"elapsed", []model.LoginAttempt{login(6, 3*time.Second)}, Verdict{Wait: time.Second}},
+		{"delay passed", []model.LoginAttempt{login(4, 2*time.Second)}, Verdict{}},
+		{"outside window", []model.LoginAttempt{login(9, 2*time.Hour)}, Verdict{}},
+		{"login locked", []model.LoginAttempt{{Kind: model.AttemptLogin, Key: "user", Failures: 5, LockedFor: time.Minute}},
+			Verdict{Locked: true, Wait: time.Minute}},
+		{"ip locked", []model.LoginAttempt{{Kind: model.AttemptIP, Key: "10.0.0.1", Failures: 8, LockedFor: time.Minute}},
+			Verdict{Wait: time.Minute}},
+		{"worst of both", []model.LoginAttempt{login(4, 0), ip(7, 0)}, Verdict{Wait: 8 * time.Second}},
+		{"other ip", []model.LoginAttempt{{Kind: model.AttemptIP, Key: "10.0.0.2", Failures: 8, LockedFor: time.Minute}},
+			Verdict{}},
+		{"redeem counted apart", []model.LoginAttempt{{Kind: model.AttemptRedeem, Key: "user", Failures: 5, LockedFor: time.Minute}},
+			Verdict{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := testGuard(newAttemptsRepo(tc.attempts...)).Check("user", "10.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v != tc.want {
+				t.Errorf("verdict %+v, want %+v", v, tc.want)
+			}
+			if v.Allowed() != (tc.want.Wait <= 0 && !tc.want.Locked) {
+				t.Errorf("allowed %v", v.Allowed())
+			}
+		})
+	}
+}
+
+// TestGuardFail неудачи по логину блокируют учетную запись на пороге, неудачи с адреса по разным логинам -
+// адрес; успешный вход сбрасывает только счетчик логина
+func TestGuardFail(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		logins []string //логины неудачных попыток с одного адреса
+		want   Verdict  //решение после последней попытки
+	}{
+		{"free attempts", []string{"user", "user", "user"}, Verdict{}},
+		{"delay", []string{"user", "user", "user", "user"}, Verdict{Wait: time.Second}},
+		{"login lock", []string{"user", "user", "user", "user", "user"}, Verdict{Locked: true, Wait: 15 * time.Minute}},
+		{"ip delay", []string{"a", "b", "c", "d", "user"}, Verdict{Wait: 2 * time.Second}},
+		{"ip lock", []string{"a", "b", "c", "d", "e", "f", "g", "user"}, Verdict{Wait: 15 * time.Minute}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := testGuard(newAttemptsRepo())
+			var v Verdict
+			var err error
+			for _, login := range tc.logins {
+				if v, err = g.Fail(login, "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if v != tc.want {
+				t.Errorf("fail verdict %+v, want %+v", v, tc.want)
+			}
+			if v, err = g.Check("user", "10.0.0.1"); err != nil || v != tc.want {
+				t.Errorf("check verdict %+v, %v; want %+v", v, err, tc.want)
+			}
+		})
+	}
+}
+
+// TestGuardSuccess успешный вход и разблокировка администратором сбрасывают счетчик логина,
+// но не адреса; разблокировка без блокировки возвращает false
+func TestGuardSuccess(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		reset func(g *Guard) (bool, error)
+		found bool
+	}{
+		{"success", func(g *Guard) (bool, error) { return true, g.Success("user") }, true},
+		{"unlock", func(g *Guard) (bool, error) { return g.Unlock("user") }, true},
+		{"unlock other", func(g *Guard) (bool, error) { return g.Unlock("other") }, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newAttemptsRepo()
+			g := testGuard(repo)
+			for i := 0; i < 5; i++ {
+				if _, err := g.Fail("user", "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			found, err := tc.reset(g)
+			if err != nil || found != tc.found {
+				t.Fatalf("reset: %v, %v; want %v", found, err, tc.found)
+			}
+			v, err := g.Check("user", "10.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.found {
+				// пять неудач с адреса при пороге восемь: задержка остается
+				if want := (Verdict{Wait: 2 * time.Second}); v != want {
+					t.Errorf("verdict after reset %+v, want %+v", v, want)
+				}
+			} else if !v.Locked {
+				t.Errorf("verdict %+v, want locked", v)
+			}
+		})
+	}
+}
+
+// TestGuardRedeem неверные коды ваучеров считаются отдельно от входов
+func TestGuardRedeem(t *testing.T) {
+	g := testGuard(newAttemptsRepo())
+	for i := 0; i < 5; i++ {
+		if _, err := g.FailRedeem("user", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, err := g.CheckRedeem("user", "10.0.0.1"); err != nil || !v.Locked {
+		t.Errorf("redeem verdict %+v, %v; want locked", v, err)
+	}
+	if v, err := g.Check("user", "10.0.0.1"); err != nil || !v.Allowed() {
+		t.Errorf("login verdict %+v, %v; want allowed", v, err)
+	}
+	if err := g.SuccessRedeem("user"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := g.CheckRedeem("user", "10.0.0.1"); err != nil || v.Locked {
+		t.Errorf("redeem verdict after success %+v, %v; want unlocked", v, err)
+	}
+}
+
+// TestGuardErrors ошибка хранилища возвращается вызывающему
+func TestGuardErrors(t *testing.T) {
+	repo := newAttemptsRepo()
+	repo.err = errors.New("database is down")
+	g := testGuard(repo)
+	if _, err := g.Check("user", "10.0.0.1"); !errors.Is(err, repo.err) {
+		t.Errorf("check error %v", err)
+	}
+	if _, err := g.Fail("user", "10.0.0.1"); !errors.Is(err, repo.err) {
+		t.Errorf("fail error %v", err)
+	}
+	if err := g.Success("user"); !errors.Is(err, repo.err) {
+		t.Errorf("success error %v", err)
+	}
+	if _, err := g.Unlock("user"); !errors.Is(err, repo.err) {
+		t.Errorf("unlock error %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists webhooks
+(
+    id       uuid                    not null
+        constraint webhooks_pk
+            primary key,
+    user_id  uuid                    not null
+        constraint webhooks_fk
+            references users
+            on delete cascade,
+    url      varchar                 not null,
+    secret   varchar                 not null,
+    events   varchar[]               not null,
+    active   boolean   default true  not null,
+    date_ins timestamp default now() not null
+);
+
+create index if not exists webhooks_user_idx
+    on webhooks (user_id);
+
+-- transactional outbox: rows are written in the same transaction as the business change
+create table if not exists outbox
+(
+    id       bigint generated always as identity
+        constraint outbox_pk
+            primary key,
+    user_id  uuid                    not null
+        constraint outbox_fk
+            references users
+            on delete cascade,
+    event    varchar                 not null,
+    payload  jsonb                   not null,
+    date_ins timestamp default now() not null
+);
+
+create table if not exists webhook_deliveries
+(
+    id            bigint generated always as identity
+        constraint webhook_deliveries_pk
+            primary key,
+    webhook_id    uuid                        not null
+        constraint webhook_deliveries_webhooks_fk
+            references webhooks
+            on delete cascade,
+    outbox_id     bigint                      not null
+        constraint webhook_deliveries_outbox_fk
+            references outbox
+            on delete cascade,
+    status        varchar   default 'PENDING' not null,
+    attempts      integer   default 0         not null,
+    next_try      timestamp default now()     not null,
+    response_code integer,
+    last_error    varchar,
+    date_upd      timestamp default now()     not null
+);
+
+create index if not exists webhook_deliveries_pending_idx
+    on webhook_deliveries (next_try)
+    where status = 'PENDING';
+
+create or replace function outbox_add(_user_id uuid, _event character varying, _payload jsonb) returns bigint
+    language plpgsql
+as
+$$
+declare
+    _outbox_id bigint;
+begin
+    insert into outbox (user_id, event, payload)
+    values (_user_id, _event, _payload)
+    returning id into _outbox_id;
+
+    insert into webhook_deliveries (webhook_id, outbox_id)
+    select h.id, _outbox_id
+    from webhooks h
+    where h.user_id = _user_id
+      and h.active
+      and _event = any (h.events);
+
+    return _outbox_id;
+end;
+$$;
+
+create or replace function webhook_add(_user_id uuid, _url character varying, _secret character varying,
+                                       _events character varying[]) returns character varying
+    language sql
+as
+$$
+insert into webhooks (id, user_id, url, secret, events)
+values (gen_random_uuid(), _user_id, _url, _secret, _events)
+returning cast(id as varchar);
+$$;
+
+create or replace function webhooks_all(_user_id uuid)
+    returns TABLE(id character varying, url character varying, events character varying[], active boolean, date_ins timestamp without time zone)
+    language sql
+as
+$$
+select cast(h.id as varchar), h.url, h.events, h.active, h.date_ins
+from webhooks h
+where h.user_id = _user_id
+order by h.date_ins asc;
+$$;
+
+create or replace function webhook_delete(_user_id uuid, _id uuid) returns boolean
+    language plpgsql
+as
+$$
+begin
+    delete from webhooks where id = _id and user_id = _user_id;
+    return found;
+end;
+$$;
+
+-- claims due deliveries; the lease keeps other instances from picking them up meanwhile
+create or replace function deliveries_claim(_limit integer, _lease interval)
+    returns TABLE(id bigint, webhook_id character varying, url character varying, secret character varying,
+                  event character varying, payload text, attempts integer)
+    language sql
+as
+$$
+with due as (
+    select d.id
+    from webhook_deliveries d
+    where d.status = 'PENDING'
+      and d.next_try <= now()
+    order by d.next_try
+    limit _limit
+    for update skip locked
+), claimed as (
+    update webhook_deliveries d
+    set next_try = now() + _lease
+    from due
+    where d.id = due.id
+    returning d.id, d.webhook_id, d.outbox_id, d.attempts
+)
+select c.id, cast(c.webhook_id as varchar), h.url, h.secret, o.event, cast(o.payload as text), c.attempts
+from claimed c
+         join webhooks h on h.id = c.webhook_id
+         join outbox o on o.id = c.outbox_id;
+$$;
+
+create or replace function delivery_result(_id bigint, _status character varying, _code integer,
+                                           _error character varying, _next_try timestamp) returns void
+    language sql
+as
+$$
+update webhook_deliveries
+set status        = _status,
+    attempts      = attempts + 1,
+    response_code = _code,
+    last_error    = _error,
+    next_try      = _next_try,
+    date_upd      = now()
+where id = _id;
+$$;
+
+create or replace function deliveries_all(_user_id uuid, _webhook_id uuid)
+    returns TABLE(id bigint, webhook_id character varying, event character varying, status character varying,
+                  attempts integer, response_code integer, last_error character varying,
+                  next_try timestamp without time zone, date_upd timestamp without time zone)
+    language sql
+as
+$$
+select d.id, cast(d.webhook_id as varchar), o.event, d.status, d.attempts, d.response_code, d.last_error,
+       d.next_try, d.date_upd
+from webhook_deliveries d
+         join webhooks h on h.id = d.webhook_id
+         join outbox o on o.id = d.outbox_id
+where h.user_id = _user_id
+  and h.id = _webhook_id
+order by d.id desc;
+$$;
+
+create or replace function delivery_redeliver(_user_id uuid, _id bigint) returns boolean
+    language plpgsql
+as
+$$
+begin
+    update webhook_deliveries d
+    set status   = 'PENDING',
+        attempts = 0,
+        next_try = now(),
+        date_upd = now()
+    from webhooks h
+    where h.id = d.webhook_id
+      and h.user_id = _user_id
+      and d.id = _id;
+    return found;
+end;
+$$;
+
+drop function if exists order_update(bigint, varchar, bigint);
+
+create function order_update(_num bigint, _status character varying, _accrual bigint) returns character varying
+    language sql
+as
+$$
+update orders
+set status  = _status,
+    accural = _accrual
+where num = _num
+returning cast(user_id as varchar);
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,106 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists sessions
+(
+    token_hash bytea                   not null
+        constraint sessions_pk
+            primary key,
+    user_id    uuid                    not null
+        constraint sessions_fk
+            references users
+            on delete cascade,
+    date_ins   timestamp default now() not null,
+    expires    timestamp               not null
+);
+
+create index if not exists sessions_user_idx
+    on sessions (user_id);
+
+create table if not exists password_resets
+(
+    token_hash bytea                   not null
+        constraint password_resets_pk
+            primary key,
+    user_id    uuid                    not null
+        constraint password_resets_fk
+            references users
+            on delete cascade,
+    date_ins   timestamp default now() not null,
+    expires    timestamp               not null,
+    used       boolean   default false not null
+);
+
+create or replace function session_add(_user_id uuid, _token_hash bytea, _ttl interval) returns void
+    language sql
+as
+$$
+delete from sessions where expires < now();
+insert into sessions (token_hash, user_id, expires)
+values (_token_hash, _user_id, now() + _ttl);
+$$;
+
+create or replace function session_check(_token_hash bytea, OUT id character varying, OUT login character varying) returns record
+    language sql
+as
+$$
+select cast(u.id as varchar), u.login
+from sessions s
+         join users u on u.id = s.user_id
+where s.token_hash = _token_hash
+  and s.expires > now()
+$$;
+
+-- смена пароля отзывает все сессии и неиспользованные токены сброса
+create or replace function user_password_set(_user_id uuid, _hash bytea) returns void
+    language sql
+as
+$$
+update users set hash = _hash where id = _user_id;
+delete from sessions where user_id = _user_id;
+delete from password_resets where user_id = _user_id and not used;
+$$;
+
+create or replace function password_reset_add(_login character varying, _token_hash bytea, _ttl interval) returns boolean
+    language plpgsql
+as
+$$
+declare
+    _user_id uuid;
+begin
+    select u.id into _user_id from users u where u.login = _login;
+    if _user_id is null then
+        return false;
+    end if;
+    delete from password_resets where user_id = _user_id and not used;
+    insert into password_resets (token_hash, user_id, expires)
+    values (_token_hash, _user_id, now() + _ttl);
+    return true;
+end;
+$$;
+
+create or replace function password_reset_use(_login character varying, _token_hash bytea, _hash bytea) returns boolean
+    language plpgsql
+as
+$$
+declare
+    _user_id uuid;
+begin
+    select r.user_id into _user_id
+    from password_resets r
+             join users u on u.id = r.user_id
+    where r.token_hash = _token_hash
+      and u.login = _login
+      and not r.used
+      and r.expires > now()
+        for update of r;
+    if _user_id is null then
+        return false;
+    end if;
+    update password_resets set used = true where token_hash = _token_hash;
+    perform user_password_set(_user_id, _hash);
+    return true;
+end;
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- счетчики неудачных входов по логину (kind = 'login') и по адресу клиента (kind = 'ip')
+create table if not exists login_attempts
+(
+    kind         varchar                 not null,
+    key          varchar                 not null,
+    failures     integer   default 0     not null,
+    last_failure timestamp default now() not null,
+    locked_until timestamp,
+    constraint login_attempts_pk
+        primary key (kind, key)
+);
+
+-- интервалы отдаются в секундах относительно now() базы, чтобы не зависеть от часового пояса
+create or replace function login_attempts_get(_login character varying, _ip character varying)
+    returns TABLE(kind character varying, failures integer, since_failure double precision, locked_for double precision)
+    language sql
+as
+$$
+select a.kind,
+       a.failures,
+       extract(epoch from now() - a.last_failure),
+       greatest(coalesce(extract(epoch from a.locked_until - now()), 0), 0)
+from login_attempts a
+where (a.kind = 'login' and a.key = _login)
+   or (a.kind = 'ip' and a.key = _ip);
+$$;
+
+-- после _lock_after неудач подряд (в пределах _window) ключ блокируется на _lock,
+-- после истечения блокировки счет начинается заново
+create or replace function login_attempt_fail(_kind character varying, _key character varying, _lock_after integer,
+                                              _lock interval, _window interval)
+    returns TABLE(failures integer, since_failure double precision, locked_for double precision)
+    language sql
+as
+$$
+insert into login_attempts as a (kind, key, failures, last_failure)
+values (_kind, _key, 1, now())
+on conflict on constraint login_attempts_pk
+    do update set failures     = case
+                                     when a.last_failure < now() - _window or a.locked_until < now() then 1
+                                     else a.failures + 1 end,
+                  last_failure = now(),
+                  locked_until = case
+                                     when a.last_failure < now() - _window or a.locked_until < now() then null
+                                     when a.failures + 1 >= _lock_after then now() + _lock
+                                     else a.locked_until end
+returning a.failures, 0::double precision, greatest(coalesce(extract(epoch from a.locked_until - now()), 0), 0);
+$$;
+
+create or replace function login_attempt_reset(_kind character varying, _key character varying) returns boolean
+    language plpgsql
+as
+$$
+begin
+    delete from login_attempts a where a.kind = _kind and a.key = _key;
+    return found;
+end;
+$$;
+
+-- +goose StatementEnd
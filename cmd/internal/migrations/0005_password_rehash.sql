@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- пересчет хэша по новой политике: сессии не отзываются,
+-- и хэш не меняется, если пароль успели сменить параллельно
+create or replace function user_hash_upgrade(_user_id uuid, _old bytea, _hash bytea) returns boolean
+    language sql
+as
+$$
+with upd as (
+    update users set hash = _hash
+    where id = _user_id
+      and hash = _old
+    returning id)
+select exists(select 1 from upd)
+$$;
+
+-- +goose StatementEnd
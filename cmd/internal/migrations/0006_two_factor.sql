@@ -0,0 +1,97 @@
+-- +goose Up
+-- +goose StatementBegin
+
+create table if not exists user_totp
+(
+    user_id   uuid                    not null
+        constraint user_totp_pk
+            primary key
+        constraint user_totp_fk
+            references users
+            on delete cascade,
+    secret    character varying       not null,
+    confirmed boolean   default false not null,
+    last_step bigint    default 0     not null, -- последний принятый шаг, повтор кода не принимается
+    date_ins  timestamp default now() not null
+);
+
+create table if not exists recovery_codes
+(
+    code_hash bytea                 not null,
+    user_id   uuid                  not null
+        constraint recovery_codes_fk
+            references user_totp
+            on delete cascade,
+    used      boolean default false not null,
+    constraint recovery_codes_pk
+        primary key (user_id, code_hash)
+);
+
+-- новая привязка заменяет неподтвержденную; подтвержденную нужно сначала отключить
+create or replace function totp_enroll(_user_id uuid, _secret character varying, _codes bytea[]) returns boolean
+    language plpgsql
+as
+$$
+begin
+    if exists(select 1 from user_totp where user_id = _user_id and confirmed) then
+        return false;
+    end if;
+    delete from user_totp where user_id = _user_id;
+    insert into user_totp (user_id, secret) values (_user_id, _secret);
+    insert into recovery_codes (code_hash, user_id)
+    select c, _user_id
+    from unnest(_codes) c;
+    return true;
+end;
+$$;
+
+create or replace function totp_get(_user_id uuid, OUT secret character varying, OUT confirmed boolean) returns record
+    language sql
+as
+$$
+select t.secret, t.confirmed
+from user_totp t
+where t.user_id = _user_id
+$$;
+
+-- принимает шаг кода, если он новее последнего принятого
+create or replace function totp_step_use(_user_id uuid, _step bigint) returns boolean
+    language sql
+as
+$$
+with upd as (
+    update user_totp set last_step = _step
+    where user_id = _user_id
+      and last_step < _step
+    returning user_id)
+select exists(select 1 from upd)
+$$;
+
+create or replace function totp_confirm(_user_id uuid) returns void
+    language sql
+as
+$$
+update user_totp set confirmed = true where user_id = _user_id;
+$$;
+
+create or replace function totp_disable(_user_id uuid) returns void
+    language sql
+as
+$$
+delete from user_totp where user_id = _user_id;
+$$;
+
+create or replace function recovery_code_use(_user_id uuid, _code_hash bytea) returns boolean
+    language sql
+as
+$$
+with upd as (
+    update recovery_codes set used = true
+    where user_id = _user_id
+      and code_hash = _code_hash
+      and not used
+    returning user_id)
+select exists(select 1 from upd)
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+
+alter table users
+    add column if not exists role character varying default 'user' not null
+        constraint users_role_check
+            check (role in ('user', 'support', 'admin'));
+
+drop function if exists user_check(character varying);
+
+create or replace function user_check(_login character varying, OUT id character varying, OUT hash bytea,
+                                      OUT role character varying) returns record
+    language sql
+as
+$$
+select cast(u.id as varchar), u.hash, u.role
+from users u
+where u.login = _login
+$$;
+
+drop function if exists session_check(bytea);
+
+create or replace function session_check(_token_hash bytea, OUT id character varying, OUT login character varying,
+                                         OUT role character varying) returns record
+    language sql
+as
+$$
+select cast(u.id as varchar), u.login, u.role
+from sessions s
+         join users u on u.id = s.user_id
+where s.token_hash = _token_hash
+  and s.expires > now()
+$$;
+
+create or replace function user_find(_login character varying, OUT id character varying, OUT login character varying,
+                                     OUT role character varying) returns record
+    language sql
+as
+$$
+select cast(u.id as varchar), u.login, u.role
+from users u
+where u.login = _login
+$$;
+
+create or replace function users_search(_query character varying, _limit integer, _offset integer)
+    returns TABLE(id character varying, login character varying, role character varying)
+    language sql
+as
+$$
+select cast(u.id as varchar), u.login, u.role
+from users u
+where u.login ilike '%' || replace(replace(replace(_query, '\', '\\'), '%', '\%'), '_', '\_') || '%'
+order by u.login
+limit _limit offset _offset
+$$;
+
+create or replace function user_role_set(_login character varying, _role character varying) returns boolean
+    language sql
+as
+$$
+with upd as (
+    update users set role = _role
+    where login = _login
+    returning id)
+select exists(select 1 from upd)
+$$;
+
+create or replace function order_get(_num bigint)
+    returns TABLE(user_id character varying, num bigint, status character varying, accural bigint, date_ins timestamp without time zone)
+    language sql
+as
+$$
+select cast(o.user_id as varchar), o.num, o.status, o.accural, o.date_ins
+from orders o
+where o.num = _num
+$$;
+
+-- +goose StatementEnd
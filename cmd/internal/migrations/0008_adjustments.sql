@@ -0,0 +1,116 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- движения баллов помимо начислений за заказы и списаний: корректировки и т.п.
+create table if not exists ledger
+(
+    id        bigint generated always as identity
+        constraint ledger_pk
+            primary key,
+    user_id   uuid                    not null
+        constraint ledger_fk
+            references users
+            on delete cascade,
+    kind      character varying       not null,
+    amount    bigint                  not null, -- со знаком: > 0 зачисление, < 0 списание
+    reference character varying       not null,
+    comment   character varying,
+    actor_id  uuid,                             -- сотрудник, выполнивший операцию
+    date_ins  timestamp default now() not null,
+    constraint ledger_un
+        unique (user_id, kind, reference)
+);
+
+create table if not exists audit_log
+(
+    id       bigint generated always as identity
+        constraint audit_log_pk
+            primary key,
+    actor_id uuid                    not null,
+    action   character varying       not null,
+    user_id  uuid,
+    payload  jsonb,
+    date_ins timestamp default now() not null
+);
+
+create index if not exists audit_log_user_idx
+    on audit_log (user_id, date_ins);
+
+create or replace view user_balance(id, accs, exps, ledg) as
+select u.id,
+       coalesce((select sum(o.accural) from orders o where o.user_id = u.id and o.status = 'PROCESSED'), 0) as accs,
+       coalesce((select sum(w.expence) from withdraws w where w.user_id = u.id), 0)                         as exps,
+       coalesce((select sum(l.amount) from ledger l where l.user_id = u.id), 0)                             as ledg
+from users u;
+
+create or replace function balance(_user_id uuid)
+    returns TABLE(balance bigint, expence bigint)
+    language sql
+as
+$$
+select (b.accs - b.exps + b.ledg) as balance, b.exps as expence
+from user_balance b
+where b.id = _user_id
+$$;
+
+create or replace function audit_add(_actor_id uuid, _action character varying, _user_id uuid, _payload jsonb) returns void
+    language sql
+as
+$$
+insert into audit_log (actor_id, action, user_id, payload)
+values (_actor_id, _action, _user_id, _payload);
+$$;
+
+-- корректировка баланса сотрудником: 'OK', 'DUPLICATE' - reference уже использован, 'INSUFFICIENT' - списание больше баланса
+create or replace function adjustment_add(_user_id uuid, _actor_id uuid, _amount bigint, _reason character varying,
+                                          _reference character varying, OUT id bigint, OUT result character varying) returns record
+    language plpgsql
+as
+$$
+begin
+    perform 1 from users u where u.id = _user_id for update;
+    if exists(select 1 from ledger l where l.user_id = _user_id and l.kind = 'adjustment' and l.reference = _reference) then
+        result := 'DUPLICATE';
+        return;
+    end if;
+    if _amount < 0 and (select b.balance from balance(_user_id) b) + _amount < 0 then
+        result := 'INSUFFICIENT';
+        return;
+    end if;
+    insert into ledger (user_id, kind, amount, reference, comment, actor_id)
+    values (_user_id, 'adjustment', _amount, _reference, _reason, _actor_id)
+    returning ledger.id into id;
+    perform audit_add(_actor_id, 'balance.adjust', _user_id,
+                      jsonb_build_object('ledger_id', id, 'amount', _amount, 'reason', _reason, 'reference', _reference));
+    result := 'OK';
+end;
+$$;
+
+-- история движений баллов пользователя, старые первыми
+create or replace function history(_user_id uuid)
+    returns TABLE(kind character varying, reference character varying, amount bigint, comment character varying,
+                  date_ins timestamp without time zone)
+    language sql
+as
+$$
+select *
+from (select cast('accrual' as varchar) as kind,
+             cast(o.num as varchar)     as reference,
+             o.accural                  as amount,
+             cast(null as varchar)      as comment,
+             o.date_ins                 as date_ins
+      from orders o
+      where o.user_id = _user_id
+        and o.status = 'PROCESSED'
+      union all
+      select 'withdrawal', cast(w.num as varchar), -w.expence, null, w.date_ins
+      from withdraws w
+      where w.user_id = _user_id
+      union all
+      select l.kind, l.reference, l.amount, l.comment, l.date_ins
+      from ledger l
+      where l.user_id = _user_id) h
+order by h.date_ins
+$$;
+
+-- +goose StatementEnd
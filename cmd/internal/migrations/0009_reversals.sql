@@ -0,0 +1,112 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- сколько баллов списания возвращено; сами возвраты - строки ledger вида 'reversal' со ссылкой '<num>:<n>'
+alter table withdraws
+    add column if not exists reversed bigint default 0 not null;
+
+create or replace view user_balance(id, accs, exps, ledg, refs) as
+select u.id,
+       coalesce((select sum(o.accural) from orders o where o.user_id = u.id and o.status = 'PROCESSED'), 0) as accs,
+       coalesce((select sum(w.expence) from withdraws w where w.user_id = u.id), 0)                         as exps,
+       coalesce((select sum(l.amount) from ledger l where l.user_id = u.id), 0)                             as ledg,
+       coalesce((select sum(w.reversed) from withdraws w where w.user_id = u.id), 0)                        as refs
+from users u;
+
+-- withdrawn - за вычетом возвратов
+create or replace function balance(_user_id uuid)
+    returns TABLE(balance bigint, expence bigint)
+    language sql
+as
+$$
+select (b.accs - b.exps + b.ledg) as balance, (b.exps - b.refs) as expence
+from user_balance b
+where b.id = _user_id
+$$;
+
+-- списания со статусом и возвраты по ним (сумма со знаком минус), старые первыми
+drop function if exists withdrawals_all(uuid);
+
+create function withdrawals_all(_user_id uuid)
+    returns TABLE(num bigint, expence bigint, date_ins timestamp without time zone, status character varying)
+    language sql
+as
+$$
+select *
+from (select w.num,
+             w.expence,
+             w.date_ins,
+             cast(case
+                      when w.reversed = 0 then 'PROCESSED'
+                      when w.reversed < w.expence then 'PARTIALLY_REVERSED'
+                      else 'REVERSED' end as varchar) as status
+      from withdraws w
+      where w.user_id = _user_id
+      union all
+      select cast(split_part(l.reference, ':', 1) as bigint), -l.amount, l.date_ins, 'REVERSAL'
+      from ledger l
+      where l.user_id = _user_id
+        and l.kind = 'reversal') h
+order by h.date_ins
+$$;
+
+-- возврат списания по номеру заказа, _amount null - весь остаток.
+-- result: 'OK', 'NOT_FOUND', 'AMBIGUOUS' - номер списан несколькими пользователями и _login не указан,
+-- 'EXCEEDS' - сумма больше невозвращенного остатка
+create or replace function withdrawal_reverse(_num bigint, _login character varying, _actor_id uuid, _amount bigint,
+                                              _reason character varying, OUT user_id uuid, OUT amount bigint,
+                                              OUT remaining bigint, OUT result character varying) returns record
+    language plpgsql
+as
+$$
+declare
+    w withdraws%rowtype;
+    n bigint;
+begin
+    select count(*)
+    into n
+    from withdraws wd
+             join users u on u.id = wd.user_id
+    where wd.num = _num
+      and (_login is null or u.login = _login);
+    if n = 0 then
+        result := 'NOT_FOUND';
+        return;
+    elsif n > 1 then
+        result := 'AMBIGUOUS';
+        return;
+    end if;
+    select wd.*
+    into w
+    from withdraws wd
+             join users u on u.id = wd.user_id
+    where wd.num = _num
+      and (_login is null or u.login = _login)
+        for update of wd;
+    user_id := w.user_id;
+    amount := coalesce(_amount, w.expence - w.reversed);
+    if amount <= 0 or w.reversed + amount > w.expence then
+        remaining := w.expence - w.reversed;
+        result := 'EXCEEDS';
+        return;
+    end if;
+    update withdraws wd
+    set reversed = wd.reversed + amount
+    where wd.user_id = w.user_id
+      and wd.num = w.num;
+    remaining := w.expence - w.reversed - amount;
+    select count(*) + 1
+    into n
+    from ledger l
+    where l.user_id = w.user_id
+      and l.kind = 'reversal'
+      and l.reference like _num || ':%';
+    insert into ledger (user_id, kind, amount, reference, comment, actor_id)
+    values (w.user_id, 'reversal', amount, _num || ':' || n, _reason, _actor_id);
+    perform audit_add(_actor_id, 'withdrawal.reverse', w.user_id,
+                      jsonb_build_object('order', _num, 'amount', amount, 'remaining', remaining, 'reason', _reason));
+    result := 'OK';
+end;
+$$;
+
+-- +goose StatementEnd
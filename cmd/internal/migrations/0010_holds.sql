@@ -0,0 +1,169 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- резервирование баллов до оплаты: ACTIVE -> CAPTURED (стало списанием) | VOIDED | EXPIRED
+create table if not exists holds
+(
+    id         uuid      default gen_random_uuid() not null
+        constraint holds_pk
+            primary key,
+    user_id    uuid                                not null
+        constraint holds_fk
+            references users
+            on delete cascade,
+    num        bigint                              not null,
+    amount     bigint                              not null,
+    status     varchar   default 'ACTIVE'          not null,
+    expires_at timestamp                           not null,
+    date_ins   timestamp default now()             not null,
+    date_upd   timestamp
+);
+
+create unique index if not exists holds_active_un
+    on holds (user_id, num)
+    where status = 'ACTIVE';
+
+create index if not exists holds_expires_idx
+    on holds (expires_at)
+    where status = 'ACTIVE';
+
+create or replace view user_balance(id, accs, exps, ledg, refs, held) as
+select u.id,
+       coalesce((select sum(o.accural) from orders o where o.user_id = u.id and o.status = 'PROCESSED'), 0) as accs,
+       coalesce((select sum(w.expence) from withdraws w where w.user_id = u.id), 0)                         as exps,
+       coalesce((select sum(l.amount) from ledger l where l.user_id = u.id), 0)                             as ledg,
+       coalesce((select sum(w.reversed) from withdraws w where w.user_id = u.id), 0)                        as refs,
+       coalesce((select sum(h.amount)
+                 from holds h
+                 where h.user_id = u.id
+                   and h.status = 'ACTIVE'
+                   and h.expires_at > now()), 0)                                                            as held
+from users u;
+
+-- balance - доступно с учетом резервов, их сумма отдельно в held
+drop function if exists balance(uuid);
+
+create function balance(_user_id uuid)
+    returns TABLE(balance bigint, expence bigint, held bigint)
+    language sql
+as
+$$
+select (b.accs - b.exps + b.ledg - b.held) as balance, (b.exps - b.refs) as expence, b.held as held
+from user_balance b
+where b.id = _user_id
+$$;
+
+-- result: 'OK', 'DUPLICATE' - по заказу уже есть резерв или списание, 'INSUFFICIENT' - не хватает баллов
+create or replace function hold_add(_user_id uuid, _num bigint, _amount bigint, _ttl double precision,
+                                    OUT id uuid, OUT expires_at timestamp, OUT result character varying) returns record
+    language plpgsql
+as
+$$
+begin
+    perform 1 from users u where u.id = _user_id for update;
+    if exists(select 1
+              from holds h
+              where h.user_id = _user_id
+                and h.num = _num
+                and h.status = 'ACTIVE'
+                and h.expires_at > now())
+        or exists(select 1 from withdraws w where w.user_id = _user_id and w.num = _num) then
+        result := 'DUPLICATE';
+        return;
+    end if;
+    if (select b.balance from balance(_user_id) b) < _amount then
+        result := 'INSUFFICIENT';
+        return;
+    end if;
+    -- истекший, но еще не обработанный резерв по тому же заказу мешал бы уникальному индексу
+    update holds h
+    set status   = 'EXPIRED',
+        date_upd = now()
+    where h.user_id = _user_id
+      and h.num = _num
+      and h.status = 'ACTIVE';
+    insert into holds (user_id, num, amount, expires_at)
+    values (_user_id, _num, _amount, now() + make_interval(secs => _ttl))
+    returning holds.id, holds.expires_at into id, expires_at;
+    result := 'OK';
+end;
+$$;
+
+-- закрывает резерв пользователя: _capture - превращает в списание, иначе отменяет.
+-- result: 'OK', 'NOT_FOUND', 'NOT_ACTIVE' (текущий статус в status), 'DUPLICATE' - по заказу уже есть списание
+create or replace function hold_close(_user_id uuid, _id uuid, _capture boolean,
+                                      OUT num bigint, OUT amount bigint, OUT status character varying,
+                                      OUT result character varying) returns record
+    language plpgsql
+as
+$$
+declare
+    h holds%rowtype;
+begin
+    select *
+    into h
+    from holds hl
+    where hl.id = _id
+      and hl.user_id = _user_id
+        for update;
+    if not found then
+        result := 'NOT_FOUND';
+        return;
+    end if;
+    num := h.num;
+    amount := h.amount;
+    status := h.status;
+    if h.status = 'ACTIVE' and h.expires_at <= now() then
+        update holds hl set status = 'EXPIRED', date_upd = now() where hl.id = _id;
+        status := 'EXPIRED';
+    end if;
+    if status <> 'ACTIVE' then
+        result := 'NOT_ACTIVE';
+        return;
+    end if;
+    if _capture then
+        if exists(select 1 from withdraws w where w.user_id = _user_id and w.num = h.num) then
+            result := 'DUPLICATE';
+            return;
+        end if;
+        insert into withdraws (user_id, num, expence, date_ins)
+        values (_user_id, h.num, h.amount, default);
+        status := 'CAPTURED';
+    else
+        status := 'VOIDED';
+    end if;
+    update holds hl set status = hold_close.status, date_upd = now() where hl.id = _id;
+    result := 'OK';
+end;
+$$;
+
+create or replace function holds_active(_user_id uuid)
+    returns TABLE(id uuid, num bigint, amount bigint, expires_at timestamp without time zone,
+                  date_ins timestamp without time zone)
+    language sql
+as
+$$
+select h.id, h.num, h.amount, h.expires_at, h.date_ins
+from holds h
+where h.user_id = _user_id
+  and h.status = 'ACTIVE'
+  and h.expires_at > now()
+order by h.date_ins
+$$;
+
+-- переводит истекшие резервы в EXPIRED, возвращает их число
+create or replace function holds_expire() returns bigint
+    language sql
+as
+$$
+with expired as (
+    update holds h
+        set status = 'EXPIRED', date_upd = now()
+        where h.status = 'ACTIVE'
+            and h.expires_at <= now()
+        returning 1)
+select count(*)
+from expired
+$$;
+
+-- +goose StatementEnd
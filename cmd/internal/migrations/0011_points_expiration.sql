@@ -0,0 +1,98 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- партии баллов: начисления за заказы и зачисления ledger (корректировки, возвраты).
+-- списания, отрицательные записи ledger и действующие резервы гасят партии по FIFO, старые первыми.
+-- remaining - непогашенный остаток партии, expires_at - когда он сгорит
+create or replace function point_lots(_user_id uuid, _months integer)
+    returns TABLE(kind character varying, reference character varying, amount bigint, remaining bigint,
+                  date_ins timestamp without time zone, expires_at timestamp without time zone)
+    language sql
+as
+$$
+with credits as (select cast('accrual' as varchar) as kind,
+                        cast(o.num as varchar)     as reference,
+                        o.accural                  as amount,
+                        o.date_ins                 as date_ins
+                 from orders o
+                 where o.user_id = _user_id
+                   and o.status = 'PROCESSED'
+                   and o.accural > 0
+                 union all
+                 select l.kind, l.reference, l.amount, l.date_ins
+                 from ledger l
+                 where l.user_id = _user_id
+                   and l.amount > 0),
+     debits as (select b.exps - (select coalesce(sum(l.amount), 0)
+                                 from ledger l
+                                 where l.user_id = _user_id
+                                   and l.amount < 0) + b.held as total
+                from user_balance b
+                where b.id = _user_id),
+     running as (select c.*,
+                        sum(c.amount) over (order by c.date_ins, c.kind, c.reference) as upto
+                 from credits c)
+select r.kind,
+       r.reference,
+       r.amount,
+       cast(greatest(0, least(r.amount, r.upto - d.total)) as bigint) as remaining,
+       r.date_ins,
+       r.date_ins + make_interval(months => _months)                  as expires_at
+from running r,
+     debits d
+order by r.date_ins, r.kind, r.reference
+$$;
+
+-- непогашенные баллы, которые сгорят в ближайшие _within секунд
+create or replace function points_expiring(_user_id uuid, _months integer, _within double precision) returns bigint
+    language sql
+as
+$$
+select cast(coalesce(sum(p.remaining), 0) as bigint)
+from point_lots(_user_id, _months) p
+where p.expires_at <= now() + make_interval(secs => _within)
+$$;
+
+-- списывает сгоревшие остатки партий записью ledger 'expiration', возвращает число затронутых пользователей.
+-- параллельный запуск на другом экземпляре пропускается
+create or replace function points_expire(_months integer) returns bigint
+    language plpgsql
+as
+$$
+declare
+    u       uuid;
+    expired bigint;
+    n       bigint := 0;
+begin
+    if not pg_try_advisory_xact_lock(hashtext('points_expire')) then
+        return 0;
+    end if;
+    for u in select o.user_id
+             from orders o
+             where o.status = 'PROCESSED'
+               and o.date_ins + make_interval(months => _months) <= now()
+             union
+             select l.user_id
+             from ledger l
+             where l.amount > 0
+               and l.date_ins + make_interval(months => _months) <= now()
+        loop
+            perform 1 from users us where us.id = u for update;
+            select coalesce(sum(p.remaining), 0)
+            into expired
+            from point_lots(u, _months) p
+            where p.expires_at <= now();
+            if expired > 0 then
+                insert into ledger (user_id, kind, amount, reference, comment)
+                values (u, 'expiration', -expired, to_char(now(), 'YYYY-MM-DD"T"HH24:MI:SS.US'),
+                        'points accrued before ' || to_char(now() - make_interval(months => _months), 'YYYY-MM-DD') ||
+                        ' expired')
+                on conflict on constraint ledger_un do nothing;
+                n := n + 1;
+            end if;
+        end loop;
+    return n;
+end;
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,163 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- перевод баллов между пользователями: COMPLETED сразу или PENDING до подтверждения получателем
+-- (затем COMPLETED | DECLINED), отправитель может отменить ожидающий перевод (CANCELED).
+-- движения баллов - строки ledger 'transfer_out', 'transfer_in', 'transfer_return' со ссылкой на id перевода
+create table if not exists transfers
+(
+    id       uuid      default gen_random_uuid() not null
+        constraint transfers_pk
+            primary key,
+    from_id  uuid                                not null
+        constraint transfers_from_fk
+            references users
+            on delete cascade,
+    to_id    uuid                                not null
+        constraint transfers_to_fk
+            references users
+            on delete cascade,
+    amount   bigint                              not null,
+    comment  character varying,
+    status   character varying                   not null,
+    date_ins timestamp default now()             not null,
+    date_upd timestamp
+);
+
+create index if not exists transfers_from_idx
+    on transfers (from_id, date_ins);
+
+create index if not exists transfers_to_idx
+    on transfers (to_id, date_ins);
+
+-- result: 'OK', 'NOT_FOUND' - нет получателя, 'SELF', 'LIMIT' - превышен дневной лимит (остаток в remaining),
+-- 'INSUFFICIENT'. _daily_limit и _confirm_above: 0 - без ограничения
+create or replace function transfer_add(_from_id uuid, _to_login character varying, _amount bigint,
+                                        _comment character varying, _daily_limit bigint, _confirm_above bigint,
+                                        OUT id uuid, OUT to_id uuid, OUT status character varying,
+                                        OUT remaining bigint, OUT result character varying) returns record
+    language plpgsql
+as
+$$
+declare
+    sent bigint;
+begin
+    select u.id into to_id from users u where u.login = _to_login;
+    if to_id is null then
+        result := 'NOT_FOUND';
+        return;
+    end if;
+    if to_id = _from_id then
+        result := 'SELF';
+        return;
+    end if;
+    -- обе стороны в одном порядке, чтобы встречные переводы не взаимоблокировались
+    perform 1 from users u where u.id in (_from_id, to_id) order by u.id for update;
+    if _daily_limit > 0 then
+        select coalesce(sum(t.amount), 0)
+        into sent
+        from transfers t
+        where t.from_id = _from_id
+          and t.status in ('PENDING', 'COMPLETED')
+          and t.date_ins >= date_trunc('day', now());
+        remaining := greatest(0, _daily_limit - sent);
+        if _amount > remaining then
+            result := 'LIMIT';
+            return;
+        end if;
+    end if;
+    if (select b.balance from balance(_from_id) b) < _amount then
+        result := 'INSUFFICIENT';
+        return;
+    end if;
+    status := case when _confirm_above > 0 and _amount > _confirm_above then 'PENDING' else 'COMPLETED' end;
+    insert into transfers (from_id, to_id, amount, comment, status)
+    values (_from_id, to_id, _amount, _comment, status)
+    returning transfers.id into id;
+    insert into ledger (user_id, kind, amount, reference, comment)
+    values (_from_id, 'transfer_out', -_amount, cast(id as varchar), 'to ' || _to_login || coalesce(': ' || _comment, ''));
+    if status = 'COMPLETED' then
+        insert into ledger (user_id, kind, amount, reference, comment)
+        values (to_id, 'transfer_in', _amount, cast(id as varchar),
+                'from ' || (select u.login from users u where u.id = _from_id) || coalesce(': ' || _comment, ''));
+    end if;
+    result := 'OK';
+end;
+$$;
+
+-- решение по ожидающему переводу: 'accept' и 'decline' - получатель, 'cancel' - отправитель.
+-- result: 'OK', 'NOT_FOUND' - нет перевода или пользователь не та сторона, 'NOT_PENDING' (статус в status)
+create or replace function transfer_close(_user_id uuid, _id uuid, _action character varying,
+                                          OUT status character varying, OUT result character varying) returns record
+    language plpgsql
+as
+$$
+declare
+    t          transfers%rowtype;
+    from_login character varying;
+    to_login   character varying;
+begin
+    select *
+    into t
+    from transfers tr
+    where tr.id = _id
+        for update;
+    if not found or (_action = 'cancel' and t.from_id <> _user_id) or
+       (_action in ('accept', 'decline') and t.to_id <> _user_id) then
+        result := 'NOT_FOUND';
+        return;
+    end if;
+    status := t.status;
+    if t.status <> 'PENDING' then
+        result := 'NOT_PENDING';
+        return;
+    end if;
+    perform 1 from users u where u.id in (t.from_id, t.to_id) order by u.id for update;
+    select u.login into from_login from users u where u.id = t.from_id;
+    select u.login into to_login from users u where u.id = t.to_id;
+    if _action = 'accept' then
+        insert into ledger (user_id, kind, amount, reference, comment)
+        values (t.to_id, 'transfer_in', t.amount, cast(t.id as varchar), 'from ' || from_login || coalesce(': ' || t.comment, ''));
+        status := 'COMPLETED';
+    else
+        insert into ledger (user_id, kind, amount, reference, comment)
+        values (t.from_id, 'transfer_return', t.amount, cast(t.id as varchar),
+                case when _action = 'cancel' then 'canceled' else 'declined by ' || to_login end);
+        status := case when _action = 'cancel' then 'CANCELED' else 'DECLINED' end;
+    end if;
+    update transfers tr set status = transfer_close.status, date_upd = now() where tr.id = _id;
+    result := 'OK';
+end;
+$$;
+
+-- переводы пользователя в обе стороны, новые первыми
+create or replace function transfers_all(_user_id uuid)
+    returns TABLE(id uuid, from_login character varying, to_login character varying, amount bigint,
+                  comment character varying, status character varying, date_ins timestamp without time zone)
+    language sql
+as
+$$
+select t.id, f.login, r.login, t.amount, t.comment, t.status, t.date_ins
+from transfers t
+         join users f on f.id = t.from_id
+         join users r on r.id = t.to_id
+where t.from_id = _user_id
+   or t.to_id = _user_id
+order by t.date_ins desc
+$$;
+
+create or replace function transfer_get(_id uuid)
+    returns TABLE(id uuid, from_id uuid, to_id uuid, from_login character varying, to_login character varying,
+                  amount bigint, comment character varying, status character varying,
+                  date_ins timestamp without time zone)
+    language sql
+as
+$$
+select t.id, t.from_id, t.to_id, f.login, r.login, t.amount, t.comment, t.status, t.date_ins
+from transfers t
+         join users f on f.id = t.from_id
+         join users r on r.id = t.to_id
+where t.id = _id
+$$;
+
+-- +goose StatementEnd
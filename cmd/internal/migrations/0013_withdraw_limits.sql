@@ -0,0 +1,202 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- дата регистрации для паузы перед первым списанием; у зарегистрированных ранее не заполнена - паузы нет
+alter table users
+    add column if not exists date_ins timestamp;
+
+alter table users
+    alter column date_ins set default now();
+
+-- индивидуальные правила списаний, null - действует общее правило из конфигурации
+create table if not exists withdraw_limits
+(
+    user_id    uuid not null
+        constraint withdraw_limits_pk
+            primary key
+        constraint withdraw_limits_fk
+            references users
+            on delete cascade,
+    min_amount bigint,
+    max_tx     bigint,
+    daily      bigint,
+    monthly    bigint,
+    cooldown   bigint -- секунд после регистрации
+);
+
+create or replace function withdraw_limits_get(_user_id uuid)
+    returns TABLE(min_amount bigint, max_tx bigint, daily bigint, monthly bigint, cooldown bigint)
+    language sql
+as
+$$
+select l.min_amount, l.max_tx, l.daily, l.monthly, l.cooldown
+from withdraw_limits l
+where l.user_id = _user_id
+$$;
+
+create or replace function withdraw_limits_set(_user_id uuid, _min bigint, _max bigint, _daily bigint, _monthly bigint,
+                                               _cooldown bigint) returns void
+    language sql
+as
+$$
+insert into withdraw_limits (user_id, min_amount, max_tx, daily, monthly, cooldown)
+values (_user_id, _min, _max, _daily, _monthly, _cooldown)
+on conflict on constraint withdraw_limits_pk do update
+    set min_amount = excluded.min_amount,
+        max_tx     = excluded.max_tx,
+        daily      = excluded.daily,
+        monthly    = excluded.monthly,
+        cooldown   = excluded.cooldown;
+$$;
+
+-- проверка правил списания суммы _amount; общие правила в параметрах, 0 - правило отключено.
+-- за день и месяц учитываются списания за вычетом возвратов и действующие резервы.
+-- result: 'OK', 'BELOW_MIN', 'ABOVE_MAX', 'DAILY_LIMIT', 'MONTHLY_LIMIT', 'COOLDOWN';
+-- remaining - допустимая сумма (для COOLDOWN - секунд до окончания паузы)
+create or replace function withdraw_rules_check(_user_id uuid, _amount bigint, _min bigint, _max bigint,
+                                                _daily bigint, _monthly bigint, _cooldown bigint,
+                                                OUT remaining bigint, OUT result character varying) returns record
+    language plpgsql
+as
+$$
+declare
+    r    withdraw_limits%rowtype;
+    reg  timestamp;
+    used bigint;
+begin
+    select * into r from withdraw_limits l where l.user_id = _user_id;
+    _min := coalesce(r.min_amount, _min);
+    _max := coalesce(r.max_tx, _max);
+    _daily := coalesce(r.daily, _daily);
+    _monthly := coalesce(r.monthly, _monthly);
+    _cooldown := coalesce(r.cooldown, _cooldown);
+    select u.date_ins into reg from users u where u.id = _user_id;
+    if _cooldown > 0 and reg is not null and reg + make_interval(secs => _cooldown) > now() then
+        remaining := ceil(extract(epoch from reg + make_interval(secs => _cooldown) - now()));
+        result := 'COOLDOWN';
+        return;
+    end if;
+    if _min > 0 and _amount < _min then
+        remaining := _min;
+        result := 'BELOW_MIN';
+        return;
+    end if;
+    if _max > 0 and _amount > _max then
+        remaining := _max;
+        result := 'ABOVE_MAX';
+        return;
+    end if;
+    if _daily > 0 then
+        used := (select coalesce(sum(w.expence - w.reversed), 0)
+                 from withdraws w
+                 where w.user_id = _user_id
+                   and w.date_ins >= date_trunc('day', now()))
+            + (select coalesce(sum(h.amount), 0)
+               from holds h
+               where h.user_id = _user_id
+                 and h.status = 'ACTIVE'
+                 and h.expires_at > now()
+                 and h.date_ins >= date_trunc('day', now()));
+        if used + _amount > _daily then
+            remaining := greatest(0, _daily - used);
+            result := 'DAILY_LIMIT';
+            return;
+        end if;
+    end if;
+    if _monthly > 0 then
+        used := (select coalesce(sum(w.expence - w.reversed), 0)
+                 from withdraws w
+                 where w.user_id = _user_id
+                   and w.date_ins >= date_trunc('month', now()))
+            + (select coalesce(sum(h.amount), 0)
+               from holds h
+               where h.user_id = _user_id
+                 and h.status = 'ACTIVE'
+                 and h.expires_at > now()
+                 and h.date_ins >= date_trunc('month', now()));
+        if used + _amount > _monthly then
+            remaining := greatest(0, _monthly - used);
+            result := 'MONTHLY_LIMIT';
+            return;
+        end if;
+    end if;
+    result := 'OK';
+end;
+$$;
+
+-- списание с проверкой правил под блокировкой пользователя.
+-- result: 'OK', 'INSUFFICIENT' или код нарушенного правила из withdraw_rules_check
+drop function if exists withdraw(uuid, bigint, bigint);
+
+create function withdraw(_user_id uuid, _number bigint, _expence bigint, _min bigint, _max bigint, _daily bigint,
+                         _monthly bigint, _cooldown bigint,
+                         OUT remaining bigint, OUT result character varying) returns record
+    language plpgsql
+as
+$$
+begin
+    perform 1 from users u where u.id = _user_id for update;
+    select c.remaining, c.result
+    into remaining, result
+    from withdraw_rules_check(_user_id, _expence, _min, _max, _daily, _monthly, _cooldown) c;
+    if result <> 'OK' then
+        return;
+    end if;
+    if (select b.balance from balance(_user_id) b) <= _expence then
+        remaining := null;
+        result := 'INSUFFICIENT';
+        return;
+    end if;
+    insert into withdraws (user_id, num, expence, date_ins)
+    values (_user_id, _number, _expence, default)
+    on conflict on constraint withdraws_pk do nothing;
+end;
+$$;
+
+-- резерв проверяется по тем же правилам, что и списание
+drop function if exists hold_add(uuid, bigint, bigint, double precision);
+
+create function hold_add(_user_id uuid, _num bigint, _amount bigint, _ttl double precision, _min bigint, _max bigint,
+                         _daily bigint, _monthly bigint, _cooldown bigint,
+                         OUT id uuid, OUT expires_at timestamp, OUT remaining bigint,
+                         OUT result character varying) returns record
+    language plpgsql
+as
+$$
+begin
+    perform 1 from users u where u.id = _user_id for update;
+    if exists(select 1
+              from holds h
+              where h.user_id = _user_id
+                and h.num = _num
+                and h.status = 'ACTIVE'
+                and h.expires_at > now())
+        or exists(select 1 from withdraws w where w.user_id = _user_id and w.num = _num) then
+        result := 'DUPLICATE';
+        return;
+    end if;
+    select c.remaining, c.result
+    into remaining, result
+    from withdraw_rules_check(_user_id, _amount, _min, _max, _daily, _monthly, _cooldown) c;
+    if result <> 'OK' then
+        return;
+    end if;
+    if (select b.balance from balance(_user_id) b) < _amount then
+        remaining := null;
+        result := 'INSUFFICIENT';
+        return;
+    end if;
+    update holds h
+    set status   = 'EXPIRED',
+        date_upd = now()
+    where h.user_id = _user_id
+      and h.num = _num
+      and h.status = 'ACTIVE';
+    insert into holds (user_id, num, amount, expires_at)
+    values (_user_id, _num, _amount, now() + make_interval(secs => _ttl))
+    returning holds.id, holds.expires_at into id, expires_at;
+    result := 'OK';
+end;
+$$;
+
+-- +goose StatementEnd
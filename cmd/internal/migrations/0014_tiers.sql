@@ -0,0 +1,117 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- уровни программы лояльности: threshold - сумма начислений за скользящее окно, multiplier - множитель начислений
+create table if not exists tiers
+(
+    name       character varying not null
+        constraint tiers_pk
+            primary key,
+    threshold  bigint            not null
+        constraint tiers_un
+            unique,
+    multiplier numeric(6, 3)     not null
+);
+
+insert into tiers (name, threshold, multiplier)
+values ('base', 0, 1),
+       ('silver', 1000, 1.1),
+       ('gold', 5000, 1.25),
+       ('platinum', 20000, 1.5)
+on conflict do nothing;
+
+alter table users
+    add column if not exists tier character varying default 'base' not null
+        constraint users_tier_fk
+            references tiers;
+
+-- начисление системы расчета до умножения и примененный множитель
+alter table orders
+    add column if not exists accural_base bigint;
+
+alter table orders
+    add column if not exists multiplier numeric(6, 3) default 1 not null;
+
+-- начисление умножается на множитель текущего уровня пользователя
+drop function if exists order_update(bigint, varchar, bigint);
+
+create function order_update(_num bigint, _status character varying, _accrual bigint)
+    returns TABLE(user_id character varying, accrual bigint)
+    language sql
+as
+$$
+update orders o
+set status       = _status,
+    accural_base = _accrual,
+    multiplier   = t.multiplier,
+    accural      = floor(_accrual * t.multiplier)
+from users u
+         join tiers t on t.name = u.tier
+where o.num = _num
+  and u.id = o.user_id
+returning cast(o.user_id as varchar), o.accural;
+$$;
+
+-- начислено за окно _window секунд до умножения
+create or replace function tier_accrued(_user_id uuid, _window double precision) returns bigint
+    language sql
+as
+$$
+select cast(coalesce(sum(coalesce(o.accural_base, o.accural)), 0) as bigint)
+from orders o
+where o.user_id = _user_id
+  and o.status = 'PROCESSED'
+  and o.date_ins > now() - make_interval(secs => _window)
+$$;
+
+create or replace function profile(_user_id uuid, _window double precision)
+    returns TABLE(login character varying, tier character varying, multiplier numeric, accrued bigint,
+                  next_tier character varying, next_threshold bigint, date_ins timestamp without time zone)
+    language sql
+as
+$$
+select u.login, u.tier, t.multiplier, a.accrued, n.name, n.threshold, u.date_ins
+from users u
+         join tiers t on t.name = u.tier
+         cross join lateral (select tier_accrued(u.id, _window) as accrued) a
+         left join lateral (select nt.name, nt.threshold
+                            from tiers nt
+                            where nt.threshold > t.threshold
+                            order by nt.threshold
+                            limit 1) n on true
+where u.id = _user_id
+$$;
+
+-- пересчет уровней всех пользователей, возвращает изменившиеся. параллельный запуск пропускается
+create or replace function tiers_recalc(_window double precision)
+    returns TABLE(user_id uuid, old_tier character varying, new_tier character varying, multiplier numeric)
+    language plpgsql
+as
+$$
+begin
+    if not pg_try_advisory_xact_lock(hashtext('tiers_recalc')) then
+        return;
+    end if;
+    return query
+        with target as (select u.id,
+                               u.tier as old_tier,
+                               (select t.name
+                                from tiers t
+                                where t.threshold <= tier_accrued(u.id, _window)
+                                order by t.threshold desc
+                                limit 1) as new_tier
+                        from users u),
+             changed as (update users u
+                 set tier = tg.new_tier
+                 from target tg
+                 where u.id = tg.id
+                     and tg.new_tier is not null
+                     and u.tier <> tg.new_tier
+                 returning u.id, tg.old_tier, tg.new_tier)
+        select c.id, c.old_tier, c.new_tier, t.multiplier
+        from changed c
+                 join tiers t on t.name = c.new_tier;
+end;
+$$;
+
+-- +goose StatementEnd
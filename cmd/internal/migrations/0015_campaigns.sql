@@ -0,0 +1,148 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- маркетинговые акции: при переходе заказа в PROCESSED действующая акция начисляет бонус отдельной записью ledger
+-- 'bonus' со ссылкой '<номер заказа>:<id акции>'. kind: 'multiplier' - бонус accrual * (value - 1) от начисления
+-- системы расчета, 'fixed' - value баллов. condition: 'any' | 'first_order' - только первый обработанный заказ
+create table if not exists campaigns
+(
+    id          uuid      default gen_random_uuid() not null
+        constraint campaigns_pk
+            primary key,
+    name        character varying                   not null,
+    kind        character varying                   not null
+        constraint campaigns_kind_check
+            check (kind in ('multiplier', 'fixed')),
+    value       numeric(12, 3)                      not null,
+    condition   character varying default 'any'     not null
+        constraint campaigns_condition_check
+            check (condition in ('any', 'first_order')),
+    min_accrual bigint    default 0                 not null,
+    starts_at   timestamp                           not null,
+    ends_at     timestamp                           not null,
+    active      boolean   default true              not null,
+    date_ins    timestamp default now()             not null
+);
+
+create index if not exists campaigns_period_idx
+    on campaigns (starts_at, ends_at)
+    where active;
+
+create or replace function campaign_add(_name character varying, _kind character varying, _value numeric,
+                                        _condition character varying, _min_accrual bigint, _starts_at timestamp,
+                                        _ends_at timestamp, _active boolean)
+    returns TABLE(id uuid, date_ins timestamp without time zone)
+    language sql
+as
+$$
+insert into campaigns (name, kind, value, condition, min_accrual, starts_at, ends_at, active)
+values (_name, _kind, _value, _condition, _min_accrual, _starts_at, _ends_at, _active)
+returning campaigns.id, campaigns.date_ins;
+$$;
+
+-- нет строки - акция не найдена
+create or replace function campaign_update(_id uuid, _name character varying, _kind character varying,
+                                           _value numeric, _condition character varying, _min_accrual bigint,
+                                           _starts_at timestamp, _ends_at timestamp, _active boolean)
+    returns TABLE(date_ins timestamp without time zone)
+    language sql
+as
+$$
+update campaigns c
+set name        = _name,
+    kind        = _kind,
+    value       = _value,
+    condition   = _condition,
+    min_accrual = _min_accrual,
+    starts_at   = _starts_at,
+    ends_at     = _ends_at,
+    active      = _active
+where c.id = _id
+returning c.date_ins;
+$$;
+
+create or replace function campaigns_all()
+    returns TABLE(id uuid, name character varying, kind character varying, value numeric,
+                  condition character varying, min_accrual bigint, starts_at timestamp without time zone,
+                  ends_at timestamp without time zone, active boolean, date_ins timestamp without time zone)
+    language sql
+as
+$$
+select c.id, c.name, c.kind, c.value, c.condition, c.min_accrual, c.starts_at, c.ends_at, c.active, c.date_ins
+from campaigns c
+order by c.starts_at desc
+$$;
+
+create or replace function campaign_delete(_id uuid) returns boolean
+    language sql
+as
+$$
+with deleted as (delete from campaigns c where c.id = _id returning 1)
+select exists(select 1 from deleted)
+$$;
+
+-- бонусы действующих акций за обработанный заказ, повторный вызов ничего не добавляет
+create or replace function campaigns_apply(_user_id uuid, _num bigint, _accrual bigint) returns bigint
+    language sql
+as
+$$
+with bonuses as (
+    insert into ledger (user_id, kind, amount, reference, comment)
+        select _user_id,
+               'bonus',
+               case c.kind
+                   when 'multiplier' then floor(_accrual * (c.value - 1))
+                   else floor(c.value) end,
+               _num || ':' || c.id,
+               c.name
+        from campaigns c
+        where c.active
+          and now() between c.starts_at and c.ends_at
+          and _accrual >= c.min_accrual
+          and (c.condition = 'any' or not exists(select 1
+                                                  from orders o
+                                                  where o.user_id = _user_id
+                                                    and o.status = 'PROCESSED'
+                                                    and o.num <> _num))
+          and case c.kind
+                  when 'multiplier' then floor(_accrual * (c.value - 1))
+                  else floor(c.value) end > 0
+        on conflict on constraint ledger_un do nothing
+        returning amount)
+select cast(coalesce(sum(b.amount), 0) as bigint)
+from bonuses b
+$$;
+
+-- переход заказа в PROCESSED применяет акции
+drop function if exists order_update(bigint, varchar, bigint);
+
+create function order_update(_num bigint, _status character varying, _accrual bigint)
+    returns TABLE(user_id character varying, accrual bigint)
+    language plpgsql
+as
+$$
+declare
+    o orders%rowtype;
+begin
+    select * into o from orders ord where ord.num = _num for update;
+    if not found then
+        return;
+    end if;
+    return query
+        update orders ord
+            set status = _status,
+                accural_base = _accrual,
+                multiplier = t.multiplier,
+                accural = floor(_accrual * t.multiplier)
+            from users u
+                join tiers t on t.name = u.tier
+            where ord.num = _num
+                and u.id = ord.user_id
+            returning cast(ord.user_id as varchar), ord.accural;
+    if _status = 'PROCESSED' and o.status <> 'PROCESSED' then
+        perform campaigns_apply(o.user_id, _num, _accrual);
+    end if;
+end;
+$$;
+
+-- +goose StatementEnd
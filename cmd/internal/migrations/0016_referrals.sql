@@ -0,0 +1,192 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- реферальная программа: у каждого пользователя есть код, новый пользователь может указать код при регистрации.
+-- Бонусы обеим сторонам начисляются записями ledger 'referral', когда первый заказ приглашенного переходит
+-- в PROCESSED. Суммы бонусов фиксируются при регистрации
+alter table users
+    add column if not exists referral_code character varying default upper(substr(md5(gen_random_uuid()::text), 1, 10));
+
+update users
+set referral_code = upper(substr(md5(gen_random_uuid()::text), 1, 10))
+where referral_code is null;
+
+alter table users
+    alter column referral_code set not null;
+
+alter table users
+    add constraint users_referral_code_un unique (referral_code);
+
+-- адреса регистрации и входа для проверки злоупотреблений
+alter table users
+    add column if not exists reg_ip character varying;
+
+alter table sessions
+    add column if not exists ip character varying;
+
+create index if not exists sessions_ip_idx
+    on sessions (ip);
+
+drop function if exists session_add(uuid, bytea, interval);
+
+create function session_add(_user_id uuid, _token_hash bytea, _ttl interval, _ip character varying) returns void
+    language sql
+as
+$$
+delete from sessions where expires < now();
+insert into sessions (token_hash, user_id, expires, ip)
+values (_token_hash, _user_id, now() + _ttl, _ip);
+$$;
+
+-- status: PENDING - ждет первого обработанного заказа, REWARDED - бонусы начислены,
+-- REJECTED - приглашение не засчитано (reason: self_referral | same_ip)
+create table if not exists referrals
+(
+    referee_id     uuid                    not null
+        constraint referrals_pk
+            primary key
+        constraint referrals_referee_fk
+            references users
+            on delete cascade,
+    referrer_id    uuid                    not null
+        constraint referrals_referrer_fk
+            references users
+            on delete cascade,
+    ip             character varying,
+    status         character varying       not null
+        constraint referrals_status_check
+            check (status in ('PENDING', 'REWARDED', 'REJECTED')),
+    reason         character varying,
+    referrer_bonus bigint                  not null,
+    referee_bonus  bigint                  not null,
+    date_ins       timestamp default now() not null,
+    date_upd       timestamp
+);
+
+create index if not exists referrals_referrer_idx
+    on referrals (referrer_id);
+
+-- регистрация с необязательным реферальным кодом. id null - логин занят;
+-- referral: null - без кода, UNKNOWN_CODE - код не найден (пользователь не создан), PENDING | REJECTED
+create or replace function user_register(_login character varying, _hash bytea, _ip character varying,
+                                         _code character varying, _referrer_bonus bigint, _referee_bonus bigint)
+    returns TABLE(id character varying, referral character varying)
+    language plpgsql
+as
+$$
+declare
+    _referrer users%rowtype;
+    _user_id  uuid;
+    _reason   character varying;
+begin
+    if _code is not null then
+        select * into _referrer from users u where u.referral_code = upper(_code);
+        if not found then
+            return query select cast(null as varchar), cast('UNKNOWN_CODE' as varchar);
+            return;
+        end if;
+    end if;
+
+    insert into users (id, hash, login, reg_ip)
+    values (gen_random_uuid(), _hash, _login, _ip)
+    on conflict on constraint users_un do nothing
+    returning users.id into _user_id;
+    if _user_id is null or _code is null then
+        return query select cast(_user_id as varchar), cast(null as varchar);
+        return;
+    end if;
+
+    -- пригласивший регистрируется повторно или приглашает с того же адреса несколько учетных записей
+    if _referrer.reg_ip = _ip
+        or exists(select 1 from sessions s where s.user_id = _referrer.id and s.ip = _ip) then
+        _reason := 'self_referral';
+    elsif exists(select 1 from referrals r where r.referrer_id = _referrer.id and r.ip = _ip) then
+        _reason := 'same_ip';
+    end if;
+
+    insert into referrals (referee_id, referrer_id, ip, status, reason, referrer_bonus, referee_bonus)
+    values (_user_id, _referrer.id, _ip,
+            case when _reason is null then 'PENDING' else 'REJECTED' end,
+            _reason, _referrer_bonus, _referee_bonus);
+
+    return query select cast(_user_id as varchar),
+                        cast(case when _reason is null then 'PENDING' else 'REJECTED' end as varchar);
+end;
+$$;
+
+-- бонусы за первый обработанный заказ приглашенного, повторный вызов ничего не добавляет
+create or replace function referral_reward(_user_id uuid) returns void
+    language plpgsql
+as
+$$
+declare
+    _ref referrals%rowtype;
+begin
+    update referrals r
+    set status   = 'REWARDED',
+        date_upd = now()
+    where r.referee_id = _user_id
+      and r.status = 'PENDING'
+    returning * into _ref;
+    if not found then
+        return;
+    end if;
+    insert into ledger (user_id, kind, amount, reference, comment)
+    select _ref.referrer_id, 'referral', _ref.referrer_bonus, 'referee:' || _ref.referee_id, 'referral bonus'
+    where _ref.referrer_bonus > 0
+    union all
+    select _ref.referee_id, 'referral', _ref.referee_bonus, 'referrer:' || _ref.referrer_id, 'welcome bonus'
+    where _ref.referee_bonus > 0
+    on conflict on constraint ledger_un do nothing;
+end;
+$$;
+
+create or replace function referral_info(_user_id uuid)
+    returns TABLE(code character varying, invited bigint, rewarded bigint, earned bigint)
+    language sql
+as
+$$
+select u.referral_code,
+       (select count(*) from referrals r where r.referrer_id = u.id and r.status <> 'REJECTED'),
+       (select count(*) from referrals r where r.referrer_id = u.id and r.status = 'REWARDED'),
+       (select cast(coalesce(sum(l.amount), 0) as bigint)
+        from ledger l
+        where l.user_id = u.id
+          and l.kind = 'referral'
+          and l.reference like 'referee:%')
+from users u
+where u.id = _user_id
+$$;
+
+-- первый заказ приглашенного в PROCESSED начисляет реферальные бонусы
+create or replace function order_update(_num bigint, _status character varying, _accrual bigint)
+    returns TABLE(user_id character varying, accrual bigint)
+    language plpgsql
+as
+$$
+declare
+    o orders%rowtype;
+begin
+    select * into o from orders ord where ord.num = _num for update;
+    if not found then
+        return;
+    end if;
+    return query
+        update orders ord
+            set status = _status,
+                accural_base = _accrual,
+                multiplier = t.multiplier,
+                accural = floor(_accrual * t.multiplier)
+            from users u
+                join tiers t on t.name = u.tier
+            where ord.num = _num
+                and u.id = ord.user_id
+            returning cast(ord.user_id as varchar), ord.accural;
+    if _status = 'PROCESSED' and o.status <> 'PROCESSED' then
+        perform campaigns_apply(o.user_id, _num, _accrual);
+        perform referral_reward(o.user_id);
+    end if;
+end;
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,132 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- ваучеры: администратор выпускает партию кодов, код хранится только в виде sha256.
+-- max_uses = 1 - одноразовые коды, больше - многоразовые; один пользователь погашает код один раз
+create table if not exists voucher_batches
+(
+    id         uuid      default gen_random_uuid() not null
+        constraint voucher_batches_pk
+            primary key,
+    name       character varying                   not null,
+    amount     bigint                              not null
+        constraint voucher_batches_amount_check
+            check (amount > 0),
+    max_uses   integer                             not null
+        constraint voucher_batches_max_uses_check
+            check (max_uses > 0),
+    expires_at timestamp,
+    actor_id   uuid,
+    date_ins   timestamp default now()             not null
+);
+
+create table if not exists vouchers
+(
+    id        bigint generated always as identity
+        constraint vouchers_pk
+            primary key,
+    batch_id  uuid              not null
+        constraint vouchers_batch_fk
+            references voucher_batches
+            on delete cascade,
+    code_hash bytea             not null
+        constraint vouchers_code_un
+            unique,
+    uses      integer default 0 not null
+);
+
+create index if not exists vouchers_batch_idx
+    on vouchers (batch_id);
+
+create or replace function voucher_batch_add(_name character varying, _amount bigint, _max_uses integer,
+                                             _expires_at timestamp, _hashes bytea[], _actor_id uuid)
+    returns TABLE(id uuid, date_ins timestamp without time zone)
+    language plpgsql
+as
+$$
+declare
+    _batch voucher_batches%rowtype;
+begin
+    insert into voucher_batches (name, amount, max_uses, expires_at, actor_id)
+    values (_name, _amount, _max_uses, _expires_at, _actor_id)
+    returning * into _batch;
+    insert into vouchers (batch_id, code_hash)
+    select _batch.id, h
+    from unnest(_hashes) h;
+    insert into audit_log (actor_id, action, payload)
+    values (_actor_id, 'voucher.batch',
+            jsonb_build_object('batch_id', _batch.id, 'name', _name, 'amount', _amount,
+                               'count', cardinality(_hashes), 'max_uses', _max_uses));
+    return query select _batch.id, _batch.date_ins;
+end;
+$$;
+
+create or replace function voucher_batches_all()
+    returns TABLE(id uuid, name character varying, amount bigint, max_uses integer, expires_at timestamp without time zone,
+                  codes bigint, redeemed bigint, date_ins timestamp without time zone)
+    language sql
+as
+$$
+select b.id, b.name, b.amount, b.max_uses, b.expires_at, count(v.id), cast(coalesce(sum(v.uses), 0) as bigint), b.date_ins
+from voucher_batches b
+         left join vouchers v on v.batch_id = b.id
+group by b.id
+order by b.date_ins desc
+$$;
+
+-- погашение кода: строка ваучера блокируется, бонус и счетчик меняются в одной транзакции.
+-- result: OK | NOT_FOUND | EXPIRED | EXHAUSTED - исчерпан лимит погашений | REDEEMED - уже погашен пользователем
+create or replace function voucher_redeem(_user_id uuid, _hash bytea)
+    returns TABLE(amount bigint, result character varying)
+    language plpgsql
+as
+$$
+declare
+    _v vouchers%rowtype;
+    _b voucher_batches%rowtype;
+begin
+    select * into _v from vouchers v where v.code_hash = _hash for update;
+    if not found then
+        return query select cast(0 as bigint), cast('NOT_FOUND' as varchar);
+        return;
+    end if;
+    select * into _b from voucher_batches b where b.id = _v.batch_id;
+    if _b.expires_at is not null and _b.expires_at < now() then
+        return query select cast(0 as bigint), cast('EXPIRED' as varchar);
+        return;
+    end if;
+    if _v.uses >= _b.max_uses then
+        return query select cast(0 as bigint), cast('EXHAUSTED' as varchar);
+        return;
+    end if;
+    insert into ledger (user_id, kind, amount, reference, comment)
+    values (_user_id, 'voucher', _b.amount, 'voucher:' || _v.id, _b.name)
+    on conflict on constraint ledger_un do nothing;
+    if not found then
+        return query select cast(0 as bigint), cast('REDEEMED' as varchar);
+        return;
+    end if;
+    update vouchers v set uses = v.uses + 1 where v.id = _v.id;
+    return query select _b.amount, cast('OK' as varchar);
+end;
+$$;
+
+-- счетчики попыток по виду: вход (login, ip), погашение ваучеров (redeem, redeem_ip)
+drop function if exists login_attempts_get(varchar, varchar);
+
+create function login_attempts_get(_user_kind character varying, _login character varying,
+                                   _ip_kind character varying, _ip character varying)
+    returns TABLE(kind character varying, failures integer, since_failure double precision, locked_for double precision)
+    language sql
+as
+$$
+select a.kind,
+       a.failures,
+       extract(epoch from now() - a.last_failure),
+       greatest(coalesce(extract(epoch from a.locked_until - now()), 0), 0)
+from login_attempts a
+where (a.kind = _user_kind and a.key = _login)
+   or (a.kind = _ip_kind and a.key = _ip);
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,140 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- каталог наград: цена в баллах и остаток, stock null - без ограничения
+create table if not exists rewards
+(
+    id          uuid      default gen_random_uuid() not null
+        constraint rewards_pk
+            primary key,
+    name        character varying                   not null,
+    description character varying,
+    price       bigint                              not null
+        constraint rewards_price_check
+            check (price > 0),
+    stock       bigint
+        constraint rewards_stock_check
+            check (stock >= 0),
+    active      boolean   default true              not null,
+    date_ins    timestamp default now()             not null
+);
+
+-- обмен баллов на награду: списание withdraws с номером num, название и цена на момент обмена
+create table if not exists reward_redemptions
+(
+    id        uuid      default gen_random_uuid() not null
+        constraint reward_redemptions_pk
+            primary key,
+    user_id   uuid                                not null
+        constraint reward_redemptions_fk
+            references users
+            on delete cascade,
+    reward_id uuid
+        constraint reward_redemptions_reward_fk
+            references rewards
+            on delete set null,
+    name      character varying                   not null,
+    price     bigint                              not null,
+    num       bigint                              not null,
+    date_ins  timestamp default now()             not null
+);
+
+create index if not exists reward_redemptions_user_idx
+    on reward_redemptions (user_id, date_ins);
+
+create or replace function reward_add(_name character varying, _description character varying, _price bigint,
+                                      _stock bigint, _active boolean)
+    returns TABLE(id uuid, date_ins timestamp without time zone)
+    language sql
+as
+$$
+insert into rewards (name, description, price, stock, active)
+values (_name, _description, _price, _stock, _active)
+returning rewards.id, rewards.date_ins;
+$$;
+
+-- нет строки - награда не найдена
+create or replace function reward_update(_id uuid, _name character varying, _description character varying,
+                                         _price bigint, _stock bigint, _active boolean)
+    returns TABLE(date_ins timestamp without time zone)
+    language sql
+as
+$$
+update rewards rw
+set name        = _name,
+    description = _description,
+    price       = _price,
+    stock       = _stock,
+    active      = _active
+where rw.id = _id
+returning rw.date_ins;
+$$;
+
+-- _id null - весь каталог, _active_only - только доступные пользователям
+create or replace function rewards_all(_id uuid, _active_only boolean)
+    returns TABLE(id uuid, name character varying, description character varying, price bigint, stock bigint,
+                  active boolean, date_ins timestamp without time zone)
+    language sql
+as
+$$
+select rw.id, rw.name, rw.description, rw.price, rw.stock, rw.active, rw.date_ins
+from rewards rw
+where (_id is null or rw.id = _id)
+  and (not _active_only or rw.active)
+order by rw.price, rw.name
+$$;
+
+-- обмен в одной транзакции: награда блокируется, списание проверяется теми же правилами, что withdraw,
+-- остаток уменьшается. result: OK | NOT_FOUND | OUT_OF_STOCK | DUPLICATE - номер списания занят | результат withdraw
+create or replace function reward_redeem(_user_id uuid, _reward_id uuid, _num bigint, _min bigint, _max bigint,
+                                         _daily bigint, _monthly bigint, _cooldown bigint,
+                                         OUT id uuid, OUT name character varying, OUT price bigint,
+                                         OUT date_ins timestamp, OUT remaining bigint,
+                                         OUT result character varying) returns record
+    language plpgsql
+as
+$$
+declare
+    _rw rewards%rowtype;
+begin
+    select * into _rw from rewards rw where rw.id = _reward_id and rw.active for update;
+    if not found then
+        result := 'NOT_FOUND';
+        return;
+    end if;
+    name := _rw.name;
+    price := _rw.price;
+    if _rw.stock = 0 then
+        result := 'OUT_OF_STOCK';
+        return;
+    end if;
+    if exists(select 1 from withdraws w where w.user_id = _user_id and w.num = _num) then
+        result := 'DUPLICATE';
+        return;
+    end if;
+    select wd.remaining, wd.result
+    into remaining, result
+    from withdraw(_user_id, _num, _rw.price, _min, _max, _daily, _monthly, _cooldown) wd;
+    if result <> 'OK' then
+        return;
+    end if;
+    update rewards rw set stock = rw.stock - 1 where rw.id = _rw.id and rw.stock is not null;
+    insert into reward_redemptions (user_id, reward_id, name, price, num)
+    values (_user_id, _rw.id, _rw.name, _rw.price, _num)
+    returning reward_redemptions.id, reward_redemptions.date_ins into id, date_ins;
+end;
+$$;
+
+create or replace function reward_redemptions_all(_user_id uuid)
+    returns TABLE(id uuid, reward_id uuid, name character varying, price bigint, num bigint,
+                  date_ins timestamp without time zone)
+    language sql
+as
+$$
+select rr.id, rr.reward_id, rr.name, rr.price, rr.num, rr.date_ins
+from reward_redemptions rr
+where rr.user_id = _user_id
+order by rr.date_ins desc
+$$;
+
+-- +goose StatementEnd
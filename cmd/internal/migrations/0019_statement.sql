@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- выписка за период [_from, _to): заказы, начисления, списания и операции ledger с остатком после каждой строки.
+-- Остаток считается по всей истории, резервы в него не входят. _from null - с начала истории
+create or replace function statement(_user_id uuid, _from timestamp, _to timestamp)
+    returns TABLE(date_ins timestamp without time zone, kind character varying, reference character varying,
+                  status character varying, amount bigint, balance bigint, comment character varying)
+    language sql
+as
+$$
+select s.date_ins, s.kind, s.reference, s.status, s.amount, s.balance, s.comment
+from (select h.date_ins,
+             h.kind,
+             h.reference,
+             h.status,
+             h.amount,
+             cast(sum(h.amount) over (order by h.date_ins, h.seq rows unbounded preceding) as bigint) as balance,
+             h.comment,
+             h.seq
+      from (select o.date_ins,
+                   cast('order' as varchar) as kind,
+                   cast(o.num as varchar)   as reference,
+                   o.status,
+                   case when o.status = 'PROCESSED' then coalesce(o.accural, 0) else 0 end as amount,
+                   cast(null as varchar)    as comment,
+                   0                        as seq
+            from orders o
+            where o.user_id = _user_id
+            union all
+            select w.date_ins, 'withdrawal', cast(w.num as varchar), null, -w.expence, null, 1
+            from withdraws w
+            where w.user_id = _user_id
+            union all
+            select l.date_ins, l.kind, l.reference, null, l.amount, l.comment, 2
+            from ledger l
+            where l.user_id = _user_id) h
+      where h.date_ins < _to) s
+where _from is null
+   or s.date_ins >= _from
+order by s.date_ins, s.seq
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,85 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- ежемесячные выписки: итоги месяца и ключ PDF-документа в хранилище файлов, одна выписка на пользователя за месяц.
+-- period - первый день месяца
+create table if not exists monthly_statements
+(
+    id          uuid      default gen_random_uuid() not null
+        constraint monthly_statements_pk
+            primary key,
+    user_id     uuid                                not null
+        constraint monthly_statements_fk
+            references users
+            on delete cascade,
+    period      date                                not null,
+    opening     bigint                              not null,
+    accruals    bigint                              not null,
+    withdrawals bigint                              not null,
+    other       bigint                              not null,
+    closing     bigint                              not null,
+    file        character varying                   not null,
+    date_ins    timestamp default now()             not null,
+    constraint monthly_statements_un
+        unique (user_id, period)
+);
+
+-- пользователи с операциями до конца месяца, для которых выписка еще не сформирована
+create or replace function monthly_statements_due(_period date, _limit integer)
+    returns TABLE(id uuid, login character varying)
+    language sql
+as
+$$
+select u.id, u.login
+from users u
+where not exists(select 1 from monthly_statements ms where ms.user_id = u.id and ms.period = _period)
+  and (exists(select 1 from orders o where o.user_id = u.id and o.date_ins < _period + interval '1 month')
+    or exists(select 1 from withdraws w where w.user_id = u.id and w.date_ins < _period + interval '1 month')
+    or exists(select 1 from ledger l where l.user_id = u.id and l.date_ins < _period + interval '1 month'))
+order by u.id
+limit _limit
+$$;
+
+-- итоги месяца по строкам выписки: начисления по заказам, списания (положительным числом) и прочие операции ledger
+create or replace function monthly_summary(_user_id uuid, _period date)
+    returns TABLE(opening bigint, accruals bigint, withdrawals bigint, other bigint, closing bigint)
+    language sql
+as
+$$
+select cast(coalesce(sum(s.amount) filter (where s.date_ins < _period), 0) as bigint),
+       cast(coalesce(sum(s.amount) filter (where s.date_ins >= _period and s.kind = 'order'), 0) as bigint),
+       cast(coalesce(-sum(s.amount) filter (where s.date_ins >= _period and s.kind = 'withdrawal'), 0) as bigint),
+       cast(coalesce(sum(s.amount) filter (where s.date_ins >= _period and s.kind not in ('order', 'withdrawal')), 0) as bigint),
+       cast(coalesce(sum(s.amount), 0) as bigint)
+from statement(_user_id, null, cast(_period + interval '1 month' as timestamp)) s
+$$;
+
+-- повторная запись за тот же месяц ничего не меняет, id null - выписка уже есть
+create or replace function monthly_statement_add(_user_id uuid, _period date, _opening bigint, _accruals bigint,
+                                                 _withdrawals bigint, _other bigint, _closing bigint,
+                                                 _file character varying)
+    returns TABLE(id uuid, date_ins timestamp without time zone)
+    language sql
+as
+$$
+insert into monthly_statements (user_id, period, opening, accruals, withdrawals, other, closing, file)
+values (_user_id, _period, _opening, _accruals, _withdrawals, _other, _closing, _file)
+on conflict on constraint monthly_statements_un do nothing
+returning monthly_statements.id, monthly_statements.date_ins;
+$$;
+
+-- _id null - все выписки пользователя, новые первыми
+create or replace function monthly_statements_all(_user_id uuid, _id uuid)
+    returns TABLE(id uuid, period date, opening bigint, accruals bigint, withdrawals bigint, other bigint,
+                  closing bigint, file character varying, date_ins timestamp without time zone)
+    language sql
+as
+$$
+select ms.id, ms.period, ms.opening, ms.accruals, ms.withdrawals, ms.other, ms.closing, ms.file, ms.date_ins
+from monthly_statements ms
+where ms.user_id = _user_id
+  and (_id is null or ms.id = _id)
+order by ms.period desc
+$$;
+
+-- +goose StatementEnd
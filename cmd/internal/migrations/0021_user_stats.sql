@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- статистика заказов и списаний пользователя: строка с month null - итог за всю историю,
+-- остальные - по месяцам начиная с _since
+create or replace function user_stats(_user_id uuid, _since timestamp)
+    returns TABLE(month timestamp without time zone, orders bigint, processed bigint, invalid bigint, accrued bigint,
+                  withdrawals bigint, withdrawn bigint)
+    language sql
+as
+$$
+select date_trunc('month', h.date_ins),
+       count(*) filter (where h.kind = 'order'),
+       count(*) filter (where h.status = 'PROCESSED'),
+       count(*) filter (where h.status = 'INVALID'),
+       cast(coalesce(sum(h.amount) filter (where h.status = 'PROCESSED'), 0) as bigint),
+       count(*) filter (where h.kind = 'withdrawal'),
+       cast(coalesce(sum(h.amount) filter (where h.kind = 'withdrawal'), 0) as bigint)
+from (select o.date_ins, cast('order' as varchar) as kind, o.status, coalesce(o.accural, 0) as amount
+      from orders o
+      where o.user_id = _user_id
+      union all
+      select w.date_ins, 'withdrawal', null, w.expence
+      from withdraws w
+      where w.user_id = _user_id) h
+group by grouping sets ((date_trunc('month', h.date_ins)), ())
+having grouping(date_trunc('month', h.date_ins)) = 1
+    or date_trunc('month', h.date_ins) >= _since
+order by 1 desc nulls first
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,135 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- отчеты для финансов. Дневные итоги собираются в материализованное представление,
+-- фоновая задача обновляет его без блокировки чтения (нужен уникальный индекс по дню)
+create materialized view if not exists report_daily as
+select d.day,
+       cast(sum(d.orders) as bigint)      as orders,
+       cast(sum(d.processed) as bigint)   as processed,
+       cast(sum(d.invalid) as bigint)     as invalid,
+       cast(sum(d.accrued) as bigint)     as accrued,
+       cast(sum(d.withdrawals) as bigint) as withdrawals,
+       cast(sum(d.withdrawn) as bigint)   as withdrawn,
+       cast(sum(d.credited) as bigint)    as credited,
+       cast(sum(d.debited) as bigint)     as debited
+from (select cast(o.date_ins as date)                                                 as day,
+             1                                                                        as orders,
+             case when o.status = 'PROCESSED' then 1 else 0 end                       as processed,
+             case when o.status = 'INVALID' then 1 else 0 end                         as invalid,
+             case when o.status = 'PROCESSED' then coalesce(o.accural, 0) else 0 end as accrued,
+             0                                                                        as withdrawals,
+             0                                                                        as withdrawn,
+             0                                                                        as credited,
+             0                                                                        as debited
+      from orders o
+      union all
+      select cast(w.date_ins as date), 0, 0, 0, 0, 1, w.expence, 0, 0
+      from withdraws w
+      union all
+      select cast(l.date_ins as date), 0, 0, 0, 0, 0, 0, greatest(l.amount, 0), greatest(-l.amount, 0)
+      from ledger l) d
+group by d.day;
+
+create unique index if not exists report_daily_day_un
+    on report_daily (day);
+
+create or replace function report_daily_refresh() returns void
+    language sql
+as
+$$
+refresh materialized view concurrently report_daily;
+$$;
+
+create or replace function report_daily_range(_from date, _to date)
+    returns TABLE(day date, orders bigint, processed bigint, invalid bigint, accrued bigint, withdrawals bigint,
+                  withdrawn bigint, credited bigint, debited bigint)
+    language sql
+as
+$$
+select r.day, r.orders, r.processed, r.invalid, r.accrued, r.withdrawals, r.withdrawn, r.credited, r.debited
+from report_daily r
+where r.day >= _from
+  and r.day < _to
+order by r.day
+$$;
+
+-- обязательства по баллам: все непотраченные баллы, в т.ч. зарезервированные, и число пользователей с остатком
+create or replace function report_liability()
+    returns TABLE(outstanding bigint, held bigint, users bigint)
+    language sql
+as
+$$
+select cast(coalesce(sum(p.amount), 0) as bigint),
+       (select cast(coalesce(sum(h.amount), 0) as bigint)
+        from holds h
+        where h.status = 'ACTIVE'
+          and h.expires_at > now()),
+       count(*) filter (where p.amount > 0)
+from (select t.user_id, sum(t.amount) as amount
+      from (select o.user_id, o.accural as amount
+            from orders o
+            where o.status = 'PROCESSED'
+            union all
+            select w.user_id, -w.expence
+            from withdraws w
+            union all
+            select l.user_id, l.amount
+            from ledger l) t
+      group by t.user_id) p
+$$;
+
+create index if not exists orders_pending_idx
+    on orders (date_ins)
+    where status in ('NEW', 'PROCESSING');
+
+-- заказы, ожидающие расчета, по статусу и возрасту
+create or replace function report_stuck_orders()
+    returns TABLE(status character varying, age character varying, orders bigint, oldest timestamp without time zone)
+    language sql
+as
+$$
+select o.status,
+       cast(case
+                when o.date_ins > now() - interval '1 hour' then 'under_1h'
+                when o.date_ins > now() - interval '1 day' then '1h_1d'
+                when o.date_ins > now() - interval '7 days' then '1d_7d'
+                else 'over_7d' end as varchar) as age,
+       count(*),
+       min(o.date_ins)
+from orders o
+where o.status in ('NEW', 'PROCESSING')
+group by 1, 2
+order by 1, min(o.date_ins)
+$$;
+
+-- пользователи с наибольшими начислениями или списаниями (_by = 'withdrawn') за период
+create or replace function report_top_users(_from timestamp, _to timestamp, _by character varying, _limit integer)
+    returns TABLE(login character varying, orders bigint, accrued bigint, withdrawals bigint, withdrawn bigint)
+    language sql
+as
+$$
+select s.login, s.orders, s.accrued, s.withdrawals, s.withdrawn
+from (select u.login,
+             count(*) filter (where t.kind = 'order')                                         as orders,
+             cast(coalesce(sum(t.amount) filter (where t.kind = 'order'), 0) as bigint)      as accrued,
+             count(*) filter (where t.kind = 'withdrawal')                                    as withdrawals,
+             cast(coalesce(sum(t.amount) filter (where t.kind = 'withdrawal'), 0) as bigint) as withdrawn
+      from (select o.user_id,
+                   cast('order' as varchar)                                                as kind,
+                   case when o.status = 'PROCESSED' then coalesce(o.accural, 0) else 0 end as amount
+            from orders o
+            where o.date_ins >= _from
+              and o.date_ins < _to
+            union all
+            select w.user_id, 'withdrawal', w.expence
+            from withdraws w
+            where w.date_ins >= _from
+              and w.date_ins < _to) t
+               join users u on u.id = t.user_id
+      group by u.login) s
+order by case when _by = 'withdrawn' then s.withdrawn else s.accrued end desc, s.login
+limit _limit
+$$;
+
+-- +goose StatementEnd
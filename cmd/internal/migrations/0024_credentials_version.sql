@@ -0,0 +1,60 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- версия учетных данных входит в ключ кэша проверенных паролей: смена пароля или включение 2FA
+-- на любом экземпляре сервиса делает закэшированные пары недействительными везде
+alter table users
+    add column if not exists cred_version bigint default 0 not null;
+
+create or replace function users_cred_version() returns trigger
+    language plpgsql
+as
+$$
+begin
+    new.cred_version := old.cred_version + 1;
+    return new;
+end;
+$$;
+
+drop trigger if exists users_cred_version_tg on users;
+
+create trigger users_cred_version_tg
+    before update of hash
+    on users
+    for each row
+    when (old.hash is distinct from new.hash)
+execute function users_cred_version();
+
+-- после подтверждения 2FA вход по одному паролю больше не действует
+create or replace function user_totp_cred_version() returns trigger
+    language plpgsql
+as
+$$
+begin
+    update users u set cred_version = u.cred_version + 1 where u.id = new.user_id;
+    return null;
+end;
+$$;
+
+drop trigger if exists user_totp_cred_version_tg on user_totp;
+
+create trigger user_totp_cred_version_tg
+    after insert or update of confirmed
+    on user_totp
+    for each row
+    when (new.confirmed)
+execute function user_totp_cred_version();
+
+drop function if exists user_check(character varying);
+
+create or replace function user_check(_login character varying, OUT id character varying, OUT hash bytea,
+                                      OUT role character varying, OUT cred_version bigint) returns record
+    language sql
+as
+$$
+select cast(u.id as varchar), u.hash, u.role, u.cred_version
+from users u
+where u.login = _login
+$$;
+
+-- +goose StatementEnd
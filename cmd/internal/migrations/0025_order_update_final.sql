@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- множитель уровня и начисление фиксируются, когда заказ впервые становится PROCESSED.
+-- Повторный опрос обработанного заказа ничего не меняет: иначе смена уровня пересчитала бы
+-- начисление задним числом, а событие об изменении заказа ушло бы повторно
+create or replace function order_update(_num bigint, _status character varying, _accrual bigint)
+    returns TABLE(user_id character varying, accrual bigint)
+    language plpgsql
+as
+$$
+declare
+    o orders%rowtype;
+begin
+    select * into o from orders ord where ord.num = _num for update;
+    if not found or o.status = 'PROCESSED' then
+        return;
+    end if;
+    if _status <> 'PROCESSED' then
+        return query
+            update orders ord
+                set status = _status
+                where ord.num = _num
+                returning cast(ord.user_id as varchar), ord.accural;
+        return;
+    end if;
+    return query
+        update orders ord
+            set status = _status,
+                accural_base = _accrual,
+                multiplier = t.multiplier,
+                accural = floor(_accrual * t.multiplier)
+            from users u
+                join tiers t on t.name = u.tier
+            where ord.num = _num
+                and u.id = ord.user_id
+            returning cast(ord.user_id as varchar), ord.accural;
+    perform campaigns_apply(o.user_id, _num, _accrual);
+    perform referral_reward(o.user_id);
+end;
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,114 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- время перехода заказа в PROCESSED: начисление попадает в выписку месяца обработки, а не загрузки заказа.
+-- Для уже обработанных заказов другого времени нет - берется время загрузки.
+-- order_update такой же, как в 0025, и дополнительно отмечает время обработки
+alter table orders
+    add column if not exists date_processed timestamp;
+
+update orders
+set date_processed = date_ins
+where status = 'PROCESSED'
+  and date_processed is null;
+
+create or replace function order_update(_num bigint, _status character varying, _accrual bigint)
+    returns TABLE(user_id character varying, accrual bigint)
+    language plpgsql
+as
+$$
+declare
+    o orders%rowtype;
+begin
+    select * into o from orders ord where ord.num = _num for update;
+    if not found or o.status = 'PROCESSED' then
+        return;
+    end if;
+    if _status <> 'PROCESSED' then
+        return query
+            update orders ord
+                set status = _status
+                where ord.num = _num
+                returning cast(ord.user_id as varchar), ord.accural;
+        return;
+    end if;
+    return query
+        update orders ord
+            set status = _status,
+                accural_base = _accrual,
+                multiplier = t.multiplier,
+                accural = floor(_accrual * t.multiplier),
+                date_processed = now()
+            from users u
+                join tiers t on t.name = u.tier
+            where ord.num = _num
+                and u.id = ord.user_id
+            returning cast(ord.user_id as varchar), ord.accural;
+    perform campaigns_apply(o.user_id, _num, _accrual);
+    perform referral_reward(o.user_id);
+end;
+$$;
+
+-- строка заказа датируется временем обработки, необработанный заказ - временем загрузки
+create or replace function statement(_user_id uuid, _from timestamp, _to timestamp)
+    returns TABLE(date_ins timestamp without time zone, kind character varying, reference character varying,
+                  status character varying, amount bigint, balance bigint, comment character varying)
+    language sql
+as
+$$
+select s.date_ins, s.kind, s.reference, s.status, s.amount, s.balance, s.comment
+from (select h.date_ins,
+             h.kind,
+             h.reference,
+             h.status,
+             h.amount,
+             cast(sum(h.amount) over (order by h.date_ins, h.seq rows unbounded preceding) as bigint) as balance,
+             h.comment,
+             h.seq
+      from (select coalesce(o.date_processed, o.date_ins) as date_ins,
+                   cast('order' as varchar) as kind,
+                   cast(o.num as varchar)   as reference,
+                   o.status,
+                   case when o.status = 'PROCESSED' then coalesce(o.accural, 0) else 0 end as amount,
+                   cast(null as varchar)    as comment,
+                   0                        as seq
+            from orders o
+            where o.user_id = _user_id
+            union all
+            select w.date_ins, 'withdrawal', cast(w.num as varchar), null, -w.expence, null, 1
+            from withdraws w
+            where w.user_id = _user_id
+            union all
+            select l.date_ins, l.kind, l.reference, null, l.amount, l.comment, 2
+            from ledger l
+            where l.user_id = _user_id) h
+      where h.date_ins < _to) s
+where _from is null
+   or s.date_ins >= _from
+order by s.date_ins, s.seq
+$$;
+
+-- пользователи по возрастанию id после _after (null - с начала): сбой выписки одного пользователя
+-- не мешает обойти остальных, он остается в очереди до следующего запуска
+drop function if exists monthly_statements_due(date, integer);
+
+create or replace function monthly_statements_due(_period date, _after uuid, _limit integer)
+    returns TABLE(id uuid, login character varying)
+    language sql
+as
+$$
+select u.id, u.login
+from users u
+where (_after is null or u.id > _after)
+  and not exists(select 1 from monthly_statements ms where ms.user_id = u.id and ms.period = _period)
+  and (exists(select 1
+              from orders o
+              where o.user_id = u.id
+                and coalesce(o.date_processed, o.date_ins) < _period + interval '1 month')
+    or exists(select 1 from withdraws w where w.user_id = u.id and w.date_ins < _period + interval '1 month')
+    or exists(select 1 from ledger l where l.user_id = u.id and l.date_ins < _period + interval '1 month'))
+order by u.id
+limit _limit
+$$;
+
+-- +goose StatementEnd
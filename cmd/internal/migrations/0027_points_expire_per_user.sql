@@ -0,0 +1,100 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- начисление за заказ стареет со времени обработки: заказ, загруженный давно и обработанный сегодня,
+-- не должен сгорать при ближайшем запуске
+create or replace function point_lots(_user_id uuid, _months integer)
+    returns TABLE(kind character varying, reference character varying, amount bigint, remaining bigint,
+                  date_ins timestamp without time zone, expires_at timestamp without time zone)
+    language sql
+as
+$$
+with credits as (select cast('accrual' as varchar)              as kind,
+                        cast(o.num as varchar)                  as reference,
+                        o.accural                               as amount,
+                        coalesce(o.date_processed, o.date_ins) as date_ins
+                 from orders o
+                 where o.user_id = _user_id
+                   and o.status = 'PROCESSED'
+                   and o.accural > 0
+                 union all
+                 select l.kind, l.reference, l.amount, l.date_ins
+                 from ledger l
+                 where l.user_id = _user_id
+                   and l.amount > 0),
+     debits as (select b.exps - (select coalesce(sum(l.amount), 0)
+                                 from ledger l
+                                 where l.user_id = _user_id
+                                   and l.amount < 0) + b.held as total
+                from user_balance b
+                where b.id = _user_id),
+     running as (select c.*,
+                        sum(c.amount) over (order by c.date_ins, c.kind, c.reference) as upto
+                 from credits c)
+select r.kind,
+       r.reference,
+       r.amount,
+       cast(greatest(0, least(r.amount, r.upto - d.total)) as bigint) as remaining,
+       r.date_ins,
+       r.date_ins + make_interval(months => _months)                  as expires_at
+from running r,
+     debits d
+order by r.date_ins, r.kind, r.reference
+$$;
+
+-- сгорание по одному пользователю на транзакцию вместо одной транзакции на всех:
+-- points_expire_due отдает пользователей со старыми партиями по возрастанию id после _after (null - с начала),
+-- points_expire_user списывает сгоревшее у одного пользователя под его блокировкой.
+-- Повторный или параллельный вызов видит уже записанное сгорание в погашениях и ничего не списывает
+drop function if exists points_expire(integer);
+
+create or replace function points_expire_due(_months integer, _after uuid, _limit integer)
+    returns TABLE(id uuid)
+    language sql
+as
+$$
+select d.user_id
+from (select o.user_id
+      from orders o
+      where o.status = 'PROCESSED'
+        and coalesce(o.date_processed, o.date_ins) + make_interval(months => _months) <= now()
+      union
+      select l.user_id
+      from ledger l
+      where l.amount > 0
+        and l.date_ins + make_interval(months => _months) <= now()) d
+where _after is null
+   or d.user_id > _after
+order by d.user_id
+limit _limit
+$$;
+
+-- возвращает списанную сумму, 0 - сгорать нечему
+create or replace function points_expire_user(_user_id uuid, _months integer) returns bigint
+    language plpgsql
+as
+$$
+declare
+    expired bigint;
+begin
+    perform 1 from users us where us.id = _user_id for update;
+    select coalesce(sum(p.remaining), 0)
+    into expired
+    from point_lots(_user_id, _months) p
+    where p.expires_at <= now();
+    if expired <= 0 then
+        return 0;
+    end if;
+    insert into ledger (user_id, kind, amount, reference, comment)
+    values (_user_id, 'expiration', -expired, to_char(now(), 'YYYY-MM-DD"T"HH24:MI:SS.US'),
+            'points accrued before ' || to_char(now() - make_interval(months => _months), 'YYYY-MM-DD') ||
+            ' expired')
+    on conflict on constraint ledger_un do nothing;
+    if not found then
+        return 0;
+    end if;
+    return expired;
+end;
+$$;
+
+-- +goose StatementEnd
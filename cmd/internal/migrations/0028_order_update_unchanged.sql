@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- опрос заказа, статус которого не изменился (например, все еще PROCESSING), ничего не обновляет и не возвращает:
+-- иначе каждый опрос записывал бы в outbox еще одно событие order.updated и партнеры получали бы повторы
+create or replace function order_update(_num bigint, _status character varying, _accrual bigint)
+    returns TABLE(user_id character varying, accrual bigint)
+    language plpgsql
+as
+$$
+declare
+    o orders%rowtype;
+begin
+    select * into o from orders ord where ord.num = _num for update;
+    if not found or o.status = 'PROCESSED' then
+        return;
+    end if;
+    if _status <> 'PROCESSED' then
+        return query
+            update orders ord
+                set status = _status
+                where ord.num = _num
+                    and ord.status is distinct from _status
+                returning cast(ord.user_id as varchar), ord.accural;
+        return;
+    end if;
+    return query
+        update orders ord
+            set status = _status,
+                accural_base = _accrual,
+                multiplier = t.multiplier,
+                accural = floor(_accrual * t.multiplier),
+                date_processed = now()
+            from users u
+                join tiers t on t.name = u.tier
+            where ord.num = _num
+                and u.id = ord.user_id
+            returning cast(ord.user_id as varchar), ord.accural;
+    perform campaigns_apply(o.user_id, _num, _accrual);
+    perform referral_reward(o.user_id);
+end;
+$$;
+
+-- +goose StatementEnd
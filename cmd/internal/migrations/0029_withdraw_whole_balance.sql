@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- списание, резерв и перевод отказывают по одному правилу: баллов меньше суммы.
+-- Раньше списание отказывало и при сумме, равной остатку, хотя резерв на весь остаток можно было списать
+create or replace function withdraw(_user_id uuid, _number bigint, _expence bigint, _min bigint, _max bigint,
+                                    _daily bigint, _monthly bigint, _cooldown bigint,
+                                    OUT remaining bigint, OUT result character varying) returns record
+    language plpgsql
+as
+$$
+begin
+    perform 1 from users u where u.id = _user_id for update;
+    if exists(select 1 from withdraws w where w.user_id = _user_id and w.num = _number) then
+        result := 'DUPLICATE';
+        return;
+    end if;
+    select c.remaining, c.result
+    into remaining, result
+    from withdraw_rules_check(_user_id, _expence, _min, _max, _daily, _monthly, _cooldown) c;
+    if result <> 'OK' then
+        return;
+    end if;
+    if (select b.balance from balance(_user_id) b) < _expence then
+        remaining := null;
+        result := 'INSUFFICIENT';
+        return;
+    end if;
+    insert into withdraws (user_id, num, expence, date_ins)
+    values (_user_id, _number, _expence, default)
+    on conflict on constraint withdraws_pk do nothing;
+    if not found then
+        remaining := null;
+        result := 'DUPLICATE';
+    end if;
+end;
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,68 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- дневные итоги и лучшие пользователи считают заказ в день обработки, как выписка и статистика:
+-- начисление за заказ, загруженный вчера и обработанный сегодня, относится к сегодняшнему дню.
+-- Необработанный заказ - ко дню загрузки. Представление пересоздается, функции чтения остаются прежними
+drop materialized view if exists report_daily;
+
+create materialized view report_daily as
+select d.day,
+       cast(sum(d.orders) as bigint)      as orders,
+       cast(sum(d.processed) as bigint)   as processed,
+       cast(sum(d.invalid) as bigint)     as invalid,
+       cast(sum(d.accrued) as bigint)     as accrued,
+       cast(sum(d.withdrawals) as bigint) as withdrawals,
+       cast(sum(d.withdrawn) as bigint)   as withdrawn,
+       cast(sum(d.credited) as bigint)    as credited,
+       cast(sum(d.debited) as bigint)     as debited
+from (select cast(coalesce(o.date_processed, o.date_ins) as date)                       as day,
+             1                                                                        as orders,
+             case when o.status = 'PROCESSED' then 1 else 0 end                       as processed,
+             case when o.status = 'INVALID' then 1 else 0 end                         as invalid,
+             case when o.status = 'PROCESSED' then coalesce(o.accural, 0) else 0 end as accrued,
+             0                                                                        as withdrawals,
+             0                                                                        as withdrawn,
+             0                                                                        as credited,
+             0                                                                        as debited
+      from orders o
+      union all
+      select cast(w.date_ins as date), 0, 0, 0, 0, 1, w.expence, 0, 0
+      from withdraws w
+      union all
+      select cast(l.date_ins as date), 0, 0, 0, 0, 0, 0, greatest(l.amount, 0), greatest(-l.amount, 0)
+      from ledger l) d
+group by d.day;
+
+create unique index if not exists report_daily_day_un
+    on report_daily (day);
+
+create or replace function report_top_users(_from timestamp, _to timestamp, _by character varying, _limit integer)
+    returns TABLE(login character varying, orders bigint, accrued bigint, withdrawals bigint, withdrawn bigint)
+    language sql
+as
+$$
+select s.login, s.orders, s.accrued, s.withdrawals, s.withdrawn
+from (select u.login,
+             count(*) filter (where t.kind = 'order')                                         as orders,
+             cast(coalesce(sum(t.amount) filter (where t.kind = 'order'), 0) as bigint)      as accrued,
+             count(*) filter (where t.kind = 'withdrawal')                                    as withdrawals,
+             cast(coalesce(sum(t.amount) filter (where t.kind = 'withdrawal'), 0) as bigint) as withdrawn
+      from (select o.user_id,
+                   cast('order' as varchar)                                                as kind,
+                   case when o.status = 'PROCESSED' then coalesce(o.accural, 0) else 0 end as amount
+            from orders o
+            where coalesce(o.date_processed, o.date_ins) >= _from
+              and coalesce(o.date_processed, o.date_ins) < _to
+            union all
+            select w.user_id, 'withdrawal', w.expence
+            from withdraws w
+            where w.date_ins >= _from
+              and w.date_ins < _to) t
+               join users u on u.id = t.user_id
+      group by u.login) s
+order by case when _by = 'withdrawn' then s.withdrawn else s.accrued end desc, s.login
+limit _limit
+$$;
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- скользящее окно уровня считает начисления по времени обработки заказа: заказ, загруженный до окна
+-- и обработанный в нем, учитывается, как и в выписке и статистике
+create or replace function tier_accrued(_user_id uuid, _window double precision) returns bigint
+    language sql
+as
+$$
+select cast(coalesce(sum(coalesce(o.accural_base, o.accural)), 0) as bigint)
+from orders o
+where o.user_id = _user_id
+  and o.status = 'PROCESSED'
+  and coalesce(o.date_processed, o.date_ins) > now() - make_interval(secs => _window)
+$$;
+
+-- +goose StatementEnd
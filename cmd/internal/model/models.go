@@ -6,10 +6,31 @@ import (
 )
 
 type User struct {
-	ID       string `json:"id,omitempty"`   //uuid пользователя
-	Login    string `json:"login"`          //login
-	Password string `json:"password"`       //login
-	Hash     string `json:"hash,omitempty"` //hash for password
+	ID       string `json:"id,omitempty"`            //uuid пользователя
+	Login    string `json:"login"`                   //login
+	Password string `json:"password"`                //login
+	Hash     string `json:"hash,omitempty"`          //hash for password
+	OTP      string `json:"otp,omitempty"`           //одноразовый код второго фактора
+	Role     string `json:"-"`                       //роль: user, support, admin
+	Referral string `json:"referral_code,omitempty"` //код пригласившего при регистрации
+	IP       string `json:"-"`                       //адрес клиента при регистрации
+	Version  int64  `json:"-"`                       //версия учетных данных, растет при смене пароля и включении 2FA
+}
+
+const (
+	RoleUser    = "user"    //клиент программы лояльности
+	RoleSupport = "support" //поддержка: просмотр данных пользователей
+	RoleAdmin   = "admin"   //администратор
+)
+
+// Roles все роли
+var Roles = []string{RoleUser, RoleSupport, RoleAdmin}
+
+// Account сведения о пользователе для администрирования
+type Account struct {
+	ID    string `json:"id"`
+	Login string `json:"login"`
+	Role  string `json:"role"`
 }
 
 type Order struct {
@@ -41,23 +62,479 @@ type Withdraw struct {
 	Num     *int64    `json:"order"`                  //номер заказа
 	Expence *int64    `json:"sum"`                    //сумма списания баллов
 	Ins     time.Time `json:"processed_at,omitempty"` //дата совершения
+	OTP     string    `json:"otp,omitempty"`          //одноразовый код второго фактора, только в запросе
+	Status  string    `json:"status,omitempty"`       //статус возврата, для возвратов - WithdrawReversal
 }
 
+const (
+	WithdrawProcessed         = "PROCESSED"          //списание без возвратов
+	WithdrawPartiallyReversed = "PARTIALLY_REVERSED" //часть суммы возвращена
+	WithdrawReversed          = "REVERSED"           //сумма возвращена полностью
+	WithdrawReversal          = "REVERSAL"           //возврат, сумма со знаком минус
+)
+
 func (w *Withdraw) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&struct {
 		UserID  string `json:"userid,omitempty"`
 		Num     *int64 `json:"order"`
 		Expence *int64 `json:"sum"`
 		Ins     string `json:"processed_at,omitempty"`
+		Status  string `json:"status,omitempty"`
 	}{
 		UserID:  w.UserID,
 		Num:     w.Num,
 		Expence: w.Expence,
 		Ins:     w.Ins.Format(time.RFC3339),
+		Status:  w.Status,
 	})
 }
 
 type Balance struct {
-	Current *int64 `json:"current"`   //текущий баланс
-	Expence *int64 `json:"withdrawn"` //использовано баллов за весь период
+	Current  *int64 `json:"current"`                 //текущий баланс
+	Expence  *int64 `json:"withdrawn"`               //использовано баллов за весь период
+	Held     *int64 `json:"held"`                    //зарезервировано, в current не входит
+	Expiring *int64 `json:"expiring_soon,omitempty"` //сгорит в ближайшее время, только если баллы сгорают
+}
+
+const (
+	EventOrderCreated       = "order.created"       //заказ загружен пользователем
+	EventOrderUpdated       = "order.updated"       //получен расчет из системы начислений
+	EventWithdrawalCreated  = "withdrawal.created"  //списание баллов
+	EventWithdrawalReversed = "withdrawal.reversed" //возврат списанных баллов
+	EventTransferReceived   = "transfer.received"   //входящий перевод, в том числе ожидающий подтверждения
+	EventTransferUpdated    = "transfer.updated"    //перевод подтвержден, отклонен или отменен другой стороной
+	EventTierChanged        = "tier.changed"        //изменился уровень программы лояльности
+)
+
+// Events перечень событий, на которые можно подписать webhook
+var Events = []string{EventOrderCreated, EventOrderUpdated, EventWithdrawalCreated, EventWithdrawalReversed,
+	EventTransferReceived, EventTransferUpdated, EventTierChanged}
+
+type Webhook struct {
+	ID     string    `json:"id,omitempty"`     //uuid подписки
+	UserID string    `json:"-"`                //uuid пользователя
+	URL    string    `json:"url"`              //адрес доставки
+	Events []string  `json:"events"`           //события подписки
+	Secret string    `json:"secret,omitempty"` //ключ подписи HMAC, отдается только при создании
+	Active bool      `json:"active"`           //подписка активна
+	Ins    time.Time `json:"created_at"`       //дата создания
+}
+
+type WebhookEvent struct {
+	Event  string    `json:"event"`       //тип события
+	UserID string    `json:"user_id"`     //uuid пользователя
+	Data   any       `json:"data"`        //данные события
+	Ins    time.Time `json:"occurred_at"` //дата события
+}
+
+type Delivery struct {
+	ID           int64     `json:"id"`                      //id доставки
+	WebhookID    string    `json:"webhook_id"`              //uuid подписки
+	URL          string    `json:"-"`                       //адрес доставки
+	Secret       string    `json:"-"`                       //ключ подписи
+	Event        string    `json:"event"`                   //тип события
+	Payload      string    `json:"-"`                       //тело запроса
+	Status       string    `json:"status"`                  //PENDING, DELIVERED, FAILED
+	Attempts     int       `json:"attempts"`                //число попыток
+	ResponseCode *int      `json:"response_code,omitempty"` //код ответа получателя
+	LastError    string    `json:"last_error,omitempty"`    //ошибка последней попытки
+	NextTry      time.Time `json:"next_try"`                //время следующей попытки
+	Upd          time.Time `json:"updated_at"`              //дата изменения
+}
+
+type PasswordChange struct {
+	Login       string `json:"login,omitempty"`        //login, для сброса по токену
+	Token       string `json:"token,omitempty"`        //токен сброса пароля
+	OldPassword string `json:"old_password,omitempty"` //текущий пароль, для смены
+	NewPassword string `json:"new_password"`           //новый пароль
+	Hash        string `json:"-"`                      //hash нового пароля
+}
+
+type PasswordReset struct {
+	Login   string    `json:"login"`      //login пользователя
+	Token   string    `json:"token"`      //одноразовый токен сброса
+	Expires time.Time `json:"expires_at"` //срок действия токена
+}
+
+const (
+	AttemptLogin = "login" //счетчик неудачных входов по логину
+	AttemptIP    = "ip"    //счетчик неудачных входов по адресу клиента
+
+	AttemptRedeem   = "redeem"    //счетчик неверных кодов ваучеров по логину
+	AttemptRedeemIP = "redeem_ip" //счетчик неверных кодов ваучеров по адресу клиента
+)
+
+type LoginAttempt struct {
+	Kind         string        //login, ip, redeem или redeem_ip
+	Key          string        //логин или адрес
+	Failures     int           //неудачных попыток подряд
+	SinceFailure time.Duration //прошло с последней неудачи
+	LockedFor    time.Duration //осталось до снятия блокировки
+}
+
+// TwoFactor данные привязки TOTP: секрет и коды восстановления отдаются один раз
+type TwoFactor struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TOTP struct {
+	Secret    string //секрет в base32
+	Confirmed bool   //привязка подтверждена кодом
+}
+
+// OTP одноразовый код TOTP или код восстановления
+type OTP struct {
+	Code string `json:"otp"`
+}
+
+const (
+	HistoryAccrual        = "accrual"         //начисление за заказ
+	HistoryWithdrawal     = "withdrawal"      //списание
+	HistoryAdjustment     = "adjustment"      //корректировка сотрудником
+	HistoryReversal       = "reversal"        //возврат списания
+	HistoryExpiration     = "expiration"      //сгорание баллов
+	HistoryTransferOut    = "transfer_out"    //перевод другому пользователю
+	HistoryTransferIn     = "transfer_in"     //перевод от другого пользователя
+	HistoryTransferReturn = "transfer_return" //возврат отклоненного или отмененного перевода
+	HistoryBonus          = "bonus"           //бонус маркетинговой акции
+	HistoryReferral       = "referral"        //бонус реферальной программы
+	HistoryVoucher        = "voucher"         //погашение ваучера
+)
+
+// Adjustment корректировка баланса сотрудником поддержки
+type Adjustment struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"-"`
+	ActorID   string    `json:"actor_id"`  //uuid сотрудника
+	Amount    *int64    `json:"amount"`    //> 0 зачисление, < 0 списание
+	Reason    string    `json:"reason"`    //причина
+	Reference string    `json:"reference"` //внешняя ссылка (обращение, тикет), уникальна для пользователя
+	Ins       time.Time `json:"created_at"`
+}
+
+const (
+	AdjustmentOK           = "OK"
+	AdjustmentDuplicate    = "DUPLICATE"
+	AdjustmentInsufficient = "INSUFFICIENT"
+)
+
+// HistoryEntry движение баллов в истории пользователя
+type HistoryEntry struct {
+	Kind      string    `json:"kind"`
+	Reference string    `json:"reference"`         //номер заказа или ссылка операции
+	Amount    int64     `json:"amount"`            //со знаком
+	Comment   string    `json:"comment,omitempty"` //причина корректировки
+	Ins       time.Time `json:"processed_at"`
+}
+
+// Reversal возврат списания баллов, полный или частичный
+type Reversal struct {
+	Num       int64     `json:"order"`
+	Login     string    `json:"login,omitempty"` //нужен, если номер списан несколькими пользователями
+	UserID    string    `json:"-"`
+	ActorID   string    `json:"actor_id"`
+	Amount    *int64    `json:"amount"`    //в запросе не указан - возвращается весь остаток
+	Remaining int64     `json:"remaining"` //невозвращенный остаток списания
+	Reason    string    `json:"reason"`
+	Ins       time.Time `json:"created_at"`
+}
+
+const (
+	ReversalOK        = "OK"
+	ReversalNotFound  = "NOT_FOUND"
+	ReversalAmbiguous = "AMBIGUOUS"
+	ReversalExceeds   = "EXCEEDS"
+)
+
+// Hold резерв баллов под заказ до оплаты
+type Hold struct {
+	ID      string    `json:"id"`
+	UserID  string    `json:"-"`
+	Num     int64     `json:"order"`
+	Amount  int64     `json:"sum"`
+	Status  string    `json:"status"`
+	Expires time.Time `json:"expires_at"`
+	Ins     time.Time `json:"created_at"`
+}
+
+const (
+	HoldActive   = "ACTIVE"
+	HoldCaptured = "CAPTURED" //превращен в списание
+	HoldVoided   = "VOIDED"
+	HoldExpired  = "EXPIRED"
+)
+
+const (
+	HoldOK        = "OK"
+	HoldDuplicate = "DUPLICATE"
+	HoldNotFound  = "NOT_FOUND"
+	HoldNotActive = "NOT_ACTIVE"
+)
+
+// Transfer перевод баллов другому пользователю
+type Transfer struct {
+	ID      string    `json:"id"`
+	FromID  string    `json:"-"`
+	ToID    string    `json:"-"`
+	From    string    `json:"from"`              //логин отправителя
+	To      string    `json:"to"`                //логин получателя
+	Amount  *int64    `json:"sum"`               //сумма перевода
+	Comment string    `json:"comment,omitempty"` //сообщение получателю
+	Status  string    `json:"status"`
+	Ins     time.Time `json:"created_at"`
+	OTP     string    `json:"otp,omitempty"` //одноразовый код второго фактора, только в запросе
+}
+
+const (
+	TransferPending   = "PENDING" //ждет подтверждения получателем
+	TransferCompleted = "COMPLETED"
+	TransferDeclined  = "DECLINED"
+	TransferCanceled  = "CANCELED"
+)
+
+const (
+	TransferOK           = "OK"
+	TransferNotFound     = "NOT_FOUND"
+	TransferSelf         = "SELF"
+	TransferLimit        = "LIMIT"
+	TransferInsufficient = "INSUFFICIENT"
+	TransferNotPending   = "NOT_PENDING"
+)
+
+// действия над ожидающим переводом
+const (
+	TransferAccept  = "accept"
+	TransferDecline = "decline"
+	TransferCancel  = "cancel"
+)
+
+// WithdrawRules правила списаний и резервов. В общих правилах 0 - правило отключено,
+// в индивидуальных null - действует общее правило
+type WithdrawRules struct {
+	Min      *int64 `json:"min_amount"`          //минимальная сумма
+	MaxTx    *int64 `json:"max_per_transaction"` //максимальная сумма одного списания
+	Daily    *int64 `json:"daily"`               //за календарный день
+	Monthly  *int64 `json:"monthly"`             //за календарный месяц
+	Cooldown *int64 `json:"cooldown_seconds"`    //пауза после регистрации
+}
+
+const (
+	WithdrawOK           = "OK"
+	WithdrawInsufficient = "INSUFFICIENT"
+	WithdrawBelowMin     = "BELOW_MIN"
+	WithdrawAboveMax     = "ABOVE_MAX"
+	WithdrawDailyLimit   = "DAILY_LIMIT"
+	WithdrawMonthlyLimit = "MONTHLY_LIMIT"
+	WithdrawCooldown     = "COOLDOWN"
+	WithdrawDuplicate    = "DUPLICATE" //списание с таким номером уже есть
+)
+
+// Profile профиль пользователя в программе лояльности
+type Profile struct {
+	Login      string     `json:"login"`
+	Tier       string     `json:"tier"`
+	Multiplier float64    `json:"multiplier"`               //множитель начислений уровня
+	Accrued    int64      `json:"rolling_accrued"`          //начислено за скользящее окно без множителя
+	NextTier   string     `json:"next_tier,omitempty"`      //следующий уровень, нет для высшего
+	ToNextTier *int64     `json:"points_to_next,omitempty"` //сколько осталось начислить до следующего уровня
+	Registered *time.Time `json:"registered_at,omitempty"`  //нет у зарегистрированных до учета даты
+}
+
+// Referral реферальный код пользователя и итоги приглашений
+type Referral struct {
+	Code     string `json:"code"`
+	Invited  int64  `json:"invited"`  //приглашенные, кроме отклоненных проверкой злоупотреблений
+	Rewarded int64  `json:"rewarded"` //приглашенные с обработанным заказом
+	Earned   int64  `json:"earned"`   //начислено пригласившему
+}
+
+const (
+	ReferralPending  = "PENDING"      //ждет первого обработанного заказа приглашенного
+	ReferralRejected = "REJECTED"     //не засчитано: самоприглашение или повтор адреса
+	ReferralUnknown  = "UNKNOWN_CODE" //код не найден, пользователь не создан
+)
+
+// TierChange смена уровня пользователя при пересчете
+type TierChange struct {
+	UserID     string  `json:"-"`
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// Campaign маркетинговая акция с бонусом за обработанный заказ
+type Campaign struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`        //CampaignMultiplier или CampaignFixed
+	Value      float64   `json:"value"`       //множитель начисления или число баллов
+	Condition  string    `json:"condition"`   //CampaignAny или CampaignFirstOrder
+	MinAccrual int64     `json:"min_accrual"` //минимальное начисление системы расчета
+	Starts     time.Time `json:"starts_at"`
+	Ends       time.Time `json:"ends_at"`
+	Active     *bool     `json:"active"`
+	Ins        time.Time `json:"created_at"`
+}
+
+const (
+	CampaignMultiplier = "multiplier" //бонус accrual * (value - 1)
+	CampaignFixed      = "fixed"      //бонус value баллов
+	CampaignAny        = "any"
+	CampaignFirstOrder = "first_order" //только первый обработанный заказ пользователя
+)
+
+// VoucherBatch партия ваучеров; коды отдаются только при выпуске
+type VoucherBatch struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Amount   int64      `json:"amount"`   //баллов за погашение
+	Count    int        `json:"count"`    //кодов в партии
+	MaxUses  int        `json:"max_uses"` //1 - одноразовые коды, больше - многоразовые
+	Expires  *time.Time `json:"expires_at,omitempty"`
+	Redeemed int64      `json:"redeemed"` //всего погашений
+	Codes    []string   `json:"codes,omitempty"`
+	ActorID  string     `json:"-"`
+	Ins      time.Time  `json:"created_at"`
+}
+
+// Redemption погашение ваучера пользователем
+type Redemption struct {
+	UserID string `json:"-"`
+	Code   string `json:"code"`
+	Amount int64  `json:"amount"` //зачислено баллов
+}
+
+const (
+	VoucherOK        = "OK"
+	VoucherNotFound  = "NOT_FOUND"
+	VoucherExpired   = "EXPIRED"
+	VoucherExhausted = "EXHAUSTED" //исчерпан лимит погашений кода
+	VoucherRedeemed  = "REDEEMED"  //код уже погашен этим пользователем
+)
+
+// Reward награда каталога
+type Reward struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Price       int64     `json:"price"` //цена в баллах
+	Stock       *int64    `json:"stock"` //остаток, null - без ограничения
+	Active      *bool     `json:"active"`
+	Ins         time.Time `json:"created_at"`
+}
+
+// RewardRedemption обмен баллов на награду; списание идет под номером заказа Num
+type RewardRedemption struct {
+	ID       string    `json:"id"`
+	UserID   string    `json:"-"`
+	RewardID *string   `json:"reward_id"` //null - награда удалена из каталога
+	Name     string    `json:"name"`
+	Price    int64     `json:"price"`
+	Num      *int64    `json:"order"` //номер списания, без него генерируется
+	OTP      string    `json:"otp,omitempty"`
+	Ins      time.Time `json:"created_at"`
+}
+
+const (
+	RewardNotFound   = "NOT_FOUND"
+	RewardOutOfStock = "OUT_OF_STOCK"
+	RewardDuplicate  = "DUPLICATE" //списание с таким номером уже есть
+)
+
+// StatementRow строка выписки с остатком после операции
+type StatementRow struct {
+	Date      time.Time `json:"date"`
+	Kind      string    `json:"kind"`             //order, withdrawal или вид операции ledger
+	Reference string    `json:"reference"`        //номер заказа или ссылка операции
+	Status    string    `json:"status,omitempty"` //статус заказа
+	Amount    int64     `json:"amount"`           //со знаком
+	Balance   int64     `json:"balance"`          //остаток без учета резервов
+	Comment   string    `json:"comment,omitempty"`
+}
+
+// MonthlyStatement итоги месяца и PDF-документ выписки; File - ключ документа в хранилище файлов
+type MonthlyStatement struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"-"`
+	Period      string    `json:"period"` //ГГГГ-ММ
+	Opening     int64     `json:"opening_balance"`
+	Accruals    int64     `json:"accruals"`
+	Withdrawals int64     `json:"withdrawals"`
+	Other       int64     `json:"other"` //бонусы, корректировки, переводы и прочие операции ledger
+	Closing     int64     `json:"closing_balance"`
+	File        string    `json:"-"`
+	Ins         time.Time `json:"created_at"`
+}
+
+// MonthlyStatementPeriod формат периода ежемесячной выписки
+const MonthlyStatementPeriod = "2006-01"
+
+// Stats статистика заказов и списаний пользователя за всю историю и по месяцам
+type Stats struct {
+	Orders         int64        `json:"orders"`
+	Processed      int64        `json:"processed"`
+	Invalid        int64        `json:"invalid"`
+	ProcessedRatio float64      `json:"processed_ratio"` //доля обработанных заказов
+	InvalidRatio   float64      `json:"invalid_ratio"`   //доля отклоненных заказов
+	Accrued        int64        `json:"accrued"`
+	AvgAccrual     float64      `json:"avg_accrual"` //среднее начисление на обработанный заказ
+	Withdrawals    int64        `json:"withdrawals"` //число списаний
+	Withdrawn      int64        `json:"withdrawn"`   //сумма списаний
+	Months         []StatsMonth `json:"months"`      //новые первыми, только месяцы с заказами или списаниями
+}
+
+// StatsMonth статистика за месяц
+type StatsMonth struct {
+	Month       string `json:"month"` //ГГГГ-ММ
+	Orders      int64  `json:"orders"`
+	Processed   int64  `json:"processed"`
+	Invalid     int64  `json:"invalid"`
+	Accrued     int64  `json:"accrued"`
+	Withdrawals int64  `json:"withdrawals"`
+	Withdrawn   int64  `json:"withdrawn"`
+}
+
+// ReportLiability обязательства по баллам на текущий момент
+type ReportLiability struct {
+	Outstanding int64 `json:"outstanding"` //непотраченные баллы всех пользователей, включая резервы
+	Held        int64 `json:"held"`        //в резервах
+	Available   int64 `json:"available"`   //доступно к списанию
+	Users       int64 `json:"users"`       //пользователей с положительным остатком
+}
+
+// ReportDay итоги дня
+type ReportDay struct {
+	Day         string `json:"day"` //ГГГГ-ММ-ДД
+	Orders      int64  `json:"orders"`
+	Processed   int64  `json:"processed"`
+	Invalid     int64  `json:"invalid"`
+	Accrued     int64  `json:"accrued"`
+	Withdrawals int64  `json:"withdrawals"`
+	Withdrawn   int64  `json:"withdrawn"`
+	Credited    int64  `json:"credited"` //прочие зачисления ledger: бонусы, ваучеры, возвраты, корректировки
+	Debited     int64  `json:"debited"`  //прочие списания ledger: сгорание, корректировки, переводы
 }
+
+// ReportStuck заказы в статусе status, ожидающие расчета дольше age
+type ReportStuck struct {
+	Status string    `json:"status"`
+	Age    string    `json:"age"` //under_1h, 1h_1d, 1d_7d, over_7d
+	Orders int64     `json:"orders"`
+	Oldest time.Time `json:"oldest"`
+}
+
+// ReportTopUser итоги пользователя за период отчета
+type ReportTopUser struct {
+	Login       string `json:"login"`
+	Orders      int64  `json:"orders"`
+	Accrued     int64  `json:"accrued"`
+	Withdrawals int64  `json:"withdrawals"`
+	Withdrawn   int64  `json:"withdrawn"`
+}
+
+const (
+	ReportByAccrued   = "accrued"
+	ReportByWithdrawn = "withdrawn"
+)
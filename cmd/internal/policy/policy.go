@@ -0,0 +1,143 @@
+// Package policy проверяет логины и пароли новых учетных записей
+package policy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrLoginLength      = errors.New("login length is out of range")
+	ErrLoginCharset     = errors.New("login contains forbidden characters")
+	ErrPasswordShort    = errors.New("password is too short")
+	ErrPasswordWeak     = errors.New("password is too predictable")
+	ErrPasswordIsLogin  = errors.New("password equals login")
+	ErrPasswordBreached = errors.New("password is found in breached passwords list")
+)
+
+type config interface {
+	GetLoginMinLength() int
+	GetLoginMaxLength() int
+	GetLoginPattern() string
+	GetPasswordMinLength() int
+	GetPasswordMinEntropy() float64
+	GetBreachedPasswords() string
+}
+
+// Policy требования к логину и паролю
+type Policy struct {
+	loginMin   int
+	loginMax   int
+	login      *regexp.Regexp
+	passMin    int
+	minEntropy float64
+	breached   map[string]struct{}
+}
+
+// NewPolicy собирает политику из конфигурации и загружает список утекших паролей, если он задан
+func NewPolicy(cfg config) (*Policy, error) {
+	login, err := regexp.Compile(cfg.GetLoginPattern())
+	if err != nil {
+		return nil, fmt.Errorf("invalid login pattern: %w", err)
+	}
+	p := &Policy{
+		loginMin:   cfg.GetLoginMinLength(),
+		loginMax:   cfg.GetLoginMaxLength(),
+		login:      login,
+		passMin:    cfg.GetPasswordMinLength(),
+		minEntropy: cfg.GetPasswordMinEntropy(),
+	}
+	if path := cfg.GetBreachedPasswords(); path != "" {
+		if p.breached, err = load(path); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// load читает список паролей: по одному в строке, как в открытых словарях утечек
+func load(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached passwords list: %w", err)
+	}
+	defer f.Close()
+	list := map[string]struct{}{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			list[line] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached passwords list: %w", err)
+	}
+	return list, nil
+}
+
+// CheckLogin проверяет длину и допустимые символы логина
+func (p *Policy) CheckLogin(login string) error {
+	if n := utf8.RuneCountInString(login); n < p.loginMin || n > p.loginMax {
+		return fmt.Errorf("%w: %d..%d characters expected", ErrLoginLength, p.loginMin, p.loginMax)
+	}
+	if !p.login.MatchString(login) {
+		return fmt.Errorf("%w: must match %s", ErrLoginCharset, p.login)
+	}
+	return nil
+}
+
+// CheckPassword проверяет новый пароль пользователя login
+func (p *Policy) CheckPassword(login string, password string) error {
+	if n := utf8.RuneCountInString(password); n < p.passMin {
+		return fmt.Errorf("%w: at least %d characters expected", ErrPasswordShort, p.passMin)
+	}
+	if login != "" && strings.EqualFold(login, password) {
+		return ErrPasswordIsLogin
+	}
+	if bits := Entropy(password); bits < p.minEntropy {
+		return fmt.Errorf("%w: %.0f bits of entropy, at least %.0f expected", ErrPasswordWeak, bits, p.minEntropy)
+	}
+	if _, ok := p.breached[password]; ok {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+// Entropy оценка энтропии пароля в битах: длина * log2 размера алфавита по встреченным классам символов
+func Entropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	pool := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+	return float64(utf8.RuneCountInString(password)) * math.Log2(float64(pool))
+}
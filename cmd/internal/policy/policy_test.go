@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testConfig struct {
+	breached string
+}
+
+func (testConfig) GetLoginMinLength() int         { return 3 }
+func (testConfig) GetLoginMaxLength() int         { return 16 }
+func (testConfig) GetLoginPattern() string        { return `^[A-Za-z0-9._@-]+$` }
+func (testConfig) GetPasswordMinLength() int      { return 8 }
+func (testConfig) GetPasswordMinEntropy() float64 { return 36 }
+func (c testConfig) GetBreachedPasswords() string { return c.breached }
+
+func testPolicy(t *testing.T) *Policy {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("Tr0ub4dor&3\r\nP@ssw0rd2024\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPolicy(testConfig{breached: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCheckLogin(t *testing.T) {
+	p := testPolicy(t)
+	for _, tc := range []struct {
+		name  string
+		login string
+		want  error
+	}{
+		{"valid", "gopher.42@mail", nil},
+		{"shortest", "abc", nil},
+		{"too short", "ab", ErrLoginLength},
+		{"too long", strings.Repeat("a", 17), ErrLoginLength},
+		{"length in runes", "ёжик", ErrLoginCharset},
+		{"space", "bad login", ErrLoginCharset},
+		{"slash", "a/b/c", ErrLoginCharset},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := p.CheckLogin(tc.login); !errors.Is(err, tc.want) {
+				t.Errorf("CheckLogin(%q) = %v, want %v", tc.login, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	p := testPolicy(t)
+	for _, tc := range []struct {
+		name     string
+		login    string
+		password string
+		want     error
+	}{
+		{"valid", "gopher", "correct-Horse-9", nil},
+		{"too short", "gopher", "Ab1!xyz", ErrPasswordShort},
+		{"low entropy", "gopher", "12345678", ErrPasswordWeak},
+		{"equals login", "Alexander1", "alexander1", ErrPasswordIsLogin},
+		{"breached", "gopher", "Tr0ub4dor&3", ErrPasswordBreached},
+		{"breached with CRLF in list", "gopher", "P@ssw0rd2024", ErrPasswordBreached},
+		{"breached is exact", "gopher", "tr0ub4dor&3", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := p.CheckPassword(tc.login, tc.password); !errors.Is(err, tc.want) {
+				t.Errorf("CheckPassword(%q, %q) = %v, want %v", tc.login, tc.password, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		min, max float64
+	}{
+		{"", 0, 0},
+		{"aaaaaaaa", 37, 38},      // 8 * log2(26)
+		{"12345678", 26, 27},      // 8 * log2(10)
+		{"Ab1!Ab1!", 52, 53},      // 8 * log2(95)
+		{"пароль-пароль", 91, 92}, // 13 * log2(133)
+	} {
+		if bits := Entropy(tc.password); bits < tc.min || bits > tc.max {
+			t.Errorf("Entropy(%q) = %.1f, want %.0f..%.0f", tc.password, bits, tc.min, tc.max)
+		}
+	}
+}
+
+func TestNewPolicyBreachedMissing(t *testing.T) {
+	if _, err := NewPolicy(testConfig{breached: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Error("missing breached passwords list accepted")
+	}
+}
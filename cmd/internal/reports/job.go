@@ -0,0 +1,54 @@
+// Package reports периодически пересчитывает дневные итоги для отчетов администратора
+package reports
+
+import (
+	"context"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+)
+
+type Job struct {
+	repo repository
+	cfg  config
+	lg   *logger.Logger
+	ctx  context.Context
+}
+
+type config interface {
+	GetReportsInterval() time.Duration
+}
+
+type repository interface {
+	ReportsRefresh() error
+}
+
+func NewJob(c context.Context, r repository, conf config, lg *logger.Logger) *Job {
+	return &Job{
+		repo: r,
+		cfg:  conf,
+		lg:   lg,
+		ctx:  c,
+	}
+}
+
+// Run запускает задачу: итоги пересчитываются при старте и затем с периодом из конфигурации
+func (j *Job) Run() {
+	go j.worker()
+}
+
+func (j *Job) worker() {
+	ticker := time.NewTicker(j.cfg.GetReportsInterval())
+	defer ticker.Stop()
+	for {
+		if err := j.repo.ReportsRefresh(); err != nil {
+			j.lg.Err(err).Msg("reports refresh failed")
+		}
+		select {
+		case <-ticker.C:
+		case <-j.ctx.Done():
+			j.lg.Info().Msgf("reports refresh job stopped")
+			return
+		}
+	}
+}
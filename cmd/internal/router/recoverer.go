@@ -0,0 +1,35 @@
+package router
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+)
+
+// recoverer как middleware.Recoverer, но http.ErrAbortHandler не гасит, а передает серверу:
+// тот обрывает соединение, и клиент не примет начатый ответ за полный.
+// Стек пишется в лог как есть: разбор стека в chi v1.5.4 падает на формате новых версий Go
+func recoverer(lg *logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+				lg.Error().
+					Str("request_id", middleware.GetReqID(r.Context())).
+					Str("stack", string(debug.Stack())).
+					Msgf("Panic serving %s %s: %v", r.Method, r.URL.Path, rvr)
+				problem.Write(w, r, http.StatusInternalServerError, problem.Internal)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
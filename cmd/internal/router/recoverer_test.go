@@ -0,0 +1,49 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rs/zerolog"
+)
+
+// TestRecovererAbort http.ErrAbortHandler обрывает начатый ответ, остальные паники отвечают 500 с описанием проблемы
+func TestRecovererAbort(t *testing.T) {
+	lg := logger.New(&config.Config{})
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	srv := httptest.NewServer(recoverer(lg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/abort")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Errorf("aborted response read as complete: %q", body)
+	}
+
+	resp, err = http.Get(srv.URL + "/panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || resp.Header.Get("Content-Type") != problem.ContentType {
+		t.Errorf("panic: status %d, content type %q; want %d, %q",
+			resp.StatusCode, resp.Header.Get("Content-Type"), http.StatusInternalServerError, problem.ContentType)
+	}
+}
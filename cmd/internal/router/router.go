@@ -5,8 +5,11 @@ import (
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/api/openapi"
+	"github.com/rebus2015/gophermart/cmd/internal/api/problem"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
 	//"github.com/rebus2015/gophermart/cmd/internal/config"
-	//"github.com/rebus2015/gophermart/cmd/internal/logger"
 	//"github.com/rebus2015/gophermart/cmd/internal/model"
 )
 
@@ -18,42 +21,214 @@ type apiHandlers interface {
 	BalanceHandler(w http.ResponseWriter, r *http.Request)
 	WithdrawHandler(w http.ResponseWriter, r *http.Request)
 	WithdrawalsAllHandler(w http.ResponseWriter, r *http.Request)
+	WebhookAddHandler(w http.ResponseWriter, r *http.Request)
+	WebhooksAllHandler(w http.ResponseWriter, r *http.Request)
+	WebhookDeleteHandler(w http.ResponseWriter, r *http.Request)
+	DeliveriesHandler(w http.ResponseWriter, r *http.Request)
+	RedeliverHandler(w http.ResponseWriter, r *http.Request)
+	PasswordChangeHandler(w http.ResponseWriter, r *http.Request)
+	PasswordResetHandler(w http.ResponseWriter, r *http.Request)
+	AdminPasswordResetHandler(w http.ResponseWriter, r *http.Request)
+	AdminUnlockHandler(w http.ResponseWriter, r *http.Request)
+	AdminUsersHandler(w http.ResponseWriter, r *http.Request)
+	AdminUserHandler(w http.ResponseWriter, r *http.Request)
+	AdminUserOrdersHandler(w http.ResponseWriter, r *http.Request)
+	AdminUserWithdrawalsHandler(w http.ResponseWriter, r *http.Request)
+	AdminUserBalanceHandler(w http.ResponseWriter, r *http.Request)
+	AdminRoleHandler(w http.ResponseWriter, r *http.Request)
+	AdminRepollHandler(w http.ResponseWriter, r *http.Request)
+	AdminAdjustmentHandler(w http.ResponseWriter, r *http.Request)
+	HistoryHandler(w http.ResponseWriter, r *http.Request)
+	AdminReversalHandler(w http.ResponseWriter, r *http.Request)
+	HoldAddHandler(w http.ResponseWriter, r *http.Request)
+	HoldsHandler(w http.ResponseWriter, r *http.Request)
+	HoldCaptureHandler(w http.ResponseWriter, r *http.Request)
+	HoldVoidHandler(w http.ResponseWriter, r *http.Request)
+	TransferHandler(w http.ResponseWriter, r *http.Request)
+	TransfersHandler(w http.ResponseWriter, r *http.Request)
+	TransferAcceptHandler(w http.ResponseWriter, r *http.Request)
+	TransferDeclineHandler(w http.ResponseWriter, r *http.Request)
+	TransferCancelHandler(w http.ResponseWriter, r *http.Request)
+	AdminWithdrawLimitsHandler(w http.ResponseWriter, r *http.Request)
+	AdminWithdrawLimitsSetHandler(w http.ResponseWriter, r *http.Request)
+	ProfileHandler(w http.ResponseWriter, r *http.Request)
+	ReferralHandler(w http.ResponseWriter, r *http.Request)
+	RedeemHandler(w http.ResponseWriter, r *http.Request)
+	RewardsHandler(w http.ResponseWriter, r *http.Request)
+	StatementHandler(w http.ResponseWriter, r *http.Request)
+	StatsHandler(w http.ResponseWriter, r *http.Request)
+	MonthlyStatementsHandler(w http.ResponseWriter, r *http.Request)
+	MonthlyStatementHandler(w http.ResponseWriter, r *http.Request)
+	RewardRedeemHandler(w http.ResponseWriter, r *http.Request)
+	RewardRedemptionsHandler(w http.ResponseWriter, r *http.Request)
+	AdminRewardsHandler(w http.ResponseWriter, r *http.Request)
+	AdminRewardAddHandler(w http.ResponseWriter, r *http.Request)
+	AdminRewardUpdateHandler(w http.ResponseWriter, r *http.Request)
+	AdminReportLiabilityHandler(w http.ResponseWriter, r *http.Request)
+	AdminReportDailyHandler(w http.ResponseWriter, r *http.Request)
+	AdminReportStuckHandler(w http.ResponseWriter, r *http.Request)
+	AdminReportTopUsersHandler(w http.ResponseWriter, r *http.Request)
+	AdminVoucherBatchHandler(w http.ResponseWriter, r *http.Request)
+	AdminVoucherBatchesHandler(w http.ResponseWriter, r *http.Request)
+	AdminCampaignsHandler(w http.ResponseWriter, r *http.Request)
+	AdminCampaignAddHandler(w http.ResponseWriter, r *http.Request)
+	AdminCampaignUpdateHandler(w http.ResponseWriter, r *http.Request)
+	AdminCampaignDeleteHandler(w http.ResponseWriter, r *http.Request)
+	TwoFactorEnrollHandler(w http.ResponseWriter, r *http.Request)
+	TwoFactorConfirmHandler(w http.ResponseWriter, r *http.Request)
+	TwoFactorDisableHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type apiMiddleware interface {
 	BasicAuthMiddleware(next http.Handler) http.Handler
 	UserJSONMiddleware(next http.Handler) http.Handler
+	RegisterJSONMiddleware(next http.Handler) http.Handler
 	OrderTexMiddleware(next http.Handler) http.Handler
 	WithdrawJSONMiddleware(next http.Handler) http.Handler
+	WebhookJSONMiddleware(next http.Handler) http.Handler
+	PasswordJSONMiddleware(next http.Handler) http.Handler
+	OTPJSONMiddleware(next http.Handler) http.Handler
+	RoleMiddleware(roles ...string) func(next http.Handler) http.Handler
+	RoleJSONMiddleware(next http.Handler) http.Handler
+	AdjustmentJSONMiddleware(next http.Handler) http.Handler
+	ReversalJSONMiddleware(next http.Handler) http.Handler
+	TransferJSONMiddleware(next http.Handler) http.Handler
+	WithdrawRulesJSONMiddleware(next http.Handler) http.Handler
+	CampaignJSONMiddleware(next http.Handler) http.Handler
+	VoucherBatchJSONMiddleware(next http.Handler) http.Handler
+	RedeemJSONMiddleware(next http.Handler) http.Handler
+	RewardJSONMiddleware(next http.Handler) http.Handler
+	RewardRedeemJSONMiddleware(next http.Handler) http.Handler
 }
 
-func NewRouter(m apiMiddleware, h apiHandlers) chi.Router {
+func NewRouter(m apiMiddleware, h apiHandlers, lg *logger.Logger) chi.Router {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	r.Use(recoverer(lg))
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, http.StatusNotFound, problem.NotFound)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, http.StatusMethodNotAllowed, problem.MethodNotAllowed)
+	})
 
+	r.Get("/api/openapi.json", openapi.Handler)
 	r.Route("/api/user/", func(r chi.Router) {
-		r.With(m.UserJSONMiddleware).
+		r.With(m.RegisterJSONMiddleware).
 			Post("/register", h.UserRegisterHandler)
 		r.With(m.UserJSONMiddleware).
 			Post("/login", h.UserLoginHandler)
-		r.Route("/", func(r chi.Router) {
+		r.With(m.PasswordJSONMiddleware).
+			Post("/password/reset", h.PasswordResetHandler)
+		r.Group(func(r chi.Router) {
 			r.Use(m.BasicAuthMiddleware)
 			r.With(m.OrderTexMiddleware).
 				Post("/orders", h.UserOrderNewHandler)
 			r.Get("/orders", h.OrdersAllHandler)
+			r.Get("/profile", h.ProfileHandler)
+			r.Get("/stats", h.StatsHandler)
+			r.Get("/referral", h.ReferralHandler)
+			r.Get("/statement", h.StatementHandler)
+			r.Get("/statements", h.MonthlyStatementsHandler)
+			r.Get("/statements/{id}", h.MonthlyStatementHandler)
+			r.Route("/rewards", func(r chi.Router) {
+				r.Get("/", h.RewardsHandler)
+				r.Get("/redemptions", h.RewardRedemptionsHandler)
+				r.With(m.RewardRedeemJSONMiddleware).
+					Post("/{id}/redeem", h.RewardRedeemHandler)
+			})
+			r.With(m.PasswordJSONMiddleware).
+				Post("/password", h.PasswordChangeHandler)
+			r.Route("/2fa", func(r chi.Router) {
+				r.Post("/", h.TwoFactorEnrollHandler)
+				r.With(m.OTPJSONMiddleware).
+					Post("/confirm", h.TwoFactorConfirmHandler)
+				r.With(m.OTPJSONMiddleware).
+					Post("/disable", h.TwoFactorDisableHandler)
+			})
 			r.Route("/balance", func(r chi.Router) {
 				r.Get("/", h.BalanceHandler)
 				r.Get("/withdrawals", h.WithdrawalsAllHandler)
+				r.Get("/history", h.HistoryHandler)
 				r.With(m.WithdrawJSONMiddleware).
 					Post("/withdraw", h.WithdrawHandler)
+				r.Route("/holds", func(r chi.Router) {
+					r.Get("/", h.HoldsHandler)
+					r.With(m.WithdrawJSONMiddleware).
+						Post("/", h.HoldAddHandler)
+					r.Post("/{id}/capture", h.HoldCaptureHandler)
+					r.Post("/{id}/void", h.HoldVoidHandler)
+				})
+				r.With(m.TransferJSONMiddleware).
+					Post("/transfer", h.TransferHandler)
+				r.With(m.RedeemJSONMiddleware).
+					Post("/redeem", h.RedeemHandler)
+				r.Route("/transfers", func(r chi.Router) {
+					r.Get("/", h.TransfersHandler)
+					r.Post("/{id}/accept", h.TransferAcceptHandler)
+					r.Post("/{id}/decline", h.TransferDeclineHandler)
+					r.Post("/{id}/cancel", h.TransferCancelHandler)
+				})
+			})
+			r.Route("/webhooks", func(r chi.Router) {
+				r.With(m.WebhookJSONMiddleware).
+					Post("/", h.WebhookAddHandler)
+				r.Get("/", h.WebhooksAllHandler)
+				r.Delete("/{id}", h.WebhookDeleteHandler)
+				r.Get("/{id}/deliveries", h.DeliveriesHandler)
+				r.Post("/deliveries/{id}/redeliver", h.RedeliverHandler)
 			})
 
 		})
 
 	})
 
+	r.Route("/api/admin/", func(r chi.Router) {
+		r.Use(m.BasicAuthMiddleware)
+		r.Use(m.RoleMiddleware(model.RoleSupport, model.RoleAdmin))
+		r.Get("/users", h.AdminUsersHandler)
+		r.Get("/users/{login}", h.AdminUserHandler)
+		r.Get("/users/{login}/orders", h.AdminUserOrdersHandler)
+		r.Get("/users/{login}/withdrawals", h.AdminUserWithdrawalsHandler)
+		r.Get("/users/{login}/balance", h.AdminUserBalanceHandler)
+		r.Get("/users/{login}/withdraw-limits", h.AdminWithdrawLimitsHandler)
+		r.Post("/orders/{number}/repoll", h.AdminRepollHandler)
+		r.Get("/campaigns", h.AdminCampaignsHandler)
+		r.Get("/vouchers", h.AdminVoucherBatchesHandler)
+		r.Get("/rewards", h.AdminRewardsHandler)
+		r.With(m.AdjustmentJSONMiddleware).
+			Post("/users/{login}/adjustments", h.AdminAdjustmentHandler)
+		r.With(m.ReversalJSONMiddleware).
+			Post("/withdrawals/{order}/reverse", h.AdminReversalHandler)
+		r.Group(func(r chi.Router) {
+			r.Use(m.RoleMiddleware(model.RoleAdmin))
+			r.With(m.RoleJSONMiddleware).
+				Put("/users/{login}/role", h.AdminRoleHandler)
+			r.Post("/users/{login}/password-reset", h.AdminPasswordResetHandler)
+			r.Delete("/users/{login}/lockout", h.AdminUnlockHandler)
+			r.With(m.WithdrawRulesJSONMiddleware).
+				Put("/users/{login}/withdraw-limits", h.AdminWithdrawLimitsSetHandler)
+			r.With(m.CampaignJSONMiddleware).
+				Post("/campaigns", h.AdminCampaignAddHandler)
+			r.With(m.CampaignJSONMiddleware).
+				Put("/campaigns/{id}", h.AdminCampaignUpdateHandler)
+			r.Delete("/campaigns/{id}", h.AdminCampaignDeleteHandler)
+			r.With(m.VoucherBatchJSONMiddleware).
+				Post("/vouchers", h.AdminVoucherBatchHandler)
+			r.With(m.RewardJSONMiddleware).
+				Post("/rewards", h.AdminRewardAddHandler)
+			r.With(m.RewardJSONMiddleware).
+				Put("/rewards/{id}", h.AdminRewardUpdateHandler)
+			r.Route("/reports", func(r chi.Router) {
+				r.Get("/liability", h.AdminReportLiabilityHandler)
+				r.Get("/daily", h.AdminReportDailyHandler)
+				r.Get("/stuck-orders", h.AdminReportStuckHandler)
+				r.Get("/top-users", h.AdminReportTopUsersHandler)
+			})
+		})
+	})
+
 	return r
 }
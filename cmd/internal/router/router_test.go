@@ -0,0 +1,89 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rebus2015/gophermart/cmd/internal/api/handlers"
+	"github.com/rebus2015/gophermart/cmd/internal/api/middleware"
+	"github.com/rebus2015/gophermart/cmd/internal/api/openapi"
+	"github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// TestRoutesDocumented каждый маршрут роутера описан в спецификации и наоборот
+func TestRoutesDocumented(t *testing.T) {
+	cfg := &config.Config{}
+	lg := logger.New(cfg)
+	r := NewRouter(middleware.NewMiddlewares(nil, lg, nil, nil, nil, nil), handlers.NewAPI(nil, lg, nil, cfg, nil, nil, nil, nil), lg)
+	if err := openapi.Verify(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sessionRepo пользователи по токенам сессий
+type sessionRepo struct {
+	users map[string]*model.User
+}
+
+func (r *sessionRepo) UserLogin(user *model.User) (*model.User, error) {
+	return nil, nil
+}
+
+func (r *sessionRepo) SessionCheck(token string) (*model.User, error) {
+	return r.users[token], nil
+}
+
+// resetHandlers обработчики API, выдача токена сброса пароля только отмечает вызов
+type resetHandlers struct {
+	apiHandlers
+	issued int
+}
+
+func (h *resetHandlers) AdminPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	h.issued++
+	w.WriteHeader(http.StatusCreated)
+}
+
+// TestPasswordResetRole токен сброса пароля выдается только по сессии пользователя с ролью admin
+func TestPasswordResetRole(t *testing.T) {
+	cfg := &config.Config{}
+	lg := logger.New(cfg)
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	repo := &sessionRepo{users: map[string]*model.User{}}
+	for _, role := range []string{model.RoleUser, model.RoleSupport, model.RoleAdmin} {
+		repo.users[role] = &model.User{ID: role, Login: role, Role: role}
+	}
+	h := &resetHandlers{apiHandlers: handlers.NewAPI(nil, lg, nil, cfg, nil, nil, nil, nil)}
+	router := NewRouter(middleware.NewMiddlewares(repo, lg, nil, nil, nil, nil), h, lg)
+	for _, tc := range []struct {
+		name   string
+		header string
+		code   int
+	}{
+		{"anonymous", "", http.StatusBadRequest},
+		{"unknown session", "Bearer unknown", http.StatusUnauthorized},
+		{"user", "Bearer " + model.RoleUser, http.StatusForbidden},
+		{"support", "Bearer " + model.RoleSupport, http.StatusForbidden},
+		{"admin", "Bearer " + model.RoleAdmin, http.StatusCreated},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			issued := h.issued
+			r := httptest.NewRequest(http.MethodPost, "/api/admin/users/someone/password-reset", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tc.code {
+				t.Errorf("status %d, want %d", w.Code, tc.code)
+			}
+			if called := h.issued > issued; called != (tc.code == http.StatusCreated) {
+				t.Errorf("handler called %v", called)
+			}
+		})
+	}
+}
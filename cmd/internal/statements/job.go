@@ -0,0 +1,156 @@
+// Package statements формирует ежемесячные выписки пользователей в PDF
+package statements
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+const batchSize = 100 //пользователей за один запрос к базе
+
+type Job struct {
+	repo  repository
+	store store
+	cfg   config
+	lg    *logger.Logger
+	ctx   context.Context
+}
+
+type config interface {
+	GetStatementsInterval() time.Duration
+}
+
+type repository interface {
+	MonthlyStatementsDue(period time.Time, after string, limit int) (*[]model.User, error)
+	MonthlySummary(userID string, period time.Time) (*model.MonthlyStatement, error)
+	MonthlyStatementAdd(ms *model.MonthlyStatement) (bool, error)
+	Statement(user *model.User, from time.Time, to time.Time, row func(*model.StatementRow) error) error
+}
+
+type store interface {
+	Put(key string, data []byte) error
+}
+
+func NewJob(c context.Context, r repository, s store, conf config, lg *logger.Logger) *Job {
+	return &Job{
+		repo:  r,
+		store: s,
+		cfg:   conf,
+		lg:    lg,
+		ctx:   c,
+	}
+}
+
+// Run запускает задачу: выписки за прошедший месяц формируются при старте и затем с периодом из конфигурации,
+// пока не будут готовы для всех пользователей
+func (j *Job) Run() {
+	go j.worker()
+}
+
+func (j *Job) worker() {
+	ticker := time.NewTicker(j.cfg.GetStatementsInterval())
+	defer ticker.Stop()
+	for {
+		j.generate(time.Now())
+		select {
+		case <-ticker.C:
+		case <-j.ctx.Done():
+			j.lg.Info().Msgf("monthly statements job stopped")
+			return
+		}
+	}
+}
+
+// generate формирует выписки за месяц, предшествующий now. Пользователи обходятся по возрастанию id:
+// сбой по одному пользователю не останавливает остальных, его выписка повторится при следующем запуске
+func (j *Job) generate(now time.Time) {
+	y, m, _ := now.Date()
+	period := time.Date(y, m-1, 1, 0, 0, 0, 0, now.Location())
+	done, failed, after := 0, 0, ""
+	for j.ctx.Err() == nil {
+		users, err := j.repo.MonthlyStatementsDue(period, after, batchSize)
+		if err != nil {
+			j.lg.Err(err).Msg("monthly statements job failed to get users")
+			break
+		}
+		for i := range *users {
+			user := &(*users)[i]
+			after = user.ID
+			if err = j.statement(user, period); err != nil {
+				j.lg.Err(err).Msgf("monthly statement [%s] for user [%s] failed", period.Format(model.MonthlyStatementPeriod), user.Login)
+				failed++
+				continue
+			}
+			done++
+		}
+		if len(*users) < batchSize {
+			break
+		}
+	}
+	if done > 0 || failed > 0 {
+		j.lg.Info().Msgf("monthly statements [%s] generated for %v users, failed for %v", period.Format(model.MonthlyStatementPeriod), done, failed)
+	}
+}
+
+func (j *Job) statement(user *model.User, period time.Time) error {
+	ms, err := j.repo.MonthlySummary(user.ID, period)
+	if err != nil {
+		return err
+	}
+	var rows []model.StatementRow
+	err = j.repo.Statement(user, period, period.AddDate(0, 1, 0), func(row *model.StatementRow) error {
+		rows = append(rows, *row)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// ключ не зависит от попытки: повторная запись после сбоя заменяет тот же документ
+	ms.File = fmt.Sprintf("statements/%s/%s.pdf", user.ID, ms.Period)
+	if err = j.store.Put(ms.File, render(user, period, ms, rows)); err != nil {
+		return err
+	}
+	_, err = j.repo.MonthlyStatementAdd(ms)
+	return err
+}
+
+// render текст выписки: итоги месяца и операции с остатком после каждой
+func render(user *model.User, period time.Time, ms *model.MonthlyStatement, rows []model.StatementRow) []byte {
+	last := period.AddDate(0, 1, -1)
+	lines := []string{
+		"GOPHERMART LOYALTY PROGRAM - MONTHLY STATEMENT",
+		"",
+		fmt.Sprintf("Account:  %s", user.Login),
+		fmt.Sprintf("Period:   %s - %s", period.Format("2006-01-02"), last.Format("2006-01-02")),
+		fmt.Sprintf("Issued:   %s", time.Now().Format("2006-01-02")),
+		"",
+		fmt.Sprintf("%-24s %14d", "Opening balance", ms.Opening),
+		fmt.Sprintf("%-24s %+14d", "Accruals", ms.Accruals),
+		fmt.Sprintf("%-24s %+14d", "Withdrawals", -ms.Withdrawals),
+		fmt.Sprintf("%-24s %+14d", "Other operations", ms.Other),
+		fmt.Sprintf("%-24s %14d", "Closing balance", ms.Closing),
+		"",
+		"Balance excludes points held for pending payments.",
+		"",
+	}
+	if len(rows) == 0 {
+		lines = append(lines, "No operations in this period.")
+	} else {
+		header := fmt.Sprintf("%-16s  %-12s  %-22s  %10s  %10s", "Date", "Operation", "Reference", "Amount", "Balance")
+		lines = append(lines, header, strings.Repeat("-", len(header)))
+	}
+	for _, r := range rows {
+		ref := r.Reference
+		if len(ref) > 22 {
+			ref = ref[:21] + "~"
+		}
+		lines = append(lines, fmt.Sprintf("%-16s  %-12s  %-22s  %+10d  %10d",
+			r.Date.Format("2006-01-02 15:04"), r.Kind, ref, r.Amount, r.Balance))
+	}
+	return renderPDF(lines, fmt.Sprintf("Gophermart statement %s, %s", ms.Period, user.Login))
+}
@@ -0,0 +1,130 @@
+package statements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+// testRepo пользователи, которым нужна выписка, как monthly_statements_due: по возрастанию id после after
+type testRepo struct {
+	users  []model.User
+	done   map[string]bool
+	failed map[string]bool //сбой выписки пользователя
+	calls  int
+}
+
+func (r *testRepo) MonthlyStatementsDue(period time.Time, after string, limit int) (*[]model.User, error) {
+	r.calls++
+	list := []model.User{}
+	for _, u := range r.users {
+		if u.ID > after && !r.done[u.ID] && len(list) < limit {
+			list = append(list, u)
+		}
+	}
+	return &list, nil
+}
+
+func (r *testRepo) MonthlySummary(userID string, period time.Time) (*model.MonthlyStatement, error) {
+	if r.failed[userID] {
+		return nil, errors.New("database error")
+	}
+	return &model.MonthlyStatement{UserID: userID, Period: period.Format(model.MonthlyStatementPeriod)}, nil
+}
+
+func (r *testRepo) MonthlyStatementAdd(ms *model.MonthlyStatement) (bool, error) {
+	r.done[ms.UserID] = true
+	return true, nil
+}
+
+func (r *testRepo) Statement(user *model.User, from time.Time, to time.Time, row func(*model.StatementRow) error) error {
+	return row(&model.StatementRow{Date: from, Kind: "order", Reference: "12345678903", Amount: 10, Balance: 10})
+}
+
+type testStore struct {
+	files map[string][]byte
+}
+
+func (s *testStore) Put(key string, data []byte) error {
+	s.files[key] = data
+	return nil
+}
+
+type testConfig struct{}
+
+func (testConfig) GetStatementsInterval() time.Duration {
+	return time.Hour
+}
+
+func (testConfig) IsDebug() bool {
+	return false
+}
+
+// TestGenerateSkipsFailedUser сбой по одному пользователю не останавливает выписки остальных,
+// а при следующем запуске выписка повторяется только для него
+func TestGenerateSkipsFailedUser(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	users := make([]model.User, 2*batchSize+batchSize/2)
+	for i := range users {
+		users[i] = model.User{ID: fmt.Sprintf("%04d", i), Login: fmt.Sprintf("user%d", i)}
+	}
+	failing := []string{users[0].ID, users[batchSize+1].ID, users[len(users)-1].ID}
+	repo := &testRepo{users: users, done: map[string]bool{}, failed: map[string]bool{}}
+	for _, id := range failing {
+		repo.failed[id] = true
+	}
+	store := &testStore{files: map[string][]byte{}}
+	j := NewJob(context.Background(), repo, store, testConfig{}, logger.New(testConfig{}))
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	j.generate(now)
+	if len(repo.done) != len(users)-len(failing) {
+		t.Fatalf("%d statements, want %d", len(repo.done), len(users)-len(failing))
+	}
+	for _, id := range failing {
+		if repo.done[id] {
+			t.Errorf("statement for failing user %s generated", id)
+		}
+	}
+	if repo.calls != 3 {
+		t.Errorf("%d batches requested, want 3", repo.calls)
+	}
+	keys := make([]string, 0, len(store.files))
+	for key := range store.files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if want := "statements/0001/2024-02.pdf"; keys[0] != want {
+		t.Errorf("first file %s, want %s", keys[0], want)
+	}
+
+	repo.failed = map[string]bool{}
+	repo.calls = 0
+	j.generate(now)
+	if len(repo.done) != len(users) {
+		t.Errorf("%d statements after retry, want %d", len(repo.done), len(users))
+	}
+	if repo.calls != 1 {
+		t.Errorf("%d batches requested on retry, want 1", repo.calls)
+	}
+}
+
+// TestGenerateStopped остановленная задача не запрашивает пользователей
+func TestGenerateStopped(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	repo := &testRepo{users: []model.User{{ID: "1"}}, done: map[string]bool{}}
+	j := NewJob(ctx, repo, &testStore{files: map[string][]byte{}}, testConfig{}, logger.New(testConfig{}))
+	j.generate(time.Now())
+	if repo.calls != 0 || len(repo.done) != 0 {
+		t.Errorf("stopped job requested %d batches and made %d statements", repo.calls, len(repo.done))
+	}
+}
@@ -0,0 +1,83 @@
+package statements
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Минимальный PDF 1.4: страницы A4, встроенный моноширинный шрифт Courier, только текст.
+// Встроенные шрифты не содержат кириллицы, поэтому символы вне ASCII заменяются на '?'
+const (
+	pdfWidth    = 595
+	pdfHeight   = 842
+	pdfMargin   = 50
+	pdfFontSize = 9
+	pdfLeading  = 12
+	pdfPageRows = (pdfHeight - 2*pdfMargin) / pdfLeading
+)
+
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// renderPDF раскладывает строки по страницам, внизу каждой страницы - номер и footer
+func renderPDF(lines []string, footer string) []byte {
+	rows := pdfPageRows - 2 // место под номер страницы
+	var pages [][]string
+	for len(lines) > rows {
+		pages = append(pages, lines[:rows])
+		lines = lines[rows:]
+	}
+	pages = append(pages, lines)
+
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	// 1 - каталог, 2 - дерево страниц, 3 - шрифт, далее пары страница + содержимое
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>")
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfHeight-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+		}
+		content.WriteString("ET\n")
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d %d Td\n(%s) Tj\nET\n", pdfFontSize, pdfMargin, pdfMargin/2,
+			pdfEscape(fmt.Sprintf("%s    page %d of %d", footer, i+1, len(pages))))
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfWidth, pdfHeight, 5+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,130 @@
+package statements
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// checkXref каждая запись таблицы xref указывает на начало своего объекта
+func checkXref(t *testing.T, doc []byte) {
+	t.Helper()
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(doc)
+	if m == nil {
+		t.Fatal("no startxref trailer")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(doc[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to xref", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc[xref:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(doc[off:], []byte(want)) {
+			t.Errorf("xref entry %d points to %q", i+1, doc[off:off+len(want)])
+		}
+	}
+	if size := fmt.Sprintf("/Size %d ", len(entries)+1); !bytes.Contains(doc, []byte(size)) {
+		t.Errorf("trailer does not contain %q", size)
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		lines int
+		pages int
+	}{
+		{"empty", 0, 1},
+		{"one page", pdfPageRows - 2, 1},
+		{"two pages", pdfPageRows - 1, 2},
+		{"three pages", 2*(pdfPageRows-2) + 1, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := make([]string, tc.lines)
+			for i := range lines {
+				lines[i] = fmt.Sprintf("line %d", i)
+			}
+			doc := renderPDF(lines, "footer")
+			if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) {
+				t.Fatal("no PDF header")
+			}
+			checkXref(t, doc)
+			if count := fmt.Sprintf("/Count %d >>", tc.pages); !bytes.Contains(doc, []byte(count)) {
+				t.Errorf("page tree does not contain %q", count)
+			}
+			if got := bytes.Count(doc, []byte("/Type /Page /Parent")); got != tc.pages {
+				t.Errorf("%d pages, want %d", got, tc.pages)
+			}
+			if last := fmt.Sprintf("(footer    page %d of %d)", tc.pages, tc.pages); !bytes.Contains(doc, []byte(last)) {
+				t.Errorf("no footer %q", last)
+			}
+			for _, line := range lines {
+				if !bytes.Contains(doc, []byte("("+line+") Tj")) {
+					t.Fatalf("line %q is missing", line)
+				}
+			}
+		})
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":       "plain",
+		`a(b)c\d`:     `a\(b\)c\\d`,
+		"тест":        "????",
+		"tab\there":   "tab?here",
+		"price: 10 €": "price: 10 ?",
+	} {
+		if got := pdfEscape(in); got != want {
+			t.Errorf("pdfEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	user := &model.User{ID: "42", Login: "gopher"}
+	period := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	ms := &model.MonthlyStatement{Period: "2024-02", Opening: 100, Accruals: 50, Withdrawals: 30, Other: -5, Closing: 115}
+
+	doc := render(user, period, ms, nil)
+	checkXref(t, doc)
+	for _, want := range []string{
+		"(Account:  gopher)",
+		"(Period:   2024-02-01 - 2024-02-29)",
+		fmt.Sprintf("(%-24s %14d)", "Opening balance", 100),
+		fmt.Sprintf("(%-24s %+14d)", "Accruals", 50),
+		fmt.Sprintf("(%-24s %+14d)", "Withdrawals", -30),
+		fmt.Sprintf("(%-24s %+14d)", "Other operations", -5),
+		fmt.Sprintf("(%-24s %14d)", "Closing balance", 115),
+		"(No operations in this period.)",
+		"(Gophermart statement 2024-02, gopher    page 1 of 1)",
+	} {
+		if !bytes.Contains(doc, []byte(want)) {
+			t.Errorf("statement does not contain %q", want)
+		}
+	}
+
+	rows := []model.StatementRow{
+		{Date: period.Add(time.Hour), Kind: "order", Reference: "12345678903", Amount: 50, Balance: 150},
+		{Date: period.Add(2 * time.Hour), Kind: "withdrawal", Reference: strings.Repeat("9", 30), Amount: -30, Balance: 120},
+	}
+	doc = render(user, period, ms, rows)
+	if bytes.Contains(doc, []byte("No operations")) {
+		t.Error("statement with operations says there are none")
+	}
+	for _, want := range []string{
+		fmt.Sprintf("(%-16s  %-12s  %-22s  %+10d  %10d)", "2024-02-01 01:00", "order", "12345678903", 50, 150),
+		fmt.Sprintf("(%-16s  %-12s  %-22s  %+10d  %10d)", "2024-02-01 02:00", "withdrawal", strings.Repeat("9", 21)+"~", -30, 120),
+	} {
+		if !bytes.Contains(doc, []byte(want)) {
+			t.Errorf("statement does not contain row %q", want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// UserFind возвращает пользователя по логину или nil
+func (pgs *PostgreSQLStorage) UserFind(login string) (*model.Account, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"login": login,
+	}
+	var id, name, role sql.NullString
+	err := pgs.connection.QueryRowContext(ctx, userFindQuery, args).Scan(&id, &name, &role)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error finding user [%v]", login)
+		return nil, fmt.Errorf("error finding user [%v], query '%s' error: %w", login, userFindQuery, err)
+	}
+	if !id.Valid {
+		return nil, nil
+	}
+	return &model.Account{ID: id.String, Login: name.String, Role: role.String}, nil
+}
+
+// UsersSearch ищет пользователей по части логина
+func (pgs *PostgreSQLStorage) UsersSearch(query string, limit int, offset int) (*[]model.Account, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"query":  query,
+		"limit":  limit,
+		"offset": offset,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, usersSearchQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to search users, query: '%s' error: %v", usersSearchQuery, err)
+		return nil, fmt.Errorf("error trying to search users, query: '%s' error: %w", usersSearchQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.Account)
+	for rows.Next() {
+		u := model.Account{}
+		err = rows.Scan(&u.ID, &u.Login, &u.Role)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*list = append(*list, u)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// UserRoleSet назначает роль, false - пользователь не найден
+func (pgs *PostgreSQLStorage) UserRoleSet(login string, role string) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"login": login,
+		"role":  role,
+	}
+	var done sql.NullBool
+	if err := pgs.connection.QueryRowContext(ctx, userRoleSetQuery, args).Scan(&done); err != nil {
+		pgs.log.Err(err).Msgf("Error setting role for user [%v]", login)
+		return false, fmt.Errorf("error setting role for user [%v], query '%s' error: %w", login, userRoleSetQuery, err)
+	}
+	return done.Bool, nil
+}
+
+// OrderGet возвращает заказ по номеру или nil
+func (pgs *PostgreSQLStorage) OrderGet(num int64) (*model.Order, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"num": num,
+	}
+	var userID sql.NullString
+	var o dbOrder
+	err := pgs.connection.QueryRowContext(ctx, orderGetQuery, args).Scan(&userID, &o.Num, &o.Status, &o.Accrural, &o.Ins)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error getting order [%v]", num)
+		return nil, fmt.Errorf("error getting order [%v], query '%s' error: %w", num, orderGetQuery, err)
+	}
+	order := &model.Order{
+		UserID: userID.String,
+		Num:    &o.Num.Int64,
+		Status: o.Status.String,
+		Ins:    o.Ins.Time,
+	}
+	if o.Accrural.Valid {
+		order.Accrural = &o.Accrural.Int64
+	}
+	return order, nil
+}
@@ -0,0 +1,93 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+func campaignArgs(c *model.Campaign) pgx.NamedArgs {
+	return pgx.NamedArgs{
+		"id":        c.ID,
+		"name":      c.Name,
+		"kind":      c.Kind,
+		"value":     c.Value,
+		"condition": c.Condition,
+		"min":       c.MinAccrual,
+		"starts":    c.Starts,
+		"ends":      c.Ends,
+		"active":    *c.Active,
+	}
+}
+
+// CampaignAdd создает акцию и заполняет ее id
+func (pgs *PostgreSQLStorage) CampaignAdd(c *model.Campaign) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	err := pgs.connection.QueryRowContext(ctx, campaignAddQuery, campaignArgs(c)).Scan(&c.ID, &c.Ins)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error adding campaign [%v]", c.Name)
+		return fmt.Errorf("error adding campaign [%v], query '%s' error: %w", c.Name, campaignAddQuery, err)
+	}
+	return nil
+}
+
+// CampaignUpdate заменяет условия акции c.ID, false - акция не найдена
+func (pgs *PostgreSQLStorage) CampaignUpdate(c *model.Campaign) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	err := pgs.connection.QueryRowContext(ctx, campaignUpdateQuery, campaignArgs(c)).Scan(&c.Ins)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error updating campaign [%v]", c.ID)
+		return false, fmt.Errorf("error updating campaign [%v], query '%s' error: %w", c.ID, campaignUpdateQuery, err)
+	}
+	return true, nil
+}
+
+// Campaigns все акции, новые первыми
+func (pgs *PostgreSQLStorage) Campaigns() (*[]model.Campaign, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	rows, err := pgs.connection.QueryContext(ctx, campaignsAllQuery)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get campaigns, query: '%s' error: %v", campaignsAllQuery, err)
+		return nil, fmt.Errorf("error trying to get campaigns, query: '%s' error: %w", campaignsAllQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.Campaign)
+	for rows.Next() {
+		c := model.Campaign{Active: new(bool)}
+		err = rows.Scan(&c.ID, &c.Name, &c.Kind, &c.Value, &c.Condition, &c.MinAccrual, &c.Starts, &c.Ends, c.Active, &c.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*list = append(*list, c)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CampaignDelete удаляет акцию, начисленные ею бонусы остаются; false - акция не найдена
+func (pgs *PostgreSQLStorage) CampaignDelete(id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	var found sql.NullBool
+	err := pgs.connection.QueryRowContext(ctx, campaignDeleteQuery, pgx.NamedArgs{"id": id}).Scan(&found)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error deleting campaign [%v]", id)
+		return false, fmt.Errorf("error deleting campaign [%v], query '%s' error: %w", id, campaignDeleteQuery, err)
+	}
+	return found.Bool, nil
+}
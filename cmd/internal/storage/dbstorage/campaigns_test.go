@@ -0,0 +1,69 @@
+package dbstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// campaignMin порог начисления тестовых акций: начисления других тестов меньше, и действующие акции
+// не добавляют им бонусов, даже если тесты идут одновременно
+const campaignMin = 50000
+
+// testCampaign создает акцию на campaignMin и больше, действующую с starts по ends, и удаляет ее после теста
+func testCampaign(t *testing.T, s *PostgreSQLStorage, kind string, value float64, condition string, starts, ends time.Time, active bool) {
+	t.Helper()
+	c := &model.Campaign{Name: "test", Kind: kind, Value: value, Condition: condition, MinAccrual: campaignMin,
+		Starts: starts, Ends: ends, Active: &active}
+	if err := s.CampaignAdd(c); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := s.CampaignDelete(c.ID); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// TestCampaignsApply бонусы множителя и фиксированной суммы, условие первого заказа, порог начисления
+// и окно действия акции; повторное применение к заказу ничего не добавляет
+func TestCampaignsApply(t *testing.T) {
+	s := testStorage(t)
+	// окно с запасом в сутки: время акции передается без часового пояса
+	now := time.Now()
+	past, future := now.Add(-48*time.Hour), now.Add(48*time.Hour)
+	testCampaign(t, s, model.CampaignMultiplier, 1.5, model.CampaignAny, past, future, true)
+	testCampaign(t, s, model.CampaignFixed, 200, model.CampaignFirstOrder, past, future, true)
+	testCampaign(t, s, model.CampaignFixed, 1000, model.CampaignAny, future, future.Add(time.Hour), true)
+	testCampaign(t, s, model.CampaignFixed, 1000, model.CampaignAny, past.Add(-time.Hour), past, true)
+	testCampaign(t, s, model.CampaignFixed, 1000, model.CampaignAny, past, future, false)
+
+	for _, tc := range []struct {
+		name     string
+		accruals []int64 //начисления заказов по порядку обработки
+		balance  int64
+	}{
+		// первый заказ: половина начисления и 200 за первый заказ
+		{"first order", []int64{60000}, 60000 + 30000 + 200},
+		{"next orders", []int64{60000, 80000}, 60000 + 30000 + 200 + 80000 + 40000},
+		{"below the minimum", []int64{100}, 100},
+		// первым считается первый обработанный заказ, даже если он не прошел порог
+		{"first order below the minimum", []int64{100, 60000}, 100 + 60000 + 30000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			user := testUser(t, s, "campaign")
+			var num int64
+			for _, accrual := range tc.accruals {
+				num = testAccrual(t, s, user, accrual)
+			}
+			if balance := testBalance(t, s, user); balance != tc.balance {
+				t.Errorf("balance %d, want %d", balance, tc.balance)
+			}
+			testExec(t, s, "select campaigns_apply($1, $2, $3)", user.ID, num, tc.accruals[len(tc.accruals)-1])
+			if balance := testBalance(t, s, user); balance != tc.balance {
+				t.Errorf("balance after second apply %d, want %d", balance, tc.balance)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// HoldAdd резервирует баллы на ttl по правилам списаний rules.
+// Возвращает model.HoldOK или причину отказа и остаток допустимой суммы
+func (pgs *PostgreSQLStorage) HoldAdd(hold *model.Hold, ttl time.Duration, rules *model.WithdrawRules) (string, int64, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":     hold.UserID,
+		"num":    hold.Num,
+		"amount": hold.Amount,
+		"ttl":    ttl.Seconds(),
+	}
+	ruleArgs(args, rules)
+	var id sql.NullString
+	var expires sql.NullTime
+	var remaining sql.NullInt64
+	var result sql.NullString
+	if err := pgs.connection.QueryRowContext(ctx, holdAddQuery, args).Scan(&id, &expires, &remaining, &result); err != nil {
+		pgs.log.Err(err).Msgf("Error adding hold for user id [%v]", hold.UserID)
+		return "", 0, fmt.Errorf("error adding hold for user id [%v], query '%s' error: %w", hold.UserID, holdAddQuery, err)
+	}
+	hold.ID = id.String
+	hold.Expires = expires.Time
+	hold.Status = model.HoldActive
+	return result.String, remaining.Int64, nil
+}
+
+// HoldClose списывает (capture) или отменяет резерв пользователя; при списании уведомляет как об обычном списании
+func (pgs *PostgreSQLStorage) HoldClose(hold *model.Hold, capture bool) (string, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+
+	tx, err := pgs.connection.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		rberr := tx.Rollback()
+		if rberr != nil {
+			pgs.log.Printf("failed to rollback transaction err: %v", rberr)
+		}
+	}()
+	args := pgx.NamedArgs{
+		"id":      hold.UserID,
+		"hold":    hold.ID,
+		"capture": capture,
+	}
+	var num, amount sql.NullInt64
+	var status, result sql.NullString
+	err = tx.QueryRowContext(ctx, holdCloseQuery, args).Scan(&num, &amount, &status, &result)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error closing hold [%v] for user id [%v]", hold.ID, hold.UserID)
+		return "", fmt.Errorf("error closing hold [%v] for user id [%v], query '%s' error: %w", hold.ID, hold.UserID, holdCloseQuery, err)
+	}
+	hold.Num = num.Int64
+	hold.Amount = amount.Int64
+	hold.Status = status.String
+	if result.String == model.HoldOK && capture {
+		withdraw := &model.Withdraw{UserID: hold.UserID, Num: &hold.Num, Expence: &hold.Amount, Ins: time.Now()}
+		if err = pgs.outboxAdd(ctx, tx, hold.UserID, model.EventWithdrawalCreated, withdraw); err != nil {
+			return "", err
+		}
+	}
+	// статус EXPIRED, выставленный при проверке, тоже сохраняем
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to execute transaction %w", err)
+	}
+	return result.String, nil
+}
+
+// Holds возвращает действующие резервы пользователя, старые первыми
+func (pgs *PostgreSQLStorage) Holds(user *model.User) (*[]model.Hold, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id": user.ID,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, holdsActiveQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get holds, query: '%s' error: %v", holdsActiveQuery, err)
+		return nil, fmt.Errorf("error trying to get holds, query: '%s' error: %w", holdsActiveQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.Hold)
+	for rows.Next() {
+		h := model.Hold{Status: model.HoldActive}
+		err = rows.Scan(&h.ID, &h.Num, &h.Amount, &h.Expires, &h.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*list = append(*list, h)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// HoldsExpire закрывает истекшие резервы, возвращает их число
+func (pgs *PostgreSQLStorage) HoldsExpire() (int64, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	var n sql.NullInt64
+	if err := pgs.connection.QueryRowContext(ctx, holdsExpireQuery).Scan(&n); err != nil {
+		return 0, fmt.Errorf("error expiring holds, query '%s' error: %w", holdsExpireQuery, err)
+	}
+	return n.Int64, nil
+}
@@ -0,0 +1,155 @@
+package dbstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+func testHold(t *testing.T, s *PostgreSQLStorage, user *model.User, amount int64) *model.Hold {
+	t.Helper()
+	hold := &model.Hold{UserID: user.ID, Num: testNum(), Amount: amount}
+	if result, _, err := s.HoldAdd(hold, time.Hour, nil); err != nil || result != model.HoldOK {
+		t.Fatalf("hold %d: %s, %v", amount, result, err)
+	}
+	return hold
+}
+
+func testHeld(t *testing.T, s *PostgreSQLStorage, user *model.User) (int64, int64) {
+	t.Helper()
+	b, err := s.Balance(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *b.Current, *b.Held
+}
+
+// TestHoldCaptureVoid резерв уменьшает доступный остаток, списание превращает его в обычное списание,
+// отмена возвращает баллы; закрыть резерв можно один раз и только владельцу
+func TestHoldCaptureVoid(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "hold")
+	testAccrual(t, s, user, 1000)
+
+	captured := testHold(t, s, user, 300)
+	if current, held := testHeld(t, s, user); current != 700 || held != 300 {
+		t.Fatalf("after hold: current %d, held %d; want 700, 300", current, held)
+	}
+	again := &model.Hold{UserID: user.ID, Num: captured.Num, Amount: 100}
+	if result, _, err := s.HoldAdd(again, time.Hour, nil); err != nil || result != model.HoldDuplicate {
+		t.Errorf("second hold on the same order: %s, %v; want %s", result, err, model.HoldDuplicate)
+	}
+	if holds, err := s.Holds(user); err != nil || len(*holds) != 1 || (*holds)[0].ID != captured.ID {
+		t.Errorf("active holds %v, %v; want the one hold", holds, err)
+	}
+
+	other := testUser(t, s, "hold-other")
+	if result, err := s.HoldClose(&model.Hold{UserID: other.ID, ID: captured.ID}, true); err != nil || result != model.HoldNotFound {
+		t.Errorf("capture by another user: %s, %v; want %s", result, err, model.HoldNotFound)
+	}
+	if result, err := s.HoldClose(&model.Hold{UserID: user.ID, ID: captured.ID}, true); err != nil || result != model.HoldOK {
+		t.Fatalf("capture: %s, %v", result, err)
+	}
+	if current, held := testHeld(t, s, user); current != 700 || held != 0 {
+		t.Errorf("after capture: current %d, held %d; want 700, 0", current, held)
+	}
+	withdrawals, err := s.Withdrawals(user)
+	if err != nil || len(*withdrawals) != 1 || *(*withdrawals)[0].Num != captured.Num || *(*withdrawals)[0].Expence != 300 {
+		t.Errorf("withdrawals after capture %v, %v; want order %d for 300", withdrawals, err, captured.Num)
+	}
+	closed := &model.Hold{UserID: user.ID, ID: captured.ID}
+	if result, err := s.HoldClose(closed, false); err != nil || result != model.HoldNotActive || closed.Status != model.HoldCaptured {
+		t.Errorf("void after capture: %s, status %s, %v; want %s, %s", result, closed.Status, err, model.HoldNotActive, model.HoldCaptured)
+	}
+	if result, _, err := s.HoldAdd(&model.Hold{UserID: user.ID, Num: captured.Num, Amount: 100}, time.Hour, nil); err != nil || result != model.HoldDuplicate {
+		t.Errorf("hold on a withdrawn order: %s, %v; want %s", result, err, model.HoldDuplicate)
+	}
+
+	voided := testHold(t, s, user, 200)
+	if result, err := s.HoldClose(&model.Hold{UserID: user.ID, ID: voided.ID}, false); err != nil || result != model.HoldOK {
+		t.Fatalf("void: %s, %v", result, err)
+	}
+	if current, held := testHeld(t, s, user); current != 700 || held != 0 {
+		t.Errorf("after void: current %d, held %d; want 700, 0", current, held)
+	}
+	if result, err := s.HoldClose(&model.Hold{UserID: user.ID, ID: voided.ID}, true); err != nil || result != model.HoldNotActive {
+		t.Errorf("capture after void: %s, %v; want %s", result, err, model.HoldNotActive)
+	}
+}
+
+// TestHoldsExpire истекший резерв сразу перестает держать баллы, не списывается,
+// закрывается обходом и не мешает новому резерву под тот же заказ
+func TestHoldsExpire(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "hold-expire")
+	testAccrual(t, s, user, 1000)
+
+	late := testHold(t, s, user, 400)
+	swept := testHold(t, s, user, 100)
+	testExec(t, s, "update holds set expires_at = now() - interval '1 second' where id in ($1, $2)", late.ID, swept.ID)
+	if current, held := testHeld(t, s, user); current != 1000 || held != 0 {
+		t.Errorf("after expiry: current %d, held %d; want 1000, 0", current, held)
+	}
+	closed := &model.Hold{UserID: user.ID, ID: late.ID}
+	if result, err := s.HoldClose(closed, true); err != nil || result != model.HoldNotActive || closed.Status != model.HoldExpired {
+		t.Errorf("capture of an expired hold: %s, status %s, %v; want %s, %s", result, closed.Status, err, model.HoldNotActive, model.HoldExpired)
+	}
+
+	if n, err := s.HoldsExpire(); err != nil || n < 1 {
+		t.Fatalf("sweeper expired %d holds, %v; want at least 1", n, err)
+	}
+	var status string
+	if err := s.connection.QueryRow("select status from holds where id = $1", swept.ID).Scan(&status); err != nil || status != model.HoldExpired {
+		t.Errorf("swept hold status %s, %v; want %s", status, err, model.HoldExpired)
+	}
+	if n, err := s.HoldsExpire(); err != nil {
+		t.Fatal(err)
+	} else if err = s.connection.QueryRow("select status from holds where id = $1", swept.ID).Scan(&status); err != nil || status != model.HoldExpired {
+		t.Errorf("second sweep (%d holds) changed the hold: %s, %v", n, status, err)
+	}
+
+	renewed := &model.Hold{UserID: user.ID, Num: swept.Num, Amount: 100}
+	if result, _, err := s.HoldAdd(renewed, time.Hour, nil); err != nil || result != model.HoldOK {
+		t.Errorf("new hold on the order of an expired one: %s, %v", result, err)
+	}
+}
+
+// TestWholeBalance списание, резерв и перевод одинаково принимают сумму, равную остатку, и отказывают в большей
+func TestWholeBalance(t *testing.T) {
+	s := testStorage(t)
+	recipient := testUser(t, s, "whole-to")
+	for _, tc := range []struct {
+		name  string
+		spend func(user *model.User, amount int64) (string, error)
+		ok    string
+		short string
+	}{
+		{"withdraw", func(user *model.User, amount int64) (string, error) {
+			result, _, err := s.Withdraw(testWithdraw(user, amount), nil)
+			return result, err
+		}, model.WithdrawOK, model.WithdrawInsufficient},
+		{"hold", func(user *model.User, amount int64) (string, error) {
+			result, _, err := s.HoldAdd(&model.Hold{UserID: user.ID, Num: testNum(), Amount: amount}, time.Hour, nil)
+			return result, err
+		}, model.HoldOK, model.WithdrawInsufficient},
+		{"transfer", func(user *model.User, amount int64) (string, error) {
+			result, _, err := s.TransferAdd(&model.Transfer{FromID: user.ID, To: recipient.Login, Amount: &amount}, 0, 0)
+			return result, err
+		}, model.TransferOK, model.TransferInsufficient},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			user := testUser(t, s, "whole")
+			testAccrual(t, s, user, 500)
+			if result, err := tc.spend(user, 501); err != nil || result != tc.short {
+				t.Errorf("more than the balance: %s, %v; want %s", result, err, tc.short)
+			}
+			if result, err := tc.spend(user, 500); err != nil || result != tc.ok {
+				t.Errorf("the whole balance: %s, %v; want %s", result, err, tc.ok)
+			}
+			if balance := testBalance(t, s, user); balance != 0 {
+				t.Errorf("balance %d, want 0", balance)
+			}
+		})
+	}
+}
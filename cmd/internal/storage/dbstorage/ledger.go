@@ -0,0 +1,230 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// AdjustmentAdd проводит корректировку баланса и пишет ее в журнал аудита, возвращает model.AdjustmentOK или причину отказа
+func (pgs *PostgreSQLStorage) AdjustmentAdd(adj *model.Adjustment) (string, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":        adj.UserID,
+		"actor":     adj.ActorID,
+		"amount":    adj.Amount,
+		"reason":    adj.Reason,
+		"reference": adj.Reference,
+	}
+	var id sql.NullInt64
+	var result sql.NullString
+	if err := pgs.connection.QueryRowContext(ctx, adjustmentAddQuery, args).Scan(&id, &result); err != nil {
+		pgs.log.Err(err).Msgf("Error adjusting balance for user id [%v]", adj.UserID)
+		return "", fmt.Errorf("error adjusting balance for user id [%v], query '%s' error: %w", adj.UserID, adjustmentAddQuery, err)
+	}
+	adj.ID = id.Int64
+	return result.String, nil
+}
+
+// History возвращает движения баллов пользователя, старые первыми
+func (pgs *PostgreSQLStorage) History(user *model.User) (*[]model.HistoryEntry, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id": user.ID,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, historyQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get history, query: '%s' error: %v", historyQuery, err)
+		return nil, fmt.Errorf("error trying to get history, query: '%s' error: %w", historyQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.HistoryEntry)
+	for rows.Next() {
+		var comment sql.NullString
+		e := model.HistoryEntry{}
+		err = rows.Scan(&e.Kind, &e.Reference, &e.Amount, &comment, &e.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		e.Comment = comment.String
+		*list = append(*list, e)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Statement передает строки выписки за период [from, to) в row по мере чтения из базы, не накапливая их.
+// Нулевой from - с начала истории; ошибка row прерывает чтение
+func (pgs *PostgreSQLStorage) Statement(user *model.User, from time.Time, to time.Time, row func(*model.StatementRow) error) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Minute*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":   user.ID,
+		"from": sql.NullTime{Time: from, Valid: !from.IsZero()},
+		"to":   to,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, statementQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get statement, query: '%s' error: %v", statementQuery, err)
+		return fmt.Errorf("error trying to get statement, query: '%s' error: %w", statementQuery, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status, comment sql.NullString
+		r := model.StatementRow{}
+		err = rows.Scan(&r.Date, &r.Kind, &r.Reference, &status, &r.Amount, &r.Balance, &comment)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		r.Status, r.Comment = status.String, comment.String
+		if err = row(&r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// WithdrawalReverse возвращает баллы списания и уведомляет пользователя, возвращает model.ReversalOK или причину отказа
+func (pgs *PostgreSQLStorage) WithdrawalReverse(rev *model.Reversal) (string, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+
+	tx, err := pgs.connection.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		rberr := tx.Rollback()
+		if rberr != nil {
+			pgs.log.Printf("failed to rollback transaction err: %v", rberr)
+		}
+	}()
+	var login sql.NullString
+	if rev.Login != "" {
+		login = sql.NullString{String: rev.Login, Valid: true}
+	}
+	args := pgx.NamedArgs{
+		"num":    rev.Num,
+		"login":  login,
+		"actor":  rev.ActorID,
+		"amount": rev.Amount,
+		"reason": rev.Reason,
+	}
+	var userID, result sql.NullString
+	var amount, remaining sql.NullInt64
+	err = tx.QueryRowContext(ctx, reverseQuery, args).Scan(&userID, &amount, &remaining, &result)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error reversing withdrawal for order [%v]", rev.Num)
+		return "", fmt.Errorf("error reversing withdrawal for order [%v], query '%s' error: %w", rev.Num, reverseQuery, err)
+	}
+	rev.UserID = userID.String
+	rev.Remaining = remaining.Int64
+	if result.String != model.ReversalOK {
+		return result.String, nil
+	}
+	rev.Amount = &amount.Int64
+	rev.Ins = time.Now()
+	// получателю webhook отдаем возврат в виде строки списка списаний
+	refund := -amount.Int64
+	event := &model.Withdraw{Num: &rev.Num, Expence: &refund, Ins: rev.Ins, Status: model.WithdrawReversal}
+	if err = pgs.outboxAdd(ctx, tx, rev.UserID, model.EventWithdrawalReversed, event); err != nil {
+		return "", err
+	}
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to execute transaction %w", err)
+	}
+	return result.String, nil
+}
+
+// PointsExpiring сумма непогашенных баллов, которые сгорят в течение within
+func (pgs *PostgreSQLStorage) PointsExpiring(user *model.User, months int, within time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":     user.ID,
+		"months": months,
+		"within": within.Seconds(),
+	}
+	var n sql.NullInt64
+	if err := pgs.connection.QueryRowContext(ctx, pointsExpiringQuery, args).Scan(&n); err != nil {
+		pgs.log.Err(err).Msgf("Error getting expiring points for user id [%v]", user.ID)
+		return 0, fmt.Errorf("error getting expiring points for user id [%v], query '%s' error: %w", user.ID, pointsExpiringQuery, err)
+	}
+	return n.Int64, nil
+}
+
+// PointsExpire списывает сгоревшие баллы, возвращает число затронутых пользователей.
+// Каждый пользователь списывается отдельным запросом, т.е. в своей транзакции: блокировки не копятся
+// на весь проход, а сбой не откатывает уже списанное у других
+func (pgs *PostgreSQLStorage) PointsExpire(months int) (int64, error) {
+	var n int64
+	after := sql.NullString{}
+	for {
+		users, err := pgs.pointsExpireDue(months, after)
+		if err != nil {
+			return n, err
+		}
+		for _, id := range users {
+			expired, err := pgs.pointsExpireUser(id, months)
+			if err != nil {
+				return n, err
+			}
+			if expired > 0 {
+				n++
+			}
+		}
+		if len(users) < pointsExpireBatch {
+			return n, nil
+		}
+		after = sql.NullString{String: users[len(users)-1], Valid: true}
+	}
+}
+
+func (pgs *PostgreSQLStorage) pointsExpireDue(months int, after sql.NullString) ([]string, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*30)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"months": months,
+		"after":  after,
+		"limit":  pointsExpireBatch,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, pointsDueQuery, args)
+	if err != nil {
+		return nil, fmt.Errorf("error getting users with expired points, query '%s' error: %w", pointsDueQuery, err)
+	}
+	defer rows.Close()
+	var users []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		users = append(users, id)
+	}
+	return users, rows.Err()
+}
+
+func (pgs *PostgreSQLStorage) pointsExpireUser(userID string, months int) (int64, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":     userID,
+		"months": months,
+	}
+	var expired sql.NullInt64
+	if err := pgs.connection.QueryRowContext(ctx, pointsExpireQuery, args).Scan(&expired); err != nil {
+		return 0, fmt.Errorf("error expiring points for user id [%v], query '%s' error: %w", userID, pointsExpireQuery, err)
+	}
+	return expired.Int64, nil
+}
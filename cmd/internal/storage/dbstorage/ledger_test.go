@@ -0,0 +1,207 @@
+package dbstorage
+
+import (
+	"testing"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// TestPointsExpire списания гасят старые партии первыми, сгорает только непогашенный остаток просроченных,
+// повторный запуск ничего не списывает
+func TestPointsExpire(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "expire")
+	for _, lot := range []struct {
+		accrual int64
+		days    int
+	}{
+		{100, 90},
+		{50, 60},
+		{30, 0},
+	} {
+		num := testAccrual(t, s, user, lot.accrual)
+		testExec(t, s, "update orders set date_processed = now() - make_interval(days => $2) where num = $1", num, lot.days)
+	}
+	if result, _, err := s.Withdraw(testWithdraw(user, 120), nil); err != nil || result != model.WithdrawOK {
+		t.Fatalf("withdraw: %s, %v", result, err)
+	}
+
+	// 120 гасит партию в 100 и 20 из 50: сгорают оставшиеся 30, свежая партия не трогается
+	if expiring, err := s.PointsExpiring(user, 1, 0); err != nil || expiring != 30 {
+		t.Fatalf("expiring %d, %v; want 30", expiring, err)
+	}
+	if _, err := s.PointsExpire(1); err != nil {
+		t.Fatal(err)
+	}
+	if balance := testBalance(t, s, user); balance != 30 {
+		t.Errorf("balance after expiration %d, want 30", balance)
+	}
+	if expiring, err := s.PointsExpiring(user, 1, 0); err != nil || expiring != 0 {
+		t.Errorf("expiring after expiration %d, %v; want 0", expiring, err)
+	}
+	if _, err := s.PointsExpire(1); err != nil {
+		t.Fatal(err)
+	}
+	if balance := testBalance(t, s, user); balance != 30 {
+		t.Errorf("balance after second run %d, want 30", balance)
+	}
+}
+
+// TestPointsExpireProcessedLate начисление стареет со времени обработки, а не загрузки заказа
+func TestPointsExpireProcessedLate(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "expire-late")
+	num := testAccrual(t, s, user, 100)
+	testExec(t, s, "update orders set date_ins = now() - interval '90 days' where num = $1", num)
+
+	if expiring, err := s.PointsExpiring(user, 1, 0); err != nil || expiring != 0 {
+		t.Fatalf("expiring %d, %v; want 0", expiring, err)
+	}
+	if _, err := s.PointsExpire(1); err != nil {
+		t.Fatal(err)
+	}
+	if balance := testBalance(t, s, user); balance != 100 {
+		t.Errorf("balance %d, want 100", balance)
+	}
+}
+
+// TestWithdrawalReverse частичный и полный возврат списания, отказы EXCEEDS и AMBIGUOUS; возвраты попадают в баланс
+// и в список списаний строками REVERSAL
+func TestWithdrawalReverse(t *testing.T) {
+	s := testStorage(t)
+	admin := testUser(t, s, "reverse-admin")
+	user := testUser(t, s, "reverse")
+	testAccrual(t, s, user, 1000)
+	withdraw := testWithdraw(user, 400)
+	if result, _, err := s.Withdraw(withdraw, nil); err != nil || result != model.WithdrawOK {
+		t.Fatalf("withdraw: %s, %v", result, err)
+	}
+	num := *withdraw.Num
+	reverse := func(login string, amount *int64) (*model.Reversal, string) {
+		t.Helper()
+		rev := &model.Reversal{Num: num, Login: login, ActorID: admin.ID, Amount: amount, Reason: "refund"}
+		result, err := s.WithdrawalReverse(rev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rev, result
+	}
+	balance := func(current, withdrawn int64) {
+		t.Helper()
+		b, err := s.Balance(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *b.Current != current || *b.Expence != withdrawn {
+			t.Errorf("balance %d, withdrawn %d; want %d, %d", *b.Current, *b.Expence, current, withdrawn)
+		}
+	}
+
+	if _, result := reverse("", testInt(0)); result != model.ReversalExceeds {
+		t.Errorf("zero amount: %s, want %s", result, model.ReversalExceeds)
+	}
+	if rev, result := reverse("", testInt(500)); result != model.ReversalExceeds || rev.Remaining != 400 {
+		t.Errorf("more than withdrawn: %s, remaining %d; want %s, 400", result, rev.Remaining, model.ReversalExceeds)
+	}
+	if rev, result := reverse("", testInt(150)); result != model.ReversalOK || rev.Remaining != 250 || rev.UserID != user.ID {
+		t.Fatalf("partial: %s, remaining %d, user %s; want %s, 250, %s", result, rev.Remaining, rev.UserID, model.ReversalOK, user.ID)
+	}
+	balance(750, 250)
+	if rev, result := reverse("", testInt(300)); result != model.ReversalExceeds || rev.Remaining != 250 {
+		t.Errorf("more than the rest: %s, remaining %d; want %s, 250", result, rev.Remaining, model.ReversalExceeds)
+	}
+
+	// тот же номер списан другим пользователем: без логина возврат неоднозначен
+	other := testUser(t, s, "reverse-other")
+	testAccrual(t, s, other, 100)
+	if result, _, err := s.Withdraw(&model.Withdraw{UserID: other.ID, Num: &num, Expence: testInt(50)}, nil); err != nil || result != model.WithdrawOK {
+		t.Fatalf("withdraw of the same order by another user: %s, %v", result, err)
+	}
+	if _, result := reverse("", nil); result != model.ReversalAmbiguous {
+		t.Errorf("without login: %s, want %s", result, model.ReversalAmbiguous)
+	}
+	if _, result := reverse("reverse-nobody", nil); result != model.ReversalNotFound {
+		t.Errorf("unknown login: %s, want %s", result, model.ReversalNotFound)
+	}
+	rev, result := reverse(user.Login, nil)
+	if result != model.ReversalOK || *rev.Amount != 250 || rev.Remaining != 0 {
+		t.Fatalf("the rest: %s, amount %d, remaining %d; want %s, 250, 0", result, *rev.Amount, rev.Remaining, model.ReversalOK)
+	}
+	balance(1000, 0)
+	if _, result = reverse(user.Login, nil); result != model.ReversalExceeds {
+		t.Errorf("fully reversed: %s, want %s", result, model.ReversalExceeds)
+	}
+	if b := testBalance(t, s, other); b != 50 {
+		t.Errorf("other user balance %d, want 50", b)
+	}
+
+	withdrawals, err := s.Withdrawals(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		sum    int64
+		status string
+	}{{400, model.WithdrawReversed}, {-150, model.WithdrawReversal}, {-250, model.WithdrawReversal}}
+	if len(*withdrawals) != len(want) {
+		t.Fatalf("withdrawals %d, want %d", len(*withdrawals), len(want))
+	}
+	for i, w := range *withdrawals {
+		if *w.Num != num || *w.Expence != want[i].sum || w.Status != want[i].status {
+			t.Errorf("withdrawal %d: order %d, sum %d, status %s; want %d, %d, %s", i, *w.Num, *w.Expence, w.Status, num, want[i].sum, want[i].status)
+		}
+	}
+	var audited int
+	if err = s.connection.QueryRow("select count(*) from audit_log where action = 'withdrawal.reverse' and actor_id = $1 and user_id = $2",
+		admin.ID, user.ID).Scan(&audited); err != nil || audited != 2 {
+		t.Errorf("audit rows %d, %v; want 2", audited, err)
+	}
+}
+
+// TestAdjustmentAdd корректировка меняет баланс и пишет аудит; повтор ссылки и списание больше баланса отклоняются
+// без записей, списание до нуля допустимо
+func TestAdjustmentAdd(t *testing.T) {
+	s := testStorage(t)
+	admin := testUser(t, s, "adjust-admin")
+	user := testUser(t, s, "adjust")
+	testAccrual(t, s, user, 100)
+	adjust := func(amount int64, reference string) (*model.Adjustment, string) {
+		t.Helper()
+		adj := &model.Adjustment{UserID: user.ID, ActorID: admin.ID, Amount: &amount, Reason: "support", Reference: reference}
+		result, err := s.AdjustmentAdd(adj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return adj, result
+	}
+
+	credit, result := adjust(50, "ticket-1")
+	if result != model.AdjustmentOK || credit.ID == 0 {
+		t.Fatalf("credit: %s, id %d", result, credit.ID)
+	}
+	if _, result = adjust(70, "ticket-1"); result != model.AdjustmentDuplicate {
+		t.Errorf("same reference: %s, want %s", result, model.AdjustmentDuplicate)
+	}
+	if _, result = adjust(-151, "ticket-2"); result != model.AdjustmentInsufficient {
+		t.Errorf("debit over the balance: %s, want %s", result, model.AdjustmentInsufficient)
+	}
+	if balance := testBalance(t, s, user); balance != 150 {
+		t.Fatalf("balance after refusals %d, want 150", balance)
+	}
+	if _, result = adjust(-150, "ticket-2"); result != model.AdjustmentOK {
+		t.Errorf("debit of the whole balance: %s, want %s", result, model.AdjustmentOK)
+	}
+	if balance := testBalance(t, s, user); balance != 0 {
+		t.Errorf("balance %d, want 0", balance)
+	}
+
+	var n int
+	var amount int64
+	var reference string
+	err := s.connection.QueryRow(`select count(*) over (), (payload ->> 'amount')::bigint, payload ->> 'reference'
+		from audit_log where action = 'balance.adjust' and actor_id = $1 and user_id = $2 order by id limit 1`,
+		admin.ID, user.ID).Scan(&n, &amount, &reference)
+	if err != nil || n != 2 || amount != 50 || reference != "ticket-1" {
+		t.Errorf("audit: %d rows, first %d for %s, %v; want 2 rows, first 50 for ticket-1", n, amount, reference, err)
+	}
+}
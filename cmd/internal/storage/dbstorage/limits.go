@@ -0,0 +1,76 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// ruleArgs добавляет общие правила списаний в параметры запроса, неуказанное правило отключено
+func ruleArgs(args pgx.NamedArgs, rules *model.WithdrawRules) {
+	value := func(v *int64) int64 {
+		if v == nil {
+			return 0
+		}
+		return *v
+	}
+	if rules == nil {
+		rules = &model.WithdrawRules{}
+	}
+	args["min"] = value(rules.Min)
+	args["max"] = value(rules.MaxTx)
+	args["daily"] = value(rules.Daily)
+	args["monthly"] = value(rules.Monthly)
+	args["cooldown"] = value(rules.Cooldown)
+}
+
+// WithdrawLimits индивидуальные правила списаний пользователя, без них - все поля nil
+func (pgs *PostgreSQLStorage) WithdrawLimits(userID string) (*model.WithdrawRules, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id": userID,
+	}
+	var minAmount, maxTx, daily, monthly, cooldown sql.NullInt64
+	err := pgs.connection.QueryRowContext(ctx, limitsGetQuery, args).Scan(&minAmount, &maxTx, &daily, &monthly, &cooldown)
+	if err != nil && err != sql.ErrNoRows {
+		pgs.log.Err(err).Msgf("Error getting withdraw limits for user id [%v]", userID)
+		return nil, fmt.Errorf("error getting withdraw limits for user id [%v], query '%s' error: %w", userID, limitsGetQuery, err)
+	}
+	ptr := func(v sql.NullInt64) *int64 {
+		if !v.Valid {
+			return nil
+		}
+		return &v.Int64
+	}
+	return &model.WithdrawRules{
+		Min:      ptr(minAmount),
+		MaxTx:    ptr(maxTx),
+		Daily:    ptr(daily),
+		Monthly:  ptr(monthly),
+		Cooldown: ptr(cooldown),
+	}, nil
+}
+
+// WithdrawLimitsSet заменяет индивидуальные правила списаний пользователя
+func (pgs *PostgreSQLStorage) WithdrawLimitsSet(userID string, rules *model.WithdrawRules) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":       userID,
+		"min":      rules.Min,
+		"max":      rules.MaxTx,
+		"daily":    rules.Daily,
+		"monthly":  rules.Monthly,
+		"cooldown": rules.Cooldown,
+	}
+	if _, err := pgs.connection.ExecContext(ctx, limitsSetQuery, args); err != nil {
+		pgs.log.Err(err).Msgf("Error setting withdraw limits for user id [%v]", userID)
+		return fmt.Errorf("error setting withdraw limits for user id [%v], query '%s' error: %w", userID, limitsSetQuery, err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package dbstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+func testInt(v int64) *int64 {
+	return &v
+}
+
+// TestWithdrawRules каждый код отказа списания и допустимая сумма remaining; у пользователя 1000 баллов
+func TestWithdrawRules(t *testing.T) {
+	s := testStorage(t)
+	for _, tc := range []struct {
+		name      string
+		rules     model.WithdrawRules  //общие правила
+		override  *model.WithdrawRules //индивидуальные правила пользователя
+		prior     []int64              //списания до проверки
+		priorAt   string               //время предыдущих списаний, по умолчанию сейчас
+		hold      int64                //действующий резерв до проверки
+		age       string               //возраст регистрации, по умолчанию только что
+		sameNum   bool                 //повтор номера последнего списания
+		amount    int64
+		result    string
+		remaining int64
+	}{
+		{name: "ok", amount: 100, result: model.WithdrawOK},
+		{name: "insufficient", amount: 1500, result: model.WithdrawInsufficient},
+		{name: "duplicate", prior: []int64{100}, sameNum: true, amount: 100, result: model.WithdrawDuplicate},
+		{name: "below min", rules: model.WithdrawRules{Min: testInt(100)}, amount: 50,
+			result: model.WithdrawBelowMin, remaining: 100},
+		{name: "above max", rules: model.WithdrawRules{MaxTx: testInt(200)}, amount: 300,
+			result: model.WithdrawAboveMax, remaining: 200},
+		{name: "rules before balance", rules: model.WithdrawRules{MaxTx: testInt(200)}, amount: 5000,
+			result: model.WithdrawAboveMax, remaining: 200},
+		{name: "daily limit", rules: model.WithdrawRules{Daily: testInt(300)}, prior: []int64{200}, amount: 150,
+			result: model.WithdrawDailyLimit, remaining: 100},
+		{name: "daily limit counts holds", rules: model.WithdrawRules{Daily: testInt(300)}, hold: 250, amount: 100,
+			result: model.WithdrawDailyLimit, remaining: 50},
+		{name: "daily limit spent", rules: model.WithdrawRules{Daily: testInt(300)}, prior: []int64{200, 100}, amount: 1,
+			result: model.WithdrawDailyLimit, remaining: 0},
+		{name: "daily limit ignores yesterday", rules: model.WithdrawRules{Daily: testInt(300)}, prior: []int64{200},
+			priorAt: "now() - interval '1 day'", amount: 150, result: model.WithdrawOK},
+		{name: "monthly limit", rules: model.WithdrawRules{Monthly: testInt(300)}, prior: []int64{200}, amount: 150,
+			result: model.WithdrawMonthlyLimit, remaining: 100},
+		{name: "monthly limit counts the whole month", rules: model.WithdrawRules{Daily: testInt(1000), Monthly: testInt(300)},
+			prior: []int64{200}, priorAt: "date_trunc('month', now())", amount: 150,
+			result: model.WithdrawMonthlyLimit, remaining: 100},
+		{name: "monthly limit ignores last month", rules: model.WithdrawRules{Monthly: testInt(300)}, prior: []int64{200},
+			priorAt: "date_trunc('month', now()) - interval '1 day'", amount: 150, result: model.WithdrawOK},
+		{name: "cooldown", rules: model.WithdrawRules{Cooldown: testInt(3600)}, amount: 100,
+			result: model.WithdrawCooldown, remaining: 3600},
+		{name: "cooldown over", rules: model.WithdrawRules{Cooldown: testInt(3600)}, age: "2 hours", amount: 100,
+			result: model.WithdrawOK},
+		{name: "override loosens", rules: model.WithdrawRules{Min: testInt(100)},
+			override: &model.WithdrawRules{Min: testInt(10)}, amount: 50, result: model.WithdrawOK},
+		{name: "override tightens", override: &model.WithdrawRules{MaxTx: testInt(40)}, amount: 50,
+			result: model.WithdrawAboveMax, remaining: 40},
+		{name: "override disables", rules: model.WithdrawRules{Daily: testInt(100)},
+			override: &model.WithdrawRules{Daily: testInt(0)}, prior: []int64{200}, amount: 150, result: model.WithdrawOK},
+		{name: "override keeps other general rules", rules: model.WithdrawRules{Min: testInt(100)},
+			override: &model.WithdrawRules{Daily: testInt(500)}, amount: 50, result: model.WithdrawBelowMin, remaining: 100},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			user := testUser(t, s, "limits")
+			testAccrual(t, s, user, 1000)
+			if tc.age != "" {
+				testExec(t, s, "update users set date_ins = now() - cast($2 as text)::interval where id = $1", user.ID, tc.age)
+			}
+			var last *model.Withdraw
+			for _, sum := range tc.prior {
+				last = testWithdraw(user, sum)
+				if result, _, err := s.Withdraw(last, nil); err != nil || result != model.WithdrawOK {
+					t.Fatalf("prior withdraw: %s, %v", result, err)
+				}
+				if tc.priorAt != "" {
+					testExec(t, s, "update withdraws set date_ins = "+tc.priorAt+" where user_id = $1 and num = $2", user.ID, *last.Num)
+				}
+			}
+			if tc.hold > 0 {
+				hold := &model.Hold{UserID: user.ID, Num: testNum(), Amount: tc.hold}
+				if result, _, err := s.HoldAdd(hold, time.Hour, nil); err != nil || result != model.HoldOK {
+					t.Fatalf("prior hold: %s, %v", result, err)
+				}
+			}
+			if tc.override != nil {
+				if err := s.WithdrawLimitsSet(user.ID, tc.override); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			request := testWithdraw(user, tc.amount)
+			if tc.sameNum {
+				request.Num = last.Num
+			}
+			result, remaining, err := s.Withdraw(request, &tc.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result != tc.result {
+				t.Fatalf("result %s, want %s", result, tc.result)
+			}
+			// до конца паузы остается чуть меньше часа: секунды идут, пока выполняется тест
+			if tc.result == model.WithdrawCooldown {
+				if remaining <= tc.remaining-60 || remaining > tc.remaining {
+					t.Errorf("cooldown remaining %d, want about %d", remaining, tc.remaining)
+				}
+			} else if remaining != tc.remaining {
+				t.Errorf("remaining %d, want %d", remaining, tc.remaining)
+			}
+
+			want := int64(1000) - tc.hold
+			for _, sum := range tc.prior {
+				want -= sum
+			}
+			if tc.result == model.WithdrawOK {
+				want -= tc.amount
+			}
+			if balance := testBalance(t, s, user); balance != want {
+				t.Errorf("balance %d, want %d", balance, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+func (pgs *PostgreSQLStorage) LoginAttempts(userKind string, login string, ipKind string, ip string) (*[]model.LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"user_kind": userKind,
+		"login":     login,
+		"ip_kind":   ipKind,
+		"ip":        ip,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, attemptsGetQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get login attempts, query: '%s' error: %v", attemptsGetQuery, err)
+		return nil, fmt.Errorf("error trying to get login attempts, query: '%s' error: %w", attemptsGetQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.LoginAttempt)
+	for rows.Next() {
+		var since, locked float64
+		a := model.LoginAttempt{}
+		err = rows.Scan(&a.Kind, &a.Failures, &since, &locked)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		a.SinceFailure = seconds(since)
+		a.LockedFor = seconds(locked)
+		a.Key = ip
+		if a.Kind == userKind {
+			a.Key = login
+		}
+		*list = append(*list, a)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// LoginAttemptFail учитывает неудачную попытку и возвращает состояние счетчика
+func (pgs *PostgreSQLStorage) LoginAttemptFail(kind string, key string, lockAfter int, lock time.Duration, window time.Duration) (*model.LoginAttempt, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"kind":   kind,
+		"key":    key,
+		"after":  lockAfter,
+		"lock":   lock.Seconds(),
+		"window": window.Seconds(),
+	}
+	var since, locked float64
+	a := model.LoginAttempt{Kind: kind, Key: key}
+	err := pgs.connection.QueryRowContext(ctx, attemptFailQuery, args).Scan(&a.Failures, &since, &locked)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error saving failed login attempt [%s:%s]", kind, key)
+		return nil, fmt.Errorf("error saving failed login attempt [%s:%s], query '%s' error: %w", kind, key, attemptFailQuery, err)
+	}
+	a.SinceFailure = seconds(since)
+	a.LockedFor = seconds(locked)
+	return &a, nil
+}
+
+// LoginAttemptReset сбрасывает счетчик, false - счетчика не было
+func (pgs *PostgreSQLStorage) LoginAttemptReset(kind string, key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"kind": kind,
+		"key":  key,
+	}
+	var found sql.NullBool
+	err := pgs.connection.QueryRowContext(ctx, attemptResetQuery, args).Scan(&found)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error resetting login attempts [%s:%s]", kind, key)
+		return false, fmt.Errorf("error resetting login attempts [%s:%s], query '%s' error: %w", kind, key, attemptResetQuery, err)
+	}
+	return found.Bool, nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
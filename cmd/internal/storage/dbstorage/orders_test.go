@@ -0,0 +1,123 @@
+package dbstorage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// TestAccruralUpdateProcessedFinal повторный опрос обработанного заказа после смены уровня
+// не пересчитывает начисление и не меняет статус
+func TestAccruralUpdateProcessedFinal(t *testing.T) {
+	s := testStorage(t)
+	now := time.Now().UnixNano()
+	userID, _, err := s.UserRegister(&model.User{Login: fmt.Sprintf("repoll-%d", now), Hash: "-"}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	num, base := now, int64(100)
+	if _, err = s.OrdersNew(&model.Order{UserID: userID, Num: &num, Status: "NEW"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.AccruralUpdate(&model.Order{Num: &num, Status: "PROCESSED", Accrural: &base}); err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.OrderGet(num)
+	if err != nil || first == nil || first.Accrural == nil {
+		t.Fatalf("processed order not found: %v", err)
+	}
+
+	if _, err = s.connection.Exec("update users set tier = 'platinum' where id = $1", userID); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []string{"PROCESSED", "PROCESSING", "INVALID"} {
+		again := base * 2
+		if err = s.AccruralUpdate(&model.Order{Num: &num, Status: status, Accrural: &again}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.OrderGet(num)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "PROCESSED" || got.Accrural == nil || *got.Accrural != *first.Accrural {
+			t.Errorf("re-poll with %s: status %s, accrual %v; want PROCESSED, %d", status, got.Status, got.Accrural, *first.Accrural)
+		}
+	}
+}
+
+// TestStatementProcessedDate начисление по заказу, загруженному месяц назад, попадает в выписку дня обработки
+func TestStatementProcessedDate(t *testing.T) {
+	s := testStorage(t)
+	now := time.Now().UnixNano()
+	userID, _, err := s.UserRegister(&model.User{Login: fmt.Sprintf("processed-%d", now), Hash: "-"}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	num, base := now, int64(100)
+	if _, err = s.OrdersNew(&model.Order{UserID: userID, Num: &num, Status: "NEW"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.connection.Exec("update orders set date_ins = now() - interval '40 days' where num = $1", num); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.AccruralUpdate(&model.Order{Num: &num, Status: "PROCESSED", Accrural: &base}); err != nil {
+		t.Fatal(err)
+	}
+
+	var orders []model.StatementRow // бонусы кампаний за заказ идут отдельными строками ledger
+	from := time.Now().Add(-24 * time.Hour)
+	err = s.Statement(&model.User{ID: userID}, from, time.Now().Add(time.Hour), func(row *model.StatementRow) error {
+		if row.Kind == "order" {
+			orders = append(orders, *row)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Amount != base {
+		t.Fatalf("orders in the statement of the last day %+v, want the processed order with amount %d", orders, base)
+	}
+}
+
+// testEvents число событий event пользователя в outbox
+func testEvents(t *testing.T, s *PostgreSQLStorage, user *model.User, event string) int {
+	t.Helper()
+	var n int
+	err := s.connection.QueryRow("select count(*) from outbox where user_id = $1 and event = $2", user.ID, event).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestAccruralUpdateUnchanged событие order.updated пишется только при смене статуса, а не на каждый опрос
+func TestAccruralUpdateUnchanged(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "unchanged")
+	num := testNum()
+	if _, err := s.OrdersNew(&model.Order{UserID: user.ID, Num: &num, Status: "NEW"}); err != nil {
+		t.Fatal(err)
+	}
+	accrual := int64(100)
+	for _, poll := range []struct {
+		status string
+		events int
+	}{
+		{"NEW", 0},
+		{"PROCESSING", 1},
+		{"PROCESSING", 1},
+		{"PROCESSING", 1},
+		{"PROCESSED", 2},
+		{"PROCESSED", 2},
+	} {
+		if err := s.AccruralUpdate(&model.Order{Num: &num, Status: poll.status, Accrural: &accrual}); err != nil {
+			t.Fatal(err)
+		}
+		if events := testEvents(t, s, user, model.EventOrderUpdated); events != poll.events {
+			t.Errorf("after poll with %s: %d order.updated events, want %d", poll.status, events, poll.events)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+func (pgs *PostgreSQLStorage) SessionAdd(userID string, token string, ip string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":    userID,
+		"token": utils.TokenHash(token),
+		"ttl":   ttl.Seconds(),
+		"ip":    ip,
+	}
+	if _, err := pgs.connection.ExecContext(ctx, sessionAddQuery, args); err != nil {
+		pgs.log.Err(err).Msgf("Error adding session for user id [%v]", userID)
+		return fmt.Errorf("error adding session for user id [%v], query '%s' error: %w", userID, sessionAddQuery, err)
+	}
+	return nil
+}
+
+// SessionCheck возвращает владельца действующей сессии или nil
+func (pgs *PostgreSQLStorage) SessionCheck(token string) (*model.User, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"token": utils.TokenHash(token),
+	}
+	var id, login, role sql.NullString
+	err := pgs.connection.QueryRowContext(ctx, sessionCheckQuery, args).Scan(&id, &login, &role)
+	if err != nil {
+		pgs.log.Err(err).Msg("Error checking session")
+		return nil, fmt.Errorf("error checking session, query '%s' error: %w", sessionCheckQuery, err)
+	}
+	if !id.Valid {
+		return nil, nil
+	}
+	return &model.User{ID: id.String, Login: login.String, Role: role.String}, nil
+}
+
+// PasswordSet меняет hash пароля и отзывает все сессии пользователя
+func (pgs *PostgreSQLStorage) PasswordSet(userID string, hash string) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":   userID,
+		"hash": hash,
+	}
+	if _, err := pgs.connection.ExecContext(ctx, passwordSetQuery, args); err != nil {
+		pgs.log.Err(err).Msgf("Error setting password for user id [%v]", userID)
+		return fmt.Errorf("error setting password for user id [%v], query '%s' error: %w", userID, passwordSetQuery, err)
+	}
+	return nil
+}
+
+// PasswordRehash заменяет hash пароля на пересчитанный, false - пароль уже сменили
+func (pgs *PostgreSQLStorage) PasswordRehash(userID string, old string, hash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":   userID,
+		"old":  old,
+		"hash": hash,
+	}
+	var done sql.NullBool
+	if err := pgs.connection.QueryRowContext(ctx, hashUpgradeQuery, args).Scan(&done); err != nil {
+		pgs.log.Err(err).Msgf("Error upgrading password hash for user id [%v]", userID)
+		return false, fmt.Errorf("error upgrading password hash for user id [%v], query '%s' error: %w", userID, hashUpgradeQuery, err)
+	}
+	return done.Bool, nil
+}
+
+// PasswordResetAdd сохраняет токен сброса, false - пользователь не найден
+func (pgs *PostgreSQLStorage) PasswordResetAdd(reset *model.PasswordReset, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"login": reset.Login,
+		"token": utils.TokenHash(reset.Token),
+		"ttl":   ttl.Seconds(),
+	}
+	var found sql.NullBool
+	if err := pgs.connection.QueryRowContext(ctx, resetAddQuery, args).Scan(&found); err != nil {
+		pgs.log.Err(err).Msgf("Error adding password reset for user [%v]", reset.Login)
+		return false, fmt.Errorf("error adding password reset for user [%v], query '%s' error: %w", reset.Login, resetAddQuery, err)
+	}
+	return found.Bool, nil
+}
+
+// PasswordResetUse меняет пароль по токену сброса, false - токен недействителен
+func (pgs *PostgreSQLStorage) PasswordResetUse(change *model.PasswordChange) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"login": change.Login,
+		"token": utils.TokenHash(change.Token),
+		"hash":  change.Hash,
+	}
+	var ok sql.NullBool
+	if err := pgs.connection.QueryRowContext(ctx, resetUseQuery, args).Scan(&ok); err != nil {
+		pgs.log.Err(err).Msgf("Error using password reset for user [%v]", change.Login)
+		return false, fmt.Errorf("error using password reset for user [%v], query '%s' error: %w", change.Login, resetUseQuery, err)
+	}
+	return ok.Bool, nil
+}
@@ -0,0 +1,116 @@
+package dbstorage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+// testSession открывает сессию пользователя и возвращает ее токен
+func testSession(t *testing.T, s *PostgreSQLStorage, user *model.User) string {
+	t.Helper()
+	token := fmt.Sprintf("session-%d", testNum())
+	if err := s.SessionAdd(user.ID, token, "10.0.0.1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// testSessionValid сессия действует
+func testSessionValid(t *testing.T, s *PostgreSQLStorage, token string) bool {
+	t.Helper()
+	user, err := s.SessionCheck(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user != nil
+}
+
+// testResetToken выдает пользователю токен сброса пароля
+func testResetToken(t *testing.T, s *PostgreSQLStorage, user *model.User) string {
+	t.Helper()
+	token := fmt.Sprintf("reset-%d", testNum())
+	if found, err := s.PasswordResetAdd(&model.PasswordReset{Login: user.Login, Token: token}, time.Hour); err != nil || !found {
+		t.Fatalf("reset token: %v, %v", found, err)
+	}
+	return token
+}
+
+// TestPasswordSet смена пароля отзывает все сессии пользователя и его неиспользованный токен сброса,
+// сессии других пользователей остаются
+func TestPasswordSet(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "password")
+	other := testUser(t, s, "password")
+	sessions := []string{testSession(t, s, user), testSession(t, s, user)}
+	kept := testSession(t, s, other)
+	reset := testResetToken(t, s, user)
+
+	if err := s.PasswordSet(user.ID, "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+	acc, err := s.UserLogin(&model.User{Login: user.Login})
+	if err != nil || acc == nil || acc.Hash != "new-hash" {
+		t.Fatalf("user after change %+v, %v; want hash new-hash", acc, err)
+	}
+	for _, token := range sessions {
+		if testSessionValid(t, s, token) {
+			t.Errorf("session %s kept after password change", token)
+		}
+	}
+	if !testSessionValid(t, s, kept) {
+		t.Error("session of another user revoked")
+	}
+	if done, err := s.PasswordResetUse(&model.PasswordChange{Login: user.Login, Token: reset, Hash: "reset-hash"}); err != nil || done {
+		t.Errorf("reset token after password change: %v, %v; want revoked", done, err)
+	}
+}
+
+// TestPasswordResetUse токен сброса действует один раз для своего логина до истечения срока,
+// новый токен заменяет прежний; сброс отзывает сессии
+func TestPasswordResetUse(t *testing.T) {
+	s := testStorage(t)
+	if found, err := s.PasswordResetAdd(&model.PasswordReset{Login: fmt.Sprintf("nobody-%d", testNum()), Token: "x"}, time.Hour); err != nil || found {
+		t.Errorf("unknown login: %v, %v; want not found", found, err)
+	}
+
+	user := testUser(t, s, "reset")
+	other := testUser(t, s, "reset")
+	session := testSession(t, s, user)
+	replaced := testResetToken(t, s, user)
+	token := testResetToken(t, s, user)
+	expired := testResetToken(t, s, other)
+	testExec(t, s, "update password_resets set expires = now() - interval '1 minute' where token_hash = $1", utils.TokenHash(expired))
+
+	for _, tc := range []struct {
+		name  string
+		login string
+		token string
+		done  bool
+	}{
+		{"wrong token", user.Login, "wrong", false},
+		{"other login", other.Login, token, false},
+		{"replaced", user.Login, replaced, false},
+		{"expired", other.Login, expired, false},
+		{"reset", user.Login, token, true},
+		{"used", user.Login, token, false},
+	} {
+		done, err := s.PasswordResetUse(&model.PasswordChange{Login: tc.login, Token: tc.token, Hash: "hash-" + tc.name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done != tc.done {
+			t.Errorf("%s: done %v, want %v", tc.name, done, tc.done)
+		}
+	}
+	acc, err := s.UserLogin(&model.User{Login: user.Login})
+	if err != nil || acc == nil || acc.Hash != "hash-reset" {
+		t.Fatalf("user after reset %+v, %v; want hash hash-reset", acc, err)
+	}
+	if testSessionValid(t, s, session) {
+		t.Error("session kept after password reset")
+	}
+}
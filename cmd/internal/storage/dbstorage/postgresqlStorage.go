@@ -3,6 +3,7 @@ package dbstorage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -62,37 +63,44 @@ func (pgs *PostgreSQLStorage) UserLogin(user *model.User) (*model.User, error) {
 		"login": user.Login,
 	}
 
-	var id sql.NullString
+	var id, role sql.NullString
 	var hash []byte
+	var version sql.NullInt64
 	row := tx.QueryRowContext(ctx, userLoginQuery, args)
-	errg := row.Scan(&id, &hash)
+	errg := row.Scan(&id, &hash, &role, &version)
 	if errg != nil {
 		pgs.log.Printf("Error log in user:[%v] query '%s' error: %v", user.Login, userAddQuery, err)
 		return nil, fmt.Errorf("error log in user [%v] query '%s' error: %v", user.Login, userAddQuery, err)
 	}
 	// шаг 4 — сохраняем изменения
 	err = tx.Commit()
-	if err != nil || !id.Valid {
-
+	if err != nil {
 		return nil, fmt.Errorf("failed to execute transaction %w", err)
 	}
+	if !id.Valid { //такого пользователя нет
+		return nil, nil
+	}
 	userAcc := model.User{
 		ID:       id.String,
 		Login:    user.Login,
 		Password: user.Password,
 		Hash:     string(hash),
+		Role:     role.String,
+		Version:  version.Int64,
 	}
 
 	return &userAcc, nil
 }
 
-func (pgs *PostgreSQLStorage) UserRegister(user *model.User) (string, error) {
+// UserRegister создает пользователя и приглашение по коду user.Referral.
+// Возвращает id (пусто - логин занят) и итог приглашения model.Referral*, пусто - без кода
+func (pgs *PostgreSQLStorage) UserRegister(user *model.User, referrerBonus int64, refereeBonus int64) (string, string, error) {
 	ctx, cancel := context.WithCancel(pgs.context)
 	defer cancel()
 
 	tx, err := pgs.connection.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer func() {
 		rberr := tx.Rollback()
@@ -101,23 +109,27 @@ func (pgs *PostgreSQLStorage) UserRegister(user *model.User) (string, error) {
 		}
 	}()
 	args := pgx.NamedArgs{
-		"login": user.Login,
-		"hash":  user.Hash,
-	}
-	var id sql.NullString
-	errg := tx.QueryRowContext(ctx, userAddQuery, args).Scan(&id)
+		"login":    user.Login,
+		"hash":     user.Hash,
+		"ip":       user.IP,
+		"code":     sql.NullString{String: user.Referral, Valid: user.Referral != ""},
+		"referrer": referrerBonus,
+		"referee":  refereeBonus,
+	}
+	var id, referral sql.NullString
+	errg := tx.QueryRowContext(ctx, userRegisterQuery, args).Scan(&id, &referral)
 	if errg != nil {
-		pgs.log.Printf("Error register user:[%v] query '%s' error: %v", user.Login, userAddQuery, err)
-		return "", fmt.Errorf("error register user [%v] query '%s' error: %v", user.Login, userAddQuery, err)
+		pgs.log.Printf("Error register user:[%v] query '%s' error: %v", user.Login, userRegisterQuery, errg)
+		return "", "", fmt.Errorf("error register user [%v] query '%s' error: %w", user.Login, userRegisterQuery, errg)
 	}
 
 	// шаг 4 — сохраняем изменения
 	err = tx.Commit()
 	if err != nil {
-		return "", fmt.Errorf("failed to execute transaction %w", err)
+		return "", "", fmt.Errorf("failed to execute transaction %w", err)
 	}
 
-	return id.String, nil
+	return id.String, referral.String, nil
 }
 
 func (pgs *PostgreSQLStorage) OrdersNew(order *model.Order) (string, error) {
@@ -145,6 +157,11 @@ func (pgs *PostgreSQLStorage) OrdersNew(order *model.Order) (string, error) {
 		pgs.log.Printf("StorageError: failed to add order [%v] for user id [%v], query '%s' error: %v", order.Num, order.UserID, orderAddQuery, err)
 		return "", fmt.Errorf("storageError. failed to add order [%v] for user id [%v], query '%s' error: %v", order.Num, order.UserID, orderAddQuery, err)
 	}
+	if id.String == "" {
+		if err = pgs.outboxAdd(ctx, tx, order.UserID, model.EventOrderCreated, order); err != nil {
+			return "", err
+		}
+	}
 
 	// шаг 4 — сохраняем изменения
 	err = tx.Commit()
@@ -210,8 +227,9 @@ func (pgs *PostgreSQLStorage) Balance(user *model.User) (*model.Balance, error)
 
 	var balance sql.NullInt64
 	var expence sql.NullInt64
+	var held sql.NullInt64
 	row := tx.QueryRowContext(ctx, balanceGetQuery, args)
-	errg := row.Scan(&balance, &expence)
+	errg := row.Scan(&balance, &expence, &held)
 	if errg != nil {
 		pgs.log.Err(errg).Msgf("[Balance] failed to get balance for user:[%v] error: %v", user.Login, err)
 		return nil, fmt.Errorf("[Balance] failed to get balance for user:[%v] error: %v", user.Login, err)
@@ -225,17 +243,21 @@ func (pgs *PostgreSQLStorage) Balance(user *model.User) (*model.Balance, error)
 	b := model.Balance{
 		Current: &balance.Int64,
 		Expence: &expence.Int64,
+		Held:    &held.Int64,
 	}
 	return &b, nil
 }
 
-func (pgs *PostgreSQLStorage) Withdraw(request *model.Withdraw) (bool, error) {
+// Withdraw списывает баллы по общим правилам rules с учетом индивидуальных.
+// Возвращает model.WithdrawOK или причину отказа и остаток допустимой суммы.
+// Событие о списании пишется только при model.WithdrawOK, повтор номера - model.WithdrawDuplicate
+func (pgs *PostgreSQLStorage) Withdraw(request *model.Withdraw, rules *model.WithdrawRules) (string, int64, error) {
 	ctx, cancel := context.WithCancel(pgs.context)
 	defer cancel()
 
 	tx, err := pgs.connection.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
 	if err != nil {
-		return false, err
+		return "", 0, err
 	}
 	defer func() {
 		rberr := tx.Rollback()
@@ -248,19 +270,27 @@ func (pgs *PostgreSQLStorage) Withdraw(request *model.Withdraw) (bool, error) {
 		"num": request.Num,
 		"exp": request.Expence,
 	}
-	var result sql.NullBool
-	errg := tx.QueryRowContext(ctx, withdrawQuery, args).Scan(&result)
+	ruleArgs(args, rules)
+	var remaining sql.NullInt64
+	var result sql.NullString
+	errg := tx.QueryRowContext(ctx, withdrawQuery, args).Scan(&remaining, &result)
 	if errg != nil {
-		pgs.log.Printf("StorageError: failed to withdraw [%v] points for user id [%v], query '%s' error: %v", request.Expence, request.UserID, withdrawQuery, err)
-		return false, fmt.Errorf("StorageError: failed to withdraw [%v] points for user id [%v], query '%s' error: %v", request.Expence, request.UserID, withdrawQuery, err)
+		pgs.log.Printf("StorageError: failed to withdraw [%v] points for user id [%v], query '%s' error: %v", request.Expence, request.UserID, withdrawQuery, errg)
+		return "", 0, fmt.Errorf("StorageError: failed to withdraw [%v] points for user id [%v], query '%s' error: %v", request.Expence, request.UserID, withdrawQuery, errg)
+	}
+	if result.String != model.WithdrawOK {
+		return result.String, remaining.Int64, nil
+	}
+	if err = pgs.outboxAdd(ctx, tx, request.UserID, model.EventWithdrawalCreated, request); err != nil {
+		return "", 0, err
 	}
 
 	// шаг 4 — сохраняем изменения
 	err = tx.Commit()
 	if err != nil {
-		return false, fmt.Errorf("failed to execute transaction %w", err)
+		return "", 0, fmt.Errorf("failed to execute transaction %w", err)
 	}
-	return result.Bool, nil
+	return result.String, 0, nil
 }
 
 func (pgs *PostgreSQLStorage) Withdrawals(user *model.User) (*[]model.Withdraw, error) {
@@ -277,7 +307,7 @@ func (pgs *PostgreSQLStorage) Withdrawals(user *model.User) (*[]model.Withdraw,
 	wdrsList := new([]model.Withdraw)
 	for rows.Next() {
 		var o dbWdr
-		err = rows.Scan(&o.Num, &o.Expence, &o.Ins)
+		err = rows.Scan(&o.Num, &o.Expence, &o.Ins, &o.Status)
 		if err != nil {
 			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
 			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
@@ -286,6 +316,7 @@ func (pgs *PostgreSQLStorage) Withdrawals(user *model.User) (*[]model.Withdraw,
 		mo.Num = &o.Num.Int64
 		mo.Expence = &o.Expence.Int64
 		mo.Ins = o.Ins.Time
+		mo.Status = o.Status.String
 		*wdrsList = append(
 			*wdrsList, mo)
 	}
@@ -317,10 +348,21 @@ func (pgs *PostgreSQLStorage) AccruralUpdate(order *model.Order) error {
 		"acc":    order.Accrural,
 	}
 
-	_, errg := tx.ExecContext(ctx, accUpdate, args)
-	if errg != nil {
-		pgs.log.Printf("Error AccruralUpdate order num:[%v] query '%s' error: %v", order.Num, accUpdate, err)
-		return fmt.Errorf("error AccruralUpdate order num:[%v] query '%s' error: %v", order.Num, accUpdate, err)
+	var userID sql.NullString
+	var accrual sql.NullInt64
+	errg := tx.QueryRowContext(ctx, accUpdate, args).Scan(&userID, &accrual)
+	if errg != nil && !errors.Is(errg, sql.ErrNoRows) {
+		pgs.log.Printf("Error AccruralUpdate order num:[%v] query '%s' error: %v", order.Num, accUpdate, errg)
+		return fmt.Errorf("error AccruralUpdate order num:[%v] query '%s' error: %v", order.Num, accUpdate, errg)
+	}
+	if userID.Valid {
+		// начисление с учетом множителя уровня пользователя
+		if order.Accrural != nil && accrual.Valid {
+			order.Accrural = &accrual.Int64
+		}
+		if err = pgs.outboxAdd(ctx, tx, userID.String, model.EventOrderUpdated, order); err != nil {
+			return err
+		}
 	}
 
 	// шаг 4 — сохраняем изменения
@@ -4,16 +4,88 @@ import "database/sql"
 
 const (
 	userAddQuery        string = "select user_add(@login,@hash)" // если вернулся uuid - ok, null - такой есть
+	userRegisterQuery   string = "select * from user_register(@login, @hash, @ip, @code, @referrer, @referee)"
+	referralInfoQuery   string = "select * from referral_info(@id)"
+	voucherBatchQuery   string = "select * from voucher_batch_add(@name, @amount, @max_uses, @expires, @hashes, @actor)"
+	voucherBatchesQuery string = "select * from voucher_batches_all()"
+	voucherRedeemQuery  string = "select * from voucher_redeem(@id, @hash)"
+	rewardAddQuery      string = "select * from reward_add(@name, @description, @price, @stock, @active)"
+	rewardUpdateQuery   string = "select * from reward_update(@id, @name, @description, @price, @stock, @active)"
+	rewardsAllQuery     string = "select * from rewards_all(@id, @active_only)"
+	rewardRedeemQuery   string = "select * from reward_redeem(@id, @reward, @num, @min, @max, @daily, @monthly, @cooldown)"
+	redemptionsQuery    string = "select * from reward_redemptions_all(@id)"
+	statementQuery      string = "select * from statement(@id, @from, @to)"
 	userLoginQuery      string = "select * from user_check(@login)"
 	orderAddQuery       string = "select * from order_add(@id, @number, @status, 0)"
 	ordersAllQuery      string = "select * from orders_all(@id)"
 	balanceGetQuery     string = "select * from balance(@id)"
-	withdrawQuery       string = "select * from withdraw(@id,@num,@exp)"
+	withdrawQuery       string = "select * from withdraw(@id,@num,@exp,@min,@max,@daily,@monthly,@cooldown)"
 	withdrawalsAllQuery string = "select * from withdrawals_all(@id)"
-	accUpdate           string = "select order_update(@num,@status,@acc)"
+	accUpdate           string = "select * from order_update(@num,@status,@acc)"
 	ordersAcc           string = "select * from orders_acc()"
+	outboxAddQuery      string = "select outbox_add(@id, @event, cast(@payload as jsonb))"
+	webhookAddQuery     string = "select webhook_add(@id, @url, @secret, @events)"
+	webhooksAllQuery    string = "select * from webhooks_all(@id)"
+	webhookDeleteQuery  string = "select webhook_delete(@id, @hook)"
+	deliveriesClaim     string = "select * from deliveries_claim(@limit, make_interval(secs => @lease))"
+	deliveryResult      string = "select delivery_result(@id, @status, @code, @error, @next)"
+	deliveriesAllQuery  string = "select * from deliveries_all(@id, @hook)"
+	deliveryRedeliver   string = "select delivery_redeliver(@id, @delivery)"
+	sessionAddQuery     string = "select session_add(@id, @token, make_interval(secs => @ttl), @ip)"
+	sessionCheckQuery   string = "select * from session_check(@token)"
+	passwordSetQuery    string = "select user_password_set(@id, @hash)"
+	hashUpgradeQuery    string = "select user_hash_upgrade(@id, @old, @hash)"
+	resetAddQuery       string = "select password_reset_add(@login, @token, make_interval(secs => @ttl))"
+	resetUseQuery       string = "select password_reset_use(@login, @token, @hash)"
+	attemptsGetQuery    string = "select * from login_attempts_get(@user_kind, @login, @ip_kind, @ip)"
+	attemptFailQuery    string = "select * from login_attempt_fail(@kind, @key, @after, make_interval(secs => @lock), make_interval(secs => @window))"
+	attemptResetQuery   string = "select login_attempt_reset(@kind, @key)"
+	totpEnrollQuery     string = "select totp_enroll(@id, @secret, @codes)"
+	totpGetQuery        string = "select * from totp_get(@id)"
+	totpStepUseQuery    string = "select totp_step_use(@id, @step)"
+	totpConfirmQuery    string = "select totp_confirm(@id)"
+	totpDisableQuery    string = "select totp_disable(@id)"
+	recoveryUseQuery    string = "select recovery_code_use(@id, @code)"
+	userFindQuery       string = "select * from user_find(@login)"
+	usersSearchQuery    string = "select * from users_search(@query, @limit, @offset)"
+	userRoleSetQuery    string = "select user_role_set(@login, @role)"
+	orderGetQuery       string = "select * from order_get(@num)"
+	adjustmentAddQuery  string = "select * from adjustment_add(@id, @actor, @amount, @reason, @reference)"
+	historyQuery        string = "select * from history(@id)"
+	reverseQuery        string = "select * from withdrawal_reverse(@num, @login, @actor, @amount, @reason)"
+	holdAddQuery        string = "select * from hold_add(@id, @num, @amount, @ttl, @min, @max, @daily, @monthly, @cooldown)"
+	holdCloseQuery      string = "select * from hold_close(@id, @hold, @capture)"
+	holdsActiveQuery    string = "select * from holds_active(@id)"
+	holdsExpireQuery    string = "select holds_expire()"
+	pointsExpiringQuery string = "select points_expiring(@id, @months, @within)"
+	pointsDueQuery      string = "select * from points_expire_due(@months, @after, @limit)"
+	pointsExpireQuery   string = "select points_expire_user(@id, @months)"
+	transferAddQuery    string = "select * from transfer_add(@id, @to, @amount, @comment, @limit, @confirm)"
+	transferCloseQuery  string = "select * from transfer_close(@id, @transfer, @action)"
+	transferGetQuery    string = "select * from transfer_get(@transfer)"
+	transfersAllQuery   string = "select * from transfers_all(@id)"
+	limitsGetQuery      string = "select * from withdraw_limits_get(@id)"
+	limitsSetQuery      string = "select withdraw_limits_set(@id, @min, @max, @daily, @monthly, @cooldown)"
+	profileQuery        string = "select * from profile(@id, @window)"
+	tiersRecalcQuery    string = "select * from tiers_recalc(@window)"
+	campaignAddQuery    string = "select * from campaign_add(@name, @kind, @value, @condition, @min, @starts, @ends, @active)"
+	campaignUpdateQuery string = "select * from campaign_update(@id, @name, @kind, @value, @condition, @min, @starts, @ends, @active)"
+	campaignsAllQuery   string = "select * from campaigns_all()"
+	campaignDeleteQuery string = "select campaign_delete(@id)"
+	statementsDueQuery  string = "select * from monthly_statements_due(@period, @after, @limit)"
+	monthSummaryQuery   string = "select * from monthly_summary(@id, @period)"
+	monthStatementQuery string = "select * from monthly_statement_add(@id, @period, @opening, @accruals, @withdrawals, @other, @closing, @file)"
+	monthStatementsAll  string = "select * from monthly_statements_all(@id, @statement)"
+	userStatsQuery      string = "select * from user_stats(@id, @since)"
+	reportRefreshQuery  string = "select report_daily_refresh()"
+	reportDailyQuery    string = "select * from report_daily_range(@from, @to)"
+	liabilityQuery      string = "select * from report_liability()"
+	reportStuckQuery    string = "select * from report_stuck_orders()"
+	reportTopUsersQuery string = "select * from report_top_users(@from, @to, @by, @limit)"
 )
 
+const pointsExpireBatch = 100 //пользователей за один запрос при сгорании баллов
+
 type dbOrder struct {
 	Num      sql.NullInt64
 	Status   sql.NullString
@@ -25,4 +97,5 @@ type dbWdr struct {
 	Num     sql.NullInt64
 	Expence sql.NullInt64
 	Ins     sql.NullTime
+	Status  sql.NullString
 }
@@ -0,0 +1,24 @@
+package dbstorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// Referral реферальный код пользователя и итоги его приглашений
+func (pgs *PostgreSQLStorage) Referral(user *model.User) (*model.Referral, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	ref := model.Referral{}
+	err := pgs.connection.QueryRowContext(ctx, referralInfoQuery, pgx.NamedArgs{"id": user.ID}).
+		Scan(&ref.Code, &ref.Invited, &ref.Rewarded, &ref.Earned)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error getting referral info for user id [%v]", user.ID)
+		return nil, fmt.Errorf("error getting referral info for user id [%v], query '%s' error: %w", user.ID, referralInfoQuery, err)
+	}
+	return &ref, nil
+}
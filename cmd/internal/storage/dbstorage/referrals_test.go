@@ -0,0 +1,99 @@
+package dbstorage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// testIP уникальный адрес клиента: у пользователей других тестов адрес пустой
+func testIP() string {
+	return fmt.Sprintf("ip-%d", testNum())
+}
+
+// TestReferrals самоприглашение и повтор адреса отклоняются, бонусы обеим сторонам начисляются один раз
+// за первый обработанный заказ приглашенного
+func TestReferrals(t *testing.T) {
+	s := testStorage(t)
+	register := func(ip string, code string) (*model.User, string) {
+		t.Helper()
+		user := &model.User{Login: fmt.Sprintf("referee-%d", testNum()), Hash: "-", IP: ip, Referral: code}
+		id, referral, err := s.UserRegister(user, 50, 25)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.ID = id
+		return user, referral
+	}
+	reason := func(user *model.User) string {
+		t.Helper()
+		var reason string
+		if err := s.connection.QueryRow("select coalesce(reason, '') from referrals where referee_id = $1", user.ID).Scan(&reason); err != nil {
+			t.Fatal(err)
+		}
+		return reason
+	}
+
+	referrerIP, sessionIP := testIP(), testIP()
+	referrer, _ := register(referrerIP, "")
+	if err := s.SessionAdd(referrer.ID, fmt.Sprintf("token-%d", testNum()), sessionIP, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	info, err := s.Referral(referrer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := info.Code
+
+	if user, referral := register(testIP(), "NO-SUCH-CODE"); referral != model.ReferralUnknown || user.ID != "" {
+		t.Errorf("unknown code: %s, user id %q; want %s and no user", referral, user.ID, model.ReferralUnknown)
+	}
+	for _, tc := range []struct {
+		name string
+		ip   string
+	}{
+		{"registration address", referrerIP},
+		{"session address", sessionIP},
+	} {
+		if user, referral := register(tc.ip, code); referral != model.ReferralRejected || reason(user) != "self_referral" {
+			t.Errorf("referrer's %s: %s, reason %s; want %s, self_referral", tc.name, referral, reason(user), model.ReferralRejected)
+		}
+	}
+	sharedIP := testIP()
+	// код принимается в любом регистре
+	referee, referral := register(sharedIP, strings.ToLower(code))
+	if referral != model.ReferralPending {
+		t.Fatalf("referee: %s, want %s", referral, model.ReferralPending)
+	}
+	again, referral := register(sharedIP, code)
+	if referral != model.ReferralRejected || reason(again) != "same_ip" {
+		t.Errorf("second referee from the same address: %s, reason %s; want %s, same_ip", referral, reason(again), model.ReferralRejected)
+	}
+
+	testAccrual(t, s, referee, 100)
+	testAccrual(t, s, referee, 100)
+	testAccrual(t, s, again, 100)
+	for _, tc := range []struct {
+		name    string
+		user    *model.User
+		balance int64
+	}{
+		{"referrer", referrer, 50},
+		{"referee", referee, 200 + 25},
+		{"rejected referee", again, 100},
+	} {
+		if balance := testBalance(t, s, tc.user); balance != tc.balance {
+			t.Errorf("%s balance %d, want %d", tc.name, balance, tc.balance)
+		}
+	}
+	info, err = s.Referral(referrer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Invited != 1 || info.Rewarded != 1 || info.Earned != 50 {
+		t.Errorf("referral info: invited %d, rewarded %d, earned %d; want 1, 1, 50", info.Invited, info.Rewarded, info.Earned)
+	}
+}
@@ -0,0 +1,129 @@
+package dbstorage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// ReportsRefresh пересчитывает дневные итоги отчетов
+func (pgs *PostgreSQLStorage) ReportsRefresh() error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Minute*5)
+	defer cancel()
+	_, err := pgs.connection.ExecContext(ctx, reportRefreshQuery)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error refreshing reports, query: '%s' error: %v", reportRefreshQuery, err)
+		return fmt.Errorf("error refreshing reports, query: '%s' error: %w", reportRefreshQuery, err)
+	}
+	return nil
+}
+
+// ReportLiability обязательства по баллам на текущий момент
+func (pgs *PostgreSQLStorage) ReportLiability() (*model.ReportLiability, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*30)
+	defer cancel()
+	rep := model.ReportLiability{}
+	err := pgs.connection.QueryRowContext(ctx, liabilityQuery).Scan(&rep.Outstanding, &rep.Held, &rep.Users)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error getting liability report, query: '%s' error: %v", liabilityQuery, err)
+		return nil, fmt.Errorf("error getting liability report, query: '%s' error: %w", liabilityQuery, err)
+	}
+	rep.Available = rep.Outstanding - rep.Held
+	return &rep, nil
+}
+
+// ReportDaily дневные итоги за дни [from, to) по состоянию на последний пересчет
+func (pgs *PostgreSQLStorage) ReportDaily(from time.Time, to time.Time) (*[]model.ReportDay, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*30)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"from": from,
+		"to":   to,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, reportDailyQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get daily report, query: '%s' error: %v", reportDailyQuery, err)
+		return nil, fmt.Errorf("error trying to get daily report, query: '%s' error: %w", reportDailyQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.ReportDay)
+	for rows.Next() {
+		var day time.Time
+		d := model.ReportDay{}
+		err = rows.Scan(&day, &d.Orders, &d.Processed, &d.Invalid, &d.Accrued, &d.Withdrawals, &d.Withdrawn, &d.Credited, &d.Debited)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		d.Day = day.Format("2006-01-02")
+		*list = append(*list, d)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ReportStuck заказы NEW и PROCESSING по возрасту
+func (pgs *PostgreSQLStorage) ReportStuck() (*[]model.ReportStuck, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*30)
+	defer cancel()
+	rows, err := pgs.connection.QueryContext(ctx, reportStuckQuery)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get stuck orders report, query: '%s' error: %v", reportStuckQuery, err)
+		return nil, fmt.Errorf("error trying to get stuck orders report, query: '%s' error: %w", reportStuckQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.ReportStuck)
+	for rows.Next() {
+		s := model.ReportStuck{}
+		err = rows.Scan(&s.Status, &s.Age, &s.Orders, &s.Oldest)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*list = append(*list, s)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ReportTopUsers до limit пользователей с наибольшими начислениями или списаниями (by) за период [from, to)
+func (pgs *PostgreSQLStorage) ReportTopUsers(from time.Time, to time.Time, by string, limit int) (*[]model.ReportTopUser, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*30)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"from":  from,
+		"to":    to,
+		"by":    by,
+		"limit": limit,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, reportTopUsersQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get top users report, query: '%s' error: %v", reportTopUsersQuery, err)
+		return nil, fmt.Errorf("error trying to get top users report, query: '%s' error: %w", reportTopUsersQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.ReportTopUser)
+	for rows.Next() {
+		u := model.ReportTopUser{}
+		err = rows.Scan(&u.Login, &u.Orders, &u.Accrued, &u.Withdrawals, &u.Withdrawn)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*list = append(*list, u)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,202 @@
+package dbstorage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// testReportDay день в прошлом для операций теста: отчеты общие для всех пользователей,
+// и в выбранные дни других операций почти наверняка нет
+func testReportDay() time.Time {
+	return time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(testNum()%5000))
+}
+
+// testReportDaily дневные итоги за дни [from, to) после пересчета, по дням
+func testReportDaily(t *testing.T, s *PostgreSQLStorage, from, to time.Time) map[string]model.ReportDay {
+	t.Helper()
+	if err := s.ReportsRefresh(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.ReportDaily(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	days := map[string]model.ReportDay{}
+	for _, d := range *list {
+		days[d.Day] = d
+	}
+	return days
+}
+
+// TestReportDaily заказ попадает в итоги дня обработки, необработанный - дня загрузки; списания и прочие
+// движения баллов - в итоги своего дня
+func TestReportDaily(t *testing.T) {
+	s := testStorage(t)
+	uploaded := testReportDay()
+	processed := uploaded.AddDate(0, 0, 1)
+	day := func(d time.Time) string { return d.Format("2006-01-02") }
+	from, to := uploaded, processed.AddDate(0, 0, 1)
+	before := testReportDaily(t, s, from, to)
+
+	user := testUser(t, s, "report")
+	num := testAccrual(t, s, user, 100)
+	testExec(t, s, "update orders set date_ins = cast($2 as text)::timestamp, date_processed = cast($3 as text)::timestamp where num = $1",
+		num, day(uploaded), day(processed))
+	invalid := testNum()
+	if _, err := s.OrdersNew(&model.Order{UserID: user.ID, Num: &invalid, Status: "NEW"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AccruralUpdate(&model.Order{Num: &invalid, Status: "INVALID"}); err != nil {
+		t.Fatal(err)
+	}
+	testExec(t, s, "update orders set date_ins = cast($2 as text)::timestamp where num = $1", invalid, day(uploaded))
+	withdraw := testWithdraw(user, 30)
+	if result, _, err := s.Withdraw(withdraw, nil); err != nil || result != model.WithdrawOK {
+		t.Fatalf("withdraw: %s, %v", result, err)
+	}
+	testExec(t, s, "update withdraws set date_ins = cast($2 as text)::timestamp where num = $1", *withdraw.Num, day(processed))
+	for _, amount := range []int64{20, -5} {
+		amount := amount
+		adj := &model.Adjustment{UserID: user.ID, ActorID: user.ID, Amount: &amount, Reason: "report",
+			Reference: fmt.Sprintf("report-%d", testNum())}
+		if result, err := s.AdjustmentAdd(adj); err != nil || result != model.AdjustmentOK {
+			t.Fatalf("adjustment %d: %s, %v", amount, result, err)
+		}
+	}
+	testExec(t, s, "update ledger set date_ins = cast($2 as text)::timestamp where user_id = $1", user.ID, day(processed))
+
+	after := testReportDaily(t, s, from, to)
+	for _, tc := range []struct {
+		day  time.Time
+		want model.ReportDay
+	}{
+		{uploaded, model.ReportDay{Orders: 1, Invalid: 1}},
+		{processed, model.ReportDay{Orders: 1, Processed: 1, Accrued: 100, Withdrawals: 1, Withdrawn: 30, Credited: 20, Debited: 5}},
+	} {
+		b, a := before[day(tc.day)], after[day(tc.day)]
+		got := model.ReportDay{Orders: a.Orders - b.Orders, Processed: a.Processed - b.Processed, Invalid: a.Invalid - b.Invalid,
+			Accrued: a.Accrued - b.Accrued, Withdrawals: a.Withdrawals - b.Withdrawals, Withdrawn: a.Withdrawn - b.Withdrawn,
+			Credited: a.Credited - b.Credited, Debited: a.Debited - b.Debited}
+		if got != tc.want {
+			t.Errorf("%s: added %+v, want %+v", day(tc.day), got, tc.want)
+		}
+	}
+}
+
+// TestReportTopUsers пользователи за период по начислениям и списаниям; заказ относится к периоду обработки
+func TestReportTopUsers(t *testing.T) {
+	s := testStorage(t)
+	from := testReportDay()
+	to := from.AddDate(0, 0, 1)
+	inside, before, after := from.Add(12*time.Hour), from.Add(-time.Hour), to.Add(time.Hour)
+	setDates := func(num int64, uploaded, processed time.Time) {
+		t.Helper()
+		testExec(t, s, "update orders set date_ins = cast($2 as text)::timestamp, date_processed = cast($3 as text)::timestamp where num = $1",
+			num, uploaded.Format(time.DateTime), processed.Format(time.DateTime))
+	}
+
+	accruer := testUser(t, s, "report-top")
+	// загружен до периода, обработан в периоде
+	setDates(testAccrual(t, s, accruer, 300), before, inside)
+	// загружен в периоде, обработан после
+	setDates(testAccrual(t, s, accruer, 1000), inside, after)
+
+	spender := testUser(t, s, "report-top")
+	setDates(testAccrual(t, s, spender, 100), inside, inside)
+	withdraw := testWithdraw(spender, 80)
+	if result, _, err := s.Withdraw(withdraw, nil); err != nil || result != model.WithdrawOK {
+		t.Fatalf("withdraw: %s, %v", result, err)
+	}
+	testExec(t, s, "update withdraws set date_ins = cast($2 as text)::timestamp where num = $1", *withdraw.Num, inside.Format(time.DateTime))
+
+	want := map[string]model.ReportTopUser{
+		accruer.Login: {Login: accruer.Login, Orders: 1, Accrued: 300},
+		spender.Login: {Login: spender.Login, Orders: 1, Accrued: 100, Withdrawals: 1, Withdrawn: 80},
+	}
+	for _, tc := range []struct {
+		by    string
+		order []string //порядок тестовых пользователей в отчете
+	}{
+		{model.ReportByAccrued, []string{accruer.Login, spender.Login}},
+		{model.ReportByWithdrawn, []string{spender.Login, accruer.Login}},
+	} {
+		list, err := s.ReportTopUsers(from, to, tc.by, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var order []string
+		for _, u := range *list {
+			if w, ok := want[u.Login]; ok {
+				order = append(order, u.Login)
+				if u != w {
+					t.Errorf("by %s: %+v, want %+v", tc.by, u, w)
+				}
+			}
+		}
+		if len(order) != 2 || order[0] != tc.order[0] || order[1] != tc.order[1] {
+			t.Errorf("by %s: order %v, want %v", tc.by, order, tc.order)
+		}
+	}
+}
+
+// TestReportLiability непотраченные баллы и резервы нового пользователя добавляются к обязательствам
+func TestReportLiability(t *testing.T) {
+	s := testStorage(t)
+	before, err := s.ReportLiability()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := testUser(t, s, "report-liability")
+	testAccrual(t, s, user, 100)
+	testHold(t, s, user, 30)
+	after, err := s.ReportLiability()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := after.Outstanding - before.Outstanding; d != 100 {
+		t.Errorf("outstanding added %d, want 100", d)
+	}
+	if d := after.Held - before.Held; d != 30 {
+		t.Errorf("held added %d, want 30", d)
+	}
+	if d := after.Available - before.Available; d != 70 {
+		t.Errorf("available added %d, want 70", d)
+	}
+	if d := after.Users - before.Users; d != 1 {
+		t.Errorf("users added %d, want 1", d)
+	}
+}
+
+// TestReportStuck ожидающий расчета заказ попадает в группу своего статуса и возраста
+func TestReportStuck(t *testing.T) {
+	s := testStorage(t)
+	count := func(status, age string) int64 {
+		t.Helper()
+		list, err := s.ReportStuck()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range *list {
+			if r.Status == status && r.Age == age {
+				return r.Orders
+			}
+		}
+		return 0
+	}
+	before := count("PROCESSING", "1d_7d")
+	user := testUser(t, s, "report-stuck")
+	num := testNum()
+	if _, err := s.OrdersNew(&model.Order{UserID: user.ID, Num: &num, Status: "NEW"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AccruralUpdate(&model.Order{Num: &num, Status: "PROCESSING"}); err != nil {
+		t.Fatal(err)
+	}
+	testExec(t, s, "update orders set date_ins = now() - interval '3 days' where num = $1", num)
+	if added := count("PROCESSING", "1d_7d") - before; added != 1 {
+		t.Errorf("PROCESSING 1d_7d added %d, want 1", added)
+	}
+}
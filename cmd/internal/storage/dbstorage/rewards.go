@@ -0,0 +1,181 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+func rewardArgs(rw *model.Reward) pgx.NamedArgs {
+	var stock sql.NullInt64
+	if rw.Stock != nil {
+		stock = sql.NullInt64{Int64: *rw.Stock, Valid: true}
+	}
+	return pgx.NamedArgs{
+		"id":          rw.ID,
+		"name":        rw.Name,
+		"description": sql.NullString{String: rw.Description, Valid: rw.Description != ""},
+		"price":       rw.Price,
+		"stock":       stock,
+		"active":      *rw.Active,
+	}
+}
+
+// RewardAdd добавляет награду в каталог и заполняет ее id
+func (pgs *PostgreSQLStorage) RewardAdd(rw *model.Reward) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	err := pgs.connection.QueryRowContext(ctx, rewardAddQuery, rewardArgs(rw)).Scan(&rw.ID, &rw.Ins)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error adding reward [%v]", rw.Name)
+		return fmt.Errorf("error adding reward [%v], query '%s' error: %w", rw.Name, rewardAddQuery, err)
+	}
+	return nil
+}
+
+// RewardUpdate заменяет награду rw.ID, false - награда не найдена
+func (pgs *PostgreSQLStorage) RewardUpdate(rw *model.Reward) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	err := pgs.connection.QueryRowContext(ctx, rewardUpdateQuery, rewardArgs(rw)).Scan(&rw.Ins)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error updating reward [%v]", rw.ID)
+		return false, fmt.Errorf("error updating reward [%v], query '%s' error: %w", rw.ID, rewardUpdateQuery, err)
+	}
+	return true, nil
+}
+
+// Rewards каталог по возрастанию цены; activeOnly - только доступные пользователям
+func (pgs *PostgreSQLStorage) Rewards(activeOnly bool) (*[]model.Reward, error) {
+	return pgs.rewards(sql.NullString{}, activeOnly)
+}
+
+// RewardGet доступная пользователям награда или nil
+func (pgs *PostgreSQLStorage) RewardGet(id string) (*model.Reward, error) {
+	list, err := pgs.rewards(sql.NullString{String: id, Valid: true}, true)
+	if err != nil || len(*list) == 0 {
+		return nil, err
+	}
+	return &(*list)[0], nil
+}
+
+func (pgs *PostgreSQLStorage) rewards(id sql.NullString, activeOnly bool) (*[]model.Reward, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":          id,
+		"active_only": activeOnly,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, rewardsAllQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get rewards, query: '%s' error: %v", rewardsAllQuery, err)
+		return nil, fmt.Errorf("error trying to get rewards, query: '%s' error: %w", rewardsAllQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.Reward)
+	for rows.Next() {
+		var description sql.NullString
+		var stock sql.NullInt64
+		rw := model.Reward{Active: new(bool)}
+		err = rows.Scan(&rw.ID, &rw.Name, &description, &rw.Price, &stock, rw.Active, &rw.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		rw.Description = description.String
+		if stock.Valid {
+			rw.Stock = &stock.Int64
+		}
+		*list = append(*list, rw)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// RewardRedeem списывает цену награды под номером red.Num и уменьшает остаток в одной транзакции.
+// Возвращает model.Reward* или результат списания model.Withdraw* с остатком лимита
+func (pgs *PostgreSQLStorage) RewardRedeem(red *model.RewardRedemption, rules *model.WithdrawRules) (string, int64, error) {
+	ctx, cancel := context.WithCancel(pgs.context)
+	defer cancel()
+
+	tx, err := pgs.connection.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		rberr := tx.Rollback()
+		if rberr != nil {
+			pgs.log.Printf("failed to rollback transaction err: %v", rberr)
+		}
+	}()
+	args := pgx.NamedArgs{
+		"id":     red.UserID,
+		"reward": red.RewardID,
+		"num":    red.Num,
+	}
+	ruleArgs(args, rules)
+	var id, name sql.NullString
+	var price, remaining sql.NullInt64
+	var ins sql.NullTime
+	var result string
+	err = tx.QueryRowContext(ctx, rewardRedeemQuery, args).Scan(&id, &name, &price, &ins, &remaining, &result)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error redeeming reward [%v] for user id [%v]", *red.RewardID, red.UserID)
+		return "", 0, fmt.Errorf("error redeeming reward [%v] for user id [%v], query '%s' error: %w", *red.RewardID, red.UserID, rewardRedeemQuery, err)
+	}
+	if result != model.WithdrawOK {
+		return result, remaining.Int64, nil
+	}
+	red.ID, red.Name, red.Price, red.Ins = id.String, name.String, price.Int64, ins.Time
+	withdraw := &model.Withdraw{UserID: red.UserID, Num: red.Num, Expence: &red.Price, Ins: red.Ins}
+	if err = pgs.outboxAdd(ctx, tx, red.UserID, model.EventWithdrawalCreated, withdraw); err != nil {
+		return "", 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to execute transaction %w", err)
+	}
+	return result, 0, nil
+}
+
+// RewardRedemptions обмены пользователя, новые первыми
+func (pgs *PostgreSQLStorage) RewardRedemptions(user *model.User) (*[]model.RewardRedemption, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	rows, err := pgs.connection.QueryContext(ctx, redemptionsQuery, pgx.NamedArgs{"id": user.ID})
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get reward redemptions, query: '%s' error: %v", redemptionsQuery, err)
+		return nil, fmt.Errorf("error trying to get reward redemptions, query: '%s' error: %w", redemptionsQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.RewardRedemption)
+	for rows.Next() {
+		var rewardID sql.NullString
+		rr := model.RewardRedemption{Num: new(int64)}
+		err = rows.Scan(&rr.ID, &rewardID, &rr.Name, &rr.Price, rr.Num, &rr.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		if rewardID.Valid {
+			rr.RewardID = &rewardID.String
+		}
+		*list = append(*list, rr)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
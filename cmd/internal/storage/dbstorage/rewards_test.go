@@ -0,0 +1,98 @@
+package dbstorage
+
+import (
+	"testing"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// testReward добавляет в каталог награду ценой price с остатком stock, nil - без ограничения
+func testReward(t *testing.T, s *PostgreSQLStorage, price int64, stock *int64, active bool) *model.Reward {
+	t.Helper()
+	rw := &model.Reward{Name: "test", Price: price, Stock: stock, Active: &active}
+	if err := s.RewardAdd(rw); err != nil {
+		t.Fatal(err)
+	}
+	return rw
+}
+
+// testStock остаток награды в каталоге
+func testStock(t *testing.T, s *PostgreSQLStorage, rw *model.Reward) *int64 {
+	t.Helper()
+	got, err := s.RewardGet(rw.ID)
+	if err != nil || got == nil {
+		t.Fatalf("reward %s: %v, %v", rw.ID, got, err)
+	}
+	return got.Stock
+}
+
+// TestRewardRedeem обмен списывает цену и уменьшает остаток; любой отказ, в том числе по правилам списания,
+// не трогает ни баланс, ни остаток
+func TestRewardRedeem(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "reward")
+	testAccrual(t, s, user, 1000)
+	rw := testReward(t, s, 300, testInt(2), true)
+	redeem := func(user *model.User, rw *model.Reward, num int64, rules *model.WithdrawRules) (string, int64) {
+		t.Helper()
+		red := &model.RewardRedemption{UserID: user.ID, RewardID: &rw.ID, Num: &num}
+		result, remaining, err := s.RewardRedeem(red, rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, remaining
+	}
+	check := func(balance, stock int64) {
+		t.Helper()
+		if got := testBalance(t, s, user); got != balance {
+			t.Errorf("balance %d, want %d", got, balance)
+		}
+		if got := testStock(t, s, rw); got == nil || *got != stock {
+			t.Errorf("stock %v, want %d", got, stock)
+		}
+	}
+
+	num := testNum()
+	if result, _ := redeem(user, rw, num, nil); result != model.WithdrawOK {
+		t.Fatalf("redeem: %s", result)
+	}
+	check(700, 1)
+	if result, _ := redeem(user, rw, num, nil); result != model.RewardDuplicate {
+		t.Errorf("same order number: %s, want %s", result, model.RewardDuplicate)
+	}
+	if result, remaining := redeem(user, rw, testNum(), &model.WithdrawRules{MaxTx: testInt(200)}); result != model.WithdrawAboveMax || remaining != 200 {
+		t.Errorf("above the max: %s, remaining %d; want %s, 200", result, remaining, model.WithdrawAboveMax)
+	}
+	poor := testUser(t, s, "reward-poor")
+	testAccrual(t, s, poor, 100)
+	if result, _ := redeem(poor, rw, testNum(), nil); result != model.WithdrawInsufficient {
+		t.Errorf("insufficient: %s, want %s", result, model.WithdrawInsufficient)
+	}
+	check(700, 1)
+
+	if result, _ := redeem(user, rw, testNum(), nil); result != model.WithdrawOK {
+		t.Fatalf("last in stock: %s", result)
+	}
+	check(400, 0)
+	if result, _ := redeem(user, rw, testNum(), nil); result != model.RewardOutOfStock {
+		t.Errorf("out of stock: %s, want %s", result, model.RewardOutOfStock)
+	}
+	check(400, 0)
+
+	unlimited := testReward(t, s, 100, nil, true)
+	if result, _ := redeem(user, unlimited, testNum(), nil); result != model.WithdrawOK {
+		t.Errorf("unlimited stock: %s", result)
+	}
+	if stock := testStock(t, s, unlimited); stock != nil {
+		t.Errorf("unlimited stock became %d", *stock)
+	}
+	if result, _ := redeem(user, testReward(t, s, 100, nil, false), testNum(), nil); result != model.RewardNotFound {
+		t.Errorf("inactive reward: %s, want %s", result, model.RewardNotFound)
+	}
+	if balance := testBalance(t, s, user); balance != 300 {
+		t.Errorf("balance %d, want 300", balance)
+	}
+	if list, err := s.RewardRedemptions(user); err != nil || len(*list) != 3 {
+		t.Errorf("redemptions %v, %v; want 3", list, err)
+	}
+}
@@ -0,0 +1,137 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// MonthlyStatementsDue до limit пользователей, которым нужна выписка за месяц period
+func (pgs *PostgreSQLStorage) MonthlyStatementsDue(period time.Time, after string, limit int) (*[]model.User, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*30)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"period": period,
+		"after":  sql.NullString{String: after, Valid: after != ""},
+		"limit":  limit,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, statementsDueQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get users due for statement, query: '%s' error: %v", statementsDueQuery, err)
+		return nil, fmt.Errorf("error trying to get users due for statement, query: '%s' error: %w", statementsDueQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.User)
+	for rows.Next() {
+		u := model.User{}
+		err = rows.Scan(&u.ID, &u.Login)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*list = append(*list, u)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// MonthlySummary итоги месяца period для пользователя userID
+func (pgs *PostgreSQLStorage) MonthlySummary(userID string, period time.Time) (*model.MonthlyStatement, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*30)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":     userID,
+		"period": period,
+	}
+	ms := model.MonthlyStatement{UserID: userID, Period: period.Format(model.MonthlyStatementPeriod)}
+	err := pgs.connection.QueryRowContext(ctx, monthSummaryQuery, args).
+		Scan(&ms.Opening, &ms.Accruals, &ms.Withdrawals, &ms.Other, &ms.Closing)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error getting summary of [%s] for user id [%v]", ms.Period, userID)
+		return nil, fmt.Errorf("error getting summary of [%s] for user id [%v], query '%s' error: %w", ms.Period, userID, monthSummaryQuery, err)
+	}
+	return &ms, nil
+}
+
+// MonthlyStatementAdd сохраняет выписку и заполняет ее id, false - выписка за этот месяц уже есть
+func (pgs *PostgreSQLStorage) MonthlyStatementAdd(ms *model.MonthlyStatement) (bool, error) {
+	period, err := time.Parse(model.MonthlyStatementPeriod, ms.Period)
+	if err != nil {
+		return false, fmt.Errorf("invalid statement period [%s]: %w", ms.Period, err)
+	}
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":          ms.UserID,
+		"period":      period,
+		"opening":     ms.Opening,
+		"accruals":    ms.Accruals,
+		"withdrawals": ms.Withdrawals,
+		"other":       ms.Other,
+		"closing":     ms.Closing,
+		"file":        ms.File,
+	}
+	err = pgs.connection.QueryRowContext(ctx, monthStatementQuery, args).Scan(&ms.ID, &ms.Ins)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error adding statement [%s] for user id [%v]", ms.Period, ms.UserID)
+		return false, fmt.Errorf("error adding statement [%s] for user id [%v], query '%s' error: %w", ms.Period, ms.UserID, monthStatementQuery, err)
+	}
+	return true, nil
+}
+
+// MonthlyStatements выписки пользователя, новые первыми
+func (pgs *PostgreSQLStorage) MonthlyStatements(user *model.User) (*[]model.MonthlyStatement, error) {
+	return pgs.monthlyStatements(user, sql.NullString{})
+}
+
+// MonthlyStatementGet выписка пользователя или nil
+func (pgs *PostgreSQLStorage) MonthlyStatementGet(user *model.User, id string) (*model.MonthlyStatement, error) {
+	list, err := pgs.monthlyStatements(user, sql.NullString{String: id, Valid: true})
+	if err != nil || len(*list) == 0 {
+		return nil, err
+	}
+	return &(*list)[0], nil
+}
+
+func (pgs *PostgreSQLStorage) monthlyStatements(user *model.User, id sql.NullString) (*[]model.MonthlyStatement, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":        user.ID,
+		"statement": id,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, monthStatementsAll, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get statements, query: '%s' error: %v", monthStatementsAll, err)
+		return nil, fmt.Errorf("error trying to get statements, query: '%s' error: %w", monthStatementsAll, err)
+	}
+	defer rows.Close()
+	list := new([]model.MonthlyStatement)
+	for rows.Next() {
+		var period time.Time
+		ms := model.MonthlyStatement{UserID: user.ID}
+		err = rows.Scan(&ms.ID, &period, &ms.Opening, &ms.Accruals, &ms.Withdrawals, &ms.Other, &ms.Closing, &ms.File, &ms.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		ms.Period = period.Format(model.MonthlyStatementPeriod)
+		*list = append(*list, ms)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,58 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// Stats статистика пользователя за всю историю и по месяцам за последние months месяцев, включая текущий
+func (pgs *PostgreSQLStorage) Stats(user *model.User, months int) (*model.Stats, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	y, m, _ := time.Now().Date()
+	args := pgx.NamedArgs{
+		"id":    user.ID,
+		"since": time.Date(y, m-time.Month(months-1), 1, 0, 0, 0, 0, time.Local),
+	}
+	rows, err := pgs.connection.QueryContext(ctx, userStatsQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get stats, query: '%s' error: %v", userStatsQuery, err)
+		return nil, fmt.Errorf("error trying to get stats, query: '%s' error: %w", userStatsQuery, err)
+	}
+	defer rows.Close()
+	stats := model.Stats{Months: []model.StatsMonth{}}
+	for rows.Next() {
+		var month sql.NullTime
+		sm := model.StatsMonth{}
+		err = rows.Scan(&month, &sm.Orders, &sm.Processed, &sm.Invalid, &sm.Accrued, &sm.Withdrawals, &sm.Withdrawn)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		if month.Valid {
+			sm.Month = month.Time.Format(model.MonthlyStatementPeriod)
+			stats.Months = append(stats.Months, sm)
+			continue
+		}
+		// итоговая строка
+		stats.Orders, stats.Processed, stats.Invalid = sm.Orders, sm.Processed, sm.Invalid
+		stats.Accrued, stats.Withdrawals, stats.Withdrawn = sm.Accrued, sm.Withdrawals, sm.Withdrawn
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if stats.Orders > 0 {
+		stats.ProcessedRatio = float64(stats.Processed) / float64(stats.Orders)
+		stats.InvalidRatio = float64(stats.Invalid) / float64(stats.Orders)
+	}
+	if stats.Processed > 0 {
+		stats.AvgAccrual = float64(stats.Accrued) / float64(stats.Processed)
+	}
+	return &stats, nil
+}
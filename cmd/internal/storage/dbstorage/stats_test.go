@@ -0,0 +1,70 @@
+package dbstorage
+
+import (
+	"testing"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// TestStats итоги и помесячная статистика; заказ относится к месяцу обработки, необработанный - к месяцу загрузки
+func TestStats(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "stats")
+	// загружен в прошлом месяце, обработан сейчас
+	late := testAccrual(t, s, user, 100)
+	testExec(t, s, "update orders set date_ins = date_trunc('month', now()) - interval '1 day' where num = $1", late)
+	// загружен и обработан в прошлом месяце
+	old := testAccrual(t, s, user, 200)
+	testExec(t, s, "update orders set date_ins = date_trunc('month', now()) - interval '2 days', "+
+		"date_processed = date_trunc('month', now()) - interval '1 day' where num = $1", old)
+	for _, status := range []string{"INVALID", "NEW"} {
+		num := testNum()
+		if _, err := s.OrdersNew(&model.Order{UserID: user.ID, Num: &num, Status: "NEW"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AccruralUpdate(&model.Order{Num: &num, Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if result, _, err := s.Withdraw(testWithdraw(user, 50), nil); err != nil || result != model.WithdrawOK {
+		t.Fatalf("withdraw: %s, %v", result, err)
+	}
+	var current, previous string
+	if err := s.connection.QueryRow("select to_char(now(), 'YYYY-MM'), to_char(now() - interval '1 month', 'YYYY-MM')").
+		Scan(&current, &previous); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.Stats(user, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Orders != 4 || stats.Processed != 2 || stats.Invalid != 1 || stats.Accrued != 300 ||
+		stats.Withdrawals != 1 || stats.Withdrawn != 50 {
+		t.Errorf("totals %+v; want 4 orders, 2 processed, 1 invalid, 300 accrued, 1 withdrawal of 50", *stats)
+	}
+	if stats.ProcessedRatio != 0.5 || stats.InvalidRatio != 0.25 || stats.AvgAccrual != 150 {
+		t.Errorf("ratios %v, %v, average %v; want 0.5, 0.25, 150", stats.ProcessedRatio, stats.InvalidRatio, stats.AvgAccrual)
+	}
+	want := []model.StatsMonth{
+		{Month: current, Orders: 3, Processed: 1, Invalid: 1, Accrued: 100, Withdrawals: 1, Withdrawn: 50},
+		{Month: previous, Orders: 1, Processed: 1, Accrued: 200},
+	}
+	if len(stats.Months) != len(want) {
+		t.Fatalf("months %+v, want %+v", stats.Months, want)
+	}
+	for i, m := range stats.Months {
+		if m != want[i] {
+			t.Errorf("month %d: %+v, want %+v", i, m, want[i])
+		}
+	}
+
+	// только текущий месяц, итоги за всю историю
+	stats, err = s.Stats(user, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Months) != 1 || stats.Months[0].Month != current || stats.Orders != 4 {
+		t.Errorf("one month: %+v, %d orders; want %s only and 4 orders", stats.Months, stats.Orders, current)
+	}
+}
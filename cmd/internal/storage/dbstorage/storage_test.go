@@ -0,0 +1,112 @@
+package dbstorage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/migrations"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rs/zerolog"
+)
+
+type testConfig struct {
+	uri string
+}
+
+func (c testConfig) GetDBConnection() string {
+	return c.uri
+}
+
+func (testConfig) IsDebug() bool {
+	return false
+}
+
+var (
+	testMigrate sync.Once
+	testMigrErr error
+	testSeq     = time.Now().UnixNano()
+)
+
+// testStorage хранилище на тестовой базе TEST_DATABASE_URI; без нее тест пропускается.
+// Тесты не чистят базу, поэтому все логины и номера в них уникальны
+func testStorage(t *testing.T) *PostgreSQLStorage {
+	t.Helper()
+	uri := os.Getenv("TEST_DATABASE_URI")
+	if uri == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	cfg := testConfig{uri: uri}
+	lg := logger.New(cfg)
+	testMigrate.Do(func() { testMigrErr = migrations.RunMigrations(lg, cfg) })
+	if testMigrErr != nil {
+		t.Fatal(testMigrErr)
+	}
+	s, err := NewStorage(context.Background(), lg, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.connection.Close() })
+	return s
+}
+
+// testNum уникальное в пределах запуска число для номеров заказов и логинов
+func testNum() int64 {
+	return atomic.AddInt64(&testSeq, 1)
+}
+
+// testUser регистрирует пользователя с уникальным логином
+func testUser(t *testing.T, s *PostgreSQLStorage, prefix string) *model.User {
+	t.Helper()
+	user := &model.User{Login: fmt.Sprintf("%s-%d", prefix, testNum()), Hash: "-"}
+	id, _, err := s.UserRegister(user, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.ID = id
+	return user
+}
+
+// testAccrual загружает и обрабатывает заказ пользователя с начислением accrual, возвращает номер заказа.
+// Новый пользователь на базовом уровне, множитель 1: начисление равно accrual
+func testAccrual(t *testing.T, s *PostgreSQLStorage, user *model.User, accrual int64) int64 {
+	t.Helper()
+	num := testNum()
+	if _, err := s.OrdersNew(&model.Order{UserID: user.ID, Num: &num, Status: "NEW"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AccruralUpdate(&model.Order{Num: &num, Status: "PROCESSED", Accrural: &accrual}); err != nil {
+		t.Fatal(err)
+	}
+	return num
+}
+
+// testBalance доступный остаток пользователя
+func testBalance(t *testing.T, s *PostgreSQLStorage, user *model.User) int64 {
+	t.Helper()
+	b, err := s.Balance(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *b.Current
+}
+
+// testExec меняет данные в обход хранилища: сдвигает даты, включает правила
+func testExec(t *testing.T, s *PostgreSQLStorage, query string, args ...any) {
+	t.Helper()
+	if _, err := s.connection.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testWithdraw списание суммы sum под новым номером заказа
+func testWithdraw(user *model.User, sum int64) *model.Withdraw {
+	num := testNum()
+	return &model.Withdraw{UserID: user.ID, Num: &num, Expence: &sum}
+}
@@ -0,0 +1,90 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// Profile профиль пользователя; уровень считается по начислениям за window
+func (pgs *PostgreSQLStorage) Profile(user *model.User, window time.Duration) (*model.Profile, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":     user.ID,
+		"window": window.Seconds(),
+	}
+	var next sql.NullString
+	var threshold sql.NullInt64
+	var registered sql.NullTime
+	p := model.Profile{}
+	err := pgs.connection.QueryRowContext(ctx, profileQuery, args).
+		Scan(&p.Login, &p.Tier, &p.Multiplier, &p.Accrued, &next, &threshold, &registered)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error getting profile for user id [%v]", user.ID)
+		return nil, fmt.Errorf("error getting profile for user id [%v], query '%s' error: %w", user.ID, profileQuery, err)
+	}
+	if next.Valid {
+		p.NextTier = next.String
+		rest := threshold.Int64 - p.Accrued
+		if rest < 0 {
+			rest = 0
+		}
+		p.ToNextTier = &rest
+	}
+	if registered.Valid {
+		p.Registered = &registered.Time
+	}
+	return &p, nil
+}
+
+// TiersRecalc пересчитывает уровни по начислениям за window и уведомляет пользователей о смене уровня
+func (pgs *PostgreSQLStorage) TiersRecalc(window time.Duration) (*[]model.TierChange, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Minute*5)
+	defer cancel()
+
+	tx, err := pgs.connection.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		rberr := tx.Rollback()
+		if rberr != nil {
+			pgs.log.Printf("failed to rollback transaction err: %v", rberr)
+		}
+	}()
+	args := pgx.NamedArgs{
+		"window": window.Seconds(),
+	}
+	rows, err := tx.QueryContext(ctx, tiersRecalcQuery, args)
+	if err != nil {
+		return nil, fmt.Errorf("error recalculating tiers, query '%s' error: %w", tiersRecalcQuery, err)
+	}
+	list := new([]model.TierChange)
+	for rows.Next() {
+		c := model.TierChange{}
+		if err = rows.Scan(&c.UserID, &c.From, &c.To, &c.Multiplier); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*list = append(*list, c)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range *list {
+		c := &(*list)[i]
+		if err = pgs.outboxAdd(ctx, tx, c.UserID, model.EventTierChanged, c); err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to execute transaction %w", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,26 @@
+package dbstorage
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTierAccrued в скользящее окно уровня входят заказы, обработанные в нем, даже если загружены раньше
+func TestTierAccrued(t *testing.T) {
+	s := testStorage(t)
+	user := testUser(t, s, "tier")
+	// загружен до окна, обработан в нем
+	late := testAccrual(t, s, user, 300)
+	testExec(t, s, "update orders set date_ins = now() - interval '40 days' where num = $1", late)
+	// обработан до окна
+	old := testAccrual(t, s, user, 500)
+	testExec(t, s, "update orders set date_ins = now() - interval '50 days', date_processed = now() - interval '40 days' where num = $1", old)
+
+	profile, err := s.Profile(user, 30*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Accrued != 300 {
+		t.Errorf("rolling accrued %d, want 300", profile.Accrued)
+	}
+}
@@ -0,0 +1,146 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// TransferAdd переводит баллы получателю t.To; при превышении дневного лимита в remaining остаток лимита.
+// Возвращает model.TransferOK или причину отказа
+func (pgs *PostgreSQLStorage) TransferAdd(t *model.Transfer, dailyLimit int64, confirmAbove int64) (result string, remaining int64, err error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+
+	tx, err := pgs.connection.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return "", 0, err
+	}
+	defer func() {
+		rberr := tx.Rollback()
+		if rberr != nil {
+			pgs.log.Printf("failed to rollback transaction err: %v", rberr)
+		}
+	}()
+	var comment sql.NullString
+	if t.Comment != "" {
+		comment = sql.NullString{String: t.Comment, Valid: true}
+	}
+	args := pgx.NamedArgs{
+		"id":      t.FromID,
+		"to":      t.To,
+		"amount":  t.Amount,
+		"comment": comment,
+		"limit":   dailyLimit,
+		"confirm": confirmAbove,
+	}
+	var id, toID, status, res sql.NullString
+	var rest sql.NullInt64
+	err = tx.QueryRowContext(ctx, transferAddQuery, args).Scan(&id, &toID, &status, &rest, &res)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error adding transfer from user id [%v]", t.FromID)
+		return "", 0, fmt.Errorf("error adding transfer from user id [%v], query '%s' error: %w", t.FromID, transferAddQuery, err)
+	}
+	if res.String != model.TransferOK {
+		return res.String, rest.Int64, nil
+	}
+	t.ID = id.String
+	t.ToID = toID.String
+	t.Status = status.String
+	t.Ins = time.Now()
+	if err = pgs.outboxAdd(ctx, tx, t.ToID, model.EventTransferReceived, t); err != nil {
+		return "", 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return "", 0, fmt.Errorf("failed to execute transaction %w", err)
+	}
+	return res.String, rest.Int64, nil
+}
+
+// TransferClose подтверждает, отклоняет или отменяет ожидающий перевод t.ID от имени userID и заполняет t.
+// Возвращает model.TransferOK или причину отказа
+func (pgs *PostgreSQLStorage) TransferClose(userID string, t *model.Transfer, action string) (string, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+
+	tx, err := pgs.connection.BeginTx(ctx, &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		rberr := tx.Rollback()
+		if rberr != nil {
+			pgs.log.Printf("failed to rollback transaction err: %v", rberr)
+		}
+	}()
+	args := pgx.NamedArgs{
+		"id":       userID,
+		"transfer": t.ID,
+		"action":   action,
+	}
+	var status, result sql.NullString
+	err = tx.QueryRowContext(ctx, transferCloseQuery, args).Scan(&status, &result)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error closing transfer [%v]", t.ID)
+		return "", fmt.Errorf("error closing transfer [%v], query '%s' error: %w", t.ID, transferCloseQuery, err)
+	}
+	t.Status = status.String
+	if result.String != model.TransferOK {
+		return result.String, nil
+	}
+	var comment sql.NullString
+	err = tx.QueryRowContext(ctx, transferGetQuery, pgx.NamedArgs{"transfer": t.ID}).
+		Scan(&t.ID, &t.FromID, &t.ToID, &t.From, &t.To, &t.Amount, &comment, &t.Status, &t.Ins)
+	if err != nil {
+		return "", fmt.Errorf("error getting transfer [%v], query '%s' error: %w", t.ID, transferGetQuery, err)
+	}
+	t.Comment = comment.String
+	// уведомляем другую сторону
+	party := t.FromID
+	if action == model.TransferCancel {
+		party = t.ToID
+	}
+	if err = pgs.outboxAdd(ctx, tx, party, model.EventTransferUpdated, t); err != nil {
+		return "", err
+	}
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to execute transaction %w", err)
+	}
+	return result.String, nil
+}
+
+// Transfers возвращает входящие и исходящие переводы пользователя, новые первыми
+func (pgs *PostgreSQLStorage) Transfers(user *model.User) (*[]model.Transfer, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id": user.ID,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, transfersAllQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get transfers, query: '%s' error: %v", transfersAllQuery, err)
+		return nil, fmt.Errorf("error trying to get transfers, query: '%s' error: %w", transfersAllQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.Transfer)
+	for rows.Next() {
+		var comment sql.NullString
+		t := model.Transfer{}
+		err = rows.Scan(&t.ID, &t.From, &t.To, &t.Amount, &comment, &t.Status, &t.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		t.Comment = comment.String
+		*list = append(*list, t)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,135 @@
+package dbstorage
+
+import (
+	"testing"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// testTransfer переводит amount от from к to; перевод больше confirmAbove ждет подтверждения получателя
+func testTransfer(t *testing.T, s *PostgreSQLStorage, from, to *model.User, amount, confirmAbove int64) *model.Transfer {
+	t.Helper()
+	tr := &model.Transfer{FromID: from.ID, To: to.Login, Amount: &amount}
+	if result, _, err := s.TransferAdd(tr, 0, confirmAbove); err != nil || result != model.TransferOK {
+		t.Fatalf("transfer %d: %s, %v", amount, result, err)
+	}
+	return tr
+}
+
+// TestTransferAdd коды отказа перевода и дневной лимит: в него входят выполненные и ожидающие переводы,
+// но не отмененные и не вчерашние
+func TestTransferAdd(t *testing.T) {
+	s := testStorage(t)
+	from := testUser(t, s, "transfer-from")
+	to := testUser(t, s, "transfer-to")
+	testAccrual(t, s, from, 1000)
+	add := func(login string, amount, limit int64) (string, int64) {
+		t.Helper()
+		result, remaining, err := s.TransferAdd(&model.Transfer{FromID: from.ID, To: login, Amount: &amount}, limit, 250)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, remaining
+	}
+
+	if result, _ := add("transfer-nobody", 10, 0); result != model.TransferNotFound {
+		t.Errorf("unknown recipient: %s, want %s", result, model.TransferNotFound)
+	}
+	if result, _ := add(from.Login, 10, 0); result != model.TransferSelf {
+		t.Errorf("to self: %s, want %s", result, model.TransferSelf)
+	}
+	if result, _ := add(to.Login, 1500, 0); result != model.TransferInsufficient {
+		t.Errorf("more than the balance: %s, want %s", result, model.TransferInsufficient)
+	}
+
+	yesterday := testTransfer(t, s, from, to, 200, 0)
+	testExec(t, s, "update transfers set date_ins = now() - interval '1 day' where id = $1", yesterday.ID)
+	testTransfer(t, s, from, to, 100, 250)
+	pending := testTransfer(t, s, from, to, 300, 250)
+	if pending.Status != model.TransferPending {
+		t.Errorf("transfer above confirmAbove: status %s, want %s", pending.Status, model.TransferPending)
+	}
+	if result, remaining := add(to.Login, 150, 500); result != model.TransferLimit || remaining != 100 {
+		t.Errorf("over the daily limit: %s, remaining %d; want %s, 100", result, remaining, model.TransferLimit)
+	}
+	if result, err := s.TransferClose(from.ID, &model.Transfer{ID: pending.ID}, model.TransferCancel); err != nil || result != model.TransferOK {
+		t.Fatalf("cancel: %s, %v", result, err)
+	}
+	if result, _ := add(to.Login, 150, 500); result != model.TransferOK {
+		t.Errorf("after cancel: %s, want %s", result, model.TransferOK)
+	}
+	if balance := testBalance(t, s, from); balance != 550 {
+		t.Errorf("sender balance %d, want 550", balance)
+	}
+	if balance := testBalance(t, s, to); balance != 450 {
+		t.Errorf("recipient balance %d, want 450", balance)
+	}
+}
+
+// TestTransferClose ожидающий перевод сразу списывается у отправителя и зачисляется получателю только после подтверждения;
+// отказ и отмена возвращают баллы, даже если отправитель уже потратил остаток. Закрыть перевод можно один раз
+// и только своей стороне
+func TestTransferClose(t *testing.T) {
+	s := testStorage(t)
+	for _, tc := range []struct {
+		name     string
+		action   string
+		sender   bool //действие отправителя, иначе получателя
+		status   string
+		from, to int64 //остатки сторон после закрытия
+	}{
+		{"accept", model.TransferAccept, false, model.TransferCompleted, 0, 800},
+		{"decline", model.TransferDecline, false, model.TransferDeclined, 800, 0},
+		{"cancel", model.TransferCancel, true, model.TransferCanceled, 800, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			from := testUser(t, s, "transfer-from")
+			to := testUser(t, s, "transfer-to")
+			testAccrual(t, s, from, 1000)
+			pending := testTransfer(t, s, from, to, 800, 500)
+			if balance := testBalance(t, s, to); balance != 0 {
+				t.Errorf("recipient balance before confirmation %d, want 0", balance)
+			}
+			// отправитель тратит все, что осталось после перевода: баллы перевода уже не его
+			if result, _, err := s.Withdraw(testWithdraw(from, 201), nil); err != nil || result != model.WithdrawInsufficient {
+				t.Errorf("spending the pending points: %s, %v; want %s", result, err, model.WithdrawInsufficient)
+			}
+			if result, _, err := s.Withdraw(testWithdraw(from, 200), nil); err != nil || result != model.WithdrawOK {
+				t.Fatalf("spending the rest: %s, %v", result, err)
+			}
+
+			actor, stranger := to, from
+			if tc.sender {
+				actor, stranger = from, to
+			}
+			if result, err := s.TransferClose(stranger.ID, &model.Transfer{ID: pending.ID}, tc.action); err != nil || result != model.TransferNotFound {
+				t.Errorf("%s by the other side: %s, %v; want %s", tc.action, result, err, model.TransferNotFound)
+			}
+			closed := &model.Transfer{ID: pending.ID}
+			if result, err := s.TransferClose(actor.ID, closed, tc.action); err != nil || result != model.TransferOK {
+				t.Fatalf("%s: %s, %v", tc.action, result, err)
+			}
+			if closed.Status != tc.status || *closed.Amount != 800 {
+				t.Errorf("closed transfer: status %s, amount %d; want %s, 800", closed.Status, *closed.Amount, tc.status)
+			}
+			if balance := testBalance(t, s, from); balance != tc.from {
+				t.Errorf("sender balance %d, want %d", balance, tc.from)
+			}
+			if balance := testBalance(t, s, to); balance != tc.to {
+				t.Errorf("recipient balance %d, want %d", balance, tc.to)
+			}
+
+			again := &model.Transfer{ID: pending.ID}
+			if result, err := s.TransferClose(actor.ID, again, tc.action); err != nil || result != model.TransferNotPending || again.Status != tc.status {
+				t.Errorf("second %s: %s, status %s, %v; want %s, %s", tc.action, result, again.Status, err, model.TransferNotPending, tc.status)
+			}
+			if balance := testBalance(t, s, from); balance != tc.from {
+				t.Errorf("sender balance after second %s %d, want %d", tc.action, balance, tc.from)
+			}
+			list, err := s.Transfers(to)
+			if err != nil || len(*list) != 1 || (*list)[0].Status != tc.status {
+				t.Errorf("recipient transfers %v, %v; want one %s", list, err, tc.status)
+			}
+		})
+	}
+}
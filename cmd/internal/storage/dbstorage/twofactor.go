@@ -0,0 +1,107 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// TOTPEnroll сохраняет новую привязку TOTP с hash кодов восстановления, false - 2FA уже включена
+func (pgs *PostgreSQLStorage) TOTPEnroll(userID string, secret string, codes [][]byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":     userID,
+		"secret": secret,
+		"codes":  codes,
+	}
+	var done sql.NullBool
+	if err := pgs.connection.QueryRowContext(ctx, totpEnrollQuery, args).Scan(&done); err != nil {
+		pgs.log.Err(err).Msgf("Error enrolling TOTP for user id [%v]", userID)
+		return false, fmt.Errorf("error enrolling TOTP for user id [%v], query '%s' error: %w", userID, totpEnrollQuery, err)
+	}
+	return done.Bool, nil
+}
+
+// TOTP возвращает привязку пользователя или nil
+func (pgs *PostgreSQLStorage) TOTP(userID string) (*model.TOTP, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id": userID,
+	}
+	var secret sql.NullString
+	var confirmed sql.NullBool
+	err := pgs.connection.QueryRowContext(ctx, totpGetQuery, args).Scan(&secret, &confirmed)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error getting TOTP for user id [%v]", userID)
+		return nil, fmt.Errorf("error getting TOTP for user id [%v], query '%s' error: %w", userID, totpGetQuery, err)
+	}
+	if !secret.Valid {
+		return nil, nil
+	}
+	return &model.TOTP{Secret: secret.String, Confirmed: confirmed.Bool}, nil
+}
+
+// TOTPStepUse отмечает шаг кода использованным, false - код этого или более позднего шага уже принимался
+func (pgs *PostgreSQLStorage) TOTPStepUse(userID string, step int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":   userID,
+		"step": step,
+	}
+	var done sql.NullBool
+	if err := pgs.connection.QueryRowContext(ctx, totpStepUseQuery, args).Scan(&done); err != nil {
+		pgs.log.Err(err).Msgf("Error using TOTP step for user id [%v]", userID)
+		return false, fmt.Errorf("error using TOTP step for user id [%v], query '%s' error: %w", userID, totpStepUseQuery, err)
+	}
+	return done.Bool, nil
+}
+
+func (pgs *PostgreSQLStorage) TOTPConfirm(userID string) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id": userID,
+	}
+	if _, err := pgs.connection.ExecContext(ctx, totpConfirmQuery, args); err != nil {
+		pgs.log.Err(err).Msgf("Error confirming TOTP for user id [%v]", userID)
+		return fmt.Errorf("error confirming TOTP for user id [%v], query '%s' error: %w", userID, totpConfirmQuery, err)
+	}
+	return nil
+}
+
+// TOTPDisable удаляет привязку вместе с кодами восстановления
+func (pgs *PostgreSQLStorage) TOTPDisable(userID string) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id": userID,
+	}
+	if _, err := pgs.connection.ExecContext(ctx, totpDisableQuery, args); err != nil {
+		pgs.log.Err(err).Msgf("Error disabling TOTP for user id [%v]", userID)
+		return fmt.Errorf("error disabling TOTP for user id [%v], query '%s' error: %w", userID, totpDisableQuery, err)
+	}
+	return nil
+}
+
+// RecoveryCodeUse гасит код восстановления, false - кода нет или он уже использован
+func (pgs *PostgreSQLStorage) RecoveryCodeUse(userID string, codeHash []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":   userID,
+		"code": codeHash,
+	}
+	var done sql.NullBool
+	if err := pgs.connection.QueryRowContext(ctx, recoveryUseQuery, args).Scan(&done); err != nil {
+		pgs.log.Err(err).Msgf("Error using recovery code for user id [%v]", userID)
+		return false, fmt.Errorf("error using recovery code for user id [%v], query '%s' error: %w", userID, recoveryUseQuery, err)
+	}
+	return done.Bool, nil
+}
@@ -0,0 +1,88 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+// VoucherBatchAdd сохраняет партию с хэшами кодов batch.Codes и заполняет ее id
+func (pgs *PostgreSQLStorage) VoucherBatchAdd(batch *model.VoucherBatch) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*30)
+	defer cancel()
+	hashes := make([][]byte, len(batch.Codes))
+	for i, code := range batch.Codes {
+		hashes[i] = utils.TokenHash(utils.VoucherNormalize(code))
+	}
+	var expires sql.NullTime
+	if batch.Expires != nil {
+		expires = sql.NullTime{Time: *batch.Expires, Valid: true}
+	}
+	args := pgx.NamedArgs{
+		"name":     batch.Name,
+		"amount":   batch.Amount,
+		"max_uses": batch.MaxUses,
+		"expires":  expires,
+		"hashes":   hashes,
+		"actor":    batch.ActorID,
+	}
+	err := pgs.connection.QueryRowContext(ctx, voucherBatchQuery, args).Scan(&batch.ID, &batch.Ins)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error adding voucher batch [%v]", batch.Name)
+		return fmt.Errorf("error adding voucher batch [%v], query '%s' error: %w", batch.Name, voucherBatchQuery, err)
+	}
+	return nil
+}
+
+// VoucherBatches все партии, новые первыми
+func (pgs *PostgreSQLStorage) VoucherBatches() (*[]model.VoucherBatch, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	rows, err := pgs.connection.QueryContext(ctx, voucherBatchesQuery)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get voucher batches, query: '%s' error: %v", voucherBatchesQuery, err)
+		return nil, fmt.Errorf("error trying to get voucher batches, query: '%s' error: %w", voucherBatchesQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.VoucherBatch)
+	for rows.Next() {
+		var expires sql.NullTime
+		b := model.VoucherBatch{}
+		err = rows.Scan(&b.ID, &b.Name, &b.Amount, &b.MaxUses, &expires, &b.Count, &b.Redeemed, &b.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		if expires.Valid {
+			b.Expires = &expires.Time
+		}
+		*list = append(*list, b)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// VoucherRedeem погашает код и заполняет зачисленную сумму, возвращает model.Voucher*
+func (pgs *PostgreSQLStorage) VoucherRedeem(red *model.Redemption) (string, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":   red.UserID,
+		"hash": utils.TokenHash(utils.VoucherNormalize(red.Code)),
+	}
+	var result string
+	err := pgs.connection.QueryRowContext(ctx, voucherRedeemQuery, args).Scan(&red.Amount, &result)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error redeeming voucher for user id [%v]", red.UserID)
+		return "", fmt.Errorf("error redeeming voucher for user id [%v], query '%s' error: %w", red.UserID, voucherRedeemQuery, err)
+	}
+	return result, nil
+}
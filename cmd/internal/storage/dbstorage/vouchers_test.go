@@ -0,0 +1,85 @@
+package dbstorage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+// testVoucher выпускает партию из одного кода на maxUses погашений по 100 баллов, возвращает код
+func testVoucher(t *testing.T, s *PostgreSQLStorage, maxUses int, expires *time.Time) string {
+	t.Helper()
+	code, err := utils.VoucherCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := &model.VoucherBatch{Name: "test", Amount: 100, Count: 1, MaxUses: maxUses, Expires: expires, Codes: []string{code}}
+	if err = s.VoucherBatchAdd(batch); err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// TestVoucherRedeem одноразовый код гасится одним пользователем, многоразовый - каждым не больше раза
+// до исчерпания лимита; просроченный и неизвестный коды не зачисляются
+func TestVoucherRedeem(t *testing.T) {
+	s := testStorage(t)
+	redeem := func(user *model.User, code string) (string, int64) {
+		t.Helper()
+		red := &model.Redemption{UserID: user.ID, Code: code}
+		result, err := s.VoucherRedeem(red)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, red.Amount
+	}
+	first := testUser(t, s, "voucher")
+	second := testUser(t, s, "voucher")
+	third := testUser(t, s, "voucher")
+
+	single := testVoucher(t, s, 1, nil)
+	// код вводится без учета регистра и разделителей
+	if result, amount := redeem(first, strings.ToLower(strings.ReplaceAll(single, "-", " "))); result != model.VoucherOK || amount != 100 {
+		t.Fatalf("single-use code: %s, amount %d", result, amount)
+	}
+	if result, _ := redeem(first, single); result != model.VoucherExhausted {
+		t.Errorf("single-use code again: %s, want %s", result, model.VoucherExhausted)
+	}
+	if result, _ := redeem(second, single); result != model.VoucherExhausted {
+		t.Errorf("single-use code by another user: %s, want %s", result, model.VoucherExhausted)
+	}
+
+	multi := testVoucher(t, s, 2, nil)
+	if result, _ := redeem(first, multi); result != model.VoucherOK {
+		t.Fatalf("multi-use code: %s", result)
+	}
+	if result, amount := redeem(first, multi); result != model.VoucherRedeemed || amount != 0 {
+		t.Errorf("multi-use code by the same user: %s, amount %d; want %s, 0", result, amount, model.VoucherRedeemed)
+	}
+	if result, _ := redeem(second, multi); result != model.VoucherOK {
+		t.Errorf("multi-use code by another user: %s, want %s", result, model.VoucherOK)
+	}
+	if result, _ := redeem(third, multi); result != model.VoucherExhausted {
+		t.Errorf("multi-use code over the limit: %s, want %s", result, model.VoucherExhausted)
+	}
+
+	expired := time.Now().Add(-48 * time.Hour)
+	if result, _ := redeem(third, testVoucher(t, s, 5, &expired)); result != model.VoucherExpired {
+		t.Errorf("expired code: %s, want %s", result, model.VoucherExpired)
+	}
+	if result, _ := redeem(third, "NO-SUCH-CODE"); result != model.VoucherNotFound {
+		t.Errorf("unknown code: %s, want %s", result, model.VoucherNotFound)
+	}
+
+	for _, tc := range []struct {
+		user    *model.User
+		balance int64
+	}{{first, 200}, {second, 100}, {third, 0}} {
+		if balance := testBalance(t, s, tc.user); balance != tc.balance {
+			t.Errorf("balance of %s %d, want %d", tc.user.Login, balance, tc.balance)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+// outboxAdd пишет событие в outbox в рамках транзакции бизнес-операции
+func (pgs *PostgreSQLStorage) outboxAdd(ctx context.Context, tx *sql.Tx, userID string, event string, data any) error {
+	payload, err := json.Marshal(&model.WebhookEvent{
+		Event:  event,
+		UserID: userID,
+		Data:   data,
+		Ins:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox event [%s]: %w", event, err)
+	}
+	args := pgx.NamedArgs{
+		"id":      userID,
+		"event":   event,
+		"payload": string(payload),
+	}
+	if _, err = tx.ExecContext(ctx, outboxAddQuery, args); err != nil {
+		pgs.log.Err(err).Msgf("Error adding outbox event [%s] for user id [%v]", event, userID)
+		return fmt.Errorf("error adding outbox event [%s] for user id [%v], query '%s' error: %w", event, userID, outboxAddQuery, err)
+	}
+	return nil
+}
+
+func (pgs *PostgreSQLStorage) WebhookAdd(hook *model.Webhook) (string, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":     hook.UserID,
+		"url":    hook.URL,
+		"secret": hook.Secret,
+		"events": hook.Events,
+	}
+	var id sql.NullString
+	err := pgs.connection.QueryRowContext(ctx, webhookAddQuery, args).Scan(&id)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error adding webhook for user id [%v]", hook.UserID)
+		return "", fmt.Errorf("error adding webhook for user id [%v], query '%s' error: %w", hook.UserID, webhookAddQuery, err)
+	}
+	return id.String, nil
+}
+
+func (pgs *PostgreSQLStorage) Webhooks(user *model.User) (*[]model.Webhook, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id": user.ID,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, webhooksAllQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get webhooks, query: '%s' error: %v", webhooksAllQuery, err)
+		return nil, fmt.Errorf("error trying to get webhooks, query: '%s' error: %w", webhooksAllQuery, err)
+	}
+	defer rows.Close()
+	hooks := new([]model.Webhook)
+	types := pgtype.NewMap()
+	for rows.Next() {
+		h := model.Webhook{}
+		err = rows.Scan(&h.ID, &h.URL, types.SQLScanner(&h.Events), &h.Active, &h.Ins)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*hooks = append(*hooks, h)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (pgs *PostgreSQLStorage) WebhookDelete(user *model.User, id string) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":   user.ID,
+		"hook": id,
+	}
+	var found sql.NullBool
+	err := pgs.connection.QueryRowContext(ctx, webhookDeleteQuery, args).Scan(&found)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error deleting webhook [%v] for user id [%v]", id, user.ID)
+		return false, fmt.Errorf("error deleting webhook [%v], query '%s' error: %w", id, webhookDeleteQuery, err)
+	}
+	return found.Bool, nil
+}
+
+func (pgs *PostgreSQLStorage) Deliveries(user *model.User, webhookID string) (*[]model.Delivery, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":   user.ID,
+		"hook": webhookID,
+	}
+	rows, err := pgs.connection.QueryContext(ctx, deliveriesAllQuery, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to get deliveries, query: '%s' error: %v", deliveriesAllQuery, err)
+		return nil, fmt.Errorf("error trying to get deliveries, query: '%s' error: %w", deliveriesAllQuery, err)
+	}
+	defer rows.Close()
+	list := new([]model.Delivery)
+	for rows.Next() {
+		var code sql.NullInt32
+		var lastErr sql.NullString
+		d := model.Delivery{}
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Status, &d.Attempts, &code, &lastErr, &d.NextTry, &d.Upd)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		if code.Valid {
+			c := int(code.Int32)
+			d.ResponseCode = &c
+		}
+		d.LastError = lastErr.String
+		*list = append(*list, d)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (pgs *PostgreSQLStorage) Redeliver(user *model.User, deliveryID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":       user.ID,
+		"delivery": deliveryID,
+	}
+	var found sql.NullBool
+	err := pgs.connection.QueryRowContext(ctx, deliveryRedeliver, args).Scan(&found)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error scheduling redelivery [%v] for user id [%v]", deliveryID, user.ID)
+		return false, fmt.Errorf("error scheduling redelivery [%v], query '%s' error: %w", deliveryID, deliveryRedeliver, err)
+	}
+	return found.Bool, nil
+}
+
+// DeliveriesClaim забирает доставки, время которых пришло, на время lease
+func (pgs *PostgreSQLStorage) DeliveriesClaim(limit int, lease time.Duration) (*[]model.Delivery, error) {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"limit": limit,
+		"lease": lease.Seconds(),
+	}
+	rows, err := pgs.connection.QueryContext(ctx, deliveriesClaim, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error trying to claim deliveries, query: '%s' error: %v", deliveriesClaim, err)
+		return nil, fmt.Errorf("error trying to claim deliveries, query: '%s' error: %w", deliveriesClaim, err)
+	}
+	defer rows.Close()
+	list := new([]model.Delivery)
+	for rows.Next() {
+		d := model.Delivery{}
+		err = rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &d.Payload, &d.Attempts)
+		if err != nil {
+			pgs.log.Err(err).Msgf("Error trying to Scan Rows error: %v", err)
+			return nil, fmt.Errorf("error trying to Scan Rows error: %w", err)
+		}
+		*list = append(*list, d)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (pgs *PostgreSQLStorage) DeliveryResult(d *model.Delivery) error {
+	ctx, cancel := context.WithTimeout(pgs.context, time.Second*5)
+	defer cancel()
+	args := pgx.NamedArgs{
+		"id":     d.ID,
+		"status": d.Status,
+		"code":   d.ResponseCode,
+		"error":  sql.NullString{String: d.LastError, Valid: d.LastError != ""},
+		"next":   d.NextTry,
+	}
+	_, err := pgs.connection.ExecContext(ctx, deliveryResult, args)
+	if err != nil {
+		pgs.log.Err(err).Msgf("Error saving delivery [%v] result", d.ID)
+		return fmt.Errorf("error saving delivery [%v] result, query '%s' error: %w", d.ID, deliveryResult, err)
+	}
+	return nil
+}
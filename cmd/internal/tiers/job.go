@@ -0,0 +1,76 @@
+// Package tiers еженощно пересчитывает уровни программы лояльности
+package tiers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+)
+
+type Job struct {
+	repo repository
+	cfg  config
+	lg   *logger.Logger
+	ctx  context.Context
+	at   time.Duration // смещение запуска от начала суток
+}
+
+type config interface {
+	GetTierWindow() time.Duration
+	GetTierRecalcAt() string
+}
+
+type repository interface {
+	TiersRecalc(window time.Duration) (*[]model.TierChange, error)
+}
+
+func NewJob(c context.Context, r repository, conf config, lg *logger.Logger) (*Job, error) {
+	at, err := time.Parse("15:04", conf.GetTierRecalcAt())
+	if err != nil {
+		return nil, fmt.Errorf("invalid tier recalculation time [%s], HH:MM expected: %w", conf.GetTierRecalcAt(), err)
+	}
+	return &Job{
+		repo: r,
+		cfg:  conf,
+		lg:   lg,
+		ctx:  c,
+		at:   time.Duration(at.Hour())*time.Hour + time.Duration(at.Minute())*time.Minute,
+	}, nil
+}
+
+func (j *Job) Run() {
+	go j.worker()
+}
+
+// next ближайший момент запуска после now
+func (j *Job) next(now time.Time) time.Time {
+	y, m, d := now.Date()
+	run := time.Date(y, m, d, 0, 0, 0, 0, now.Location()).Add(j.at)
+	if !run.After(now) {
+		run = time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Add(j.at)
+	}
+	return run
+}
+
+func (j *Job) worker() {
+	timer := time.NewTimer(time.Until(j.next(time.Now())))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			changes, err := j.repo.TiersRecalc(j.cfg.GetTierWindow())
+			if err != nil {
+				j.lg.Err(err).Msg("tier recalculation failed")
+			} else {
+				j.lg.Info().Msgf("tiers recalculated, %v users changed tier", len(*changes))
+			}
+			timer.Reset(time.Until(j.next(time.Now())))
+		case <-j.ctx.Done():
+			j.lg.Info().Msgf("tier recalculation job stopped")
+			return
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package twofactor второй фактор входа: TOTP (RFC 6238) и одноразовые коды восстановления
+package twofactor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+const recoveryCodes = 10
+
+type repository interface {
+	TOTPEnroll(userID string, secret string, codes [][]byte) (bool, error)
+	TOTP(userID string) (*model.TOTP, error)
+	TOTPStepUse(userID string, step int64) (bool, error)
+	TOTPConfirm(userID string) error
+	TOTPDisable(userID string) error
+	RecoveryCodeUse(userID string, codeHash []byte) (bool, error)
+}
+
+type config interface {
+	GetTOTPIssuer() string
+}
+
+type Service struct {
+	repo repository
+	cfg  config
+	lg   *logger.Logger
+}
+
+func NewService(r repository, c config, lg *logger.Logger) *Service {
+	return &Service{repo: r, cfg: c, lg: lg}
+}
+
+// Enroll создает неподтвержденную привязку TOTP, false - 2FA уже включена
+func (s *Service) Enroll(user *model.User) (*model.TwoFactor, bool, error) {
+	secret, err := utils.TOTPSecret()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	res := &model.TwoFactor{
+		Secret: secret,
+		URI:    utils.TOTPURI(s.cfg.GetTOTPIssuer(), user.Login, secret),
+	}
+	hashes := make([][]byte, 0, recoveryCodes)
+	for i := 0; i < recoveryCodes; i++ {
+		code, err := utils.RandomToken(5)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		res.RecoveryCodes = append(res.RecoveryCodes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, utils.TokenHash(code))
+	}
+	done, err := s.repo.TOTPEnroll(user.ID, secret, hashes)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to enroll TOTP for user id [%s]: %w", user.ID, err)
+	}
+	return res, done, nil
+}
+
+// Confirm включает 2FA, если code верен для неподтвержденной привязки
+func (s *Service) Confirm(userID string, code string) (bool, error) {
+	totp, err := s.repo.TOTP(userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get TOTP for user id [%s]: %w", userID, err)
+	}
+	if totp == nil || totp.Confirmed {
+		return false, nil
+	}
+	ok, err := s.useCode(userID, totp.Secret, code)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err = s.repo.TOTPConfirm(userID); err != nil {
+		return false, fmt.Errorf("failed to confirm TOTP for user id [%s]: %w", userID, err)
+	}
+	return true, nil
+}
+
+// Disable отключает 2FA, если code верен
+func (s *Service) Disable(userID string, code string) (bool, error) {
+	ok, err := s.Verify(userID, code)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err = s.repo.TOTPDisable(userID); err != nil {
+		return false, fmt.Errorf("failed to disable TOTP for user id [%s]: %w", userID, err)
+	}
+	return true, nil
+}
+
+// Enabled у пользователя включена 2FA
+func (s *Service) Enabled(userID string) (bool, error) {
+	totp, err := s.repo.TOTP(userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get TOTP for user id [%s]: %w", userID, err)
+	}
+	return totp != nil && totp.Confirmed, nil
+}
+
+// Verify проверяет код TOTP или код восстановления; каждый код принимается один раз
+func (s *Service) Verify(userID string, code string) (bool, error) {
+	totp, err := s.repo.TOTP(userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get TOTP for user id [%s]: %w", userID, err)
+	}
+	if totp == nil || !totp.Confirmed {
+		return false, nil
+	}
+	ok, err := s.useCode(userID, totp.Secret, code)
+	if err != nil || ok {
+		return ok, err
+	}
+	recovery := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if recovery == "" {
+		return false, nil
+	}
+	ok, err = s.repo.RecoveryCodeUse(userID, utils.TokenHash(recovery))
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code for user id [%s]: %w", userID, err)
+	}
+	if ok {
+		s.lg.Warn().Msgf("recovery code used by user id [%s]", userID)
+	}
+	return ok, nil
+}
+
+func (s *Service) useCode(userID string, secret string, code string) (bool, error) {
+	step := utils.TOTPMatch(secret, code, time.Now())
+	if step == 0 {
+		return false, nil
+	}
+	ok, err := s.repo.TOTPStepUse(userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use TOTP code for user id [%s]: %w", userID, err)
+	}
+	return ok, nil
+}
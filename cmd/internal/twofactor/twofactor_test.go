@@ -0,0 +1,79 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+	"github.com/rs/zerolog"
+)
+
+// testRepo хранит последний принятый шаг, как totp_step_use
+type testRepo struct {
+	totp     model.TOTP
+	lastStep int64
+}
+
+func (r *testRepo) TOTPEnroll(userID string, secret string, codes [][]byte) (bool, error) {
+	return true, nil
+}
+
+func (r *testRepo) TOTP(userID string) (*model.TOTP, error) {
+	return &r.totp, nil
+}
+
+func (r *testRepo) TOTPStepUse(userID string, step int64) (bool, error) {
+	if step <= r.lastStep {
+		return false, nil
+	}
+	r.lastStep = step
+	return true, nil
+}
+
+func (r *testRepo) TOTPConfirm(userID string) error {
+	return nil
+}
+
+func (r *testRepo) TOTPDisable(userID string) error {
+	return nil
+}
+
+func (r *testRepo) RecoveryCodeUse(userID string, codeHash []byte) (bool, error) {
+	return false, nil
+}
+
+type testConfig struct{}
+
+func (testConfig) IsDebug() bool {
+	return false
+}
+
+func (testConfig) GetTOTPIssuer() string {
+	return "Gophermart"
+}
+
+// TestVerifyReplay код принимается один раз, а код более раннего шага после более позднего - не принимается
+func TestVerifyReplay(t *testing.T) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	secret, err := utils.TOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &testRepo{totp: model.TOTP{Secret: secret, Confirmed: true}}
+	s := NewService(repo, testConfig{}, logger.New(testConfig{}))
+	step := utils.TOTPStep(time.Now())
+	current, _ := utils.TOTPCode(secret, step)
+	previous, _ := utils.TOTPCode(secret, step-1)
+
+	if ok, err := s.Verify("42", current); err != nil || !ok {
+		t.Fatalf("valid code rejected: %v", err)
+	}
+	if ok, _ := s.Verify("42", current); ok {
+		t.Error("replayed code accepted")
+	}
+	if ok, _ := s.Verify("42", previous); ok {
+		t.Error("code of an earlier step accepted after a later one")
+	}
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache ограниченный по числу записей кэш со временем жизни записи.
+// При переполнении вытесняется давно не использованная запись; size <= 0 отключает кэш
+type Cache[K comparable, V any] struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[K]*list.Element
+	order *list.List // в начале - недавно использованные
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func NewCache[K comparable, V any](size int, ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[K]*list.Element),
+		order: list.New(),
+	}
+}
+
+// Get возвращает действующее значение по ключу
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	var zero V
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*cacheEntry[K, V])
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set сохраняет значение на время ttl
+func (c *Cache[K, V]) Set(key K, value V) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*cacheEntry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry[K, V]{key: key, value: value, expires: expires})
+}
+
+// Delete удаляет значение по ключу
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len число записей, включая еще не вытесненные просроченные
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache[K, V]) remove(el *list.Element) {
+	delete(c.items, el.Value.(*cacheEntry[K, V]).key)
+	c.order.Remove(el)
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCacheTTL запись не отдается после ttl, повторный Set продлевает ее
+func TestCacheTTL(t *testing.T) {
+	c := NewCache[string, int](10, 50*time.Millisecond)
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("fresh entry: %d, %v", v, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	c.Set("a", 2)
+	time.Sleep(30 * time.Millisecond)
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Errorf("renewed entry: %d, %v; want 2, true", v, ok)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expired entry returned")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expired entry kept, len %d", c.Len())
+	}
+}
+
+// TestCacheEviction при переполнении вытесняется давно не использованная запись
+func TestCacheEviction(t *testing.T) {
+	c := NewCache[string, int](2, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %s evicted", key)
+		}
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 1 {
+		t.Errorf("deleted entry found or len %d, want 1", c.Len())
+	}
+}
+
+// TestCacheDisabled кэш нулевого размера ничего не хранит
+func TestCacheDisabled(t *testing.T) {
+	c := NewCache[string, int](0, time.Minute)
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Errorf("disabled cache stored an entry, len %d", c.Len())
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"hash"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Credentials кэш проверенных пар логин/пароль, чтобы не считать хэш пароля на каждый запрос.
+// Хранится HMAC-SHA256 пароля и версии учетных данных на случайном ключе процесса, сам пароль не хранится.
+// Версия хранится в базе и растет при смене пароля, поэтому сброс виден всем экземплярам сервиса
+type Credentials struct {
+	cache *Cache[string, []byte]
+	pool  sync.Pool
+}
+
+func NewCredentials(size int, ttl time.Duration) *Credentials {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return &Credentials{
+		cache: NewCache[string, []byte](size, ttl),
+		pool:  sync.Pool{New: func() any { return hmac.New(sha256.New, key) }},
+	}
+}
+
+func (c *Credentials) digest(login string, password string, version int64) []byte {
+	mac := c.pool.Get().(hash.Hash)
+	defer c.pool.Put(mac)
+	mac.Reset()
+	mac.Write([]byte(login))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	mac.Write([]byte{0})
+	mac.Write(strconv.AppendInt(nil, version, 10))
+	return mac.Sum(nil)
+}
+
+// Match сообщает, что пара уже проверялась для этой версии учетных данных и не устарела
+func (c *Credentials) Match(login string, password string, version int64) bool {
+	digest, ok := c.cache.Get(login)
+	return ok && hmac.Equal(digest, c.digest(login, password, version))
+}
+
+// Put запоминает успешно проверенную пару
+func (c *Credentials) Put(login string, password string, version int64) {
+	c.cache.Set(login, c.digest(login, password, version))
+}
+
+// Forget удаляет запись логина на этом экземпляре, не дожидаясь ttl
+func (c *Credentials) Forget(login string) {
+	c.cache.Delete(login)
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// BenchmarkCheckPasswordHash стоимость проверки пароля без кэша
+func BenchmarkCheckPasswordHash(b *testing.B) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !CheckPasswordHash("secret", hash) {
+			b.Fatal("password mismatch")
+		}
+	}
+}
+
+// BenchmarkCredentialsMatch стоимость проверки пароля по кэшу
+func BenchmarkCredentialsMatch(b *testing.B) {
+	creds := NewCredentials(10000, time.Minute)
+	for i := 0; i < 10000; i++ {
+		creds.Put("user"+strconv.Itoa(i), "secret", int64(i))
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if !creds.Match("user42", "secret", 42) {
+				b.Fatal("cache miss")
+			}
+		}
+	})
+}
+
+func TestCredentials(t *testing.T) {
+	creds := NewCredentials(10, time.Minute)
+	creds.Put("user", "secret", 1)
+	if !creds.Match("user", "secret", 1) {
+		t.Error("cached pair does not match")
+	}
+	if creds.Match("user", "wrong", 1) {
+		t.Error("wrong password matches")
+	}
+	if creds.Match("user", "secret", 2) {
+		t.Error("pair matches after the credentials version changed")
+	}
+	if creds.Match("other", "secret", 1) {
+		t.Error("pair matches for another login")
+	}
+	creds.Forget("user")
+	if creds.Match("user", "secret", 1) {
+		t.Error("pair matches after Forget")
+	}
+}
@@ -1,14 +1,135 @@
 package utils
-import(
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+
+	argonSaltLen = 16
+	argonKeyLen  = 32
+)
+
+// HashPolicy алгоритм и параметры, с которыми хэшируются новые пароли.
+// Хэш хранит алгоритм и параметры в себе (bcrypt $2a$cost$..., argon2id в формате PHC),
+// поэтому проверка не зависит от текущей политики
+type HashPolicy struct {
+	Algorithm    string
+	BcryptCost   int
+	ArgonMemory  uint32 // KiB
+	ArgonTime    uint32
+	ArgonThreads uint8
+}
+
+var policy = HashPolicy{Algorithm: Bcrypt, BcryptCost: 14}
+
+// SetHashPolicy задает политику хэширования; вызывается один раз при старте
+func SetHashPolicy(p HashPolicy) error {
+	switch p.Algorithm {
+	case Bcrypt:
+		if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("bcrypt cost %d is out of range [%d, %d]", p.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		if err := argonCheck(p); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown password hash algorithm '%s'", p.Algorithm)
+	}
+	policy = p
+	return nil
+}
+
 func HashPassword(password string) (string, error) {
-    bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-    return string(bytes), err
+	if policy.Algorithm == Argon2id {
+		return argonHash(password, policy)
+	}
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), policy.BcryptCost)
+	return string(bytes), err
 }
 
 func CheckPasswordHash(password, hash string) bool {
-    err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-    return err == nil
-}
\ No newline at end of file
+	if strings.HasPrefix(hash, "$"+Argon2id+"$") {
+		p, salt, key, err := argonParse(hash)
+		if err != nil {
+			return false
+		}
+		other := argon2.IDKey([]byte(password), salt, p.ArgonTime, p.ArgonMemory, p.ArgonThreads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// NeedsRehash хэш получен не по текущей политике и должен быть пересчитан при следующем входе
+func NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$"+Argon2id+"$") {
+		p, _, _, err := argonParse(hash)
+		return err != nil || policy.Algorithm != Argon2id ||
+			p.ArgonMemory != policy.ArgonMemory || p.ArgonTime != policy.ArgonTime || p.ArgonThreads != policy.ArgonThreads
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || policy.Algorithm != Bcrypt || cost != policy.BcryptCost
+}
+
+// argonCheck параметры, с которыми argon2.IDKey работает: без них он паникует или вырождается
+func argonCheck(p HashPolicy) error {
+	if p.ArgonMemory < 8*uint32(p.ArgonThreads) || p.ArgonTime < 1 || p.ArgonThreads < 1 {
+		return fmt.Errorf("invalid argon2id parameters m=%d, t=%d, p=%d", p.ArgonMemory, p.ArgonTime, p.ArgonThreads)
+	}
+	return nil
+}
+
+// argonHash возвращает хэш в формате $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func argonHash(password string, p HashPolicy) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.ArgonTime, p.ArgonMemory, p.ArgonThreads, argonKeyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", Argon2id, argon2.Version,
+		p.ArgonMemory, p.ArgonTime, p.ArgonThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func argonParse(hash string) (p HashPolicy, salt []byte, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, err
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+	p.Algorithm = Argon2id
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.ArgonMemory, &p.ArgonTime, &p.ArgonThreads); err != nil {
+		return p, nil, nil, err
+	}
+	if err = argonCheck(p); err != nil {
+		return p, nil, nil, err
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, err
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, nil, nil, err
+	}
+	if len(salt) == 0 || len(key) == 0 {
+		return p, nil, nil, errors.New("argon2id hash has an empty salt or key")
+	}
+	return p, salt, key, nil
+}
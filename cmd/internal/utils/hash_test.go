@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	testBcrypt = HashPolicy{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+	testArgon  = HashPolicy{Algorithm: Argon2id, ArgonMemory: 64, ArgonTime: 1, ArgonThreads: 1}
+)
+
+// withPolicy выполняет f с политикой хэширования p и возвращает прежнюю
+func withPolicy(t *testing.T, p HashPolicy, f func()) {
+	t.Helper()
+	saved := policy
+	defer func() { policy = saved }()
+	if err := SetHashPolicy(p); err != nil {
+		t.Fatal(err)
+	}
+	f()
+}
+
+func TestHashRoundTrip(t *testing.T) {
+	for _, p := range []HashPolicy{testBcrypt, testArgon} {
+		t.Run(p.Algorithm, func(t *testing.T) {
+			withPolicy(t, p, func() {
+				hash, err := HashPassword("correct horse")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if p.Algorithm == Argon2id && !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+					t.Errorf("unexpected argon2id hash format %q", hash)
+				}
+				if !CheckPasswordHash("correct horse", hash) {
+					t.Error("password does not match its hash")
+				}
+				if CheckPasswordHash("wrong horse", hash) {
+					t.Error("wrong password matches")
+				}
+				if NeedsRehash(hash) {
+					t.Error("hash made by the current policy needs rehash")
+				}
+			})
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	var bcryptHash, argonHash string
+	withPolicy(t, testBcrypt, func() {
+		bcryptHash, _ = HashPassword("secret")
+	})
+	withPolicy(t, testArgon, func() {
+		argonHash, _ = HashPassword("secret")
+	})
+	stronger := testArgon
+	stronger.ArgonTime = 2
+	for _, tc := range []struct {
+		name   string
+		policy HashPolicy
+		hash   string
+		want   bool
+	}{
+		{"bcrypt to argon2id", testArgon, bcryptHash, true},
+		{"argon2id to bcrypt", testBcrypt, argonHash, true},
+		{"bcrypt cost raised", HashPolicy{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost + 1}, bcryptHash, true},
+		{"argon2id time raised", stronger, argonHash, true},
+		{"bcrypt unchanged", testBcrypt, bcryptHash, false},
+		{"argon2id unchanged", testArgon, argonHash, false},
+		{"malformed", testBcrypt, "not a hash", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withPolicy(t, tc.policy, func() {
+				if got := NeedsRehash(tc.hash); got != tc.want {
+					t.Errorf("NeedsRehash = %v, want %v", got, tc.want)
+				}
+				// пересчет не мешает проверить старый хэш
+				if !CheckPasswordHash("secret", tc.hash) && tc.hash != "not a hash" {
+					t.Error("old hash no longer verifies")
+				}
+			})
+		})
+	}
+}
+
+// TestCheckPasswordHashMalformed испорченный хэш argon2id отклоняется, а не роняет argon2.IDKey
+func TestCheckPasswordHashMalformed(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	for _, hash := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$$" + key,
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt + "$",
+		"$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key,
+		"$argon2id$v=19$m=0,t=1,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=1,p=300$" + salt + "$" + key,
+		"$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key,
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt,
+		"$argon2id$v=19$m=64,t=1,p=1$!!$" + key,
+	} {
+		if CheckPasswordHash("secret", hash) {
+			t.Errorf("malformed hash %q accepted", hash)
+		}
+		if !NeedsRehash(hash) {
+			t.Errorf("malformed hash %q does not need rehash", hash)
+		}
+	}
+}
+
+func TestSetHashPolicy(t *testing.T) {
+	saved := policy
+	defer func() { policy = saved }()
+	for _, p := range []HashPolicy{
+		{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost - 1},
+		{Algorithm: Bcrypt, BcryptCost: bcrypt.MaxCost + 1},
+		{Algorithm: Argon2id, ArgonMemory: 64, ArgonTime: 0, ArgonThreads: 1},
+		{Algorithm: Argon2id, ArgonMemory: 64, ArgonTime: 1, ArgonThreads: 0},
+		{Algorithm: Argon2id, ArgonMemory: 7, ArgonTime: 1, ArgonThreads: 1},
+		{Algorithm: "scrypt"},
+	} {
+		if err := SetHashPolicy(p); err == nil {
+			t.Errorf("policy %+v accepted", p)
+		}
+	}
+}
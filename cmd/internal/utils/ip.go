@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned when an outgoing connection targets a non-public address
+var ErrPrivateAddress = errors.New("destination address is not public")
+
+// blockedNets are ranges outgoing webhook requests must never reach besides loopback,
+// private, link-local (cloud metadata 169.254.169.254) and multicast addresses checked by net.IP methods
+var blockedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",      // "this" network
+		"100.64.0.0/10",  // carrier-grade NAT
+		"192.0.0.0/24",   // IETF protocol assignments
+		"198.18.0.0/15",  // benchmarking
+		"240.0.0.0/4",    // reserved, broadcast
+		"64:ff9b::/96",   // NAT64 may map to internal IPv4
+		"64:ff9b:1::/48", // local-use NAT64
+		"2001:db8::/32",  // documentation
+	} {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// ClientIP returns the client address without port, RealIP middleware already applied
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// PublicIP reports whether ip is a globally routable unicast address
+func PublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// PublicOnlyControl is a net.Dialer Control hook refusing connections to non-public addresses.
+// It runs after name resolution, so DNS names pointing to internal hosts are refused too
+func PublicOnlyControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicIP(net.ParseIP(host)) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestPublicIP(t *testing.T) {
+	for _, tc := range []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"93.184.216.34", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"127.8.8.8", false},
+		{"::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"fd00:ec2::254", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+	} {
+		if got := PublicIP(net.ParseIP(tc.ip)); got != tc.public {
+			t.Errorf("PublicIP(%s) = %v, want %v", tc.ip, got, tc.public)
+		}
+	}
+}
+
+func TestPublicOnlyControl(t *testing.T) {
+	if err := PublicOnlyControl("tcp4", "169.254.169.254:80", nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("metadata address: got %v, want ErrPrivateAddress", err)
+	}
+	if err := PublicOnlyControl("tcp6", "[::1]:443", nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("loopback: got %v, want ErrPrivateAddress", err)
+	}
+	if err := PublicOnlyControl("tcp4", "8.8.8.8:443", nil); err != nil {
+		t.Errorf("public address: got %v", err)
+	}
+}
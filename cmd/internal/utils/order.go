@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// orderBase is the range of the 15 random digits preceding the check digit
+var orderBase = big.NewInt(900_000_000_000_000)
+
+// OrderNumber returns a random 16-digit number passing the Luhn check
+func OrderNumber() (int64, error) {
+	n, err := rand.Int(rand.Reader, orderBase)
+	if err != nil {
+		return 0, err
+	}
+	num := n.Int64() + 100_000_000_000_000
+	return num*10 + CalculateLuhn(num), nil
+}
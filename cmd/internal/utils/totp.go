@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30 // секунд
+	totpDigits = 6
+	totpSkew   = 1 // допустимое расхождение часов, шагов в каждую сторону
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPSecret новый секрет TOTP в base32
+func TOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPURI ссылка otpauth:// для приложений-аутентификаторов
+func TOTPURI(issuer string, login string, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(login)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// TOTPCode код RFC 6238 для шага step
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000), nil
+}
+
+// TOTPStep номер шага для момента t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / totpPeriod
+}
+
+// TOTPMatch ищет шаг около момента t, для которого code верен; 0 - код не подошел.
+// Шаг нужен вызывающему, чтобы не принимать один и тот же код дважды
+func TOTPMatch(secret string, code string, t time.Time) int64 {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0
+	}
+	now := TOTPStep(t)
+	for step := now - totpSkew; step <= now+totpSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step
+		}
+	}
+	return 0
+}
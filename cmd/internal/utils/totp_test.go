@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret ключ "12345678901234567890" из RFC 6238, Appendix B, в base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc6238Vectors тестовые значения SHA1 из RFC 6238, Appendix B; в RFC коды из 8 цифр,
+// у нас 6 - младшие цифры того же числа
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},          // 94287082
+	{1111111109, "081804"},  // 07081804
+	{1111111111, "050471"},  // 14050471
+	{1234567890, "005924"},  // 89005924
+	{2000000000, "279037"},  // 69279037
+	{20000000000, "353130"}, // 65353130
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != v.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", v.unix, code, v.code)
+		}
+		if lower, _ := TOTPCode(strings.ToLower(rfc6238Secret), TOTPStep(time.Unix(v.unix, 0))); lower != v.code {
+			t.Errorf("TOTPCode with lower case secret at %d = %s, want %s", v.unix, lower, v.code)
+		}
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestTOTPMatch(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		at := time.Unix(v.unix, 0)
+		step := TOTPStep(at)
+		for _, tc := range []struct {
+			name string
+			at   time.Time
+			code string
+			want int64
+		}{
+			{"same step", at, v.code, step},
+			{"surrounding spaces", at, " " + v.code + " ", step},
+			{"clock behind by one step", at.Add(-totpPeriod * time.Second), v.code, step},
+			{"clock ahead by one step", at.Add(totpPeriod * time.Second), v.code, step},
+			{"clock behind by two steps", at.Add(-2 * totpPeriod * time.Second), v.code, 0},
+			{"clock ahead by two steps", at.Add(2 * totpPeriod * time.Second), v.code, 0},
+			{"wrong code", at, "000000", 0},
+			{"wrong length", at, v.code[1:], 0},
+		} {
+			if tc.at.Unix() < 0 { // до начала эпохи шаги не определены
+				continue
+			}
+			if got := TOTPMatch(rfc6238Secret, tc.code, tc.at); got != tc.want {
+				t.Errorf("%d %s: TOTPMatch = %d, want %d", v.unix, tc.name, got, tc.want)
+			}
+		}
+	}
+}
+
+// TestTOTPMatchReplay повтор кода в пределах окна дает тот же шаг: по нему вызывающий отклоняет повтор
+func TestTOTPMatchReplay(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	code, err := TOTPCode(rfc6238Secret, TOTPStep(at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := TOTPMatch(rfc6238Secret, code, at)
+	again := TOTPMatch(rfc6238Secret, code, at.Add(totpPeriod*time.Second))
+	if first == 0 || first != again {
+		t.Errorf("replayed code matched step %d, first use %d", again, first)
+	}
+}
@@ -0,0 +1,10 @@
+package utils
+
+import "regexp"
+
+var uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidUUID checks s is a canonical textual uuid
+func ValidUUID(s string) bool {
+	return uuidRe.MatchString(s)
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// voucherAlphabet has 32 symbols without look-alike 0/O and 1/I
+const voucherAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// VoucherCode returns a random 80-bit code formatted as XXXX-XXXX-XXXX-XXXX
+func VoucherCode() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, v := range b {
+		if i > 0 && i%4 == 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(voucherAlphabet[v&31])
+	}
+	return sb.String(), nil
+}
+
+// VoucherNormalize drops separators and case so a code typed by hand matches its hash
+func VoucherNormalize(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(code)))
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+)
+
+const (
+	StatusPending   = "PENDING"
+	StatusDelivered = "DELIVERED"
+	StatusFailed    = "FAILED"
+
+	batchSize  = 50
+	maxBackoff = time.Hour * 6
+)
+
+type Dispatcher struct {
+	repo   repository
+	cfg    config
+	lg     *logger.Logger
+	ctx    context.Context
+	client *http.Client
+}
+
+type config interface {
+	GetWebhookInterval() time.Duration
+	GetWebhookAttempts() int
+	GetWebhookBackoff() time.Duration
+	GetWebhookTimeout() time.Duration
+}
+
+type repository interface {
+	DeliveriesClaim(limit int, lease time.Duration) (*[]model.Delivery, error)
+	DeliveryResult(d *model.Delivery) error
+}
+
+func NewDispatcher(c context.Context, r repository, conf config, lg *logger.Logger) *Dispatcher {
+	return &Dispatcher{
+		repo:   r,
+		cfg:    conf,
+		lg:     lg,
+		ctx:    c,
+		client: newClient(conf.GetWebhookTimeout(), utils.PublicOnlyControl),
+	}
+}
+
+// newClient клиент доставки: адрес получателя проверяет control после разрешения имени,
+// переменные окружения прокси игнорируются, перенаправления не выполняются - ответ 3xx считается неудачей
+func newClient(timeout time.Duration, control func(network string, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        batchSize,
+			IdleConnTimeout:     time.Minute,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (d *Dispatcher) Run() {
+	go d.worker()
+}
+
+func (d *Dispatcher) worker() {
+	ticker := time.NewTicker(d.cfg.GetWebhookInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := d.dispatch(); err != nil {
+				d.lg.Err(err).Msg("webhook dispatcher failed to process deliveries")
+			}
+		case <-d.ctx.Done():
+			d.lg.Info().Msgf("webhook dispatcher stopped")
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch() error {
+	// lease покрывает время всех запросов пачки, чтобы их не забрал другой экземпляр
+	lease := d.cfg.GetWebhookTimeout()*batchSize + d.cfg.GetWebhookInterval()
+	list, err := d.repo.DeliveriesClaim(batchSize, lease)
+	if err != nil {
+		return fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+	for i := range *list {
+		dl := &(*list)[i]
+		code, err := d.send(dl)
+		d.result(dl, code, err)
+		// результат остальных доставок пачки сохраняем, эта вернется в работу по истечении lease
+		if err = d.repo.DeliveryResult(dl); err != nil {
+			d.lg.Err(err).Msgf("webhook delivery [%v] failed to save result", dl.ID)
+			continue
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) send(dl *model.Delivery) (int, error) {
+	body := []byte(dl.Payload)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	r, err := http.NewRequestWithContext(d.ctx, http.MethodPost, dl.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Gophermart-Event", dl.Event)
+	r.Header.Set("X-Gophermart-Delivery", strconv.FormatInt(dl.ID, 10))
+	r.Header.Set("X-Gophermart-Timestamp", ts)
+	// подписываем timestamp вместе с телом, чтобы получатель мог отсечь повторы
+	r.Header.Set("X-Gophermart-Signature", "sha256="+utils.Sign(dl.Secret, append([]byte(ts+"."), body...)))
+
+	response, err := d.client.Do(r)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("receiver responded with status [%v]", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// result выставляет статус доставки и время следующей попытки с экспоненциальной задержкой
+func (d *Dispatcher) result(dl *model.Delivery, code int, err error) {
+	dl.ResponseCode = nil
+	if code != 0 {
+		dl.ResponseCode = &code
+	}
+	attempt := dl.Attempts + 1
+	if err == nil {
+		dl.Status = StatusDelivered
+		dl.LastError = ""
+		dl.NextTry = time.Now()
+		d.lg.Debug().Msgf("webhook delivery [%v] delivered on attempt %v", dl.ID, attempt)
+		return
+	}
+	dl.LastError = err.Error()
+	if attempt >= d.cfg.GetWebhookAttempts() {
+		dl.Status = StatusFailed
+		dl.NextTry = time.Now()
+		d.lg.Warn().Msgf("webhook delivery [%v] failed after %v attempts: %v", dl.ID, attempt, err)
+		return
+	}
+	dl.Status = StatusPending
+	dl.NextTry = time.Now().Add(backoff(d.cfg.GetWebhookBackoff(), attempt))
+	d.lg.Debug().Msgf("webhook delivery [%v] attempt %v failed: %v, next try at %v", dl.ID, attempt, err, dl.NextTry)
+}
+
+// backoff возвращает задержку перед попыткой attempt+1
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
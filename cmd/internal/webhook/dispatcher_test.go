@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	conf "github.com/rebus2015/gophermart/cmd/internal/config"
+	"github.com/rebus2015/gophermart/cmd/internal/logger"
+	"github.com/rebus2015/gophermart/cmd/internal/model"
+	"github.com/rebus2015/gophermart/cmd/internal/utils"
+	"github.com/rs/zerolog"
+)
+
+func TestSendRefusesPrivateAddress(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer srv.Close()
+
+	d := &Dispatcher{ctx: context.Background(), client: newClient(time.Second, utils.PublicOnlyControl)}
+	_, err := d.send(&model.Delivery{ID: 1, URL: srv.URL, Payload: "{}", Event: model.EventOrderCreated})
+	if !errors.Is(err, utils.ErrPrivateAddress) {
+		t.Fatalf("send to %s: got %v, want ErrPrivateAddress", srv.URL, err)
+	}
+	if hits != 0 {
+		t.Fatalf("receiver got %d requests", hits)
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	target := 0
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target++
+	}))
+	defer internal.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	// проверка адреса отключена, чтобы достучаться до тестового сервера
+	d := &Dispatcher{ctx: context.Background(), client: newClient(time.Second, nil)}
+	code, err := d.send(&model.Delivery{ID: 1, URL: srv.URL, Payload: "{}", Event: model.EventOrderCreated})
+	if err == nil || code != http.StatusTemporaryRedirect {
+		t.Fatalf("got code %d, error %v; want failed delivery with 307", code, err)
+	}
+	if target != 0 {
+		t.Fatalf("redirect was followed")
+	}
+}
+
+// resultRepo отдает пачку доставок и не может сохранить результат доставки failID
+type resultRepo struct {
+	claimed []model.Delivery
+	failID  int64
+	saved   []int64
+}
+
+func (r *resultRepo) DeliveriesClaim(limit int, lease time.Duration) (*[]model.Delivery, error) {
+	return &r.claimed, nil
+}
+
+func (r *resultRepo) DeliveryResult(dl *model.Delivery) error {
+	if dl.ID == r.failID {
+		return errors.New("connection reset")
+	}
+	r.saved = append(r.saved, dl.ID)
+	return nil
+}
+
+// TestDispatchSavesRestOfBatch ошибка сохранения результата одной доставки не мешает остальным доставкам пачки
+func TestDispatchSavesRestOfBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	cfg := &conf.Config{WebhookInterval: time.Second, WebhookAttempts: 3, WebhookBackoff: time.Second, WebhookTimeout: time.Second}
+	lg := logger.New(cfg)
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	repo := &resultRepo{failID: 1}
+	for id := int64(1); id <= 3; id++ {
+		repo.claimed = append(repo.claimed, model.Delivery{ID: id, URL: srv.URL, Payload: "{}", Event: model.EventOrderCreated})
+	}
+	d := &Dispatcher{repo: repo, cfg: cfg, lg: lg, ctx: context.Background(), client: newClient(time.Second, nil)}
+	if err := d.dispatch(); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.saved) != 2 || repo.saved[0] != 2 || repo.saved[1] != 3 {
+		t.Errorf("saved deliveries %v, want [2 3]", repo.saved)
+	}
+	for _, dl := range repo.claimed {
+		if dl.Status != StatusDelivered {
+			t.Errorf("delivery %d status %s, want %s", dl.ID, dl.Status, StatusDelivered)
+		}
+	}
+}
@@ -2,7 +2,10 @@ module github.com/rebus2015/gophermart
 
 go 1.20
 
-require github.com/caarlos0/env v3.5.0+incompatible
+require (
+	github.com/caarlos0/env v3.5.0+incompatible
+	golang.org/x/crypto v0.10.0
+)
 
 require (
 	github.com/gammazero/deque v0.2.0 // indirect
@@ -11,7 +14,6 @@ require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
 	github.com/rs/xid v1.4.0 // indirect
-	golang.org/x/crypto v0.10.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
 	golang.org/x/text v0.11.0 // indirect
 )